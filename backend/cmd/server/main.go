@@ -3,13 +3,21 @@ package main
 import (
 	"log"
 
+	"wms-backend/internal/auth"
 	"wms-backend/internal/config"
 	"wms-backend/internal/database"
 	"wms-backend/internal/handlers"
+	"wms-backend/internal/storage"
 )
 
 func main() {
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Refusing to start: ", err)
+	}
+	if cfg.InsecureDefaults() {
+		log.Println("WARNING: signing tokens with the default JWT_SECRET; never run like this outside development")
+	}
 
 	log.Println("Go Backend Server starting on 0.0.0.0:" + cfg.Port)
 
@@ -18,8 +26,24 @@ func main() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
-	// Create handler with database connection
-	h := handlers.NewHandler(database.DB)
+	// Refuse to serve against a schema this build does not expect
+	if err := database.CheckSchema(); err != nil {
+		log.Fatal("Database schema check failed (run wms-migrate up): ", err)
+	}
+
+	// Create handler with database connection and token signer
+	tokens := auth.NewTokenManager(cfg.JWTSecret, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	h := handlers.NewHandler(database.DB, tokens)
+	h.SSCCPrefix = cfg.SSCCCompanyPrefix
+
+	// Attachments are kept on the configured storage backend
+	files, err := storage.New(cfg.StorageBackend, cfg.StorageDir)
+	if err != nil {
+		log.Fatal("Failed to open attachment storage: ", err)
+	}
+	h.Files = files
+	h.AttachmentMaxBytes = cfg.AttachmentMaxBytes
+	h.DownloadURLTTL = cfg.DownloadURLTTL
 
 	// Setup routes
 	r := handlers.SetupRoutes(h)
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"wms-backend/internal/config"
+	"wms-backend/internal/database"
+	"wms-backend/migrations"
+)
+
+const usage = `Usage: wms-migrate <command> [args]
+
+Commands:
+  up              Apply all pending migrations
+  down [N]        Revert the last N migrations (default 1)
+  status          List migrations and whether they are applied
+  create NAME     Write an empty up/down pair to ./migrations
+  force VERSION   Clear the dirty flag after repairing a failed migration
+  create-admin USERNAME EMAIL
+                  Create a superuser, or reset its password, using the
+                  password in WMS_ADMIN_PASSWORD
+`
+
+// minAdminPassword is the shortest password create-admin accepts.
+const minAdminPassword = 8
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	// create only touches the filesystem
+	if command == "create" {
+		if len(args) != 1 {
+			log.Fatal("create needs a migration name")
+		}
+		up, down, err := database.CreateMigration(getEnv("MIGRATIONS_DIR", "migrations"), args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Created", up)
+		fmt.Println("Created", down)
+		return
+	}
+
+	cfg := config.Load()
+	if err := database.Connect(cfg.DatabaseURL); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	m, err := database.NewMigrator(database.DB, migrations.FS)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch command {
+	case "up":
+		count, err := m.Up()
+		if err != nil {
+			log.Fatalf("Applied %d migration(s) before failing: %v", count, err)
+		}
+		fmt.Printf("Applied %d migration(s)\n", count)
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+				log.Fatal("down needs a positive number of steps")
+			}
+		}
+		count, err := m.Down(steps)
+		if err != nil {
+			log.Fatalf("Reverted %d migration(s) before failing: %v", count, err)
+		}
+		fmt.Printf("Reverted %d migration(s)\n", count)
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			switch {
+			case s.Dirty:
+				state = "DIRTY"
+			case s.Missing:
+				state = "applied (not in this build)"
+			case s.Applied:
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, state)
+		}
+	case "force":
+		if len(args) != 1 {
+			log.Fatal("force needs a version")
+		}
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			log.Fatal("Invalid version: ", args[0])
+		}
+		if err := m.Force(version); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Marked version %d as cleanly applied\n", version)
+	case "create-admin":
+		if len(args) != 2 {
+			log.Fatal("create-admin needs a username and an email")
+		}
+		// The password is read from the environment so it stays out of
+		// shell history and process listings.
+		password := os.Getenv("WMS_ADMIN_PASSWORD")
+		if len(password) < minAdminPassword {
+			log.Fatalf("Set WMS_ADMIN_PASSWORD to a password of at least %d characters", minAdminPassword)
+		}
+		if err := m.Check(); err != nil {
+			log.Fatal(err)
+		}
+		if err := createAdmin(args[0], args[1], password); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Superuser %s is ready\n", args[0])
+	default:
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+}
+
+// createAdmin inserts a superuser, or resets the password of an existing
+// account with that username and makes it an active superuser.
+func createAdmin(username, email, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	_, err = database.DB.Exec(`
+		INSERT INTO auth_user (username, email, password, is_staff, is_superuser, is_active, roles, date_joined)
+		VALUES ($1, $2, $3, true, true, true, 'admin', NOW())
+		ON CONFLICT (username) DO UPDATE SET
+			email = EXCLUDED.email, password = EXCLUDED.password,
+			is_staff = true, is_superuser = true, is_active = true, roles = 'admin'`,
+		username, email, string(hashedPassword))
+	if err != nil {
+		return fmt.Errorf("error creating superuser: %w", err)
+	}
+	return nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims is the payload carried by both access and refresh tokens.
+type Claims struct {
+	UserID      int      `json:"user_id"`
+	Username    string   `json:"username"`
+	UserType    string   `json:"user_type"`
+	Roles       []string `json:"roles"`
+	IsSuperuser bool     `json:"is_superuser"`
+	TenantID    *int     `json:"tenant_id,omitempty"`
+	CompanyName string   `json:"company_name,omitempty"`
+	WarehouseID *int     `json:"warehouse_id,omitempty"`
+	TokenType   string   `json:"token_type"`
+	jwt.RegisteredClaims
+}
+
+// TokenManager signs and verifies HS256 tokens with a shared secret.
+type TokenManager struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenManager(secret string, accessTTL, refreshTTL time.Duration) *TokenManager {
+	return &TokenManager{
+		secret:     []byte(secret),
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// GeneratePair issues a fresh access and refresh token for the given identity.
+func (m *TokenManager) GeneratePair(identity Claims) (string, string, error) {
+	access, err := m.sign(identity, TokenTypeAccess, m.accessTTL)
+	if err != nil {
+		return "", "", err
+	}
+
+	refresh, err := m.sign(identity, TokenTypeRefresh, m.refreshTTL)
+	if err != nil {
+		return "", "", err
+	}
+
+	return access, refresh, nil
+}
+
+// Parse verifies the signature and expiry of tokenString and checks that it
+// is of the expected token type.
+func (m *TokenManager) Parse(tokenString, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return m.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if !token.Valid || claims.TokenType != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func (m *TokenManager) sign(identity Claims, tokenType string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := identity
+	claims.TokenType = tokenType
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Subject:   fmt.Sprintf("%d", identity.UserID),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	m := NewTokenManager("test-secret", time.Minute, time.Hour)
+	access, refresh, err := m.GeneratePair(Claims{UserID: 42, Username: "picker", Roles: []string{"picker"}})
+	if err != nil {
+		t.Fatalf("GeneratePair() error = %v", err)
+	}
+	expired, _, err := NewTokenManager("test-secret", -time.Minute, time.Hour).GeneratePair(Claims{UserID: 42})
+	if err != nil {
+		t.Fatalf("GeneratePair() error = %v", err)
+	}
+	forged, _, err := NewTokenManager("other-secret", time.Minute, time.Hour).GeneratePair(Claims{UserID: 42})
+	if err != nil {
+		t.Fatalf("GeneratePair() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		tokenType string
+		wantErr   bool
+	}{
+		{name: "access token", token: access, tokenType: TokenTypeAccess},
+		{name: "refresh token", token: refresh, tokenType: TokenTypeRefresh},
+		{name: "refresh token used as access token", token: refresh, tokenType: TokenTypeAccess, wantErr: true},
+		{name: "access token used as refresh token", token: access, tokenType: TokenTypeRefresh, wantErr: true},
+		{name: "expired", token: expired, tokenType: TokenTypeAccess, wantErr: true},
+		{name: "signed with another secret", token: forged, tokenType: TokenTypeAccess, wantErr: true},
+		{name: "garbage", token: "token_42_1700000000", tokenType: TokenTypeAccess, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := m.Parse(tt.token, tt.tokenType)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("Parse() error = %v, want %v", err, ErrInvalidToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if claims.UserID != 42 || claims.Username != "picker" || claims.Subject != "42" {
+				t.Errorf("Parse() claims = %+v", claims)
+			}
+		})
+	}
+}
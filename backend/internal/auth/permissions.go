@@ -0,0 +1,121 @@
+package auth
+
+type Permission string
+
+const (
+	PermManageUsers      Permission = "users.manage"
+	PermViewMasterData   Permission = "master.view"
+	PermManageMasterData Permission = "master.manage"
+	PermViewInventory    Permission = "inventory.view"
+	PermAdjustInventory  Permission = "inventory.adjust"
+	PermCountStock       Permission = "inventory.count"
+	PermApproveCount     Permission = "inventory.approve"
+	PermReceive          Permission = "inbound.receive"
+	PermQualityCheck     Permission = "quality.check"
+	PermIssue            Permission = "outbound.issue"
+	PermPick             Permission = "outbound.pick"
+	PermViewReports      Permission = "reports.view"
+	PermApproveReturn    Permission = "returns.approve"
+)
+
+const (
+	RoleAdmin               = "admin"
+	RoleWarehouseManagement = "warehouse_management"
+	RoleOperatorGudang      = "operator_gudang"
+	RoleChecker             = "checker"
+	RoleQC                  = "qc"
+	RolePicker              = "picker"
+	RoleTenant              = "tenant"
+	RoleTenantAdmin         = "tenant_admin"
+)
+
+var allPermissions = []Permission{
+	PermManageUsers, PermViewMasterData, PermManageMasterData, PermViewInventory, PermAdjustInventory,
+	PermCountStock, PermApproveCount, PermReceive, PermQualityCheck, PermIssue, PermPick, PermViewReports,
+	PermApproveReturn,
+}
+
+// rolePermissions maps each value stored in auth_user.roles to the actions
+// it grants. Unknown roles grant nothing.
+var rolePermissions = map[string][]Permission{
+	RoleAdmin:               allPermissions,
+	RoleWarehouseManagement: allPermissions,
+	RoleOperatorGudang: {
+		PermViewMasterData, PermViewInventory, PermAdjustInventory, PermCountStock, PermReceive, PermIssue,
+	},
+	RoleChecker: {
+		PermViewMasterData, PermViewInventory, PermCountStock, PermReceive,
+	},
+	RoleQC: {
+		PermViewMasterData, PermViewInventory, PermQualityCheck,
+	},
+	RolePicker: {
+		PermViewMasterData, PermViewInventory, PermPick,
+	},
+	RoleTenant: {
+		PermViewMasterData, PermViewInventory, PermViewReports,
+	},
+	RoleTenantAdmin: {
+		PermManageUsers, PermViewMasterData, PermViewInventory, PermViewReports,
+	},
+}
+
+// tenantRoles narrows the staff roles that act on the shared warehouse when
+// they are held by a user bound to a tenant.
+var tenantRoles = map[string]string{
+	RoleAdmin:               RoleTenantAdmin,
+	RoleWarehouseManagement: RoleTenantAdmin,
+}
+
+// HasRole reports whether the claims carry any of the given roles.
+// Superusers implicitly hold every role.
+func (c *Claims) HasRole(roles ...string) bool {
+	if c.IsSuperuser {
+		return true
+	}
+	for _, held := range c.Roles {
+		for _, role := range roles {
+			if held == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Can reports whether any of the user's roles grants perm. Staff roles held
+// by a tenant's user only grant what a tenant admin may do.
+func (c *Claims) Can(perm Permission) bool {
+	if c.IsSuperuser {
+		return true
+	}
+	for _, role := range c.Roles {
+		if narrowed, ok := tenantRoles[role]; ok && c.TenantID != nil {
+			role = narrowed
+		}
+		for _, granted := range rolePermissions[role] {
+			if granted == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CanAssign reports whether the user may give role to an account in their
+// own tenant: the role must not grant anything the user cannot do.
+func (c *Claims) CanAssign(role string) bool {
+	if narrowed, ok := tenantRoles[role]; ok && c.TenantID != nil {
+		role = narrowed
+	}
+	perms, ok := rolePermissions[role]
+	if !ok {
+		return false
+	}
+	for _, perm := range perms {
+		if !c.Can(perm) {
+			return false
+		}
+	}
+	return true
+}
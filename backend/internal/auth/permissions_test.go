@@ -0,0 +1,84 @@
+package auth
+
+import "testing"
+
+func TestCan(t *testing.T) {
+	tenant := 3
+	tests := []struct {
+		name   string
+		claims Claims
+		perm   Permission
+		want   bool
+	}{
+		{name: "admin", claims: Claims{Roles: []string{RoleAdmin}}, perm: PermManageUsers, want: true},
+		{name: "picker picks", claims: Claims{Roles: []string{RolePicker}}, perm: PermPick, want: true},
+		{name: "picker cannot adjust", claims: Claims{Roles: []string{RolePicker}}, perm: PermAdjustInventory},
+		{name: "checker counts", claims: Claims{Roles: []string{RoleChecker}}, perm: PermCountStock, want: true},
+		{name: "checker cannot approve counts", claims: Claims{Roles: []string{RoleChecker}}, perm: PermApproveCount},
+		{name: "any role grants", claims: Claims{Roles: []string{RolePicker, RoleQC}}, perm: PermQualityCheck, want: true},
+		{name: "unknown role grants nothing", claims: Claims{Roles: []string{"owner"}}, perm: PermViewInventory},
+		{name: "no roles", perm: PermViewInventory},
+		{name: "superuser", claims: Claims{IsSuperuser: true}, perm: PermApproveReturn, want: true},
+		{name: "tenant admin manages users", claims: Claims{Roles: []string{RoleTenantAdmin}, TenantID: &tenant}, perm: PermManageUsers, want: true},
+		{name: "tenant admin cannot manage master data", claims: Claims{Roles: []string{RoleTenantAdmin}, TenantID: &tenant}, perm: PermManageMasterData},
+		{name: "admin bound to a tenant cannot adjust", claims: Claims{Roles: []string{RoleAdmin}, TenantID: &tenant}, perm: PermAdjustInventory},
+		{name: "admin bound to a tenant cannot approve counts", claims: Claims{Roles: []string{RoleAdmin}, TenantID: &tenant}, perm: PermApproveCount},
+		{name: "admin bound to a tenant views reports", claims: Claims{Roles: []string{RoleAdmin}, TenantID: &tenant}, perm: PermViewReports, want: true},
+		{name: "management bound to a tenant cannot manage master data", claims: Claims{Roles: []string{RoleWarehouseManagement}, TenantID: &tenant}, perm: PermManageMasterData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.claims.Can(tt.perm); got != tt.want {
+				t.Errorf("Can(%s) = %v, want %v", tt.perm, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims Claims
+		roles  []string
+		want   bool
+	}{
+		{name: "held", claims: Claims{Roles: []string{RoleQC}}, roles: []string{RoleChecker, RoleQC}, want: true},
+		{name: "not held", claims: Claims{Roles: []string{RoleQC}}, roles: []string{RoleAdmin}},
+		{name: "superuser", claims: Claims{IsSuperuser: true}, roles: []string{RoleAdmin}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.claims.HasRole(tt.roles...); got != tt.want {
+				t.Errorf("HasRole(%v) = %v, want %v", tt.roles, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanAssign(t *testing.T) {
+	tenant := 3
+	tests := []struct {
+		name   string
+		claims Claims
+		role   string
+		want   bool
+	}{
+		{name: "management assigns pickers", claims: Claims{Roles: []string{RoleWarehouseManagement}}, role: RolePicker, want: true},
+		{name: "management assigns management", claims: Claims{Roles: []string{RoleWarehouseManagement}}, role: RoleWarehouseManagement, want: true},
+		{name: "operator cannot assign qc", claims: Claims{Roles: []string{RoleOperatorGudang}}, role: RoleQC},
+		{name: "unknown role", claims: Claims{IsSuperuser: true}, role: "owner"},
+		{name: "tenant admin cannot assign floor roles", claims: Claims{Roles: []string{RoleTenantAdmin}, TenantID: &tenant}, role: RolePicker},
+		{name: "tenant admin assigns management narrowed to the tenant", claims: Claims{Roles: []string{RoleTenantAdmin}, TenantID: &tenant}, role: RoleWarehouseManagement, want: true},
+		{name: "tenant admin assigns tenant", claims: Claims{Roles: []string{RoleTenantAdmin}, TenantID: &tenant}, role: RoleTenant, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.claims.CanAssign(tt.role); got != tt.want {
+				t.Errorf("CanAssign(%s) = %v, want %v", tt.role, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// SignResource returns a signature granting access to resource until
+// expires, for links that are followed without an Authorization header.
+func (m *TokenManager) SignResource(resource string, expires time.Time) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(resource + "\n" + strconv.FormatInt(expires.Unix(), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyResource reports whether signature was made by SignResource for
+// resource and expires and has not expired yet.
+func (m *TokenManager) VerifyResource(resource string, expires time.Time, signature string) bool {
+	if time.Now().After(expires) {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	want, _ := hex.DecodeString(m.SignResource(resource, expires))
+	return hmac.Equal(got, want)
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifyResource(t *testing.T) {
+	m := NewTokenManager("test-secret", time.Minute, time.Hour)
+	expires := time.Now().Add(time.Minute)
+	signature := m.SignResource("attachments/1", expires)
+
+	tests := []struct {
+		name      string
+		resource  string
+		expires   time.Time
+		signature string
+		want      bool
+	}{
+		{name: "valid", resource: "attachments/1", expires: expires, signature: signature, want: true},
+		{name: "other resource", resource: "attachments/2", expires: expires, signature: signature},
+		{name: "extended expiry", resource: "attachments/1", expires: expires.Add(time.Hour), signature: signature},
+		{name: "not hex", resource: "attachments/1", expires: expires, signature: "zz"},
+		{name: "expired", resource: "attachments/1", expires: time.Now().Add(-time.Second),
+			signature: m.SignResource("attachments/1", time.Now().Add(-time.Second))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.VerifyResource(tt.resource, tt.expires, tt.signature); got != tt.want {
+				t.Errorf("VerifyResource() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
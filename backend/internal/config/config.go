@@ -1,21 +1,62 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultJWTSecret is the placeholder secret, only accepted in development.
+const defaultJWTSecret = "your-secret-key"
 
 type Config struct {
-	DatabaseURL string
-	APIURL      string
-	JWTSecret   string
-	Port        string
+	// Env is "development" on machines where insecure defaults are fine
+	Env             string
+	DatabaseURL     string
+	APIURL          string
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	Port            string
+	// SSCCCompanyPrefix is the GS1 company prefix carton SSCCs start with
+	SSCCCompanyPrefix string
+	// StorageBackend and StorageDir say where attachments are kept
+	StorageBackend     string
+	StorageDir         string
+	AttachmentMaxBytes int64
+	// DownloadURLTTL is how long a signed attachment link stays valid
+	DownloadURLTTL time.Duration
 }
 
 func Load() *Config {
 	return &Config{
-		DatabaseURL: getEnv("DATABASE_URL", "postgres://wms_user:wms_password@db:5432/wms_db?sslmode=disable"),
-		APIURL:      getEnv("API_URL", "http://localhost:8000"),
-		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key"),
-		Port:        getEnv("PORT", "8000"),
+		Env:                getEnv("APP_ENV", "production"),
+		DatabaseURL:        getEnv("DATABASE_URL", "postgres://wms_user:wms_password@db:5432/wms_db?sslmode=disable"),
+		APIURL:             getEnv("API_URL", "http://localhost:8000"),
+		JWTSecret:          getEnv("JWT_SECRET", defaultJWTSecret),
+		AccessTokenTTL:     getDuration("JWT_ACCESS_TTL", 15*time.Minute),
+		RefreshTokenTTL:    getDuration("JWT_REFRESH_TTL", 7*24*time.Hour),
+		Port:               getEnv("PORT", "8000"),
+		SSCCCompanyPrefix:  getEnv("SSCC_COMPANY_PREFIX", "0000000"),
+		StorageBackend:     getEnv("STORAGE_BACKEND", "local"),
+		StorageDir:         getEnv("ATTACHMENT_DIR", "uploads"),
+		AttachmentMaxBytes: getInt64("ATTACHMENT_MAX_BYTES", 10<<20),
+		DownloadURLTTL:     getDuration("ATTACHMENT_URL_TTL", 15*time.Minute),
+	}
+}
+
+// Validate refuses settings that are unsafe outside development.
+func (c *Config) Validate() error {
+	if c.JWTSecret == defaultJWTSecret && c.Env != "development" {
+		return errors.New("JWT_SECRET is not set; set a secret of your own, or APP_ENV=development for local use")
 	}
+	return nil
+}
+
+// InsecureDefaults reports whether development defaults are in use.
+func (c *Config) InsecureDefaults() bool {
+	return c.JWTSecret == defaultJWTSecret
 }
 
 func getEnv(key, defaultValue string) string {
@@ -24,3 +65,21 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func getInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultValue
+}
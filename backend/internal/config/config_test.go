@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		secret  string
+		wantErr bool
+	}{
+		{name: "own secret in production", env: "production", secret: "s3cret-of-our-own"},
+		{name: "default secret in production", env: "production", secret: defaultJWTSecret, wantErr: true},
+		{name: "default secret without an environment", env: "", secret: defaultJWTSecret, wantErr: true},
+		{name: "default secret in development", env: "development", secret: defaultJWTSecret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Env: tt.env, JWTSecret: tt.secret}
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationLockID is the advisory lock key that keeps two runners from
+// migrating the same database at once.
+const migrationLockID = 727001
+
+var (
+	ErrSchemaDirty    = errors.New("database schema is dirty")
+	ErrSchemaOutdated = errors.New("database schema is out of date")
+	ErrSchemaAhead    = errors.New("database schema is newer than this build")
+
+	migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	Dirty     bool
+	AppliedAt *time.Time
+	// Missing is set for versions recorded in the database that this build
+	// has no scripts for.
+	Missing bool
+}
+
+// Migrator applies the embedded migrations and records them in
+// schema_migrations.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// LoadMigrations reads NNNN_name.up.sql / NNNN_name.down.sql pairs from fsys,
+// sorted by version. Every version needs both scripts.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %v", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %v", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(200) NOT NULL,
+			dirty BOOLEAN NOT NULL DEFAULT FALSE,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations: %v", err)
+	}
+	return nil
+}
+
+type appliedMigration struct {
+	dirty     bool
+	appliedAt time.Time
+}
+
+func (m *Migrator) applied() (map[int64]appliedMigration, error) {
+	rows, err := m.DB.Query(`SELECT version, dirty, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := map[int64]appliedMigration{}
+	for rows.Next() {
+		var version int64
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.dirty, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// Status lists every known migration plus any applied version this build
+// does not ship.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range m.Migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if a, ok := applied[migration.Version]; ok {
+			appliedAt := a.appliedAt
+			status.Applied = true
+			status.Dirty = a.dirty
+			status.AppliedAt = &appliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for version, a := range applied {
+		appliedAt := a.appliedAt
+		statuses = append(statuses, MigrationStatus{Version: version, Applied: true, Dirty: a.dirty, AppliedAt: &appliedAt, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+
+	return statuses, nil
+}
+
+// Check returns an error unless every migration is applied cleanly. The
+// server calls it on startup instead of touching the schema itself.
+func (m *Migrator) Check() error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, s := range statuses {
+		switch {
+		case s.Dirty:
+			return fmt.Errorf("%w: migration %d_%s did not finish", ErrSchemaDirty, s.Version, s.Name)
+		case s.Missing:
+			return fmt.Errorf("%w: version %d is applied but not known", ErrSchemaAhead, s.Version)
+		case !s.Applied:
+			pending = append(pending, fmt.Sprintf("%d_%s", s.Version, s.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending %s", ErrSchemaOutdated, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// Up applies every pending migration in order and returns how many ran.
+func (m *Migrator) Up() (int, error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+	for _, s := range statuses {
+		if s.Dirty {
+			return 0, fmt.Errorf("%w: migration %d_%s did not finish; fix it and run force", ErrSchemaDirty, s.Version, s.Name)
+		}
+	}
+
+	applied := map[int64]bool{}
+	for _, s := range statuses {
+		applied[s.Version] = s.Applied
+	}
+
+	count := 0
+	for _, migration := range m.Migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		if err := m.run(migration, true); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down reverts the latest applied migrations, newest first.
+func (m *Migrator) Down(steps int) (int, error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	known := map[int64]Migration{}
+	for _, migration := range m.Migrations {
+		known[migration.Version] = migration
+	}
+
+	count := 0
+	for i := len(statuses) - 1; i >= 0 && count < steps; i-- {
+		s := statuses[i]
+		if !s.Applied {
+			continue
+		}
+		if s.Dirty {
+			return count, fmt.Errorf("%w: migration %d_%s did not finish; fix it and run force", ErrSchemaDirty, s.Version, s.Name)
+		}
+		migration, ok := known[s.Version]
+		if s.Missing || !ok {
+			return count, fmt.Errorf("%w: no down script for version %d", ErrSchemaAhead, s.Version)
+		}
+		if err := m.run(migration, false); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Force clears the dirty flag on a version after the schema was repaired by
+// hand. The version is marked applied.
+func (m *Migrator) Force(version int64) error {
+	if err := m.ensureTable(); err != nil {
+		return err
+	}
+
+	name := ""
+	for _, migration := range m.Migrations {
+		if migration.Version == version {
+			name = migration.Name
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	_, err := m.DB.Exec(`
+		INSERT INTO schema_migrations (version, name, dirty, applied_at) VALUES ($1, $2, false, NOW())
+		ON CONFLICT (version) DO UPDATE SET dirty = false`, version, name)
+	return err
+}
+
+// run executes one script in a transaction. The version is flagged dirty
+// first so a crash halfway leaves a visible marker behind.
+func (m *Migrator) run(migration Migration, up bool) error {
+	script := migration.Down
+	if up {
+		script = migration.Up
+		_, err := m.DB.Exec(`INSERT INTO schema_migrations (version, name, dirty) VALUES ($1, $2, true)`, migration.Version, migration.Name)
+		if err != nil {
+			return fmt.Errorf("error recording migration %d: %v", migration.Version, err)
+		}
+	} else {
+		_, err := m.DB.Exec(`UPDATE schema_migrations SET dirty = true WHERE version = $1`, migration.Version)
+		if err != nil {
+			return fmt.Errorf("error recording migration %d: %v", migration.Version, err)
+		}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		m.reset(migration, up)
+		return fmt.Errorf("migration %d_%s failed: %v", migration.Version, migration.Name, err)
+	}
+
+	if up {
+		_, err = tx.Exec(`UPDATE schema_migrations SET dirty = false, applied_at = NOW() WHERE version = $1`, migration.Version)
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("error recording migration %d: %v", migration.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		m.reset(migration, up)
+		return fmt.Errorf("migration %d_%s failed: %v", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// reset undoes the dirty marker after a script was rolled back, since
+// PostgreSQL DDL is transactional and nothing from it was kept.
+func (m *Migrator) reset(migration Migration, up bool) {
+	if up {
+		m.DB.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	} else {
+		m.DB.Exec(`UPDATE schema_migrations SET dirty = false WHERE version = $1`, migration.Version)
+	}
+}
+
+func (m *Migrator) lock() (func(), error) {
+	conn, err := m.DB.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error locking migrations: %v", err)
+	}
+	return func() {
+		conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+		conn.Close()
+	}, nil
+}
+
+// CreateMigration writes an empty up/down pair to dir using the next free
+// version number and returns the two paths.
+func CreateMigration(dir, name string) (string, string, error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("migration name is required")
+	}
+
+	migrations, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	next := int64(1)
+	if len(migrations) > 0 {
+		next = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", next, name))
+	up, down := base+".up.sql", base+".down.sql"
+	if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- revert "+name+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
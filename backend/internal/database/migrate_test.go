@@ -0,0 +1,446 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"wms-backend/internal/dbtest"
+)
+
+// fakeSchema simulates schema_migrations. Scripts run by the migrator are
+// logged, and a script containing FAIL is rejected. Writes made inside a
+// transaction only land when it commits.
+type fakeSchema struct {
+	versions map[int64]*fakeVersion
+	scripts  []string
+	pending  []func()
+	locked   int
+}
+
+type fakeVersion struct {
+	name  string
+	dirty bool
+}
+
+func newFakeSchema() *fakeSchema {
+	return &fakeSchema{versions: map[int64]*fakeVersion{}}
+}
+
+func (s *fakeSchema) write(inTx bool, op func()) {
+	if inTx {
+		s.pending = append(s.pending, op)
+		return
+	}
+	op()
+}
+
+func (s *fakeSchema) handle(st dbtest.Statement) (*dbtest.Result, error) {
+	q := strings.Join(strings.Fields(st.Query), " ")
+	var version int64
+	if len(st.Args) > 0 {
+		version, _ = st.Args[0].(int64)
+	}
+
+	switch {
+	case q == "BEGIN", q == "ROLLBACK":
+		s.pending = nil
+	case q == "COMMIT":
+		for _, op := range s.pending {
+			op()
+		}
+		s.pending = nil
+	case strings.HasPrefix(q, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+	case strings.HasPrefix(q, "SELECT pg_advisory_lock"):
+		s.locked++
+	case strings.HasPrefix(q, "SELECT pg_advisory_unlock"):
+		s.locked--
+	case strings.HasPrefix(q, "SELECT version, dirty, applied_at FROM schema_migrations"):
+		res := &dbtest.Result{Columns: []string{"version", "dirty", "applied_at"}}
+		for v, row := range s.versions {
+			res.Rows = append(res.Rows, []driver.Value{v, row.dirty, time.Now()})
+		}
+		return res, nil
+	case strings.HasPrefix(q, "INSERT INTO schema_migrations (version, name, dirty) VALUES"):
+		if _, ok := s.versions[version]; ok {
+			return nil, errors.New("duplicate key value violates unique constraint")
+		}
+		name := st.Args[1].(string)
+		s.write(st.InTx, func() { s.versions[version] = &fakeVersion{name: name, dirty: true} })
+	case strings.HasPrefix(q, "INSERT INTO schema_migrations (version, name, dirty, applied_at)"):
+		name := st.Args[1].(string)
+		s.write(st.InTx, func() {
+			if row, ok := s.versions[version]; ok {
+				row.dirty = false
+				return
+			}
+			s.versions[version] = &fakeVersion{name: name}
+		})
+	case strings.HasPrefix(q, "UPDATE schema_migrations SET dirty = true"):
+		s.write(st.InTx, func() { s.versions[version].dirty = true })
+	case strings.HasPrefix(q, "UPDATE schema_migrations SET dirty = false"):
+		s.write(st.InTx, func() { s.versions[version].dirty = false })
+	case strings.HasPrefix(q, "DELETE FROM schema_migrations"):
+		s.write(st.InTx, func() { delete(s.versions, version) })
+	default:
+		if strings.Contains(q, "FAIL") {
+			return nil, errors.New("syntax error at or near FAIL")
+		}
+		s.write(st.InTx, func() { s.scripts = append(s.scripts, q) })
+	}
+	return nil, nil
+}
+
+// applied lists the recorded versions and whether each is dirty.
+func (s *fakeSchema) applied() map[int64]bool {
+	applied := map[int64]bool{}
+	for v, row := range s.versions {
+		applied[v] = row.dirty
+	}
+	return applied
+}
+
+func testMigrations(failing ...int64) []Migration {
+	var migrations []Migration
+	for i, name := range []string{"create_a", "create_b", "create_c"} {
+		version := int64(i + 1)
+		m := Migration{Version: version, Name: name, Up: "up " + name, Down: "down " + name}
+		for _, f := range failing {
+			if f == version {
+				m.Up, m.Down = "FAIL "+m.Up, "FAIL "+m.Down
+			}
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations
+}
+
+func newTestMigrator(schema *fakeSchema, migrations []Migration) *Migrator {
+	return &Migrator{DB: dbtest.Open(schema.handle), Migrations: migrations}
+}
+
+// checkError fails the test unless err mentions want, or is nil when want
+// is empty. Script failures are reported as text, so messages are compared.
+func checkError(t *testing.T, call string, err error, want string) {
+	t.Helper()
+	if want == "" && err != nil || want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+		t.Fatalf("%s error = %v, want %q", call, err, want)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		versions []int64
+		wantErr  string
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"0010_later.up.sql":   {Data: []byte("up later")},
+				"0010_later.down.sql": {Data: []byte("down later")},
+				"0002_first.up.sql":   {Data: []byte("up first")},
+				"0002_first.down.sql": {Data: []byte("down first")},
+			},
+			versions: []int64{2, 10},
+		},
+		{
+			name: "other files are ignored",
+			files: fstest.MapFS{
+				"0001_init.up.sql":   {Data: []byte("up")},
+				"0001_init.down.sql": {Data: []byte("down")},
+				"migrations.go":      {Data: []byte("package migrations")},
+				"README.md":          {Data: []byte("notes")},
+			},
+			versions: []int64{1},
+		},
+		{
+			name: "missing down script",
+			files: fstest.MapFS{
+				"0001_init.up.sql": {Data: []byte("up")},
+			},
+			wantErr: "needs both an up and a down script",
+		},
+		{
+			name: "two names for one version",
+			files: fstest.MapFS{
+				"0001_init.up.sql":    {Data: []byte("up")},
+				"0001_other.down.sql": {Data: []byte("down")},
+			},
+			wantErr: "has two names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := LoadMigrations(tt.files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadMigrations() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadMigrations() error = %v", err)
+			}
+			var versions []int64
+			for _, m := range migrations {
+				versions = append(versions, m.Version)
+			}
+			if !reflect.DeepEqual(versions, tt.versions) {
+				t.Errorf("versions = %v, want %v", versions, tt.versions)
+			}
+		})
+	}
+}
+
+func TestMigratorUp(t *testing.T) {
+	tests := []struct {
+		name        string
+		applied     map[int64]bool
+		failing     []int64
+		wantCount   int
+		wantErr     string
+		wantScripts []string
+		wantApplied map[int64]bool
+	}{
+		{
+			name:        "fresh database",
+			wantCount:   3,
+			wantScripts: []string{"up create_a", "up create_b", "up create_c"},
+			wantApplied: map[int64]bool{1: false, 2: false, 3: false},
+		},
+		{
+			name:        "only pending versions run",
+			applied:     map[int64]bool{1: false},
+			wantCount:   2,
+			wantScripts: []string{"up create_b", "up create_c"},
+			wantApplied: map[int64]bool{1: false, 2: false, 3: false},
+		},
+		{
+			name:        "up to date",
+			applied:     map[int64]bool{1: false, 2: false, 3: false},
+			wantApplied: map[int64]bool{1: false, 2: false, 3: false},
+		},
+		{
+			name:        "failed script is not recorded",
+			failing:     []int64{2},
+			wantCount:   1,
+			wantErr:     "migration 2_create_b failed",
+			wantScripts: []string{"up create_a"},
+			wantApplied: map[int64]bool{1: false},
+		},
+		{
+			name:        "dirty version blocks everything",
+			applied:     map[int64]bool{1: true},
+			wantErr:     ErrSchemaDirty.Error(),
+			wantApplied: map[int64]bool{1: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := newFakeSchema()
+			for v, dirty := range tt.applied {
+				schema.versions[v] = &fakeVersion{dirty: dirty}
+			}
+			m := newTestMigrator(schema, testMigrations(tt.failing...))
+
+			count, err := m.Up()
+			checkError(t, "Up()", err, tt.wantErr)
+			if count != tt.wantCount {
+				t.Errorf("Up() count = %d, want %d", count, tt.wantCount)
+			}
+			if !reflect.DeepEqual(schema.scripts, tt.wantScripts) {
+				t.Errorf("scripts = %q, want %q", schema.scripts, tt.wantScripts)
+			}
+			if !reflect.DeepEqual(schema.applied(), tt.wantApplied) {
+				t.Errorf("schema_migrations = %v, want %v", schema.applied(), tt.wantApplied)
+			}
+			if schema.locked != 0 {
+				t.Errorf("advisory lock held %d time(s) after Up()", schema.locked)
+			}
+		})
+	}
+}
+
+func TestMigratorDown(t *testing.T) {
+	tests := []struct {
+		name        string
+		applied     map[int64]bool
+		steps       int
+		failing     []int64
+		wantCount   int
+		wantErr     string
+		wantScripts []string
+		wantApplied map[int64]bool
+	}{
+		{
+			name:        "newest first",
+			applied:     map[int64]bool{1: false, 2: false, 3: false},
+			steps:       2,
+			wantCount:   2,
+			wantScripts: []string{"down create_c", "down create_b"},
+			wantApplied: map[int64]bool{1: false},
+		},
+		{
+			name:        "skips pending versions",
+			applied:     map[int64]bool{1: false, 2: false},
+			steps:       1,
+			wantCount:   1,
+			wantScripts: []string{"down create_b"},
+			wantApplied: map[int64]bool{1: false},
+		},
+		{
+			name:        "failed script leaves the version applied and clean",
+			applied:     map[int64]bool{1: false, 2: false},
+			steps:       1,
+			failing:     []int64{2},
+			wantErr:     "migration 2_create_b failed",
+			wantApplied: map[int64]bool{1: false, 2: false},
+		},
+		{
+			name:        "dirty version",
+			applied:     map[int64]bool{1: false, 2: true},
+			steps:       1,
+			wantErr:     ErrSchemaDirty.Error(),
+			wantApplied: map[int64]bool{1: false, 2: true},
+		},
+		{
+			name:        "version without scripts",
+			applied:     map[int64]bool{1: false, 9: false},
+			steps:       1,
+			wantErr:     ErrSchemaAhead.Error(),
+			wantApplied: map[int64]bool{1: false, 9: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := newFakeSchema()
+			for v, dirty := range tt.applied {
+				schema.versions[v] = &fakeVersion{dirty: dirty}
+			}
+			m := newTestMigrator(schema, testMigrations(tt.failing...))
+
+			count, err := m.Down(tt.steps)
+			checkError(t, "Down()", err, tt.wantErr)
+			if count != tt.wantCount {
+				t.Errorf("Down() count = %d, want %d", count, tt.wantCount)
+			}
+			if !reflect.DeepEqual(schema.scripts, tt.wantScripts) {
+				t.Errorf("scripts = %q, want %q", schema.scripts, tt.wantScripts)
+			}
+			if !reflect.DeepEqual(schema.applied(), tt.wantApplied) {
+				t.Errorf("schema_migrations = %v, want %v", schema.applied(), tt.wantApplied)
+			}
+		})
+	}
+}
+
+func TestMigratorCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied map[int64]bool
+		wantErr error
+	}{
+		{name: "all applied", applied: map[int64]bool{1: false, 2: false, 3: false}},
+		{name: "pending", applied: map[int64]bool{1: false}, wantErr: ErrSchemaOutdated},
+		{name: "dirty", applied: map[int64]bool{1: false, 2: true, 3: false}, wantErr: ErrSchemaDirty},
+		{name: "unknown version", applied: map[int64]bool{1: false, 2: false, 3: false, 4: false}, wantErr: ErrSchemaAhead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := newFakeSchema()
+			for v, dirty := range tt.applied {
+				schema.versions[v] = &fakeVersion{dirty: dirty}
+			}
+			err := newTestMigrator(schema, testMigrations()).Check()
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Check() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMigratorStatusListsMissingVersions(t *testing.T) {
+	schema := newFakeSchema()
+	schema.versions[1] = &fakeVersion{}
+	schema.versions[7] = &fakeVersion{dirty: true}
+
+	statuses, err := newTestMigrator(schema, testMigrations()).Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	var got []string
+	for _, s := range statuses {
+		state := "pending"
+		switch {
+		case s.Missing:
+			state = "missing"
+		case s.Applied:
+			state = "applied"
+		}
+		if s.Dirty {
+			state += ",dirty"
+		}
+		got = append(got, s.Name+":"+state)
+	}
+	want := []string{"create_a:applied", "create_b:pending", "create_c:pending", ":missing,dirty"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Status() = %v, want %v", got, want)
+	}
+}
+
+func TestMigratorForce(t *testing.T) {
+	tests := []struct {
+		name        string
+		applied     map[int64]bool
+		version     int64
+		wantErr     bool
+		wantApplied map[int64]bool
+	}{
+		{
+			name:        "clears the dirty flag",
+			applied:     map[int64]bool{1: false, 2: true},
+			version:     2,
+			wantApplied: map[int64]bool{1: false, 2: false},
+		},
+		{
+			name:        "marks a pending version applied",
+			applied:     map[int64]bool{1: false},
+			version:     2,
+			wantApplied: map[int64]bool{1: false, 2: false},
+		},
+		{
+			name:        "unknown version",
+			applied:     map[int64]bool{1: true},
+			version:     9,
+			wantErr:     true,
+			wantApplied: map[int64]bool{1: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := newFakeSchema()
+			for v, dirty := range tt.applied {
+				schema.versions[v] = &fakeVersion{dirty: dirty}
+			}
+			err := newTestMigrator(schema, testMigrations()).Force(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Force() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(schema.applied(), tt.wantApplied) {
+				t.Errorf("schema_migrations = %v, want %v", schema.applied(), tt.wantApplied)
+			}
+		})
+	}
+}
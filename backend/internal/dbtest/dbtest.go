@@ -0,0 +1,176 @@
+// Package dbtest is a database/sql driver for tests. Every statement is
+// answered by a Go function, so code written against *sql.DB and *sql.Tx
+// can be exercised without a PostgreSQL server.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Statement is one statement sent to the database. Transactions arrive as
+// the statements BEGIN, COMMIT and ROLLBACK; InTx is set for everything in
+// between.
+type Statement struct {
+	Query string
+	Args  []driver.Value
+	InTx  bool
+}
+
+// Result answers a statement. Rows are only read by queries, RowsAffected
+// only by execs. A nil Result is an empty one.
+type Result struct {
+	Columns      []string
+	Rows         [][]driver.Value
+	RowsAffected int64
+}
+
+// Handler answers the statements of a test database. Calls are serialized.
+type Handler func(s Statement) (*Result, error)
+
+// Open returns a database whose statements are answered by h.
+func Open(h Handler) *sql.DB {
+	return sql.OpenDB(&connector{handler: h})
+}
+
+type connector struct {
+	mu      sync.Mutex
+	handler Handler
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{connector: c}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+func (c *connector) run(s Statement) (*Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, err := c.handler(s)
+	if res == nil {
+		res = &Result{}
+	}
+	return res, err
+}
+
+type fakeDriver struct{}
+
+// Open is only here to satisfy driver.Driver; connections come from Open
+// in this package.
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("dbtest: databases are opened with dbtest.Open")
+}
+
+type conn struct {
+	connector *connector
+	inTx      bool
+}
+
+func (c *conn) run(query string, args []driver.NamedValue) (*Result, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return c.connector.run(Statement{Query: query, Args: values, InTx: c.inTx})
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	if _, err := c.connector.run(Statement{Query: "BEGIN"}); err != nil {
+		return nil, err
+	}
+	c.inTx = true
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := c.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(res.RowsAffected), nil
+}
+
+func (c *conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res, err := c.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{result: res}, nil
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) end(statement string) error {
+	t.conn.inTx = false
+	_, err := t.conn.connector.run(Statement{Query: statement})
+	return err
+}
+
+func (t *tx) Commit() error   { return t.end("COMMIT") }
+func (t *tx) Rollback() error { return t.end("ROLLBACK") }
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return values
+}
+
+type rows struct {
+	result *Result
+	next   int
+}
+
+func (r *rows) Columns() []string {
+	if r.result.Columns == nil && len(r.result.Rows) > 0 {
+		return make([]string, len(r.result.Rows[0]))
+	}
+	return r.result.Columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.Rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.Rows[r.next])
+	r.next++
+	return nil
+}
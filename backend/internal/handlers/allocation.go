@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var errInvalidAllocation = errors.New("invalid allocation")
+
+// allocationOrder is the order each strategy consumes balances in. FIFO and
+// LIFO go by when stock arrived at its location, FEFO by expiry. A fixed
+// location is drawn down earliest expiry first.
+var allocationOrder = map[string]string{
+	models.AllocationFEFO:  `i.expiry_date NULLS LAST, i.received_at, i.batch = '', i.batch`,
+	models.AllocationFIFO:  `i.received_at, i.expiry_date NULLS LAST, i.batch`,
+	models.AllocationLIFO:  `i.received_at DESC, i.expiry_date NULLS LAST, i.batch`,
+	models.AllocationFixed: `i.expiry_date NULLS LAST, i.received_at, i.batch = '', i.batch`,
+}
+
+// allocationScope narrows where stock may be allocated from. A zero
+// LocationID allows any active storage location outside docks and returns
+// areas, skipping those frozen by an open count, and ExcludeLocationID if
+// set. Reserved stock is only allocated with IncludeReserved.
+type allocationScope struct {
+	WarehouseID       *int
+	LocationID        int
+	ExcludeLocationID int
+	Batch             string
+	IncludeReserved   bool
+	Lock              bool
+}
+
+// planAllocation works out which balances quantity of a product comes out
+// of under the product's strategy. With Lock the chosen rows stay locked
+// until the transaction ends so the plan can be posted as is.
+func planAllocation(q dbtx, productID, quantity int, scope allocationScope) (models.AllocationPlan, error) {
+	plan := models.AllocationPlan{ProductID: productID, Requested: quantity}
+
+	var fixedLocationID sql.NullInt64
+	err := q.QueryRow(`SELECT allocation_strategy, fixed_location_id FROM warehouse_product WHERE id = $1`,
+		productID).Scan(&plan.Strategy, &fixedLocationID)
+	if err == sql.ErrNoRows {
+		return plan, fmt.Errorf("%w: unknown product", errInvalidAllocation)
+	}
+	if err != nil {
+		return plan, err
+	}
+
+	locationID := scope.LocationID
+	if plan.Strategy == models.AllocationFixed && locationID == 0 {
+		locationID = int(fixedLocationID.Int64)
+	}
+	order, ok := allocationOrder[plan.Strategy]
+	if !ok {
+		order = allocationOrder[models.AllocationFEFO]
+	}
+
+	available := `i.quantity - i.reserved_quantity - i.quarantined_quantity`
+	if scope.IncludeReserved {
+		available = `i.quantity - i.quarantined_quantity`
+	}
+	query := `
+		SELECT i.location_id, l.warehouse_id, l.code, i.batch, i.expiry_date, i.received_at, ` + available + `
+		FROM inventory i
+		JOIN locations l ON i.location_id = l.id
+		WHERE i.product_id = $1 AND ` + available + ` > 0
+		  AND ($2::int IS NULL OR l.warehouse_id = $2)
+		  AND ($3::int = 0 OR i.location_id = $3)
+		  AND ($3::int <> 0 OR (l.is_active AND l.location_type NOT IN ('dock', 'returns')))
+		  AND ($4 = '' OR i.batch = $4) AND i.location_id <> $5
+		ORDER BY ` + order
+	if scope.Lock {
+		query += ` FOR UPDATE OF i`
+	}
+	rows, err := q.Query(query, productID, scope.WarehouseID, locationID, scope.Batch, scope.ExcludeLocationID)
+	if err != nil {
+		return plan, err
+	}
+	var candidates []models.AllocationLine
+	for rows.Next() {
+		var line models.AllocationLine
+		var warehouseID sql.NullInt64
+		var expiry sql.NullTime
+		if err := rows.Scan(&line.LocationID, &warehouseID, &line.LocationCode, &line.Batch, &expiry,
+			&line.ReceivedAt, &line.Quantity); err != nil {
+			rows.Close()
+			return plan, err
+		}
+		line.WarehouseID = nullableInt(warehouseID)
+		line.ExpiryDate = nullableTime(expiry)
+		candidates = append(candidates, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return plan, err
+	}
+
+	frozen := map[int]bool{}
+	remaining := quantity
+	for _, line := range candidates {
+		if remaining == 0 {
+			break
+		}
+		if locationID == 0 {
+			open, seen := frozen[line.LocationID]
+			if !seen {
+				if open, err = stockCountOpen(q, productID, line.LocationID); err != nil {
+					return plan, err
+				}
+				frozen[line.LocationID] = open
+			}
+			if open {
+				continue
+			}
+		}
+		if line.Quantity > remaining {
+			line.Quantity = remaining
+		}
+		plan.Lines = append(plan.Lines, line)
+		remaining -= line.Quantity
+	}
+	plan.Allocated = quantity - remaining
+	plan.Short = remaining
+	return plan, nil
+}
+
+// postAllocation books every line of plan as an OUT movement built from m.
+func postAllocation(tx *sql.Tx, plan models.AllocationPlan, m stockMovement) error {
+	m.ProductID = plan.ProductID
+	m.Type = models.MovementOut
+	for _, line := range plan.Lines {
+		if err := ensureStockNotFrozen(tx, plan.ProductID, line.LocationID); err != nil {
+			return err
+		}
+		out := m
+		out.LocationID, out.Batch, out.ExpiryDate, out.Quantity = line.LocationID, line.Batch, line.ExpiryDate, line.Quantity
+		if err := applyStockMovement(tx, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAllocationPlan previews where quantity of product_id would be taken
+// from, without reserving anything. location_id and batch narrow the
+// search; otherwise the selected warehouse, or all of them, is searched.
+func (h *Handler) GetAllocationPlan(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "product_id is required"})
+		return
+	}
+	quantity, err := strconv.Atoi(c.Query("quantity"))
+	if err != nil || quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be a positive number"})
+		return
+	}
+	if ok, err := h.belongsToTenant(c, "warehouse_product", productID); err != nil || !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	scope := allocationScope{WarehouseID: middleware.WarehouseID(c), Batch: c.Query("batch")}
+	if param := c.Query("location_id"); param != "" {
+		if scope.LocationID, err = strconv.Atoi(param); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location_id"})
+			return
+		}
+		if _, err := h.locationWarehouse(c, scope.LocationID); err != nil {
+			writeAllocationError(c, locationLookupError(err))
+			return
+		}
+	}
+
+	plan, err := planAllocation(h.DB, productID, quantity, scope)
+	if err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+// UpdateProductAllocation sets the strategy outbound stock of a product is
+// allocated with.
+func (h *Handler) UpdateProductAllocation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var req models.AllocationStrategyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if ok, err := h.belongsToTenant(c, "warehouse_product", id); err != nil || !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if err := h.validateAllocationStrategy(c, req.Strategy, req.FixedLocationID); err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+
+	_, err = h.DB.Exec(`UPDATE warehouse_product SET allocation_strategy = $1, fixed_location_id = $2 WHERE id = $3`,
+		req.Strategy, req.FixedLocationID, id)
+	if err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Allocation strategy updated successfully",
+		"id":                  id,
+		"allocation_strategy": req.Strategy,
+		"fixed_location_id":   req.FixedLocationID,
+	})
+}
+
+// writeInsufficientStock answers a plan that came up short with what is
+// currently available.
+func writeInsufficientStock(c *gin.Context, plan models.AllocationPlan) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":      "Insufficient stock",
+		"product_id": plan.ProductID,
+		"requested":  plan.Requested,
+		"available":  plan.Allocated,
+		"allocation": plan,
+	})
+}
+
+func (h *Handler) validateAllocationStrategy(c *gin.Context, strategy string, fixedLocationID *int) error {
+	if _, ok := allocationOrder[strategy]; !ok {
+		return fmt.Errorf("%w: allocation_strategy must be fefo, fifo, lifo or fixed", errInvalidAllocation)
+	}
+	if fixedLocationID == nil {
+		if strategy == models.AllocationFixed {
+			return fmt.Errorf("%w: fixed_location_id is required for the fixed strategy", errInvalidAllocation)
+		}
+		return nil
+	}
+	_, err := h.locationWarehouse(c, *fixedLocationID)
+	return locationLookupError(err)
+}
+
+func locationLookupError(err error) error {
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: unknown location", errInvalidAllocation)
+	}
+	return err
+}
+
+func writeAllocationError(c *gin.Context, err error) {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, errInvalidAllocation), errors.Is(err, errInvalidMovement), errors.Is(err, errTenantRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errLocationNotAccessible):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, errStockFrozen), errors.Is(err, errInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &pqErr) && pqErr.Code == "23514":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid allocation strategy"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to allocate stock"})
+	}
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+)
+
+// fakeBalance is one inventory row of the simulated warehouse.
+type fakeBalance struct {
+	productID   int
+	locationID  int
+	code        string
+	batch       string
+	expiry      *time.Time
+	receivedAt  time.Time
+	quantity    int
+	reserved    int
+	quarantined int
+}
+
+// fakeStock simulates the inventory of one warehouse for planAllocation.
+// Candidates are filtered and sorted the way the query asks for, so the
+// ORDER BY chosen for each strategy is exercised too.
+type fakeStock struct {
+	*fakeDB
+	strategy      string
+	fixedLocation int
+	balances      []*fakeBalance
+	frozen        map[int]bool
+}
+
+func day(s string) *time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return &d
+}
+
+// newFakeStock stocks product 1 in three locations. By expiry the order is
+// A-02, A-01, A-03; by arrival it is A-03, A-01, A-02.
+func newFakeStock(strategy string) *fakeStock {
+	s := &fakeStock{
+		fakeDB:   &fakeDB{},
+		strategy: strategy,
+		frozen:   map[int]bool{},
+		balances: []*fakeBalance{
+			{productID: 1, locationID: 10, code: "A-01", batch: "L1", expiry: day("2026-12-01"), receivedAt: *day("2026-01-10"), quantity: 5},
+			{productID: 1, locationID: 11, code: "A-02", batch: "L2", expiry: day("2026-11-01"), receivedAt: *day("2026-02-10"), quantity: 4},
+			{productID: 1, locationID: 12, code: "A-03", batch: "L3", receivedAt: *day("2026-01-01"), quantity: 10},
+		},
+	}
+	s.on(s.strategyOf, "SELECT allocation_strategy", "FROM warehouse_product")
+	s.on(s.candidates, "FROM inventory i JOIN locations l", "ORDER BY")
+	s.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		return &dbtest.Result{Rows: [][]driver.Value{{s.frozen[argInt(st.Args[1])]}}}, nil
+	}, "SELECT EXISTS(", "FROM stock_opnames o")
+	return s
+}
+
+func (s *fakeStock) balance(productID, locationID int, batch string) *fakeBalance {
+	for _, b := range s.balances {
+		if b.productID == productID && b.locationID == locationID && b.batch == batch {
+			return b
+		}
+	}
+	return nil
+}
+
+// strategyOf answers the strategy lookup; only product 1 exists.
+func (s *fakeStock) strategyOf(st dbtest.Statement) (*dbtest.Result, error) {
+	if argInt(st.Args[0]) != 1 {
+		return nil, nil
+	}
+	var fixed driver.Value
+	if s.fixedLocation != 0 {
+		fixed = int64(s.fixedLocation)
+	}
+	return &dbtest.Result{Rows: [][]driver.Value{{s.strategy, fixed}}}, nil
+}
+
+// candidates answers the balance query of planAllocation.
+func (s *fakeStock) candidates(st dbtest.Statement) (*dbtest.Result, error) {
+	productID, locationID, batch, excluded := argInt(st.Args[0]), argInt(st.Args[2]), st.Args[3].(string), argInt(st.Args[4])
+	includeReserved := !strings.Contains(st.Query, "i.reserved_quantity")
+
+	var found []*fakeBalance
+	for _, b := range s.balances {
+		if b.productID != productID || locationID != 0 && b.locationID != locationID ||
+			batch != "" && b.batch != batch || b.locationID == excluded {
+			continue
+		}
+		if s.available(b, includeReserved) > 0 {
+			found = append(found, b)
+		}
+	}
+
+	query := strings.Join(strings.Fields(st.Query), " ")
+	order := strings.TrimSuffix(query[strings.Index(query, "ORDER BY ")+len("ORDER BY "):], " FOR UPDATE OF i")
+	var sortErr error
+	sort.SliceStable(found, func(i, j int) bool {
+		for _, key := range strings.Split(order, ", ") {
+			less, more, err := compareBalances(key, found[i], found[j])
+			if err != nil {
+				sortErr = err
+			}
+			if less || more {
+				return less
+			}
+		}
+		return false
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+
+	res := &dbtest.Result{}
+	for _, b := range found {
+		var expiry driver.Value
+		if b.expiry != nil {
+			expiry = *b.expiry
+		}
+		res.Rows = append(res.Rows, []driver.Value{int64(b.locationID), int64(1), b.code, b.batch, expiry,
+			b.receivedAt, int64(s.available(b, includeReserved))})
+	}
+	return res, nil
+}
+
+func (s *fakeStock) available(b *fakeBalance, includeReserved bool) int {
+	if includeReserved {
+		return b.quantity - b.quarantined
+	}
+	return b.quantity - b.reserved - b.quarantined
+}
+
+// compareBalances orders a and b by one ORDER BY key and reports whether a
+// sorts first or last.
+func compareBalances(key string, a, b *fakeBalance) (bool, bool, error) {
+	switch key {
+	case "i.expiry_date NULLS LAST":
+		switch {
+		case a.expiry == nil || b.expiry == nil:
+			return a.expiry != nil && b.expiry == nil, a.expiry == nil && b.expiry != nil, nil
+		default:
+			return a.expiry.Before(*b.expiry), b.expiry.Before(*a.expiry), nil
+		}
+	case "i.received_at":
+		return a.receivedAt.Before(b.receivedAt), b.receivedAt.Before(a.receivedAt), nil
+	case "i.received_at DESC":
+		return b.receivedAt.Before(a.receivedAt), a.receivedAt.Before(b.receivedAt), nil
+	case "i.batch = ''":
+		return a.batch != "" && b.batch == "", a.batch == "" && b.batch != "", nil
+	case "i.batch":
+		return a.batch < b.batch, b.batch < a.batch, nil
+	}
+	return false, false, fmt.Errorf("unexpected ORDER BY key %q", key)
+}
+
+// planLines renders the lines of a plan as code:quantity.
+func planLines(plan models.AllocationPlan) []string {
+	var lines []string
+	for _, line := range plan.Lines {
+		lines = append(lines, fmt.Sprintf("%s:%d", line.LocationCode, line.Quantity))
+	}
+	return lines
+}
+
+func TestPlanAllocation(t *testing.T) {
+	tests := []struct {
+		name          string
+		strategy      string
+		fixedLocation int
+		productID     int
+		quantity      int
+		scope         allocationScope
+		frozen        []int
+		reserved      map[int]int
+		wantLines     []string
+		wantShort     int
+		wantErr       error
+	}{
+		{
+			name: "fefo takes the earliest expiry first", strategy: models.AllocationFEFO, quantity: 6,
+			wantLines: []string{"A-02:4", "A-01:2"},
+		},
+		{
+			name: "fefo leaves stock without expiry for last", strategy: models.AllocationFEFO, quantity: 12,
+			wantLines: []string{"A-02:4", "A-01:5", "A-03:3"},
+		},
+		{
+			name: "fifo takes the oldest arrival first", strategy: models.AllocationFIFO, quantity: 12,
+			wantLines: []string{"A-03:10", "A-01:2"},
+		},
+		{
+			name: "lifo takes the newest arrival first", strategy: models.AllocationLIFO, quantity: 3,
+			wantLines: []string{"A-02:3"},
+		},
+		{
+			name: "unknown strategy falls back to fefo", strategy: "", quantity: 4,
+			wantLines: []string{"A-02:4"},
+		},
+		{
+			name: "shortage is reported", strategy: models.AllocationFIFO, quantity: 25,
+			wantLines: []string{"A-03:10", "A-01:5", "A-02:4"}, wantShort: 6,
+		},
+		{
+			name: "reserved stock is not offered", strategy: models.AllocationFEFO, quantity: 8,
+			reserved:  map[int]int{11: 4, 10: 3},
+			wantLines: []string{"A-01:2", "A-03:6"},
+		},
+		{
+			name: "reserved stock is offered when included", strategy: models.AllocationFEFO, quantity: 8,
+			reserved: map[int]int{11: 4, 10: 3}, scope: allocationScope{IncludeReserved: true},
+			wantLines: []string{"A-02:4", "A-01:4"},
+		},
+		{
+			name: "frozen locations are skipped", strategy: models.AllocationFEFO, quantity: 6,
+			frozen:    []int{11},
+			wantLines: []string{"A-01:5", "A-03:1"},
+		},
+		{
+			name: "batch narrows the search", strategy: models.AllocationFIFO, quantity: 6,
+			scope:     allocationScope{Batch: "L1"},
+			wantLines: []string{"A-01:5"}, wantShort: 1,
+		},
+		{
+			name: "excluded location is skipped", strategy: models.AllocationFIFO, quantity: 6,
+			scope:     allocationScope{ExcludeLocationID: 12},
+			wantLines: []string{"A-01:5", "A-02:1"},
+		},
+		{
+			name: "fixed location only", strategy: models.AllocationFixed, fixedLocation: 12, quantity: 12,
+			wantLines: []string{"A-03:10"}, wantShort: 2,
+		},
+		{
+			name: "unknown product", strategy: models.AllocationFEFO, productID: 2, quantity: 1,
+			wantErr: errInvalidAllocation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stock := newFakeStock(tt.strategy)
+			stock.fixedLocation = tt.fixedLocation
+			for _, id := range tt.frozen {
+				stock.frozen[id] = true
+			}
+			for id, quantity := range tt.reserved {
+				stock.balance(1, id, fmt.Sprintf("L%d", id-9)).reserved = quantity
+			}
+			productID := tt.productID
+			if productID == 0 {
+				productID = 1
+			}
+
+			plan, err := planAllocation(dbtest.Open(stock.handle), productID, tt.quantity, tt.scope)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("planAllocation() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got := planLines(plan); !reflect.DeepEqual(got, tt.wantLines) {
+				t.Errorf("lines = %v, want %v", got, tt.wantLines)
+			}
+			if plan.Short != tt.wantShort || plan.Allocated != tt.quantity-tt.wantShort {
+				t.Errorf("allocated %d short %d, want allocated %d short %d",
+					plan.Allocated, plan.Short, tt.quantity-tt.wantShort, tt.wantShort)
+			}
+		})
+	}
+}
@@ -17,6 +17,8 @@ func APIRoot(w http.ResponseWriter, r *http.Request) {
 			"inventory":         "/api/inventory",
 			"stock-movements":   "/api/stock-movements",
 			"stock-opnames":     "/api/stock-opnames",
+			"goods-receipts":    "/api/goods-receipts",
+			"transfers":         "/api/transfers",
 			"receptions":        "/api/receptions",
 			"dispatches":        "/api/dispatches",
 			"returns":           "/api/returns",
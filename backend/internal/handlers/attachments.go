@@ -0,0 +1,540 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"wms-backend/internal/auth"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+	"wms-backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errAttachmentNotFound  = errors.New("attachment not found")
+	errAttachmentOwner     = errors.New("attachment owner not found")
+	errAttachmentForbidden = errors.New("you do not have permission to change attachments of this document")
+	errInvalidAttachment   = errors.New("invalid attachment")
+	errAttachmentTooLarge  = errors.New("attachment is too large")
+	errAttachmentType      = errors.New("unsupported attachment type, upload a JPEG, PNG, GIF, WebP or PDF file")
+)
+
+// attachmentOwners maps each owner type to the table its id points into
+// and the permission needed to add or remove its attachments. Viewing them
+// only needs inventory.view.
+var attachmentOwners = map[string]struct {
+	table string
+	perm  auth.Permission
+}{
+	models.AttachGoodsReceipt: {"goods_receipts", auth.PermReceive},
+	models.AttachQualityCheck: {"quality_checks", auth.PermQualityCheck},
+	models.AttachQCInspection: {"qc_inspections", auth.PermQualityCheck},
+	models.AttachReturn:       {"returns", auth.PermReceive},
+	models.AttachDispatch:     {"dispatches", auth.PermIssue},
+}
+
+// attachmentTypes are the accepted content types, sniffed from the upload
+// rather than taken from the client, with the extension they are stored
+// under.
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+const (
+	// thumbnailSize bounds the longer side of an image preview
+	thumbnailSize = 320
+	// maxImagePixels keeps small files that decode to huge images out
+	maxImagePixels = 50_000_000
+	// attachmentVariantFile and attachmentVariantThumbnail are what a
+	// download link serves
+	attachmentVariantFile      = "file"
+	attachmentVariantThumbnail = "thumbnail"
+)
+
+const attachmentColumns = `
+	a.id, a.owner_type, a.owner_id, a.file_name, a.content_type, a.size_bytes, a.checksum, a.notes,
+	a.uploaded_by, COALESCE(u.username, ''), a.created_at, a.storage_key, a.thumbnail_key`
+
+const attachmentFrom = `
+	FROM attachments a
+	LEFT JOIN auth_user u ON a.uploaded_by = u.id`
+
+// GetAttachments lists the attachments of one document, given by
+// owner_type and owner_id, with download links.
+func (h *Handler) GetAttachments(c *gin.Context) {
+	ownerType := c.Query("owner_type")
+	if _, ok := attachmentOwners[ownerType]; !ok {
+		writeAttachmentError(c, errInvalidAttachmentOwnerType())
+		return
+	}
+	ownerID, err := strconv.Atoi(c.Query("owner_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "owner_id is required"})
+		return
+	}
+
+	rows, err := h.DB.Query(`SELECT `+attachmentColumns+attachmentFrom+`
+		WHERE a.owner_type = $1 AND a.owner_id = $2 AND ($3::int IS NULL OR a.tenant_id = $3)
+		ORDER BY a.created_at, a.id`,
+		ownerType, ownerID, middleware.TenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			continue
+		}
+		h.signAttachment(a)
+		attachments = append(attachments, *a)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": attachments})
+}
+
+func (h *Handler) GetAttachment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	a, err := h.findAttachment(c, id)
+	if err != nil {
+		writeAttachmentError(c, err)
+		return
+	}
+	h.signAttachment(a)
+
+	c.JSON(http.StatusOK, a)
+}
+
+// UploadAttachment stores the multipart file under owner_type and
+// owner_id. Images also get a JPEG thumbnail.
+func (h *Handler) UploadAttachment(c *gin.Context) {
+	if h.Files == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Attachment storage is not configured"})
+		return
+	}
+	// Leave room for the other form fields and multipart framing
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.AttachmentMaxBytes+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeAttachmentError(c, h.attachmentTooLarge())
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	ownerType := c.PostForm("owner_type")
+	ownerID, err := strconv.Atoi(c.PostForm("owner_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "owner_id is required"})
+		return
+	}
+	tenantID, err := h.checkAttachmentOwner(c, ownerType, ownerID)
+	if err != nil {
+		writeAttachmentError(c, err)
+		return
+	}
+	if header.Size > h.AttachmentMaxBytes {
+		writeAttachmentError(c, h.attachmentTooLarge())
+		return
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, h.AttachmentMaxBytes+1))
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	if len(data) == 0 {
+		writeAttachmentError(c, fmt.Errorf("%w: file is empty", errInvalidAttachment))
+		return
+	}
+	if int64(len(data)) > h.AttachmentMaxBytes {
+		writeAttachmentError(c, h.attachmentTooLarge())
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := attachmentTypes[contentType]
+	if !ok {
+		writeAttachmentError(c, errAttachmentType)
+		return
+	}
+	var thumb []byte
+	if contentType != "image/webp" && contentType != "application/pdf" {
+		if thumb, err = makeThumbnail(data); err != nil {
+			writeAttachmentError(c, fmt.Errorf("%w: %v", errInvalidAttachment, err))
+			return
+		}
+	}
+
+	key, err := newAttachmentKey(ext)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
+		return
+	}
+	var thumbKey *string
+	if thumb != nil {
+		k := strings.TrimSuffix(key, ext) + "_thumb.jpg"
+		thumbKey = &k
+	}
+	if err := h.putAttachment(key, data, thumbKey, thumb); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	var id int
+	err = h.DB.QueryRow(`
+		INSERT INTO attachments (owner_type, owner_id, file_name, content_type, size_bytes, checksum,
+		                         storage_key, thumbnail_key, notes, uploaded_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id`,
+		ownerType, ownerID, attachmentFileName(header.Filename, ext), contentType, len(data), hex.EncodeToString(sum[:]),
+		key, thumbKey, c.PostForm("notes"), middleware.CurrentUserID(c), tenantID).Scan(&id)
+	if err != nil {
+		h.removeAttachmentFiles(key, thumbKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+		return
+	}
+	a, err := h.findAttachment(c, id)
+	if err != nil {
+		writeAttachmentError(c, err)
+		return
+	}
+	h.signAttachment(a)
+
+	c.JSON(http.StatusCreated, a)
+}
+
+// DeleteAttachment removes an attachment and its stored files.
+func (h *Handler) DeleteAttachment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	a, err := h.findAttachment(c, id)
+	if err == nil {
+		_, err = h.checkAttachmentOwner(c, a.OwnerType, a.OwnerID)
+	}
+	if err != nil {
+		writeAttachmentError(c, err)
+		return
+	}
+
+	if _, err := h.DB.Exec(`DELETE FROM attachments WHERE id = $1`, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+	if h.Files != nil {
+		h.removeAttachmentFiles(a.StorageKey, a.ThumbnailKey)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// DownloadAttachment serves an attachment or its thumbnail to anyone
+// holding a link signed by signAttachment, so images and documents can be
+// opened directly by a browser. It sits outside the authenticated routes.
+func (h *Handler) DownloadAttachment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	variant := c.Param("variant")
+	expires, expErr := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || expErr != nil ||
+		!h.Tokens.VerifyResource(attachmentResource(id, variant), time.Unix(expires, 0), c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Download link is invalid or has expired"})
+		return
+	}
+
+	a, err := scanAttachment(h.DB.QueryRow(`SELECT `+attachmentColumns+attachmentFrom+` WHERE a.id = $1`, id))
+	if err == sql.ErrNoRows {
+		writeAttachmentError(c, errAttachmentNotFound)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachment"})
+		return
+	}
+	key, contentType, size, name := a.StorageKey, a.ContentType, a.SizeBytes, a.FileName
+	if variant == attachmentVariantThumbnail {
+		if a.ThumbnailKey == nil {
+			writeAttachmentError(c, errAttachmentNotFound)
+			return
+		}
+		key, contentType, size = *a.ThumbnailKey, "image/jpeg", -1
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + "_thumb.jpg"
+	}
+	if h.Files == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Attachment storage is not configured"})
+		return
+	}
+
+	f, err := h.Files.Open(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeAttachmentError(c, errAttachmentNotFound)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read attachment"})
+		return
+	}
+	defer f.Close()
+
+	c.DataFromReader(http.StatusOK, size, contentType, f, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("inline", map[string]string{"filename": name}),
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, max-age=300",
+	})
+}
+
+// checkAttachmentOwner makes sure the document exists for the request's
+// tenant and that the caller may change its attachments. It returns the
+// document's tenant, which its attachments belong to.
+func (h *Handler) checkAttachmentOwner(c *gin.Context, ownerType string, ownerID int) (*int, error) {
+	owner, ok := attachmentOwners[ownerType]
+	if !ok {
+		return nil, errInvalidAttachmentOwnerType()
+	}
+	if claims := middleware.GetClaims(c); claims == nil || !claims.Can(owner.perm) {
+		return nil, errAttachmentForbidden
+	}
+	ok, err := h.belongsToTenant(c, owner.table, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errAttachmentOwner
+	}
+	return ownerTenant(c, h.DB, owner.table, ownerID)
+}
+
+func (h *Handler) findAttachment(c *gin.Context, id int) (*models.Attachment, error) {
+	a, err := scanAttachment(h.DB.QueryRow(`SELECT `+attachmentColumns+attachmentFrom+`
+		WHERE a.id = $1 AND ($2::int IS NULL OR a.tenant_id = $2)`,
+		id, middleware.TenantID(c)))
+	if err == sql.ErrNoRows {
+		return nil, errAttachmentNotFound
+	}
+	return a, err
+}
+
+// putAttachment stores an upload and its thumbnail, if any, leaving
+// nothing behind when either fails.
+func (h *Handler) putAttachment(key string, data []byte, thumbKey *string, thumb []byte) error {
+	if err := h.Files.Put(key, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	if thumbKey != nil {
+		if err := h.Files.Put(*thumbKey, bytes.NewReader(thumb)); err != nil {
+			h.Files.Delete(key)
+			return err
+		}
+	}
+	return nil
+}
+
+// removeAttachmentFiles deletes stored files. Failures only leave an
+// unreferenced file behind, so they are not reported.
+func (h *Handler) removeAttachmentFiles(key string, thumbKey *string) {
+	h.Files.Delete(key)
+	if thumbKey != nil {
+		h.Files.Delete(*thumbKey)
+	}
+}
+
+// signAttachment fills in download links valid for DownloadURLTTL.
+func (h *Handler) signAttachment(a *models.Attachment) {
+	expires := time.Now().Add(h.DownloadURLTTL).Truncate(time.Second)
+	a.URL = h.attachmentURL(a.ID, attachmentVariantFile, expires)
+	if a.ThumbnailKey != nil {
+		a.ThumbnailURL = h.attachmentURL(a.ID, attachmentVariantThumbnail, expires)
+	}
+	a.URLExpiresAt = &expires
+}
+
+func (h *Handler) attachmentURL(id int, variant string, expires time.Time) string {
+	resource := attachmentResource(id, variant)
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires.Unix(), 10)},
+		"signature": {h.Tokens.SignResource(resource, expires)},
+	}
+	return "/api/files/" + resource + "?" + query.Encode()
+}
+
+// attachmentResource is what a download link signs, matching the path it
+// is served under.
+func attachmentResource(id int, variant string) string {
+	return fmt.Sprintf("attachments/%d/%s", id, variant)
+}
+
+func (h *Handler) attachmentTooLarge() error {
+	return fmt.Errorf("%w, the limit is %d bytes", errAttachmentTooLarge, h.AttachmentMaxBytes)
+}
+
+func errInvalidAttachmentOwnerType() error {
+	return fmt.Errorf("%w: owner_type must be goods_receipt, quality_check, qc_inspection, return or dispatch",
+		errInvalidAttachment)
+}
+
+// newAttachmentKey picks a random storage key, grouped by month.
+func newAttachmentKey(ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "attachments/" + time.Now().Format("2006/01") + "/" + hex.EncodeToString(b) + ext, nil
+}
+
+// attachmentFileName keeps the base of the client's file name, giving it
+// the extension of the type actually uploaded when it has none.
+func attachmentFileName(name, ext string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		name = "attachment"
+	}
+	if filepath.Ext(name) == "" {
+		name += ext
+	}
+	if len(name) > 255 {
+		name = name[len(name)-255:]
+	}
+	return name
+}
+
+// makeThumbnail decodes an image and scales it to fit thumbnailSize,
+// averaging the source pixels behind each thumbnail pixel and flattening
+// transparency onto white.
+func makeThumbnail(data []byte) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("image could not be read")
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, errors.New("image dimensions are too large")
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("image could not be read")
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w > thumbnailSize || h > thumbnailSize {
+		if w >= h {
+			tw, th = thumbnailSize, h*thumbnailSize/w
+		} else {
+			tw, th = w*thumbnailSize/h, thumbnailSize
+		}
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			if x1 == x0 {
+				x1++
+			}
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			white := 0xffff - a/n
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r/n + white), G: uint16(g/n + white), B: uint16(bl/n + white), A: 0xffff,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeAttachmentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errAttachmentNotFound), errors.Is(err, errAttachmentOwner):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errAttachmentForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, errAttachmentTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, errAttachmentType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidAttachment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process attachment"})
+	}
+}
+
+func scanAttachment(s scanner) (*models.Attachment, error) {
+	var a models.Attachment
+	var uploadedBy sql.NullInt64
+	var thumbKey sql.NullString
+	err := s.Scan(&a.ID, &a.OwnerType, &a.OwnerID, &a.FileName, &a.ContentType, &a.SizeBytes, &a.Checksum, &a.Notes,
+		&uploadedBy, &a.UploadedName, &a.CreatedAt, &a.StorageKey, &thumbKey)
+	if err != nil {
+		return nil, err
+	}
+	a.UploadedBy = nullableInt(uploadedBy)
+	if thumbKey.Valid {
+		a.ThumbnailKey = &thumbKey.String
+	}
+	return &a, nil
+}
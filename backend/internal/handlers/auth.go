@@ -2,63 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"time"
 	"wms-backend/internal/database"
 	"wms-backend/internal/models"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-func Login(w http.ResponseWriter, r *http.Request) {
-	var req models.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON"})
-		return
-	}
-
-	// Validate input
-	if req.Username == "" || req.Password == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Username and password are required"})
-		return
-	}
-
-	var user models.User
-	var hashedPassword string
-	err := database.DB.QueryRow(
-		"SELECT id, username, email, password, is_staff, role, first_name, last_name, is_active FROM auth_user WHERE username = $1 AND is_active = true",
-		req.Username,
-	).Scan(&user.ID, &user.Username, &user.Email, &hashedPassword, &user.IsStaff, &user.Role, &user.FirstName, &user.LastName, &user.IsActive)
-
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid credentials"})
-		return
-	}
-
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password)); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid credentials"})
-		return
-	}
-
-	token := fmt.Sprintf("token_%d_%d", user.ID, time.Now().Unix())
-	response := models.TokenResponse{
-		Access:  token,
-		Refresh: token,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
 func Register(w http.ResponseWriter, r *http.Request) {
 	var req models.RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
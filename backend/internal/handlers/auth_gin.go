@@ -1,9 +1,10 @@
 package handlers
 
 import (
-	"fmt"
+	"database/sql"
 	"net/http"
-	"time"
+	"strings"
+	"wms-backend/internal/auth"
 	"wms-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
@@ -39,15 +40,87 @@ func (h *Handler) LoginGin(c *gin.Context) {
 		return
 	}
 
-	token := fmt.Sprintf("token_%d_%d", user.ID, time.Now().Unix())
-	response := models.TokenResponse{
-		Access:  token,
-		Refresh: token,
+	response, err := h.issueTokens(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not issue token"})
+		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+func (h *Handler) RefreshTokenGin(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Refresh == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token is required"})
+		return
+	}
+
+	claims, err := h.Tokens.Parse(req.Refresh, auth.TokenTypeRefresh)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	// Reload the user so deactivated accounts and role changes take effect
+	response, err := h.issueTokens(claims.UserID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User no longer active"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not issue token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// issueTokens loads the active user's identity and signs a new token pair.
+func (h *Handler) issueTokens(userID int) (models.TokenResponse, error) {
+	var identity auth.Claims
+	var rolesStr string
+	var tenantID, warehouseID sql.NullInt64
+	err := h.DB.QueryRow(`
+		SELECT id, username, COALESCE(user_type, 'user'), COALESCE(roles, ''), is_superuser,
+		       tenant_id, COALESCE(company_name, ''), warehouse_id
+		FROM auth_user WHERE id = $1 AND is_active = true
+	`, userID).Scan(&identity.UserID, &identity.Username, &identity.UserType, &rolesStr, &identity.IsSuperuser,
+		&tenantID, &identity.CompanyName, &warehouseID)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	identity.Roles = parseRoles(rolesStr)
+	identity.TenantID = nullableInt(tenantID)
+	identity.WarehouseID = nullableInt(warehouseID)
+
+	access, refresh, err := h.Tokens.GeneratePair(identity)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	return models.TokenResponse{Access: access, Refresh: refresh}, nil
+}
+
+func nullableInt(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+func parseRoles(rolesStr string) []string {
+	var roles []string
+	for _, role := range strings.Split(rolesStr, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
 func (h *Handler) RegisterGin(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
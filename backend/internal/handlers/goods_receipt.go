@@ -3,11 +3,24 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"wms-backend/internal/middleware"
 	"wms-backend/internal/models"
 )
 
+// The penerimaan endpoints are kept for older clients. Documents and their
+// detail rows are goods receipts and goods receipt lines underneath.
+
+// penerimaanStatusSQL maps goods receipt statuses back to penerimaan ones.
+const penerimaanStatusSQL = `CASE status
+		WHEN 'draft' THEN 'draft'
+		WHEN 'completed' THEN 'completed'
+		WHEN 'cancelled' THEN 'cancelled'
+		ELSE 'in_progress'
+	END`
+
 func (h *Handler) CreatePenerimaan(c *gin.Context) {
 	var req models.CreatePenerimaanRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -15,32 +28,49 @@ func (h *Handler) CreatePenerimaan(c *gin.Context) {
 		return
 	}
 
-	query := `INSERT INTO penerimaan_barang (no_dokumen, tanggal, supplier, no_po) 
-			  VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at`
-	
-	var penerimaan models.PenerimaanBarang
-	err := h.DB.QueryRow(query, req.NoDokumen, req.Tanggal, req.Supplier, req.NoPO).
-		Scan(&penerimaan.ID, &penerimaan.CreatedAt, &penerimaan.UpdatedAt)
-	
+	tx, err := h.DB.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
 		return
 	}
+	defer tx.Rollback()
 
-	penerimaan.NoDokumen = req.NoDokumen
-	penerimaan.Tanggal = req.Tanggal
-	penerimaan.Supplier = req.Supplier
-	penerimaan.NoPO = req.NoPO
-	penerimaan.Status = "draft"
+	r, err := h.insertGoodsReceipt(tx, c, models.GoodsReceiptRequest{
+		DocumentNumber: req.NoDokumen,
+		ReceiptDate:    req.Tanggal,
+		SupplierName:   req.Supplier,
+		PONumber:       req.NoPO,
+	}, models.SourcePenerimaan)
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
 
-	c.JSON(http.StatusCreated, penerimaan)
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.PenerimaanBarang{
+		ID:        r.ID,
+		NoDokumen: r.DocumentNumber,
+		Tanggal:   r.ReceiptDate.Format("2006-01-02"),
+		Supplier:  r.SupplierName,
+		NoPO:      r.PONumber,
+		Status:    "draft",
+		CreatedAt: r.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: r.UpdatedAt.Format(time.RFC3339),
+	})
 }
 
 func (h *Handler) GetPenerimaan(c *gin.Context) {
-	query := `SELECT id, no_dokumen, tanggal, supplier, no_po, status, created_at, updated_at 
-			  FROM penerimaan_barang ORDER BY created_at DESC`
-	
-	rows, err := h.DB.Query(query)
+	query := `SELECT id, document_number, TO_CHAR(receipt_date, 'YYYY-MM-DD'), supplier_name, po_number, ` + penerimaanStatusSQL + `,
+			  created_at, updated_at
+			  FROM goods_receipts
+			  WHERE source = $1 AND ($2::int IS NULL OR tenant_id = $2)
+			  ORDER BY created_at DESC`
+
+	rows, err := h.DB.Query(query, models.SourcePenerimaan, middleware.TenantID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -75,27 +105,56 @@ func (h *Handler) AddDetailPenerimaan(c *gin.Context) {
 		return
 	}
 
-	query := `INSERT INTO detail_penerimaan (penerimaan_id, sku, nama_barang, jumlah, batch, expired_date, satuan) 
-			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
-	
-	var detail models.DetailPenerimaan
-	err = h.DB.QueryRow(query, penerimaanID, req.SKU, req.NamaBarang, req.Jumlah, req.Batch, req.ExpiredDate, req.Satuan).
-		Scan(&detail.ID, &detail.CreatedAt)
-	
+	tx, err := h.DB.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := h.lockGoodsReceipt(tx, c, penerimaanID)
+	if err == errReceiptNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Penerimaan not found"})
+		return
+	}
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+	if r.Status != models.GoodsReceiptDraft {
+		c.JSON(http.StatusConflict, gin.H{"error": "Penerimaan is already being checked"})
+		return
+	}
+
+	line, err := h.insertGoodsReceiptLine(tx, c, r, models.GoodsReceiptLineRequest{
+		SKU:         req.SKU,
+		ProductName: req.NamaBarang,
+		Quantity:    req.Jumlah,
+		UnitName:    req.Satuan,
+		Batch:       req.Batch,
+		ExpiryDate:  req.ExpiredDate,
+	})
+	if err != nil {
+		writeGoodsReceiptError(c, err)
 		return
 	}
 
-	detail.PenerimaanID = penerimaanID
-	detail.SKU = req.SKU
-	detail.NamaBarang = req.NamaBarang
-	detail.Jumlah = req.Jumlah
-	detail.Batch = req.Batch
-	detail.ExpiredDate = req.ExpiredDate
-	detail.Satuan = req.Satuan
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusCreated, detail)
+	c.JSON(http.StatusCreated, models.DetailPenerimaan{
+		ID:           line.ID,
+		PenerimaanID: penerimaanID,
+		SKU:          line.SKU,
+		NamaBarang:   line.ProductName,
+		Jumlah:       line.Quantity,
+		Batch:        line.Batch,
+		ExpiredDate:  req.ExpiredDate,
+		Satuan:       line.UnitName,
+		CreatedAt:    line.CreatedAt.Format(time.RFC3339),
+	})
 }
 
 func (h *Handler) GetDetailPenerimaan(c *gin.Context) {
@@ -106,10 +165,14 @@ func (h *Handler) GetDetailPenerimaan(c *gin.Context) {
 		return
 	}
 
-	query := `SELECT id, penerimaan_id, sku, nama_barang, jumlah, batch, expired_date, satuan, created_at 
-			  FROM detail_penerimaan WHERE penerimaan_id = $1`
-	
-	rows, err := h.DB.Query(query, penerimaanID)
+	query := `SELECT d.id, d.receipt_id, d.sku, d.product_name, d.quantity, d.batch,
+			  COALESCE(TO_CHAR(d.expiry_date, 'YYYY-MM-DD'), ''), d.unit_name, d.created_at
+			  FROM goods_receipt_lines d
+			  JOIN goods_receipts p ON d.receipt_id = p.id
+			  WHERE d.receipt_id = $1 AND ($2::int IS NULL OR p.tenant_id = $2)
+			  ORDER BY d.id`
+
+	rows, err := h.DB.Query(query, penerimaanID, middleware.TenantID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -130,6 +193,8 @@ func (h *Handler) GetDetailPenerimaan(c *gin.Context) {
 	c.JSON(http.StatusOK, details)
 }
 
+// CreatePemeriksaanKualitas records a verdict (diterima/ditolak) on one
+// detail row. The first verdict moves a draft penerimaan into checking.
 func (h *Handler) CreatePemeriksaanKualitas(c *gin.Context) {
 	detailID := c.Param("detailId")
 	detailPenerimaanID, err := strconv.Atoi(detailID)
@@ -144,25 +209,73 @@ func (h *Handler) CreatePemeriksaanKualitas(c *gin.Context) {
 		return
 	}
 
-	query := `INSERT INTO pemeriksaan_kualitas (detail_penerimaan_id, status, keterangan) 
-			  VALUES ($1, $2, $3) RETURNING id, created_at`
-	
-	var pemeriksaan models.PemeriksaanKualitas
-	err = h.DB.QueryRow(query, detailPenerimaanID, req.Status, req.Keterangan).
-		Scan(&pemeriksaan.ID, &pemeriksaan.CreatedAt)
-	
+	var verdict string
+	switch req.Status {
+	case "diterima":
+		verdict = models.QCAccepted
+	case "ditolak":
+		verdict = models.QCRejected
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be diterima or ditolak"})
+		return
+	}
+
+	var receiptID int
+	err = h.DB.QueryRow(`SELECT receipt_id FROM goods_receipt_lines WHERE id = $1`, detailPenerimaanID).Scan(&receiptID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Detail penerimaan not found"})
 		return
 	}
 
-	pemeriksaan.DetailPenerimaanID = detailPenerimaanID
-	pemeriksaan.Status = req.Status
-	pemeriksaan.Keterangan = req.Keterangan
+	userID := middleware.CurrentUserID(c)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
 
-	c.JSON(http.StatusCreated, pemeriksaan)
+	r, err := h.lockGoodsReceipt(tx, c, receiptID)
+	if err == errReceiptNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Detail penerimaan not found"})
+		return
+	}
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+	// Only the first verdict moves the document on; later ones find it
+	// in QC already
+	if r.Status == models.GoodsReceiptDraft {
+		if err := advanceGoodsReceipt(tx, r, models.GoodsReceiptReceived, userID); err != nil {
+			writeGoodsReceiptError(c, err)
+			return
+		}
+	}
+	if err := setGoodsReceiptLineQC(tx, r, detailPenerimaanID, verdict, req.Keterangan, userID); err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.PemeriksaanKualitas{
+		ID:                 detailPenerimaanID,
+		DetailPenerimaanID: detailPenerimaanID,
+		Status:             req.Status,
+		Keterangan:         req.Keterangan,
+		CreatedAt:          time.Now().Format(time.RFC3339),
+	})
 }
 
+// CompletePenerimaan finishes the document in one transaction: every row
+// must have a verdict, accepted rows are booked to stock (rows without a
+// put-away location go to location_id from the body) and rejected rows go to
+// rejected_disposition, quarantine by default.
 func (h *Handler) CompletePenerimaan(c *gin.Context) {
 	id := c.Param("id")
 	penerimaanID, err := strconv.Atoi(id)
@@ -171,13 +284,85 @@ func (h *Handler) CompletePenerimaan(c *gin.Context) {
 		return
 	}
 
-	query := `UPDATE penerimaan_barang SET status = 'completed', updated_at = CURRENT_TIMESTAMP WHERE id = $1`
-	
-	_, err = h.DB.Exec(query, penerimaanID)
+	var req struct {
+		LocationID          int    `json:"location_id"`
+		RejectedDisposition string `json:"rejected_disposition"`
+	}
+	// The body is optional
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	userID := middleware.CurrentUserID(c)
+
+	tx, err := h.DB.Begin()
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := h.lockGoodsReceipt(tx, c, penerimaanID)
+	if err == errReceiptNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Penerimaan not found"})
+		return
+	}
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+	if r.Status == models.GoodsReceiptCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Penerimaan is already completed"})
+		return
+	}
+	// A receipt moved on to put-away through the goods receipt API is
+	// already past QC
+	if goodsReceiptStage(r.Status) < goodsReceiptStage(models.GoodsReceiptQC) {
+		if err := advanceGoodsReceipt(tx, r, models.GoodsReceiptQC, userID); err != nil {
+			writeGoodsReceiptError(c, err)
+			return
+		}
+	}
+
+	if req.RejectedDisposition != "" {
+		if err := setGoodsReceiptDisposition(tx, r, 0, req.RejectedDisposition); err != nil {
+			writeGoodsReceiptError(c, err)
+			return
+		}
+	}
+
+	if req.LocationID != 0 {
+		rows, err := tx.Query(`SELECT id FROM goods_receipt_lines WHERE receipt_id = $1 AND location_id IS NULL`, r.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var lineIDs []int
+		for rows.Next() {
+			var lineID int
+			if err := rows.Scan(&lineID); err == nil {
+				lineIDs = append(lineIDs, lineID)
+			}
+		}
+		rows.Close()
+
+		for _, lineID := range lineIDs {
+			if err := h.setGoodsReceiptLineLocation(tx, c, r, lineID, req.LocationID); err != nil {
+				writeGoodsReceiptError(c, err)
+				return
+			}
+		}
+	}
+
+	if err := advanceGoodsReceipt(tx, r, models.GoodsReceiptCompleted, userID); err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Penerimaan completed successfully"})
-}
\ No newline at end of file
+}
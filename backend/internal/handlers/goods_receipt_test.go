@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+)
+
+// acceptedLine is a receipt line that passed QC. A zero productID is a
+// line no product could be matched to.
+type acceptedLine struct {
+	id, productID, locationID, quantity int
+}
+
+// newFakePosting serves receipt r with the given accepted lines on top of
+// the warehouse of newFakeLedger, and counts the rejected-stock inserts.
+func newFakePosting(r *models.GoodsReceipt, lines []acceptedLine) (*fakeLedger, *int) {
+	ledger := newFakeLedger(models.AllocationFEFO)
+	onReceipt(ledger.fakeDB, r)
+	ledger.on(rows([]driver.Value{int64(0)}), "SELECT COUNT(*) FROM goods_receipt_lines")
+	ledger.on(rows([]driver.Value{r.UpdatedAt, nil}), "UPDATE goods_receipts SET status")
+	ledger.on(affected(1), "INSERT INTO goods_receipt_status_history")
+	ledger.on(affected(0), "UPDATE goods_receipt_lines l", "SET product_id")
+	quarantined := 0
+	ledger.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		quarantined++
+		return nil, nil
+	}, "INSERT INTO quarantine_stock")
+	ledger.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		res := &dbtest.Result{}
+		for _, l := range lines {
+			var productID driver.Value
+			if l.productID != 0 {
+				productID = int64(l.productID)
+			}
+			res.Rows = append(res.Rows, []driver.Value{int64(l.id), productID, int64(l.locationID), int64(l.quantity), "", nil})
+		}
+		return res, nil
+	}, "SELECT id, product_id, location_id", "FROM goods_receipt_lines")
+	ledger.on(affected(1), "UPDATE goods_receipt_lines SET posted_at")
+	ledger.on(rows([]driver.Value{models.LocationBin}), "SELECT location_type FROM locations")
+	return ledger, &quarantined
+}
+
+func TestCompletePenerimaan(t *testing.T) {
+	tenant, other := 3, 4
+	tests := []struct {
+		name          string
+		claims        auth.Claims
+		status        string
+		lines         []acceptedLine
+		frozen        []int
+		wantCode      int
+		wantMovements []string
+	}{
+		{
+			name: "books accepted lines to stock", claims: auth.Claims{UserID: 7}, status: models.GoodsReceiptQC,
+			lines:    []acceptedLine{{id: 21, productID: 1, locationID: 20, quantity: 3}},
+			wantCode: http.StatusOK, wantMovements: []string{"IN 3 20/"},
+		},
+		{
+			name: "lines without a product stop the posting", claims: auth.Claims{UserID: 7}, status: models.GoodsReceiptQC,
+			lines:    []acceptedLine{{id: 21, productID: 1, locationID: 20, quantity: 3}, {id: 22, locationID: 20, quantity: 1}},
+			wantCode: http.StatusConflict,
+		},
+		{
+			name: "locations under count are frozen", claims: auth.Claims{UserID: 7}, status: models.GoodsReceiptQC,
+			lines:    []acceptedLine{{id: 21, productID: 1, locationID: 20, quantity: 3}},
+			frozen:   []int{20},
+			wantCode: http.StatusConflict,
+		},
+		{
+			name: "completed once", claims: auth.Claims{UserID: 7}, status: models.GoodsReceiptCompleted,
+			wantCode: http.StatusConflict,
+		},
+		{
+			name: "other tenants' receipts are not found", claims: auth.Claims{UserID: 7, TenantID: &other}, status: models.GoodsReceiptQC,
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &models.GoodsReceipt{ID: 1, DocumentNumber: "GR-1", Status: tt.status, Source: models.SourcePenerimaan, TenantID: &tenant}
+			ledger, quarantined := newFakePosting(r, tt.lines)
+			for _, id := range tt.frozen {
+				ledger.frozen[id] = true
+			}
+			h := &Handler{DB: ledger.open(t)}
+
+			w := serveAs(&tt.claims, "/penerimaan/:id/complete", h.CompletePenerimaan,
+				jsonRequest(http.MethodPut, "/penerimaan/1/complete", ""))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if ledger.committed != (tt.wantCode == http.StatusOK) {
+				t.Errorf("committed = %v on a %d", ledger.committed, w.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if !reflect.DeepEqual(ledger.movements, tt.wantMovements) {
+				t.Errorf("movements = %v, want %v", ledger.movements, tt.wantMovements)
+			}
+			if *quarantined != 1 {
+				t.Errorf("rejected stock was routed %d times, want once", *quarantined)
+			}
+		})
+	}
+}
@@ -0,0 +1,1055 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errReceiptNotFound     = errors.New("goods receipt not found")
+	errReceiptLineNotFound = errors.New("goods receipt line not found")
+	errInvalidReceipt      = errors.New("invalid goods receipt")
+	errIllegalTransition   = errors.New("illegal status transition")
+	errReceiptNotEditable  = errors.New("goods receipt cannot be changed in its current status")
+	errReceiptIncomplete   = errors.New("goods receipt is not ready")
+	errLocationWrongSite   = errors.New("location belongs to another warehouse")
+)
+
+// goodsReceiptTransitions lists the statuses each status may move to.
+var goodsReceiptTransitions = map[string][]string{
+	models.GoodsReceiptDraft:    {models.GoodsReceiptReceived, models.GoodsReceiptCancelled},
+	models.GoodsReceiptReceived: {models.GoodsReceiptQC, models.GoodsReceiptCancelled},
+	models.GoodsReceiptQC:       {models.GoodsReceiptPutaway, models.GoodsReceiptCancelled},
+	models.GoodsReceiptPutaway:  {models.GoodsReceiptCompleted},
+}
+
+// goodsReceiptFlow is the happy path, used to fast-forward legacy documents.
+var goodsReceiptFlow = []string{
+	models.GoodsReceiptDraft,
+	models.GoodsReceiptReceived,
+	models.GoodsReceiptQC,
+	models.GoodsReceiptPutaway,
+	models.GoodsReceiptCompleted,
+}
+
+const goodsReceiptColumns = `id, document_number, receipt_date, supplier_id, supplier_name, po_number, warehouse_id,
+	status, source, notes, created_by, tenant_id, created_at, updated_at, completed_at`
+
+const goodsReceiptLineColumns = `id, receipt_id, product_id, sku, product_name, quantity, unit_id, unit_name, batch,
+	expiry_date, location_id, location_name, qc_status, qc_notes, COALESCE(disposition, ''), rejected_quantity, qc_by, qc_at, posted_at, created_at`
+
+func (h *Handler) GetGoodsReceipts(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		SELECT `+goodsReceiptColumns+` FROM goods_receipts
+		WHERE ($1::int IS NULL OR tenant_id = $1)
+		  AND ($2::int IS NULL OR warehouse_id = $2)
+		  AND ($3 = '' OR status = $3)
+		  AND ($4 = '' OR source = $4)
+		ORDER BY created_at DESC`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("status"), c.Query("source"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goods receipts"})
+		return
+	}
+	defer rows.Close()
+
+	var receipts []models.GoodsReceipt
+	for rows.Next() {
+		r, err := scanGoodsReceipt(rows)
+		if err != nil {
+			continue
+		}
+		receipts = append(receipts, *r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": receipts})
+}
+
+func (h *Handler) GetGoodsReceipt(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	r, err := scanGoodsReceipt(h.DB.QueryRow(`
+		SELECT `+goodsReceiptColumns+` FROM goods_receipts
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2) AND ($3::int IS NULL OR warehouse_id = $3)`,
+		id, middleware.TenantID(c), middleware.WarehouseID(c)))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goods receipt not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goods receipt"})
+		return
+	}
+
+	if r.Lines, err = goodsReceiptLines(h.DB, r.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goods receipt lines"})
+		return
+	}
+
+	c.JSON(http.StatusOK, r)
+}
+
+func (h *Handler) CreateGoodsReceipt(c *gin.Context) {
+	var req models.GoodsReceiptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := h.insertGoodsReceipt(tx, c, req, models.SourceGoodsReceipt)
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, r)
+}
+
+func (h *Handler) AddGoodsReceiptLine(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req models.GoodsReceiptLineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := h.lockGoodsReceipt(tx, c, id)
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+	if r.Status != models.GoodsReceiptDraft {
+		writeGoodsReceiptError(c, fmt.Errorf("%w: lines can only be added to draft receipts", errReceiptNotEditable))
+		return
+	}
+
+	line, err := h.insertGoodsReceiptLine(tx, c, r, req)
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, line)
+}
+
+// UpdateGoodsReceiptStatus moves a receipt one step through its workflow.
+// Completing it posts the accepted lines to stock.
+func (h *Handler) UpdateGoodsReceiptStatus(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req models.GoodsReceiptStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := h.lockGoodsReceipt(tx, c, id)
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+	if err := transitionGoodsReceipt(tx, r, req.Status, middleware.CurrentUserID(c)); err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, r)
+}
+
+func (h *Handler) UpdateGoodsReceiptLineQC(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	lineID, err := strconv.Atoi(c.Param("lineId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid line ID"})
+		return
+	}
+
+	var req models.GoodsReceiptQCRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := h.lockGoodsReceipt(tx, c, id)
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+	if err := setGoodsReceiptLineQC(tx, r, lineID, req.QCStatus, req.Notes, middleware.CurrentUserID(c)); err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+	if req.Disposition != "" {
+		if err := setGoodsReceiptDisposition(tx, r, lineID, req.Disposition); err != nil {
+			writeGoodsReceiptError(c, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "QC verdict recorded", "status": r.Status})
+}
+
+func (h *Handler) UpdateGoodsReceiptLinePutaway(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	lineID, err := strconv.Atoi(c.Param("lineId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid line ID"})
+		return
+	}
+
+	var req models.GoodsReceiptPutawayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := h.lockGoodsReceipt(tx, c, id)
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+	if err := h.setGoodsReceiptLineLocation(tx, c, r, lineID, req.LocationID); err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Put-away location assigned"})
+}
+
+func (h *Handler) UpdateGoodsReceiptLineProduct(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	lineID, err := strconv.Atoi(c.Param("lineId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid line ID"})
+		return
+	}
+
+	var req models.GoodsReceiptLineProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := h.lockGoodsReceipt(tx, c, id)
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+	if err := setGoodsReceiptLineProduct(tx, r, lineID, req.ProductID); err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product linked"})
+}
+
+func (h *Handler) GetGoodsReceiptHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	rows, err := h.DB.Query(`
+		SELECT hist.id, hist.receipt_id, COALESCE(hist.from_status, ''), hist.to_status, hist.changed_by,
+		       COALESCE(u.username, ''), hist.changed_at
+		FROM goods_receipt_status_history hist
+		JOIN goods_receipts gr ON hist.receipt_id = gr.id
+		LEFT JOIN auth_user u ON hist.changed_by = u.id
+		WHERE hist.receipt_id = $1 AND ($2::int IS NULL OR gr.tenant_id = $2) AND ($3::int IS NULL OR gr.warehouse_id = $3)
+		ORDER BY hist.changed_at, hist.id`,
+		id, middleware.TenantID(c), middleware.WarehouseID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch status history"})
+		return
+	}
+	defer rows.Close()
+
+	var history []models.GoodsReceiptStatusChange
+	for rows.Next() {
+		var change models.GoodsReceiptStatusChange
+		var changedBy sql.NullInt64
+		err := rows.Scan(&change.ID, &change.ReceiptID, &change.FromStatus, &change.ToStatus, &changedBy,
+			&change.ChangedByName, &change.ChangedAt)
+		if err != nil {
+			continue
+		}
+		change.ChangedBy = nullableInt(changedBy)
+		history = append(history, change)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": history})
+}
+
+// GetQuarantineStock lists rejected receipt lines and returned goods held
+// back from inventory.
+func (h *Handler) GetQuarantineStock(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		SELECT q.id, q.receipt_id, q.receipt_line_id, q.return_id, COALESCE(gr.document_number, rt.document_number),
+		       q.product_id, q.product_name, q.quantity, q.batch, q.expiry_date, q.supplier_id, q.supplier_name,
+		       q.warehouse_id, q.disposition, q.reason, q.created_by, q.created_at
+		FROM quarantine_stock q
+		LEFT JOIN goods_receipts gr ON q.receipt_id = gr.id
+		LEFT JOIN returns rt ON q.return_id = rt.id
+		WHERE ($1::int IS NULL OR q.tenant_id = $1)
+		  AND ($2::int IS NULL OR q.warehouse_id = $2)
+		  AND ($3 = '' OR q.disposition = $3)
+		ORDER BY q.created_at DESC`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("disposition"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quarantine stock"})
+		return
+	}
+	defer rows.Close()
+
+	var items []models.QuarantineStock
+	for rows.Next() {
+		var q models.QuarantineStock
+		var receiptID, receiptLineID, returnID, productID, supplierID, warehouseID, createdBy sql.NullInt64
+		var expiry sql.NullTime
+		err := rows.Scan(&q.ID, &receiptID, &receiptLineID, &returnID, &q.DocumentNumber, &productID, &q.ProductName,
+			&q.Quantity, &q.Batch, &expiry, &supplierID, &q.SupplierName, &warehouseID, &q.Disposition, &q.Reason,
+			&createdBy, &q.CreatedAt)
+		if err != nil {
+			continue
+		}
+		q.ReceiptID = nullableInt(receiptID)
+		q.ReceiptLineID = nullableInt(receiptLineID)
+		q.ReturnID = nullableInt(returnID)
+		q.ProductID = nullableInt(productID)
+		q.SupplierID = nullableInt(supplierID)
+		q.WarehouseID = nullableInt(warehouseID)
+		q.CreatedBy = nullableInt(createdBy)
+		if expiry.Valid {
+			q.ExpiryDate = &expiry.Time
+		}
+		items = append(items, q)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// insertGoodsReceipt creates a draft receipt with its lines for the
+// request's tenant. Warehouse admins always receive into their own site.
+func (h *Handler) insertGoodsReceipt(tx *sql.Tx, c *gin.Context, req models.GoodsReceiptRequest, source string) (*models.GoodsReceipt, error) {
+	// Staff not scoped to a tenant receive for the tenant of the supplier
+	// or of the first known product
+	ownerTable, ownerID := "", 0
+	if req.SupplierID != nil {
+		ownerTable, ownerID = "suppliers", *req.SupplierID
+	} else {
+		for _, line := range req.Lines {
+			if line.ProductID != nil {
+				ownerTable, ownerID = "warehouse_product", *line.ProductID
+				break
+			}
+		}
+	}
+	var tenantID *int
+	var err error
+	if ownerTable != "" {
+		tenantID, err = ownerTenant(c, tx, ownerTable, ownerID)
+	} else {
+		tenantID, err = requireTenant(c, tx)
+	}
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: unknown supplier or product", errInvalidReceipt)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	receiptDate := time.Now()
+	if req.ReceiptDate != "" {
+		d, err := time.Parse("2006-01-02", req.ReceiptDate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid receipt date", errInvalidReceipt)
+		}
+		receiptDate = d
+	}
+
+	supplierName := req.SupplierName
+	if req.SupplierID != nil {
+		err := tx.QueryRow(`SELECT name FROM suppliers WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2)`,
+			*req.SupplierID, tenantID).Scan(&supplierName)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: unknown supplier", errInvalidReceipt)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	warehouseID := req.WarehouseID
+	if pinned := middleware.PinnedWarehouseID(c); pinned != nil {
+		warehouseID = pinned
+	}
+
+	r, err := scanGoodsReceipt(tx.QueryRow(`
+		INSERT INTO goods_receipts (document_number, receipt_date, supplier_id, supplier_name, po_number, warehouse_id,
+		                            source, notes, created_by, tenant_id)
+		VALUES (COALESCE(NULLIF($1, ''), 'GR-' || LPAD(nextval('goods_receipt_number_seq')::TEXT, 6, '0')),
+		        $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+goodsReceiptColumns,
+		req.DocumentNumber, receiptDate, req.SupplierID, supplierName, req.PONumber, warehouseID,
+		source, req.Notes, middleware.CurrentUserID(c), tenantID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: document number already exists or invalid data", errInvalidReceipt)
+	}
+	if err := recordGoodsReceiptStatus(tx, r.ID, "", r.Status, middleware.CurrentUserID(c)); err != nil {
+		return nil, err
+	}
+
+	for _, lineReq := range req.Lines {
+		line, err := h.insertGoodsReceiptLine(tx, c, r, lineReq)
+		if err != nil {
+			return nil, err
+		}
+		r.Lines = append(r.Lines, *line)
+	}
+
+	return r, nil
+}
+
+// insertGoodsReceiptLine adds a line, resolving the product by id or SKU.
+// Lines for products not yet in master data keep their free-text name; they
+// are matched again on completion or linked by hand before then.
+func (h *Handler) insertGoodsReceiptLine(tx *sql.Tx, c *gin.Context, r *models.GoodsReceipt, req models.GoodsReceiptLineRequest) (*models.GoodsReceiptLine, error) {
+	if req.Quantity <= 0 {
+		return nil, fmt.Errorf("%w: quantity must be positive", errInvalidReceipt)
+	}
+
+	productID, sku, name := req.ProductID, req.SKU, req.ProductName
+	if req.ProductID != nil || req.SKU != "" {
+		var id int
+		var productSKU, productName string
+		err := tx.QueryRow(`
+			SELECT id, sku, name FROM warehouse_product
+			WHERE (id = $1 OR ($1 = 0 AND sku = $2)) AND tenant_id IS NOT DISTINCT FROM $3`,
+			intValue(req.ProductID), req.SKU, r.TenantID).Scan(&id, &productSKU, &productName)
+		switch {
+		case err == sql.ErrNoRows && req.ProductID != nil:
+			return nil, fmt.Errorf("%w: unknown product", errInvalidReceipt)
+		case err == nil:
+			productID, sku = &id, productSKU
+			if name == "" {
+				name = productName
+			}
+		case err != sql.ErrNoRows:
+			return nil, err
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%w: product_name or a known product is required", errInvalidReceipt)
+	}
+
+	var expiry *time.Time
+	if req.ExpiryDate != "" {
+		d, err := time.Parse("2006-01-02", req.ExpiryDate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid expiry date", errInvalidReceipt)
+		}
+		expiry = &d
+	}
+
+	unitName := req.UnitName
+	if req.UnitID != nil {
+		err := tx.QueryRow(`SELECT symbol FROM units WHERE id = $1`, *req.UnitID).Scan(&unitName)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: unknown unit", errInvalidReceipt)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.LocationID != nil {
+		if err := h.checkReceiptLocation(tx, c, r, *req.LocationID); err != nil {
+			return nil, err
+		}
+	}
+
+	line, err := scanGoodsReceiptLine(tx.QueryRow(`
+		INSERT INTO goods_receipt_lines (receipt_id, product_id, sku, product_name, quantity, unit_id, unit_name,
+		                                 batch, expiry_date, location_id, location_name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING `+goodsReceiptLineColumns,
+		r.ID, productID, sku, name, req.Quantity, req.UnitID, unitName,
+		req.Batch, expiry, req.LocationID, req.LocationName))
+	if err != nil {
+		return nil, err
+	}
+	return line, nil
+}
+
+// lockGoodsReceipt loads a receipt visible to the request and locks it for
+// the rest of the transaction so concurrent transitions serialize.
+func (h *Handler) lockGoodsReceipt(tx *sql.Tx, c *gin.Context, id int) (*models.GoodsReceipt, error) {
+	r, err := scanGoodsReceipt(tx.QueryRow(`
+		SELECT `+goodsReceiptColumns+` FROM goods_receipts
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2) AND ($3::int IS NULL OR warehouse_id = $3)
+		FOR UPDATE`,
+		id, middleware.TenantID(c), middleware.PinnedWarehouseID(c)))
+	if err == sql.ErrNoRows {
+		return nil, errReceiptNotFound
+	}
+	return r, err
+}
+
+func canTransitionGoodsReceipt(from, to string) bool {
+	for _, next := range goodsReceiptTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionGoodsReceipt checks the preconditions for moving r to status to,
+// runs its side effects and updates r in place.
+func transitionGoodsReceipt(tx *sql.Tx, r *models.GoodsReceipt, to string, userID int) error {
+	if !canTransitionGoodsReceipt(r.Status, to) {
+		return fmt.Errorf("%w: %s to %s", errIllegalTransition, r.Status, to)
+	}
+
+	switch to {
+	case models.GoodsReceiptReceived:
+		var lines int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM goods_receipt_lines WHERE receipt_id = $1`, r.ID).Scan(&lines); err != nil {
+			return err
+		}
+		if lines == 0 {
+			return fmt.Errorf("%w: receipt has no lines", errReceiptIncomplete)
+		}
+	case models.GoodsReceiptPutaway:
+		var pending int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM goods_receipt_lines WHERE receipt_id = $1 AND qc_status = $2`,
+			r.ID, models.QCPending).Scan(&pending)
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%w: %d line(s) still await a QC verdict", errReceiptIncomplete, pending)
+		}
+	case models.GoodsReceiptCompleted:
+		if err := postGoodsReceipt(tx, r, userID); err != nil {
+			return err
+		}
+	}
+
+	err := tx.QueryRow(`
+		UPDATE goods_receipts
+		SET status = $1, updated_at = NOW(), completed_at = CASE WHEN $1 = 'completed' THEN NOW() ELSE completed_at END
+		WHERE id = $2
+		RETURNING updated_at, completed_at`, to, r.ID).Scan(&r.UpdatedAt, &r.CompletedAt)
+	if err != nil {
+		return err
+	}
+	if err := recordGoodsReceiptStatus(tx, r.ID, r.Status, to, userID); err != nil {
+		return err
+	}
+	r.Status = to
+	return nil
+}
+
+// recordGoodsReceiptStatus appends to the status history. from is empty for
+// a newly created receipt.
+func recordGoodsReceiptStatus(tx *sql.Tx, receiptID int, from, to string, userID int) error {
+	_, err := tx.Exec(`
+		INSERT INTO goods_receipt_status_history (receipt_id, from_status, to_status, changed_by, changed_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, NOW())`, receiptID, from, to, userID)
+	return err
+}
+
+// advanceGoodsReceipt walks r along the happy path until it reaches to.
+// The legacy endpoints use it to keep their one-call semantics.
+func advanceGoodsReceipt(tx *sql.Tx, r *models.GoodsReceipt, to string, userID int) error {
+	steps, err := goodsReceiptPath(r.Status, to)
+	if err != nil {
+		return err
+	}
+	for _, next := range steps {
+		if err := transitionGoodsReceipt(tx, r, next, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// goodsReceiptPath lists the statuses a receipt passes through on its way
+// from one status to another along goodsReceiptFlow. A target off the flow,
+// such as cancelled, is a single step that transitionGoodsReceipt checks.
+func goodsReceiptPath(from, to string) ([]string, error) {
+	target := goodsReceiptStage(to)
+	if target < 0 {
+		return []string{to}, nil
+	}
+	current := goodsReceiptStage(from)
+	if current < 0 || current > target {
+		return nil, fmt.Errorf("%w: %s to %s", errIllegalTransition, from, to)
+	}
+	return goodsReceiptFlow[current+1 : target+1], nil
+}
+
+// goodsReceiptStage is the position of status in goodsReceiptFlow, or -1
+// for a status off it.
+func goodsReceiptStage(status string) int {
+	for i, s := range goodsReceiptFlow {
+		if s == status {
+			return i
+		}
+	}
+	return -1
+}
+
+type receiptPosting struct {
+	lineID     int
+	productID  sql.NullInt64
+	locationID sql.NullInt64
+	quantity   int
+	batch      string
+	expiry     sql.NullTime
+}
+
+// postGoodsReceipt books every accepted, not yet posted line into inventory
+// and the stock movement ledger, and parks rejected lines, and the units of
+// accepted lines that failed inspection, in quarantine or for return to the
+// supplier. posted_at makes a second run a no-op.
+func postGoodsReceipt(tx *sql.Tx, r *models.GoodsReceipt, userID int) error {
+	if err := linkGoodsReceiptProducts(tx, r); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO quarantine_stock (receipt_id, receipt_line_id, product_id, product_name, quantity, batch, expiry_date,
+		                              supplier_id, supplier_name, warehouse_id, disposition, reason, created_by, tenant_id)
+		SELECT l.receipt_id, l.id, l.product_id, l.product_name, CASE WHEN l.qc_status = $8 THEN l.quantity ELSE l.rejected_quantity END,
+		       l.batch, l.expiry_date, $2, $3, $4, COALESCE(l.disposition, $5), l.qc_notes, $6, $7
+		FROM goods_receipt_lines l
+		WHERE l.receipt_id = $1 AND (l.qc_status = $8 OR l.rejected_quantity IS NOT NULL) AND l.posted_at IS NULL`,
+		r.ID, r.SupplierID, r.SupplierName, r.WarehouseID, models.DispositionQuarantine, userID, r.TenantID, models.QCRejected)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, product_id, location_id, quantity - COALESCE(rejected_quantity, 0), batch, expiry_date FROM goods_receipt_lines
+		WHERE receipt_id = $1 AND qc_status = $2 AND posted_at IS NULL
+		ORDER BY id
+		FOR UPDATE`, r.ID, models.QCAccepted)
+	if err != nil {
+		return err
+	}
+	var postings []receiptPosting
+	for rows.Next() {
+		var p receiptPosting
+		if err := rows.Scan(&p.lineID, &p.productID, &p.locationID, &p.quantity, &p.batch, &p.expiry); err != nil {
+			rows.Close()
+			return err
+		}
+		postings = append(postings, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range postings {
+		if !p.productID.Valid {
+			return fmt.Errorf("%w: line %d is not linked to a product", errReceiptIncomplete, p.lineID)
+		}
+		if !p.locationID.Valid {
+			return fmt.Errorf("%w: line %d has no put-away location", errReceiptIncomplete, p.lineID)
+		}
+		if err := ensureStockNotFrozen(tx, int(p.productID.Int64), int(p.locationID.Int64)); err != nil {
+			return err
+		}
+
+		var expiry *time.Time
+		if p.expiry.Valid {
+			expiry = &p.expiry.Time
+		}
+		err := applyStockMovement(tx, stockMovement{
+			ProductID:     int(p.productID.Int64),
+			LocationID:    int(p.locationID.Int64),
+			Type:          models.MovementIn,
+			Quantity:      p.quantity,
+			Reference:     r.DocumentNumber,
+			ReferenceType: models.RefGoodsReceipt,
+			Batch:         p.batch,
+			ExpiryDate:    expiry,
+			UserID:        userID,
+			TenantID:      r.TenantID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`UPDATE goods_receipt_lines SET posted_at = NOW() WHERE id = $1`, p.lineID); err != nil {
+			return err
+		}
+
+		// Goods received onto a dock still have to be put away
+		var locationType string
+		if err := tx.QueryRow(`SELECT location_type FROM locations WHERE id = $1`, p.locationID.Int64).Scan(&locationType); err != nil {
+			return err
+		}
+		if locationType == models.LocationDock {
+			lineID := p.lineID
+			_, err := createPutawayTask(tx, putawayTask{
+				WarehouseID:    r.WarehouseID,
+				ProductID:      int(p.productID.Int64),
+				Quantity:       p.quantity,
+				Batch:          p.batch,
+				ExpiryDate:     expiry,
+				FromLocationID: int(p.locationID.Int64),
+				Reference:      r.DocumentNumber,
+				ReceiptLineID:  &lineID,
+				UserID:         userID,
+				TenantID:       r.TenantID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE goods_receipt_lines SET posted_at = NOW() WHERE receipt_id = $1 AND qc_status = $2 AND posted_at IS NULL`,
+		r.ID, models.QCRejected)
+	return err
+}
+
+// setGoodsReceiptLineQC records a verdict on one line. The first verdict on
+// a received receipt moves it into QC.
+func setGoodsReceiptLineQC(tx *sql.Tx, r *models.GoodsReceipt, lineID int, verdict, notes string, userID int) error {
+	if verdict != models.QCAccepted && verdict != models.QCRejected && verdict != models.QCPending {
+		return fmt.Errorf("%w: qc_status must be accepted, rejected or pending", errInvalidReceipt)
+	}
+	if r.Status == models.GoodsReceiptReceived {
+		if err := transitionGoodsReceipt(tx, r, models.GoodsReceiptQC, userID); err != nil {
+			return err
+		}
+	}
+	if r.Status != models.GoodsReceiptQC {
+		return fmt.Errorf("%w: QC is only possible on received receipts", errReceiptNotEditable)
+	}
+
+	result, err := tx.Exec(`
+		UPDATE goods_receipt_lines SET qc_status = $1, qc_notes = $2, qc_by = $3, qc_at = NOW(), rejected_quantity = NULL
+		WHERE id = $4 AND receipt_id = $5`, verdict, notes, userID, lineID, r.ID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errReceiptLineNotFound
+	}
+	return nil
+}
+
+// setGoodsReceiptDisposition decides where rejected stock goes once the
+// receipt is completed. Lines that only partly failed inspection take one
+// for their rejected units. lineID 0 applies it to every such line that has
+// no disposition yet.
+func setGoodsReceiptDisposition(tx *sql.Tx, r *models.GoodsReceipt, lineID int, disposition string) error {
+	if disposition != models.DispositionQuarantine && disposition != models.DispositionReturnToSupplier {
+		return fmt.Errorf("%w: disposition must be quarantine or return_to_supplier", errInvalidReceipt)
+	}
+	if r.Status == models.GoodsReceiptCompleted || r.Status == models.GoodsReceiptCancelled {
+		return fmt.Errorf("%w: rejected stock has already been routed", errReceiptNotEditable)
+	}
+
+	if lineID == 0 {
+		_, err := tx.Exec(`
+			UPDATE goods_receipt_lines SET disposition = $1
+			WHERE receipt_id = $2 AND (qc_status = $3 OR rejected_quantity IS NOT NULL) AND disposition IS NULL`,
+			disposition, r.ID, models.QCRejected)
+		return err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE goods_receipt_lines SET disposition = $1
+		WHERE id = $2 AND receipt_id = $3 AND (qc_status = $4 OR rejected_quantity IS NOT NULL)`,
+		disposition, lineID, r.ID, models.QCRejected)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: only lines with rejected units take a disposition", errInvalidReceipt)
+	}
+	return nil
+}
+
+// linkGoodsReceiptProducts matches lines that were received as free text
+// to a product of the receipt's tenant, by SKU first and then by name.
+// Lines without a match are left alone.
+func linkGoodsReceiptProducts(tx *sql.Tx, r *models.GoodsReceipt) error {
+	_, err := tx.Exec(`
+		UPDATE goods_receipt_lines l
+		SET product_id = p.id, sku = p.sku
+		FROM warehouse_product p
+		WHERE l.receipt_id = $1 AND l.product_id IS NULL
+		  AND p.id = (SELECT id FROM warehouse_product
+		              WHERE ((l.sku <> '' AND sku = l.sku) OR LOWER(name) = LOWER(l.product_name))
+		                AND tenant_id IS NOT DISTINCT FROM $2
+		              ORDER BY sku = l.sku DESC, id LIMIT 1)`, r.ID, r.TenantID)
+	return err
+}
+
+// setGoodsReceiptLineProduct links a line to a product of the receipt's
+// tenant, for free-text lines that no product could be matched to.
+func setGoodsReceiptLineProduct(tx *sql.Tx, r *models.GoodsReceipt, lineID, productID int) error {
+	if r.Status == models.GoodsReceiptCompleted || r.Status == models.GoodsReceiptCancelled {
+		return fmt.Errorf("%w: products can only be linked before completion", errReceiptNotEditable)
+	}
+
+	var sku string
+	err := tx.QueryRow(`SELECT sku FROM warehouse_product WHERE id = $1 AND tenant_id IS NOT DISTINCT FROM $2`,
+		productID, r.TenantID).Scan(&sku)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: unknown product", errInvalidReceipt)
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`UPDATE goods_receipt_lines SET product_id = $1, sku = $2 WHERE id = $3 AND receipt_id = $4 AND posted_at IS NULL`,
+		productID, sku, lineID, r.ID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errReceiptLineNotFound
+	}
+	return nil
+}
+
+func (h *Handler) setGoodsReceiptLineLocation(tx *sql.Tx, c *gin.Context, r *models.GoodsReceipt, lineID, locationID int) error {
+	switch r.Status {
+	case models.GoodsReceiptReceived, models.GoodsReceiptQC, models.GoodsReceiptPutaway:
+	default:
+		return fmt.Errorf("%w: put-away locations can only be set before completion", errReceiptNotEditable)
+	}
+	if err := h.checkReceiptLocation(tx, c, r, locationID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`UPDATE goods_receipt_lines SET location_id = $1 WHERE id = $2 AND receipt_id = $3 AND posted_at IS NULL`,
+		locationID, lineID, r.ID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errReceiptLineNotFound
+	}
+	return nil
+}
+
+// checkReceiptLocation makes sure a put-away location is reachable by the
+// user and in the receipt's warehouse. A receipt without a warehouse takes
+// the site of its first location.
+func (h *Handler) checkReceiptLocation(tx *sql.Tx, c *gin.Context, r *models.GoodsReceipt, locationID int) error {
+	warehouseID, err := h.locationWarehouse(c, locationID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: unknown location", errInvalidReceipt)
+	}
+	if err != nil {
+		return err
+	}
+
+	if r.WarehouseID == nil && warehouseID != nil {
+		if _, err := tx.Exec(`UPDATE goods_receipts SET warehouse_id = $1 WHERE id = $2`, *warehouseID, r.ID); err != nil {
+			return err
+		}
+		r.WarehouseID = warehouseID
+	}
+	if r.WarehouseID != nil && (warehouseID == nil || *warehouseID != *r.WarehouseID) {
+		return errLocationWrongSite
+	}
+	return nil
+}
+
+func goodsReceiptLines(q dbtx, receiptID int) ([]models.GoodsReceiptLine, error) {
+	rows, err := q.Query(`SELECT `+goodsReceiptLineColumns+` FROM goods_receipt_lines WHERE receipt_id = $1 ORDER BY id`, receiptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []models.GoodsReceiptLine
+	for rows.Next() {
+		line, err := scanGoodsReceiptLine(rows)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, *line)
+	}
+	return lines, rows.Err()
+}
+
+func scanGoodsReceipt(row scanner) (*models.GoodsReceipt, error) {
+	var r models.GoodsReceipt
+	var supplierID, warehouseID, createdBy, tenantID sql.NullInt64
+	var completedAt sql.NullTime
+	err := row.Scan(&r.ID, &r.DocumentNumber, &r.ReceiptDate, &supplierID, &r.SupplierName, &r.PONumber, &warehouseID,
+		&r.Status, &r.Source, &r.Notes, &createdBy, &tenantID, &r.CreatedAt, &r.UpdatedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	r.SupplierID = nullableInt(supplierID)
+	r.WarehouseID = nullableInt(warehouseID)
+	r.CreatedBy = nullableInt(createdBy)
+	r.TenantID = nullableInt(tenantID)
+	if completedAt.Valid {
+		r.CompletedAt = &completedAt.Time
+	}
+	return &r, nil
+}
+
+func scanGoodsReceiptLine(row scanner) (*models.GoodsReceiptLine, error) {
+	var l models.GoodsReceiptLine
+	var productID, unitID, locationID, rejected, qcBy sql.NullInt64
+	var expiry, qcAt, postedAt sql.NullTime
+	err := row.Scan(&l.ID, &l.ReceiptID, &productID, &l.SKU, &l.ProductName, &l.Quantity, &unitID, &l.UnitName, &l.Batch,
+		&expiry, &locationID, &l.LocationName, &l.QCStatus, &l.QCNotes, &l.Disposition, &rejected, &qcBy, &qcAt, &postedAt,
+		&l.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	l.ProductID = nullableInt(productID)
+	l.UnitID = nullableInt(unitID)
+	l.LocationID = nullableInt(locationID)
+	l.RejectedQuantity = nullableInt(rejected)
+	l.QCBy = nullableInt(qcBy)
+	if expiry.Valid {
+		l.ExpiryDate = &expiry.Time
+	}
+	if qcAt.Valid {
+		l.QCAt = &qcAt.Time
+	}
+	if postedAt.Valid {
+		l.PostedAt = &postedAt.Time
+	}
+	return &l, nil
+}
+
+// writeGoodsReceiptError maps domain errors to HTTP responses.
+func writeGoodsReceiptError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errReceiptNotFound), errors.Is(err, errReceiptLineNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidReceipt), errors.Is(err, errLocationWrongSite), errors.Is(err, errTenantRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errLocationNotAccessible):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, errIllegalTransition), errors.Is(err, errReceiptNotEditable), errors.Is(err, errReceiptIncomplete),
+		errors.Is(err, errStockFrozen):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process goods receipt"})
+	}
+}
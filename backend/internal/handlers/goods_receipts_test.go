@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+)
+
+func TestGoodsReceiptPath(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     []string
+		wantErr  error
+	}{
+		{from: models.GoodsReceiptDraft, to: models.GoodsReceiptReceived, want: []string{models.GoodsReceiptReceived}},
+		{from: models.GoodsReceiptDraft, to: models.GoodsReceiptQC, want: []string{models.GoodsReceiptReceived, models.GoodsReceiptQC}},
+		{from: models.GoodsReceiptReceived, to: models.GoodsReceiptCompleted,
+			want: []string{models.GoodsReceiptQC, models.GoodsReceiptPutaway, models.GoodsReceiptCompleted}},
+		{from: models.GoodsReceiptQC, to: models.GoodsReceiptQC, want: []string{}},
+		{from: models.GoodsReceiptDraft, to: models.GoodsReceiptCancelled, want: []string{models.GoodsReceiptCancelled}},
+		{from: models.GoodsReceiptPutaway, to: models.GoodsReceiptQC, wantErr: errIllegalTransition},
+		{from: models.GoodsReceiptCompleted, to: models.GoodsReceiptReceived, wantErr: errIllegalTransition},
+		{from: models.GoodsReceiptCancelled, to: models.GoodsReceiptCompleted, wantErr: errIllegalTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			got, err := goodsReceiptPath(tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("goodsReceiptPath() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("goodsReceiptPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeReceipt answers the statements transitionGoodsReceipt runs for one
+// receipt and records its status history.
+type fakeReceipt struct {
+	*fakeDB
+	lines   int
+	pending int
+	history []string
+}
+
+func newFakeReceipt(lines, pending int) *fakeReceipt {
+	f := &fakeReceipt{fakeDB: &fakeDB{}, lines: lines, pending: pending}
+	f.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(f.pending)}}}, nil
+	}, "SELECT COUNT(*) FROM goods_receipt_lines", "qc_status")
+	f.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(f.lines)}}}, nil
+	}, "SELECT COUNT(*) FROM goods_receipt_lines")
+	f.on(rows([]driver.Value{time.Now(), nil}), "UPDATE goods_receipts SET status")
+	f.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		f.history = append(f.history, fmt.Sprintf("%v>%v", st.Args[1], st.Args[2]))
+		return nil, nil
+	}, "INSERT INTO goods_receipt_status_history")
+	return f
+}
+
+// receiptRow is r as goodsReceiptColumns reads it.
+func receiptRow(r *models.GoodsReceipt) []driver.Value {
+	return []driver.Value{int64(r.ID), r.DocumentNumber, r.ReceiptDate, nullInt(r.SupplierID), r.SupplierName, r.PONumber,
+		nullInt(r.WarehouseID), r.Status, r.Source, r.Notes, nullInt(r.CreatedBy), nullInt(r.TenantID), r.CreatedAt, r.UpdatedAt, nil}
+}
+
+// onReceipt answers the locking lookup of r, filtered by the tenant and
+// warehouse in its arguments the way the query filters them.
+func onReceipt(db *fakeDB, r *models.GoodsReceipt) {
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[0]) != r.ID || !inScope(st.Args[1], r.TenantID) || !inScope(st.Args[2], r.WarehouseID) {
+			return nil, nil
+		}
+		return &dbtest.Result{Rows: [][]driver.Value{receiptRow(r)}}, nil
+	}, "FROM goods_receipts", "FOR UPDATE")
+}
+
+func TestAdvanceGoodsReceipt(t *testing.T) {
+	tests := []struct {
+		name        string
+		from, to    string
+		receipt     fakeReceipt
+		wantErr     error
+		wantStatus  string
+		wantHistory []string
+	}{
+		{
+			name: "walks every step", from: models.GoodsReceiptDraft, to: models.GoodsReceiptPutaway,
+			receipt:     fakeReceipt{lines: 2},
+			wantStatus:  models.GoodsReceiptPutaway,
+			wantHistory: []string{"draft>received", "received>qc", "qc>putaway"},
+		},
+		{
+			name: "already there", from: models.GoodsReceiptQC, to: models.GoodsReceiptQC,
+			receipt:    fakeReceipt{lines: 1},
+			wantStatus: models.GoodsReceiptQC,
+		},
+		{
+			name: "no lines to receive", from: models.GoodsReceiptDraft, to: models.GoodsReceiptQC,
+			wantErr:    errReceiptIncomplete,
+			wantStatus: models.GoodsReceiptDraft,
+		},
+		{
+			name: "stops at the step that fails", from: models.GoodsReceiptReceived, to: models.GoodsReceiptPutaway,
+			receipt:     fakeReceipt{lines: 3, pending: 1},
+			wantErr:     errReceiptIncomplete,
+			wantStatus:  models.GoodsReceiptQC,
+			wantHistory: []string{"received>qc"},
+		},
+		{
+			name: "never moves backwards", from: models.GoodsReceiptPutaway, to: models.GoodsReceiptReceived,
+			receipt:    fakeReceipt{lines: 1},
+			wantErr:    errIllegalTransition,
+			wantStatus: models.GoodsReceiptPutaway,
+		},
+		{
+			name: "cancel is a single step", from: models.GoodsReceiptReceived, to: models.GoodsReceiptCancelled,
+			wantStatus:  models.GoodsReceiptCancelled,
+			wantHistory: []string{"received>cancelled"},
+		},
+		{
+			name: "put-away stock cannot be cancelled", from: models.GoodsReceiptPutaway, to: models.GoodsReceiptCancelled,
+			wantErr:    errIllegalTransition,
+			wantStatus: models.GoodsReceiptPutaway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receipt := newFakeReceipt(tt.receipt.lines, tt.receipt.pending)
+			tx := beginTestTx(t, receipt.handle)
+			r := &models.GoodsReceipt{ID: 1, Status: tt.from}
+
+			err := advanceGoodsReceipt(tx, r, tt.to, 7)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("advanceGoodsReceipt() error = %v, want %v", err, tt.wantErr)
+			}
+			if r.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", r.Status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(receipt.history, tt.wantHistory) {
+				t.Errorf("history = %v, want %v", receipt.history, tt.wantHistory)
+			}
+		})
+	}
+}
+
+func TestSetGoodsReceiptLineProduct(t *testing.T) {
+	tenant, other := 3, 4
+	tests := []struct {
+		name      string
+		status    string
+		productID int
+		wantErr   error
+		wantSKU   driver.Value
+	}{
+		{name: "links the tenant's product", status: models.GoodsReceiptQC, productID: 1, wantSKU: "SKU-1"},
+		{name: "another tenant's product is unknown", status: models.GoodsReceiptQC, productID: 2, wantErr: errInvalidReceipt},
+		{name: "completed receipts are closed", status: models.GoodsReceiptCompleted, productID: 1, wantErr: errReceiptNotEditable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products := map[int]*int{1: &tenant, 2: &other}
+			db := &fakeDB{}
+			db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+				id := argInt(st.Args[0])
+				if owner, ok := products[id]; !ok || argInt(st.Args[1]) != *owner {
+					return nil, nil
+				}
+				return &dbtest.Result{Rows: [][]driver.Value{{fmt.Sprintf("SKU-%d", id)}}}, nil
+			}, "SELECT sku FROM warehouse_product")
+			var linked driver.Value
+			db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+				linked = st.Args[1]
+				return &dbtest.Result{RowsAffected: 1}, nil
+			}, "UPDATE goods_receipt_lines SET product_id")
+			tx := beginTestTx(t, db.handle)
+
+			err := setGoodsReceiptLineProduct(tx, &models.GoodsReceipt{ID: 1, Status: tt.status, TenantID: &tenant}, 11, tt.productID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("setGoodsReceiptLineProduct() error = %v, want %v", err, tt.wantErr)
+			}
+			if linked != tt.wantSKU {
+				t.Errorf("linked sku = %v, want %v", linked, tt.wantSKU)
+			}
+		})
+	}
+}
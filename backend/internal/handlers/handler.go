@@ -2,12 +2,102 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"wms-backend/internal/auth"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Tokens *auth.TokenManager
+	// SSCCPrefix is the GS1 company prefix of carton SSCCs
+	SSCCPrefix string
+	// Files holds attachment contents; uploads are refused when it is nil
+	Files              storage.Storage
+	AttachmentMaxBytes int64
+	DownloadURLTTL     time.Duration
+}
+
+func NewHandler(db *sql.DB, tokens *auth.TokenManager) *Handler {
+	return &Handler{DB: db, Tokens: tokens, AttachmentMaxBytes: 10 << 20, DownloadURLTTL: 15 * time.Minute}
+}
+
+// belongsToTenant reports whether the row with the given id in table is
+// visible to the request's tenant. table must be a trusted identifier.
+func (h *Handler) belongsToTenant(c *gin.Context, table string, id int) (bool, error) {
+	var exists bool
+	query := fmt.Sprintf(`SELECT EXISTS(SELECT 1 FROM %s WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2))`, table)
+	err := h.DB.QueryRow(query, id, middleware.TenantID(c)).Scan(&exists)
+	return exists, err
 }
 
-func NewHandler(db *sql.DB) *Handler {
-	return &Handler{DB: db}
-}
\ No newline at end of file
+var errTenantRequired = errors.New("X-Tenant-ID is required to say which tenant this is for")
+
+// ownerTenant is the tenant a new record is written for. Requests scoped to
+// a tenant write for it. Unscoped staff write for the tenant of the row with
+// the given id in table, the record the new one belongs to. table must be a
+// trusted identifier.
+func ownerTenant(c *gin.Context, q dbtx, table string, id int) (*int, error) {
+	if tenantID := middleware.TenantID(c); tenantID != nil {
+		return tenantID, nil
+	}
+	var tenantID sql.NullInt64
+	err := q.QueryRow(fmt.Sprintf(`SELECT tenant_id FROM %s WHERE id = $1`, table), id).Scan(&tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return nullableInt(tenantID), nil
+}
+
+// requireTenant is the tenant of a record that belongs to nothing its
+// tenant could be read from. Once tenants exist, unscoped staff have to
+// pick one with X-Tenant-ID.
+func requireTenant(c *gin.Context, q dbtx) (*int, error) {
+	if tenantID := middleware.TenantID(c); tenantID != nil {
+		return tenantID, nil
+	}
+	var exists bool
+	if err := q.QueryRow(`SELECT EXISTS(SELECT 1 FROM tenants)`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errTenantRequired
+	}
+	return nil, nil
+}
+
+// bindOptionalJSON binds a request body that may be left out. An empty body
+// leaves req as it is; a malformed one is answered with 400 and false.
+func bindOptionalJSON(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx.
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func intValue(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// beginTestTx opens a transaction on a database answered by h. It is
+// rolled back when the test ends.
+func beginTestTx(t *testing.T, h dbtest.Handler) *sql.Tx {
+	t.Helper()
+	db := dbtest.Open(h)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	t.Cleanup(func() {
+		tx.Rollback()
+		db.Close()
+	})
+	return tx
+}
+
+// fakeDB answers each statement with the first rule whose keywords all
+// appear in it. Matching a few distinctive words instead of the whole
+// query keeps the fakes working when a query is reflowed or gains a
+// column. committed is set once a transaction commits.
+type fakeDB struct {
+	rules     []fakeRule
+	committed bool
+}
+
+type fakeRule struct {
+	keywords []string
+	answer   dbtest.Handler
+}
+
+// on adds a rule. Rules are tried in the order they were added, so the
+// more specific of two overlapping rules goes first.
+func (db *fakeDB) on(answer dbtest.Handler, keywords ...string) {
+	db.rules = append(db.rules, fakeRule{keywords: keywords, answer: answer})
+}
+
+func (db *fakeDB) handle(st dbtest.Statement) (*dbtest.Result, error) {
+	switch st.Query {
+	case "BEGIN", "ROLLBACK":
+		return nil, nil
+	case "COMMIT":
+		db.committed = true
+		return nil, nil
+	}
+
+	query := strings.Join(strings.Fields(st.Query), " ")
+	for _, rule := range db.rules {
+		matched := true
+		for _, keyword := range rule.keywords {
+			matched = matched && strings.Contains(query, keyword)
+		}
+		if matched {
+			return rule.answer(st)
+		}
+	}
+	return nil, fmt.Errorf("unexpected statement: %s", query)
+}
+
+// open returns a database answered by db that is closed when the test ends.
+func (db *fakeDB) open(t *testing.T) *sql.DB {
+	conn := dbtest.Open(db.handle)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// rows answers a query with fixed rows.
+func rows(values ...[]driver.Value) dbtest.Handler {
+	return func(dbtest.Statement) (*dbtest.Result, error) {
+		return &dbtest.Result{Rows: values}, nil
+	}
+}
+
+// affected answers an exec with a fixed number of affected rows.
+func affected(n int64) dbtest.Handler {
+	return func(dbtest.Statement) (*dbtest.Result, error) {
+		return &dbtest.Result{RowsAffected: n}, nil
+	}
+}
+
+// argInt reads an integer argument; nil and other types read as 0.
+func argInt(v driver.Value) int {
+	n, _ := v.(int64)
+	return int(n)
+}
+
+// nullInt is the driver value of a nullable integer column.
+func nullInt(v *int) driver.Value {
+	if v == nil {
+		return nil
+	}
+	return int64(*v)
+}
+
+// inScope reports whether a row owned by owner passes a
+// "$n::int IS NULL OR column = $n" filter given arg.
+func inScope(arg driver.Value, owner *int) bool {
+	return arg == nil || owner != nil && argInt(arg) == *owner
+}
+
+// testContext is a request context scoped to tenantID, if any.
+func testContext(tenantID *int) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if tenantID != nil {
+		c.Set(middleware.TenantIDKey, *tenantID)
+	}
+	return c
+}
+
+// serveAs runs handler for req on behalf of claims, behind the tenant and
+// warehouse middleware the router puts in front of every handler. route
+// is the gin pattern that supplies the path parameters.
+func serveAs(claims *auth.Claims, route string, handler gin.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Handle(req.Method, route, func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, claims)
+		c.Set(middleware.UserIDKey, claims.UserID)
+	}, middleware.Tenant(), middleware.Warehouse(), handler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// jsonRequest builds a request with a JSON body; an empty body sends none.
+func jsonRequest(method, target, body string) *http.Request {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestOwnerTenant(t *testing.T) {
+	tenant, other := 3, 5
+	tests := []struct {
+		name   string
+		scope  *int
+		parent *int
+		want   *int
+	}{
+		{name: "scoped requests write for their tenant", scope: &tenant, parent: &other, want: &tenant},
+		{name: "unscoped staff write for the parent's tenant", parent: &other, want: &other},
+		{name: "parent without a tenant", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{}
+			db.on(rows([]driver.Value{nullInt(tt.parent)}), "SELECT tenant_id FROM orders")
+
+			got, err := ownerTenant(testContext(tt.scope), db.open(t), "orders", 1)
+			if err != nil {
+				t.Fatalf("ownerTenant() error = %v", err)
+			}
+			if nullInt(got) != nullInt(tt.want) {
+				t.Errorf("ownerTenant() = %v, want %v", nullInt(got), nullInt(tt.want))
+			}
+		})
+	}
+}
+
+func TestRequireTenant(t *testing.T) {
+	tenant := 3
+	tests := []struct {
+		name    string
+		scope   *int
+		tenants bool
+		want    *int
+		wantErr error
+	}{
+		{name: "scoped", scope: &tenant, tenants: true, want: &tenant},
+		{name: "unscoped staff must pick a tenant", tenants: true, wantErr: errTenantRequired},
+		{name: "no tenants yet"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{}
+			db.on(rows([]driver.Value{tt.tenants}), "FROM tenants")
+
+			got, err := requireTenant(testContext(tt.scope), db.open(t))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("requireTenant() error = %v, want %v", err, tt.wantErr)
+			}
+			if nullInt(got) != nullInt(tt.want) {
+				t.Errorf("requireTenant() = %v, want %v", nullInt(got), nullInt(tt.want))
+			}
+		})
+	}
+}
@@ -1,39 +1,58 @@
 package handlers
 
 import (
+	"database/sql"
 	"net/http"
+	"time"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
 	"github.com/gin-gonic/gin"
 )
 
 func (h *Handler) GetInventoryData(c *gin.Context) {
-	rows, err := h.DB.Query(`SELECT id, product_name, category, quantity, location, updated_at FROM inventory WHERE quantity > 0 ORDER BY product_name`)
+	rows, err := h.DB.Query(`
+		SELECT i.id, p.id, p.name, p.sku, COALESCE(cat.name, ''), i.quantity, i.reserved_quantity, i.quarantined_quantity, i.min_stock,
+		       l.id, l.code, i.batch, i.expiry_date, i.updated_at
+		FROM inventory i
+		JOIN warehouse_product p ON i.product_id = p.id
+		JOIN locations l ON i.location_id = l.id
+		LEFT JOIN warehouse_category cat ON p.category_id = cat.id
+		WHERE i.quantity > 0
+		  AND ($1::int IS NULL OR i.tenant_id = $1)
+		  AND ($2::int IS NULL OR l.warehouse_id = $2)
+		ORDER BY p.name, l.code, i.expiry_date NULLS LAST, i.batch`, middleware.TenantID(c), middleware.WarehouseID(c))
 	if err != nil {
-		// Fallback to static data if DB fails
-		inventory := []map[string]interface{}{
-			{"id": 1, "product_name": "Laptop Dell", "category": "Electronics", "quantity": 50, "location": "A-01", "updated_at": "2025-09-10T04:37:24Z"},
-			{"id": 2, "product_name": "Mouse Wireless", "category": "Electronics", "quantity": 100, "location": "A-02", "updated_at": "2025-09-10T04:37:24Z"},
-			{"id": 3, "product_name": "Kertas A4", "category": "Office Supplies", "quantity": 200, "location": "B-01", "updated_at": "2025-09-10T04:37:24Z"},
-		}
-		c.JSON(http.StatusOK, inventory)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory"})
 		return
 	}
 	defer rows.Close()
 
 	var inventory []map[string]interface{}
 	for rows.Next() {
-		var id, quantity int
-		var productName, category, location, updatedAt string
+		var id, productID, quantity, reserved, quarantined, minStock, locationID int
+		var productName, sku, category, location, batch, updatedAt string
+		var expiry sql.NullTime
 
-		if err := rows.Scan(&id, &productName, &category, &quantity, &location, &updatedAt); err != nil {
+		if err := rows.Scan(&id, &productID, &productName, &sku, &category, &quantity, &reserved, &quarantined, &minStock, &locationID, &location, &batch, &expiry, &updatedAt); err != nil {
 			continue
 		}
 
 		inventory = append(inventory, map[string]interface{}{
 			"id":           id,
+			"product_id":   productID,
 			"product_name": productName,
+			"sku":          sku,
 			"category":     category,
 			"quantity":     quantity,
+			"reserved":     reserved,
+			"quarantined":  quarantined,
+			"available":    quantity - reserved - quarantined,
+			"min_stock":    minStock,
+			"location_id":  locationID,
 			"location":     location,
+			"batch":        batch,
+			"expiry_date":  nullableTime(expiry),
 			"updated_at":   updatedAt,
 		})
 	}
@@ -41,50 +60,125 @@ func (h *Handler) GetInventoryData(c *gin.Context) {
 	c.JSON(http.StatusOK, inventory)
 }
 
+// CreateInventoryItem adds stock for a product at a location, optionally as
+// a lot with an expiry date. Older clients send product_name and a location
+// code or name instead of ids.
 func (h *Handler) CreateInventoryItem(c *gin.Context) {
 	var item struct {
+		ProductID   int    `json:"product_id"`
 		ProductName string `json:"product_name"`
-		Category    string `json:"category"`
-		Quantity    int    `json:"quantity"`
+		LocationID  int    `json:"location_id"`
 		Location    string `json:"location"`
+		Quantity    int    `json:"quantity"`
 		MinStock    int    `json:"min_stock"`
+		Batch       string `json:"batch"`
+		ExpiryDate  string `json:"expiry_date"`
 	}
 
 	if err := c.ShouldBindJSON(&item); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
 		return
 	}
-
-	// Check if item already exists
-	var existingID int
-	err := h.DB.QueryRow(
-		"SELECT id FROM inventory WHERE product_name = $1 AND category = $2",
-		item.ProductName, item.Category,
-	).Scan(&existingID)
-
-	if err == nil {
-		// Item exists, update quantity
-		_, err = h.DB.Exec(
-			"UPDATE inventory SET quantity = quantity + $1, updated_at = NOW() WHERE id = $2",
-			item.Quantity, existingID,
-		)
+	if item.Quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+		return
+	}
+	var expiry *time.Time
+	if item.ExpiryDate != "" {
+		d, err := time.Parse("2006-01-02", item.ExpiryDate)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiry_date must be YYYY-MM-DD"})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Inventory updated", "id": existingID})
-	} else {
-		// Item doesn't exist, create new
-		var newID int
-		err = h.DB.QueryRow(
-			"INSERT INTO inventory (product_name, category, quantity, location, min_stock, updated_at) VALUES ($1, $2, $3, $4, $5, NOW()) RETURNING id",
-			item.ProductName, item.Category, item.Quantity, item.Location, item.MinStock,
-		).Scan(&newID)
+		expiry = &d
+	}
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inventory item"})
-			return
-		}
-		c.JSON(http.StatusCreated, gin.H{"message": "Inventory item created", "id": newID})
+	tenantID := middleware.TenantID(c)
+
+	productID := item.ProductID
+	err := h.DB.QueryRow(`
+		SELECT id FROM warehouse_product
+		WHERE (id = $1 OR ($1 = 0 AND (LOWER(name) = LOWER($2) OR sku = $2)))
+		  AND ($3::int IS NULL OR tenant_id = $3)
+		ORDER BY id LIMIT 1`, item.ProductID, item.ProductName, tenantID).Scan(&productID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown product"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up product"})
+		return
+	}
+
+	// Fall back to the first location of the site when none matches
+	locationID := item.LocationID
+	err = h.DB.QueryRow(`
+		SELECT id FROM locations
+		WHERE ($1::int IS NULL OR warehouse_id = $1)
+		ORDER BY (id = $2) DESC, (code = $3 OR LOWER(name) = LOWER($3)) DESC, id
+		LIMIT 1`, middleware.WarehouseID(c), item.LocationID, item.Location).Scan(&locationID)
+	if err == sql.ErrNoRows || (item.LocationID != 0 && locationID != item.LocationID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown location"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up location"})
+		return
 	}
-}
\ No newline at end of file
+	if _, err := h.locationWarehouse(c, locationID); err == errLocationNotAccessible {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := ensureStockNotFrozen(tx, productID, locationID); err == errStockFrozen {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check open counts"})
+		return
+	}
+
+	err = applyStockMovement(tx, stockMovement{
+		ProductID:     productID,
+		LocationID:    locationID,
+		Type:          models.MovementIn,
+		Quantity:      item.Quantity,
+		Reference:     "INVENTORY",
+		ReferenceType: models.RefInventory,
+		Batch:         item.Batch,
+		ExpiryDate:    expiry,
+		UserID:        middleware.CurrentUserID(c),
+		TenantID:      tenantID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
+		return
+	}
+
+	// min_stock only seeds balances that have none yet
+	var inventoryID int
+	err = tx.QueryRow(`
+		UPDATE inventory SET min_stock = CASE WHEN min_stock = 0 THEN $3 ELSE min_stock END
+		WHERE product_id = $1 AND location_id = $2 AND batch = $4
+		RETURNING id`,
+		productID, locationID, item.MinStock, item.Batch,
+	).Scan(&inventoryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Inventory updated", "id": inventoryID})
+}
@@ -0,0 +1,520 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var (
+	errLocationNotFound = errors.New("location not found")
+	errInvalidLocation  = errors.New("invalid location")
+	errLocationInUse    = errors.New("location is in use")
+)
+
+// maxLocationRange caps how many bins one range request may create.
+const maxLocationRange = 5000
+
+// locationRank orders the storage tree. A parent must rank below its
+// children; docks, staging and returns areas may only sit under a zone.
+var locationRank = map[string]int{
+	models.LocationZone:    1,
+	models.LocationAisle:   2,
+	models.LocationRack:    3,
+	models.LocationLevel:   4,
+	models.LocationBin:     5,
+	models.LocationDock:    2,
+	models.LocationStaging: 2,
+	models.LocationReturns: 2,
+}
+
+// locationColumns counts the units on hand of the tenant in $1, or of all
+// tenants when it is NULL.
+const locationColumns = `l.id, l.warehouse_id, l.parent_id, COALESCE(p.code, ''), l.name, l.code, l.location_type,
+	COALESCE(l.description, ''), l.category_id, l.pick_sequence, l.max_units, l.max_weight, l.max_volume, l.temperature_controlled, l.hazmat,
+	l.is_active, COALESCE((SELECT SUM(i.quantity) FROM inventory i
+	           WHERE i.location_id = l.id AND ($1::int IS NULL OR i.tenant_id = $1)), 0),
+	l.created_at, COALESCE(l.updated_at, l.created_at)`
+
+const locationFrom = ` FROM locations l LEFT JOIN locations p ON l.parent_id = p.id`
+
+// GetLocations lists locations of the current site. Filters: location_type,
+// parent_id (0 for top level) and active.
+func (h *Handler) GetLocations(c *gin.Context) {
+	parentID := -1
+	if v := c.Query("parent_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent_id"})
+			return
+		}
+		parentID = id
+	}
+
+	rows, err := h.DB.Query(`SELECT `+locationColumns+locationFrom+`
+		WHERE ($2::int IS NULL OR l.warehouse_id = $2)
+		  AND ($3 = '' OR l.location_type = $3)
+		  AND ($4 < 0 OR ($4 = 0 AND l.parent_id IS NULL) OR l.parent_id = $4)
+		  AND ($5 = '' OR l.is_active = ($5 = 'true'))
+		ORDER BY l.code`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("location_type"), parentID, c.Query("active"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch locations"})
+		return
+	}
+	defer rows.Close()
+
+	var locations []models.Location
+	for rows.Next() {
+		l, err := scanLocation(rows)
+		if err != nil {
+			continue
+		}
+		locations = append(locations, *l)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": locations})
+}
+
+func (h *Handler) GetLocation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	l, err := h.findLocation(c, h.DB, id, middleware.TenantID(c))
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, l)
+}
+
+func (h *Handler) CreateLocation(c *gin.Context) {
+	var req models.LocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warehouseID, err := h.locationTarget(c, h.DB, req.WarehouseID, req.ParentID, req.Type, 0)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = req.Code
+	}
+	active := req.IsActive == nil || *req.IsActive
+
+	var id int
+	err = h.DB.QueryRow(`
+		INSERT INTO locations (warehouse_id, parent_id, name, code, location_type, description, category_id,
+			pick_sequence, max_units, max_weight, max_volume, temperature_controlled, hazmat, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id`,
+		warehouseID, req.ParentID, name, req.Code, req.Type, req.Description, req.CategoryID,
+		req.PickSequence, req.MaxUnits, req.MaxWeight, req.MaxVolume, req.TemperatureControlled, req.Hazmat, active).Scan(&id)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+
+	l, err := h.findLocation(c, h.DB, id, nil)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, l)
+}
+
+// CreateLocationRange creates every location between from_code and to_code
+// under one parent. Codes that already exist are skipped.
+func (h *Handler) CreateLocationRange(c *gin.Context) {
+	var req models.LocationRangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	separator := req.Separator
+	if separator == "" {
+		separator = "-"
+	}
+	codes, err := expandLocationRange(req.FromCode, req.ToCode, separator)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+
+	warehouseID, err := h.locationTarget(c, h.DB, req.WarehouseID, req.ParentID, req.Type, 0)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	created := 0
+	for i, code := range codes {
+		var pickSequence *int
+		if req.PickSequenceStart != nil {
+			seq := *req.PickSequenceStart + i
+			pickSequence = &seq
+		}
+		result, err := tx.Exec(`
+			INSERT INTO locations (warehouse_id, parent_id, name, code, location_type, category_id, pick_sequence,
+				max_units, max_weight, max_volume, temperature_controlled, hazmat)
+			VALUES ($1, $2, $3, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (warehouse_id, code) DO NOTHING`,
+			warehouseID, req.ParentID, code, req.Type, req.CategoryID, pickSequence,
+			req.MaxUnits, req.MaxWeight, req.MaxVolume, req.TemperatureControlled, req.Hazmat)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create locations"})
+			return
+		}
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			created++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"created": created,
+		"skipped": len(codes) - created,
+		"first":   codes[0],
+		"last":    codes[len(codes)-1],
+	})
+}
+
+func (h *Handler) UpdateLocation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req models.LocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := h.findLocation(c, tx, id, nil)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+	if req.WarehouseID != nil && !sameWarehouse(req.WarehouseID, current.WarehouseID) {
+		writeLocationError(c, fmt.Errorf("%w: locations cannot move between warehouses", errInvalidLocation))
+		return
+	}
+	if _, err := h.locationTarget(c, tx, current.WarehouseID, req.ParentID, req.Type, id); err != nil {
+		writeLocationError(c, err)
+		return
+	}
+
+	active := req.IsActive == nil || *req.IsActive
+	if !active && current.UnitsOnHand > 0 {
+		writeLocationError(c, fmt.Errorf("%w: %s still holds %d units", errLocationInUse, current.Code, current.UnitsOnHand))
+		return
+	}
+	name := req.Name
+	if name == "" {
+		name = req.Code
+	}
+
+	_, err = tx.Exec(`
+		UPDATE locations SET parent_id = $1, name = $2, code = $3, location_type = $4, description = $5,
+			category_id = $6, pick_sequence = $7, max_units = $8, max_weight = $9, max_volume = $10,
+			temperature_controlled = $11, hazmat = $12, is_active = $13, updated_at = NOW()
+		WHERE id = $14`,
+		req.ParentID, name, req.Code, req.Type, req.Description, req.CategoryID, req.PickSequence,
+		req.MaxUnits, req.MaxWeight, req.MaxVolume, req.TemperatureControlled, req.Hazmat, active, id)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+
+	l, err := h.findLocation(c, tx, id, nil)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, l)
+}
+
+// DeleteLocation removes an empty leaf location. Locations that appear in
+// stock history can only be deactivated.
+func (h *Handler) DeleteLocation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	l, err := h.findLocation(c, h.DB, id, nil)
+	if err != nil {
+		writeLocationError(c, err)
+		return
+	}
+
+	var hasChildren bool
+	if err := h.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM locations WHERE parent_id = $1)`, id).Scan(&hasChildren); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check location usage"})
+		return
+	}
+	if hasChildren {
+		writeLocationError(c, fmt.Errorf("%w: %s still has child locations", errLocationInUse, l.Code))
+		return
+	}
+	if l.UnitsOnHand > 0 {
+		writeLocationError(c, fmt.Errorf("%w: %s still holds %d units", errLocationInUse, l.Code, l.UnitsOnHand))
+		return
+	}
+
+	if _, err := h.DB.Exec(`DELETE FROM inventory WHERE location_id = $1 AND quantity = 0`, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete location"})
+		return
+	}
+	if _, err := h.DB.Exec(`DELETE FROM locations WHERE id = $1`, id); err != nil {
+		writeLocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Location deleted successfully"})
+}
+
+// findLocation loads a location the user may see, with the units tenantID
+// holds in it, or those of every tenant when it is nil. Writes count every
+// tenant so a bin still holding someone's stock is never emptied.
+func (h *Handler) findLocation(c *gin.Context, q dbtx, id int, tenantID *int) (*models.Location, error) {
+	l, err := scanLocation(q.QueryRow(`SELECT `+locationColumns+locationFrom+`
+		WHERE l.id = $2 AND ($3::int IS NULL OR l.warehouse_id = $3)`,
+		tenantID, id, middleware.PinnedWarehouseID(c)))
+	if err == sql.ErrNoRows {
+		return nil, errLocationNotFound
+	}
+	return l, err
+}
+
+// locationTarget resolves the warehouse a new or edited location belongs
+// to and checks its place in the tree. selfID is the location being edited,
+// or 0 when creating.
+func (h *Handler) locationTarget(c *gin.Context, q dbtx, warehouseID, parentID *int, locationType string, selfID int) (*int, error) {
+	rank, ok := locationRank[locationType]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown location_type %q", errInvalidLocation, locationType)
+	}
+
+	pinned := middleware.PinnedWarehouseID(c)
+	if warehouseID == nil {
+		warehouseID = pinned
+	}
+
+	if parentID != nil {
+		var parentWarehouse sql.NullInt64
+		var parentType string
+		err := q.QueryRow(`SELECT warehouse_id, location_type FROM locations WHERE id = $1`, *parentID).
+			Scan(&parentWarehouse, &parentType)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: parent location not found", errInvalidLocation)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if warehouseID == nil {
+			warehouseID = nullableInt(parentWarehouse)
+		}
+		if !sameWarehouse(warehouseID, nullableInt(parentWarehouse)) {
+			return nil, fmt.Errorf("%w: parent location is in another warehouse", errInvalidLocation)
+		}
+		if besideStorage(parentType) || locationRank[parentType] >= rank {
+			return nil, fmt.Errorf("%w: a %s cannot be placed in a %s", errInvalidLocation, locationType, parentType)
+		}
+
+		if selfID != 0 {
+			var cycle bool
+			err := q.QueryRow(`
+				WITH RECURSIVE ancestors AS (
+					SELECT id, parent_id FROM locations WHERE id = $1
+					UNION ALL
+					SELECT l.id, l.parent_id FROM locations l JOIN ancestors a ON l.id = a.parent_id
+				)
+				SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = $2)`, *parentID, selfID).Scan(&cycle)
+			if err != nil {
+				return nil, err
+			}
+			if cycle {
+				return nil, fmt.Errorf("%w: a location cannot be placed inside itself", errInvalidLocation)
+			}
+		}
+	}
+
+	if selfID != 0 {
+		var misplaced bool
+		err := q.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM locations WHERE parent_id = $1 AND location_type = ANY($2))`,
+			selfID, pq.Array(childTypesNotAllowed(locationType))).Scan(&misplaced)
+		if err != nil {
+			return nil, err
+		}
+		if misplaced {
+			return nil, fmt.Errorf("%w: a %s cannot hold its current child locations", errInvalidLocation, locationType)
+		}
+	}
+
+	if warehouseID == nil {
+		return nil, fmt.Errorf("%w: warehouse_id is required", errInvalidLocation)
+	}
+	if pinned != nil && *pinned != *warehouseID {
+		return nil, errLocationNotAccessible
+	}
+
+	return warehouseID, nil
+}
+
+// childTypesNotAllowed lists the types that cannot sit below a location of
+// the given type. Docks, staging and returns areas never hold children.
+func childTypesNotAllowed(locationType string) []string {
+	var types []string
+	for t, r := range locationRank {
+		if besideStorage(locationType) || r <= locationRank[locationType] {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// besideStorage reports whether locations of the type sit beside the
+// storage tree and hold no children.
+func besideStorage(locationType string) bool {
+	return locationType == models.LocationDock || locationType == models.LocationStaging ||
+		locationType == models.LocationReturns
+}
+
+// expandLocationRange turns A-01-01..A-02-03 into A-01-01, A-01-02, ...
+// A-02-03. Text segments must match; numeric segments count from the first
+// code to the second, keeping the first code's zero padding.
+func expandLocationRange(from, to, separator string) ([]string, error) {
+	fromParts := strings.Split(from, separator)
+	toParts := strings.Split(to, separator)
+	if len(fromParts) != len(toParts) {
+		return nil, fmt.Errorf("%w: %s and %s do not have the same shape", errInvalidLocation, from, to)
+	}
+
+	codes := []string{""}
+	for i, part := range fromParts {
+		start, errStart := strconv.Atoi(part)
+		end, errEnd := strconv.Atoi(toParts[i])
+
+		var values []string
+		switch {
+		case errStart != nil || errEnd != nil:
+			if part != toParts[i] {
+				return nil, fmt.Errorf("%w: segment %q differs from %q", errInvalidLocation, part, toParts[i])
+			}
+			values = []string{part}
+		case start > end:
+			return nil, fmt.Errorf("%w: segment %q is after %q", errInvalidLocation, part, toParts[i])
+		default:
+			for n := start; n <= end; n++ {
+				values = append(values, fmt.Sprintf("%0*d", len(part), n))
+			}
+		}
+
+		if len(codes)*len(values) > maxLocationRange {
+			return nil, fmt.Errorf("%w: range creates more than %d locations", errInvalidLocation, maxLocationRange)
+		}
+		next := make([]string, 0, len(codes)*len(values))
+		for _, prefix := range codes {
+			for _, v := range values {
+				if i > 0 {
+					v = prefix + separator + v
+				}
+				next = append(next, v)
+			}
+		}
+		codes = next
+	}
+
+	return codes, nil
+}
+
+func scanLocation(row scanner) (*models.Location, error) {
+	var l models.Location
+	var warehouseID, parentID, categoryID, pickSequence, maxUnits sql.NullInt64
+	var maxWeight, maxVolume sql.NullFloat64
+	err := row.Scan(&l.ID, &warehouseID, &parentID, &l.ParentCode, &l.Name, &l.Code, &l.Type,
+		&l.Description, &categoryID, &pickSequence, &maxUnits, &maxWeight, &maxVolume, &l.TemperatureControlled, &l.Hazmat,
+		&l.IsActive, &l.UnitsOnHand, &l.CreatedAt, &l.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	l.WarehouseID = nullableInt(warehouseID)
+	l.ParentID = nullableInt(parentID)
+	l.CategoryID = nullableInt(categoryID)
+	l.PickSequence = nullableInt(pickSequence)
+	l.MaxUnits = nullableInt(maxUnits)
+	if maxWeight.Valid {
+		l.MaxWeight = &maxWeight.Float64
+	}
+	if maxVolume.Valid {
+		l.MaxVolume = &maxVolume.Float64
+	}
+	return &l, nil
+}
+
+func writeLocationError(c *gin.Context, err error) {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, errLocationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+	case errors.Is(err, errInvalidLocation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errLocationNotAccessible):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, errLocationInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		c.JSON(http.StatusConflict, gin.H{"error": "Location code already exists in this warehouse"})
+	case errors.As(err, &pqErr) && pqErr.Code == "23503":
+		c.JSON(http.StatusConflict, gin.H{"error": "Location is referenced by stock history; deactivate it instead"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save location"})
+	}
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+)
+
+// newFakeBin answers location lookups for bin 5 of warehouse 1, which
+// holds stock of tenants 3 and 4, and records whether it was deleted.
+func newFakeBin() (*fakeDB, *bool) {
+	held := map[int]int{3: 4, 4: 6}
+	db := &fakeDB{}
+	deleted := false
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[1]) != 5 || st.Args[2] != nil && argInt(st.Args[2]) != 1 {
+			return nil, nil
+		}
+		units := 0
+		for tenant, quantity := range held {
+			if inScope(st.Args[0], &tenant) {
+				units += quantity
+			}
+		}
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(5), int64(1), nil, "", "A-01-01", "A-01-01", "bin",
+			"", nil, nil, nil, nil, nil, false, false, true, int64(units), time.Now(), time.Now()}}}, nil
+	}, "FROM locations l", "WHERE l.id = $2")
+	db.on(rows([]driver.Value{false}), "SELECT EXISTS(SELECT 1 FROM locations WHERE parent_id")
+	db.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		deleted = true
+		return nil, nil
+	}, "DELETE FROM")
+	return db, &deleted
+}
+
+func TestGetLocationCountsTheTenantsStock(t *testing.T) {
+	tenant := 3
+	tests := []struct {
+		name      string
+		claims    auth.Claims
+		header    string
+		wantUnits int
+	}{
+		{name: "staff see every tenant's stock", claims: auth.Claims{UserID: 1}, wantUnits: 10},
+		{name: "a tenant sees its own", claims: auth.Claims{UserID: 1, TenantID: &tenant}, wantUnits: 4},
+		{name: "staff may narrow to a tenant", claims: auth.Claims{UserID: 1}, header: "4", wantUnits: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeBin()
+			h := &Handler{DB: db.open(t)}
+			req := jsonRequest(http.MethodGet, "/locations/5", "")
+			if tt.header != "" {
+				req.Header.Set("X-Tenant-ID", tt.header)
+			}
+
+			w := serveAs(&tt.claims, "/locations/:id", h.GetLocation, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var l struct {
+				UnitsOnHand int `json:"units_on_hand"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &l); err != nil {
+				t.Fatalf("decoding %s: %v", w.Body, err)
+			}
+			if l.UnitsOnHand != tt.wantUnits {
+				t.Errorf("units on hand = %d, want %d", l.UnitsOnHand, tt.wantUnits)
+			}
+		})
+	}
+}
+
+func TestDeleteLocationCountsEveryTenant(t *testing.T) {
+	db, deleted := newFakeBin()
+	h := &Handler{DB: db.open(t)}
+	req := jsonRequest(http.MethodDelete, "/locations/5", "")
+	req.Header.Set("X-Tenant-ID", "9")
+
+	w := serveAs(&auth.Claims{UserID: 1}, "/locations/:id", h.DeleteLocation, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+	if *deleted {
+		t.Errorf("a bin holding stock was deleted")
+	}
+}
+
+func TestUpdateLocationKeepsStockedBinsActive(t *testing.T) {
+	site, otherSite := 1, 2
+	tests := []struct {
+		name     string
+		claims   auth.Claims
+		active   bool
+		wantCode int
+	}{
+		{name: "rename", claims: auth.Claims{UserID: 1}, active: true, wantCode: http.StatusOK},
+		{name: "deactivate while holding stock", claims: auth.Claims{UserID: 1}, wantCode: http.StatusConflict},
+		{name: "site staff edit their own site", claims: auth.Claims{UserID: 1, WarehouseID: &site}, active: true, wantCode: http.StatusOK},
+		{name: "other sites' bins", claims: auth.Claims{UserID: 1, WarehouseID: &otherSite}, active: true, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeBin()
+			updated := false
+			db.on(func(dbtest.Statement) (*dbtest.Result, error) {
+				updated = true
+				return &dbtest.Result{RowsAffected: 1}, nil
+			}, "UPDATE locations SET")
+			h := &Handler{DB: db.open(t)}
+			body := `{"code": "A-01-01", "location_type": "bin", "is_active": false}`
+			if tt.active {
+				body = `{"code": "A-01-01", "location_type": "bin", "is_active": true}`
+			}
+
+			w := serveAs(&tt.claims, "/locations/:id", h.UpdateLocation, jsonRequest(http.MethodPut, "/locations/5", body))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if updated != (tt.wantCode == http.StatusOK) || db.committed != updated {
+				t.Errorf("updated = %v, committed = %v on a %d", updated, db.committed, w.Code)
+			}
+		})
+	}
+}
+
+func TestDeleteLocationKeepsParents(t *testing.T) {
+	db := &fakeDB{}
+	db.on(rows([]driver.Value{int64(2), int64(1), nil, "", "A", "A", "zone",
+		"", nil, nil, nil, nil, nil, false, false, true, int64(0), time.Now(), time.Now()}), "FROM locations l")
+	db.on(rows([]driver.Value{true}), "SELECT EXISTS(SELECT 1 FROM locations WHERE parent_id")
+	h := &Handler{DB: db.open(t)}
+
+	w := serveAs(&auth.Claims{UserID: 1}, "/locations/:id", h.DeleteLocation, jsonRequest(http.MethodDelete, "/locations/2", ""))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+}
+
+func TestCreateLocationRange(t *testing.T) {
+	site, otherSite := 1, 2
+	staff := auth.Claims{UserID: 1}
+	tests := []struct {
+		name      string
+		claims    auth.Claims
+		parentID  int
+		from, to  string
+		wantCode  int
+		wantCodes []string
+	}{
+		{name: "bins under a zone", claims: staff, parentID: 2, from: "A-01-01", to: "A-02-02",
+			wantCode: http.StatusCreated, wantCodes: []string{"A-01-01", "A-01-02", "A-02-01", "A-02-02"}},
+		{name: "bins in a bin", claims: staff, parentID: 3, from: "A-01-01", to: "A-01-02",
+			wantCode: http.StatusBadRequest},
+		{name: "mismatched shapes", claims: staff, parentID: 2, from: "A-01", to: "A-01-02",
+			wantCode: http.StatusBadRequest},
+		{name: "site staff build on their site", claims: auth.Claims{UserID: 1, WarehouseID: &site}, parentID: 2, from: "A-01-01", to: "A-01-01",
+			wantCode: http.StatusCreated, wantCodes: []string{"A-01-01"}},
+		{name: "site staff stay off other sites", claims: auth.Claims{UserID: 1, WarehouseID: &otherSite}, parentID: 2, from: "A-01-01", to: "A-01-01",
+			wantCode: http.StatusBadRequest},
+		{name: "unknown parent", claims: staff, parentID: 9, from: "A-01-01", to: "A-01-01",
+			wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{}
+			parents := map[int]string{2: models.LocationZone, 3: models.LocationBin}
+			db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+				locationType, ok := parents[argInt(st.Args[0])]
+				if !ok {
+					return nil, nil
+				}
+				return &dbtest.Result{Rows: [][]driver.Value{{int64(1), locationType}}}, nil
+			}, "SELECT warehouse_id, location_type FROM locations")
+			var codes []string
+			db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+				codes = append(codes, st.Args[2].(string))
+				return &dbtest.Result{RowsAffected: 1}, nil
+			}, "INSERT INTO locations", "ON CONFLICT")
+			h := &Handler{DB: db.open(t)}
+
+			w := serveAs(&tt.claims, "/locations/range", h.CreateLocationRange, jsonRequest(http.MethodPost, "/locations/range",
+				fmt.Sprintf(`{"parent_id": %d, "location_type": "bin", "from_code": %q, "to_code": %q}`, tt.parentID, tt.from, tt.to)))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Errorf("created %v, want %v", codes, tt.wantCodes)
+			}
+		})
+	}
+}
+
+func TestExpandLocationRange(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     []string
+		wantErr  bool
+	}{
+		{from: "A-01-01", to: "A-01-03", want: []string{"A-01-01", "A-01-02", "A-01-03"}},
+		{from: "A-09", to: "A-10", want: []string{"A-09", "A-10"}},
+		{from: "A-1", to: "A-3", want: []string{"A-1", "A-2", "A-3"}},
+		{from: "A-03", to: "A-01", wantErr: true},
+		{from: "A-01", to: "B-01", wantErr: true},
+		{from: "A-0001-0001", to: "A-0100-0100", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := expandLocationRange(tt.from, tt.to, "-")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandLocationRange(%s, %s) error = %v, want error %v", tt.from, tt.to, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandLocationRange(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const stockLotColumns = `i.product_id, p.name, p.sku, i.location_id, l.code, i.batch, i.expiry_date,
+	i.expiry_date - CURRENT_DATE, i.quantity, i.reserved_quantity, i.quarantined_quantity`
+
+const stockLotFrom = ` FROM inventory i
+	JOIN warehouse_product p ON i.product_id = p.id
+	JOIN locations l ON i.location_id = l.id`
+
+// GetInventoryLots lists the lots on hand for product_id, optionally at
+// location_id, in the order the product's allocation strategy consumes them.
+func (h *Handler) GetInventoryLots(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "product_id is required"})
+		return
+	}
+	locationID, _ := strconv.Atoi(c.Query("location_id"))
+
+	var strategy string
+	err = h.DB.QueryRow(`SELECT allocation_strategy FROM warehouse_product WHERE id = $1`, productID).Scan(&strategy)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lots"})
+		return
+	}
+	order, ok := allocationOrder[strategy]
+	if !ok {
+		order = allocationOrder[models.AllocationFEFO]
+	}
+
+	rows, err := h.DB.Query(`SELECT `+stockLotColumns+stockLotFrom+`
+		WHERE i.product_id = $1 AND ($2 = 0 OR i.location_id = $2) AND i.quantity > 0
+		  AND ($3::int IS NULL OR i.tenant_id = $3) AND ($4::int IS NULL OR l.warehouse_id = $4)
+		ORDER BY `+order+`, l.code`,
+		productID, locationID, middleware.TenantID(c), middleware.WarehouseID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lots"})
+		return
+	}
+	defer rows.Close()
+
+	c.JSON(http.StatusOK, gin.H{"data": scanStockLots(rows)})
+}
+
+// GetExpiringStock reports lots on hand that expire within days (default
+// 30), including those already past their expiry date.
+func (h *Handler) GetExpiringStock(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a non-negative number"})
+		return
+	}
+
+	rows, err := h.DB.Query(`SELECT `+stockLotColumns+stockLotFrom+`
+		WHERE i.quantity > 0 AND i.expiry_date IS NOT NULL AND i.expiry_date <= CURRENT_DATE + $1::int
+		  AND ($2::int IS NULL OR i.tenant_id = $2) AND ($3::int IS NULL OR l.warehouse_id = $3)
+		ORDER BY i.expiry_date, p.name, l.code`,
+		days, middleware.TenantID(c), middleware.WarehouseID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch expiring stock"})
+		return
+	}
+	defer rows.Close()
+
+	lots := scanStockLots(rows)
+	quantity := 0
+	for _, lot := range lots {
+		quantity += lot.Quantity
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": lots, "days": days, "total_quantity": quantity})
+}
+
+// GetLotTrace follows one lot of a product: the receipts it came from, where
+// it is stored now and which customers it was issued to. The full movement
+// history is at /stock-movements?product_id=..&batch=..
+func (h *Handler) GetLotTrace(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "product_id is required"})
+		return
+	}
+	batch := c.Query("batch")
+	if batch == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "batch is required"})
+		return
+	}
+	if ok, err := h.belongsToTenant(c, "warehouse_product", productID); err != nil || !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	trace := models.LotTrace{ProductID: productID, Batch: batch}
+	tenantID, warehouseID := middleware.TenantID(c), middleware.WarehouseID(c)
+
+	rows, err := h.DB.Query(`
+		SELECT r.id, r.document_number, r.receipt_date, r.supplier_id, COALESCE(s.name, r.supplier_name),
+		       l.quantity, l.qc_status, l.expiry_date
+		FROM goods_receipt_lines l
+		JOIN goods_receipts r ON l.receipt_id = r.id
+		LEFT JOIN suppliers s ON r.supplier_id = s.id
+		WHERE l.product_id = $1 AND l.batch = $2 AND r.status <> 'cancelled'
+		  AND ($3::int IS NULL OR r.tenant_id = $3) AND ($4::int IS NULL OR r.warehouse_id = $4)
+		ORDER BY r.receipt_date, r.id`, productID, batch, tenantID, warehouseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to trace lot origins"})
+		return
+	}
+	for rows.Next() {
+		var o models.LotOrigin
+		var supplierID sql.NullInt64
+		var expiry sql.NullTime
+		if err := rows.Scan(&o.ReceiptID, &o.DocumentNumber, &o.ReceiptDate, &supplierID, &o.SupplierName,
+			&o.Quantity, &o.QCStatus, &expiry); err != nil {
+			continue
+		}
+		o.SupplierID = nullableInt(supplierID)
+		o.ExpiryDate = nullableTime(expiry)
+		trace.Origins = append(trace.Origins, o)
+	}
+	rows.Close()
+
+	rows, err = h.DB.Query(`SELECT `+stockLotColumns+stockLotFrom+`
+		WHERE i.product_id = $1 AND i.batch = $2 AND i.quantity <> 0
+		  AND ($3::int IS NULL OR i.tenant_id = $3) AND ($4::int IS NULL OR l.warehouse_id = $4)
+		ORDER BY l.code`, productID, batch, tenantID, warehouseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to trace lot stock"})
+		return
+	}
+	trace.OnHand = scanStockLots(rows)
+	rows.Close()
+
+	rows, err = h.DB.Query(`
+		SELECT sm.reference, iss.customer_id, COALESCE(cu.name, ''), SUM(sm.quantity), MIN(sm.created_at)
+		FROM stock_movements sm
+		JOIN issuing iss ON sm.reference = iss.document_number
+		LEFT JOIN customers cu ON iss.customer_id = cu.id
+		LEFT JOIN locations loc ON sm.location_id = loc.id
+		WHERE sm.product_id = $1 AND sm.batch = $2 AND sm.movement_type = $3 AND sm.reference_type = $4
+		  AND ($5::int IS NULL OR sm.tenant_id = $5) AND ($6::int IS NULL OR loc.warehouse_id = $6)
+		GROUP BY sm.reference, iss.customer_id, cu.name
+		ORDER BY MIN(sm.created_at)`,
+		productID, batch, models.MovementOut, models.RefIssuing, tenantID, warehouseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to trace lot recipients"})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r models.LotRecipient
+		var customerID sql.NullInt64
+		if err := rows.Scan(&r.Reference, &customerID, &r.CustomerName, &r.Quantity, &r.IssuedAt); err != nil {
+			continue
+		}
+		r.CustomerID = nullableInt(customerID)
+		trace.Recipients = append(trace.Recipients, r)
+	}
+
+	c.JSON(http.StatusOK, trace)
+}
+
+func scanStockLots(rows *sql.Rows) []models.StockLot {
+	var lots []models.StockLot
+	for rows.Next() {
+		var lot models.StockLot
+		var expiry sql.NullTime
+		var days sql.NullInt64
+		err := rows.Scan(&lot.ProductID, &lot.ProductName, &lot.SKU, &lot.LocationID, &lot.LocationCode,
+			&lot.Batch, &expiry, &days, &lot.Quantity, &lot.Reserved, &lot.Quarantined)
+		if err != nil {
+			continue
+		}
+		lot.ExpiryDate = nullableTime(expiry)
+		lot.DaysToExpiry = nullableInt(days)
+		lot.Expired = lot.DaysToExpiry != nil && *lot.DaysToExpiry < 0
+		lot.Available = lot.Quantity - lot.Reserved - lot.Quarantined
+		lots = append(lots, lot)
+	}
+	return lots
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+)
+
+func TestCreateStockMovementByLot(t *testing.T) {
+	staff := auth.Claims{UserID: 7}
+	tests := []struct {
+		name          string
+		body          string
+		wantCode      int
+		wantMovements []string
+		wantExpiry    string
+	}{
+		{name: "a new lot keeps its expiry", body: `"location_id": 10, "movement_type": "IN", "quantity": 3, "reason_code": "FOUND", "batch": "L9", "expiry_date": "2027-03-01"`,
+			wantCode: http.StatusCreated, wantMovements: []string{"IN 3 10/L9"}, wantExpiry: "2027-03-01"},
+		{name: "the named lot is drawn down", body: `"location_id": 12, "movement_type": "OUT", "quantity": 2, "reason_code": "DAMAGE", "batch": "L0"`,
+			wantCode: http.StatusCreated, wantMovements: []string{"OUT 2 12/L0"}},
+		{name: "without a lot the earliest expiry goes first", body: `"location_id": 12, "movement_type": "OUT", "quantity": 5, "reason_code": "DAMAGE"`,
+			wantCode: http.StatusCreated, wantMovements: []string{"OUT 3 12/L0", "OUT 2 12/L3"}},
+		{name: "lot short", body: `"location_id": 12, "movement_type": "OUT", "quantity": 4, "reason_code": "DAMAGE", "batch": "L0"`,
+			wantCode: http.StatusConflict},
+		{name: "lot stored elsewhere", body: `"location_id": 12, "movement_type": "OUT", "quantity": 1, "reason_code": "DAMAGE", "batch": "L1"`,
+			wantCode: http.StatusConflict},
+		{name: "malformed expiry", body: `"location_id": 10, "movement_type": "IN", "quantity": 1, "reason_code": "FOUND", "batch": "L9", "expiry_date": "01-03-2027"`,
+			wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newFakeLedger(models.AllocationFEFO)
+			ledger.balances = append(ledger.balances, &fakeBalance{productID: 1, locationID: 12, code: "A-03", batch: "L0",
+				expiry: day("2026-06-01"), receivedAt: *day("2026-03-01"), quantity: 3})
+			onMasterData(ledger.fakeDB)
+			ledger.on(rows([]driver.Value{"ADJ-000001"}), "nextval('stock_adjustment_number_seq')")
+			h := &Handler{DB: ledger.open(t)}
+
+			w := serveAs(&staff, "/stock-movements", h.CreateStockMovement,
+				jsonRequest(http.MethodPost, "/stock-movements", fmt.Sprintf(`{"product_id": 1, %s}`, tt.body)))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if ledger.committed != (tt.wantCode == http.StatusCreated) {
+				t.Errorf("committed = %v on a %d", ledger.committed, w.Code)
+			}
+			if tt.wantCode != http.StatusCreated {
+				return
+			}
+			if !reflect.DeepEqual(ledger.movements, tt.wantMovements) {
+				t.Errorf("movements = %v, want %v", ledger.movements, tt.wantMovements)
+			}
+			if tt.wantExpiry != "" {
+				if b := ledger.balance(1, 10, "L9"); b == nil || b.expiry == nil || b.expiry.Format("2006-01-02") != tt.wantExpiry {
+					t.Errorf("lot L9 = %+v, want expiry %s", b, tt.wantExpiry)
+				}
+			}
+		})
+	}
+}
+
+func TestGetInventoryLotsIsScoped(t *testing.T) {
+	tenant, site := 3, 1
+	tests := []struct {
+		name          string
+		claims        auth.Claims
+		product       string
+		wantCode      int
+		wantTenant    driver.Value
+		wantWarehouse driver.Value
+	}{
+		{name: "staff see every tenant", claims: auth.Claims{UserID: 7}, product: "1", wantCode: http.StatusOK},
+		{name: "a tenant sees its own lots", claims: auth.Claims{UserID: 7, TenantID: &tenant}, product: "1",
+			wantCode: http.StatusOK, wantTenant: int64(tenant)},
+		{name: "site staff see their site", claims: auth.Claims{UserID: 7, WarehouseID: &site}, product: "1",
+			wantCode: http.StatusOK, wantWarehouse: int64(site)},
+		{name: "unknown product", claims: auth.Claims{UserID: 7}, product: "9", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{}
+			db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+				if argInt(st.Args[0]) != 1 {
+					return nil, nil
+				}
+				return &dbtest.Result{Rows: [][]driver.Value{{models.AllocationFEFO}}}, nil
+			}, "SELECT allocation_strategy FROM warehouse_product")
+			var scope []driver.Value
+			db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+				scope = st.Args[2:4]
+				return &dbtest.Result{Rows: [][]driver.Value{{int64(1), "Gula", "GL-1", int64(10), "A-01", "L1", *day("2026-12-01"),
+					int64(-2), int64(5), int64(1), int64(1)}}}, nil
+			}, "FROM inventory i", "JOIN warehouse_product p")
+			h := &Handler{DB: db.open(t)}
+
+			w := serveAs(&tt.claims, "/inventory/lots", h.GetInventoryLots,
+				jsonRequest(http.MethodGet, "/inventory/lots?product_id="+tt.product, ""))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if want := []driver.Value{tt.wantTenant, tt.wantWarehouse}; !reflect.DeepEqual(scope, want) {
+				t.Errorf("scope = %v, want %v", scope, want)
+			}
+			var body struct {
+				Data []models.StockLot `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding %s: %v", w.Body, err)
+			}
+			if len(body.Data) != 1 || !body.Data[0].Expired || body.Data[0].Available != 3 {
+				t.Errorf("lots = %+v, want one expired lot with 3 available", body.Data)
+			}
+		})
+	}
+}
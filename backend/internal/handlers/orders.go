@@ -0,0 +1,576 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errOrderNotFound    = errors.New("order not found")
+	errOrderNotEditable = errors.New("order cannot change in its current status")
+)
+
+const orderColumns = `id, order_number, customer_id, customer, warehouse_id, status, order_date, required_date,
+	total_amount, notes, backorder_of, created_by, shipped_at, delivered_at, tenant_id, created_at, updated_at`
+
+func (h *Handler) GetOrders(c *gin.Context) {
+	customerID, _ := strconv.Atoi(c.Query("customer_id"))
+	rows, err := h.DB.Query(`
+		SELECT `+orderColumns+` FROM orders
+		WHERE ($1::int IS NULL OR tenant_id = $1)
+		  AND ($2::int IS NULL OR warehouse_id IS NULL OR warehouse_id = $2)
+		  AND ($3 = '' OR status = $3) AND ($4 = 0 OR customer_id = $4)
+		ORDER BY created_at DESC`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("status"), customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			continue
+		}
+		orders = append(orders, *o)
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+func (h *Handler) GetOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	o, err := scanOrder(h.DB.QueryRow(`
+		SELECT `+orderColumns+` FROM orders
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2)
+		  AND ($3::int IS NULL OR warehouse_id IS NULL OR warehouse_id = $3)`,
+		id, middleware.TenantID(c), middleware.WarehouseID(c)))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	if o.Lines, err = orderLines(h.DB, o.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order lines"})
+		return
+	}
+
+	c.JSON(http.StatusOK, o)
+}
+
+// CreateOrder records a new order for a customer. Nothing is reserved
+// until the order is allocated.
+func (h *Handler) CreateOrder(c *gin.Context) {
+	var req models.OrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var customer string
+	var customerTenant sql.NullInt64
+	err := h.DB.QueryRow(`SELECT name, tenant_id FROM customers WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2)`,
+		req.CustomerID, middleware.TenantID(c)).Scan(&customer, &customerTenant)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown customer"})
+		return
+	}
+	// Staff not scoped to a tenant order for the customer's tenant
+	tenantID := middleware.TenantID(c)
+	if tenantID == nil {
+		tenantID = nullableInt(customerTenant)
+	}
+
+	orderDate := time.Now()
+	if req.OrderDate != "" {
+		if orderDate, err = time.Parse("2006-01-02", req.OrderDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "order_date must be YYYY-MM-DD"})
+			return
+		}
+	}
+	var requiredDate *time.Time
+	if req.RequiredDate != "" {
+		d, err := time.Parse("2006-01-02", req.RequiredDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "required_date must be YYYY-MM-DD"})
+			return
+		}
+		requiredDate = &d
+	}
+
+	total := 0.0
+	for i, line := range req.Lines {
+		var known bool
+		err := h.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM warehouse_product WHERE id = $1 AND tenant_id IS NOT DISTINCT FROM $2)`,
+			line.ProductID, tenantID).Scan(&known)
+		if err != nil || !known {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Line %d: unknown product", i+1)})
+			return
+		}
+		total += float64(line.Quantity) * line.UnitPrice
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	o, err := scanOrder(tx.QueryRow(`
+		INSERT INTO orders (customer_id, customer, order_date, required_date, total_amount, notes, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+orderColumns,
+		req.CustomerID, customer, orderDate, requiredDate, total, req.Notes, middleware.CurrentUserID(c), tenantID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+		return
+	}
+
+	for _, line := range req.Lines {
+		_, err := tx.Exec(`
+			INSERT INTO order_lines (order_id, product_id, unit_id, quantity, unit_price)
+			VALUES ($1, $2, $3, $4, $5)`,
+			o.ID, line.ProductID, line.UnitID, line.Quantity, line.UnitPrice)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order lines"})
+			return
+		}
+	}
+	if o.Lines, err = orderLines(tx, o.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order lines"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, o)
+}
+
+// AllocateOrder reserves what it can of every outstanding line, in one
+// warehouse. It may be repeated while the order is allocated to pick up
+// stock that arrived since. Lines left short are reported; they ship as a
+// backorder unless stock turns up before the order ships.
+func (h *Handler) AllocateOrder(c *gin.Context) {
+	var shortages []models.OrderShortage
+	h.advanceOrder(c, []string{models.OrderNew, models.OrderAllocated}, func(tx *sql.Tx, o *models.Order, userID int) error {
+		var err error
+		shortages, err = allocateOrder(tx, o, middleware.WarehouseID(c), userID)
+		if err != nil {
+			return err
+		}
+		return setOrderStatus(tx, o, models.OrderAllocated)
+	}, func(o *models.Order) gin.H {
+		return gin.H{"order": o, "shortages": shortages}
+	})
+}
+
+// PickOrder starts picking an allocated order.
+func (h *Handler) PickOrder(c *gin.Context) {
+	h.advanceOrder(c, []string{models.OrderAllocated}, func(tx *sql.Tx, o *models.Order, userID int) error {
+		return setOrderStatus(tx, o, models.OrderPicking)
+	}, nil)
+}
+
+// PackOrder records that the picked goods are packed and ready to ship.
+func (h *Handler) PackOrder(c *gin.Context) {
+	h.advanceOrder(c, []string{models.OrderPicking}, func(tx *sql.Tx, o *models.Order, userID int) error {
+		return setOrderStatus(tx, o, models.OrderPacked)
+	}, nil)
+}
+
+// ShipOrder takes the allocated stock out of inventory and generates an
+// issuing and a dispatch per line. What was not allocated moves to a
+// backorder.
+func (h *Handler) ShipOrder(c *gin.Context) {
+	var backorder *models.Order
+	h.advanceOrder(c, []string{models.OrderPacked}, func(tx *sql.Tx, o *models.Order, userID int) error {
+		if err := shipOrder(tx, o, userID); err != nil {
+			return err
+		}
+		var err error
+		if backorder, err = createBackorder(tx, o, userID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE orders SET shipped_at = NOW() WHERE id = $1`, o.ID)
+		if err != nil {
+			return err
+		}
+		return setOrderStatus(tx, o, models.OrderShipped)
+	}, func(o *models.Order) gin.H {
+		return gin.H{"order": o, "backorder": backorder}
+	})
+}
+
+// DeliverOrder records that a shipped order reached the customer.
+func (h *Handler) DeliverOrder(c *gin.Context) {
+	h.advanceOrder(c, []string{models.OrderShipped}, func(tx *sql.Tx, o *models.Order, userID int) error {
+		_, err := tx.Exec(`UPDATE dispatches SET status = 'delivered' WHERE order_id = $1`, o.ID)
+		if err == nil {
+			_, err = tx.Exec(`UPDATE orders SET delivered_at = NOW() WHERE id = $1`, o.ID)
+		}
+		if err != nil {
+			return err
+		}
+		return setOrderStatus(tx, o, models.OrderDelivered)
+	}, nil)
+}
+
+// CancelOrder releases the stock reserved for an order that has not
+// shipped.
+func (h *Handler) CancelOrder(c *gin.Context) {
+	open := []string{models.OrderNew, models.OrderAllocated, models.OrderPicking, models.OrderPacked}
+	h.advanceOrder(c, open, func(tx *sql.Tx, o *models.Order, userID int) error {
+		lines, err := orderLines(tx, o.ID)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if err := releaseReservations(tx, models.RefOrderLine, line.ID); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(`UPDATE order_lines SET allocated_quantity = 0 WHERE order_id = $1`, o.ID); err != nil {
+			return err
+		}
+		return setOrderStatus(tx, o, models.OrderCancelled)
+	}, nil)
+}
+
+// advanceOrder locks an order in one of the from statuses, runs step and
+// answers with the order and its lines, or with what respond builds.
+func (h *Handler) advanceOrder(c *gin.Context, from []string, step func(*sql.Tx, *models.Order, int) error, respond func(*models.Order) gin.H) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	o, err := h.lockOrder(tx, c, id)
+	if err != nil {
+		writeOrderError(c, err)
+		return
+	}
+	allowed := false
+	for _, status := range from {
+		allowed = allowed || o.Status == status
+	}
+	if !allowed {
+		writeOrderError(c, fmt.Errorf("%w: order is %s", errOrderNotEditable, o.Status))
+		return
+	}
+
+	if err := step(tx, o, middleware.CurrentUserID(c)); err != nil {
+		writeOrderError(c, err)
+		return
+	}
+	if o.Lines, err = orderLines(tx, o.ID); err != nil {
+		writeOrderError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	if respond != nil {
+		c.JSON(http.StatusOK, respond(o))
+		return
+	}
+	c.JSON(http.StatusOK, o)
+}
+
+// allocateOrder reserves stock for the outstanding quantity of each line.
+// The order is kept to one warehouse: the one it was allocated from
+// before, else the selected one, else wherever the first stock is found.
+func allocateOrder(tx *sql.Tx, o *models.Order, warehouseID *int, userID int) ([]models.OrderShortage, error) {
+	lines, err := orderLines(tx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	if o.WarehouseID != nil {
+		warehouseID = o.WarehouseID
+	}
+
+	var shortages []models.OrderShortage
+	allocated := false
+	for _, line := range lines {
+		allocated = allocated || line.AllocatedQuantity > 0
+		if line.OutstandingQuantity == 0 {
+			continue
+		}
+
+		plan, err := planAllocation(tx, line.ProductID, line.OutstandingQuantity, allocationScope{WarehouseID: warehouseID, Lock: true})
+		if err != nil {
+			return nil, err
+		}
+		if plan.Short > 0 {
+			shortages = append(shortages, models.OrderShortage{
+				LineID:    line.ID,
+				ProductID: line.ProductID,
+				Requested: line.OutstandingQuantity,
+				Available: plan.Allocated,
+			})
+		}
+		if plan.Allocated == 0 {
+			continue
+		}
+
+		err = reservePlan(tx, plan, reservationRef{Type: models.RefOrderLine, ID: line.ID, UserID: userID, TenantID: o.TenantID})
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`UPDATE order_lines SET allocated_quantity = allocated_quantity + $1 WHERE id = $2`,
+			plan.Allocated, line.ID)
+		if err != nil {
+			return nil, err
+		}
+		if warehouseID == nil {
+			warehouseID = plan.Lines[0].WarehouseID
+		}
+		allocated = true
+	}
+
+	if !allocated {
+		return nil, fmt.Errorf("%w: none of the order is in stock", errInsufficientStock)
+	}
+	if warehouseID != nil && o.WarehouseID == nil {
+		if _, err := tx.Exec(`UPDATE orders SET warehouse_id = $1 WHERE id = $2`, *warehouseID, o.ID); err != nil {
+			return nil, err
+		}
+	}
+	return shortages, nil
+}
+
+// shipOrder books the reserved stock of every line out under an issuing
+// document, so lot traces find the customer, and records its dispatch.
+func shipOrder(tx *sql.Tx, o *models.Order, userID int) error {
+	lines, err := orderLines(tx, o.ID)
+	if err != nil {
+		return err
+	}
+
+	shipped := 0
+	for i, line := range lines {
+		if line.AllocatedQuantity == 0 {
+			continue
+		}
+		docNumber := fmt.Sprintf("ISS-%s-%d", o.OrderNumber, i+1)
+		held, err := consumeReservations(tx, models.RefOrderLine, line.ID, stockMovement{
+			Reference:     docNumber,
+			ReferenceType: models.RefIssuing,
+			UserID:        userID,
+			TenantID:      o.TenantID,
+		})
+		if err != nil {
+			return err
+		}
+		if len(held) == 0 {
+			continue
+		}
+		quantity := 0
+		for _, r := range held {
+			quantity += r.quantity
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO issuing (document_number, issue_date, customer_id, product_id, quantity, unit_id, location_id, warehouse_id,
+			                     remarks, created_by, tenant_id, order_id)
+			VALUES ($1, CURRENT_DATE, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			docNumber, o.CustomerID, line.ProductID, quantity, line.UnitID, held[0].locationID, o.WarehouseID,
+			"Order "+o.OrderNumber, userID, o.TenantID, o.ID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO dispatches (product_name, customer, quantity, location, notes, dispatch_date, status, created_by, tenant_id,
+			                        product_id, warehouse_id, posted_at, order_id)
+			VALUES ($1, $2, $3, (SELECT code FROM locations WHERE id = $4), $5, NOW(), 'shipped', $6, $7, $8, $9, NOW(), $10)`,
+			line.ProductName, o.Customer, quantity, held[0].locationID, "Order "+o.OrderNumber, userID, o.TenantID,
+			line.ProductID, o.WarehouseID, o.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE order_lines SET shipped_quantity = shipped_quantity + $1, allocated_quantity = 0 WHERE id = $2`,
+			quantity, line.ID)
+		if err != nil {
+			return err
+		}
+		shipped += quantity
+	}
+
+	if shipped == 0 {
+		return fmt.Errorf("%w: nothing is allocated to ship", errOrderNotEditable)
+	}
+	return nil
+}
+
+// createBackorder carries whatever a shipped order still owes over to a new
+// order for the same customer. It returns nil when everything shipped.
+func createBackorder(tx *sql.Tx, o *models.Order, userID int) (*models.Order, error) {
+	lines, err := orderLines(tx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var open []models.OrderLine
+	total := 0.0
+	for _, line := range lines {
+		if line.OutstandingQuantity > 0 {
+			open = append(open, line)
+			total += float64(line.OutstandingQuantity) * line.UnitPrice
+		}
+	}
+	if len(open) == 0 {
+		return nil, nil
+	}
+
+	backorder, err := scanOrder(tx.QueryRow(`
+		INSERT INTO orders (customer_id, customer, warehouse_id, required_date, total_amount, notes, backorder_of, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+orderColumns,
+		o.CustomerID, o.Customer, o.WarehouseID, o.RequiredDate, total, "Backorder of "+o.OrderNumber, o.ID, userID, o.TenantID))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range open {
+		_, err := tx.Exec(`
+			INSERT INTO order_lines (order_id, product_id, unit_id, quantity, unit_price)
+			VALUES ($1, $2, $3, $4, $5)`,
+			backorder.ID, line.ProductID, line.UnitID, line.OutstandingQuantity, line.UnitPrice)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`UPDATE order_lines SET backordered_quantity = backordered_quantity + $1 WHERE id = $2`,
+			line.OutstandingQuantity, line.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	backorder.Lines, err = orderLines(tx, backorder.ID)
+	return backorder, err
+}
+
+func setOrderStatus(tx *sql.Tx, o *models.Order, status string) error {
+	updated, err := scanOrder(tx.QueryRow(`
+		UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2
+		RETURNING `+orderColumns, status, o.ID))
+	if err != nil {
+		return err
+	}
+	*o = *updated
+	return nil
+}
+
+// lockOrder loads an order for update. Users pinned to a warehouse see
+// orders allocated there and those not yet allocated anywhere.
+func (h *Handler) lockOrder(tx *sql.Tx, c *gin.Context, id int) (*models.Order, error) {
+	o, err := scanOrder(tx.QueryRow(`
+		SELECT `+orderColumns+` FROM orders
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2)
+		  AND ($3::int IS NULL OR warehouse_id IS NULL OR warehouse_id = $3)
+		FOR UPDATE`,
+		id, middleware.TenantID(c), middleware.PinnedWarehouseID(c)))
+	if err == sql.ErrNoRows {
+		return nil, errOrderNotFound
+	}
+	return o, err
+}
+
+func orderLines(q dbtx, orderID int) ([]models.OrderLine, error) {
+	rows, err := q.Query(`
+		SELECT l.id, l.order_id, l.product_id, p.name, p.sku, l.unit_id, l.quantity, l.unit_price,
+		       l.allocated_quantity, l.picked_quantity, l.shipped_quantity, l.backordered_quantity
+		FROM order_lines l
+		JOIN warehouse_product p ON l.product_id = p.id
+		WHERE l.order_id = $1
+		ORDER BY l.id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []models.OrderLine
+	for rows.Next() {
+		var l models.OrderLine
+		var unitID sql.NullInt64
+		err := rows.Scan(&l.ID, &l.OrderID, &l.ProductID, &l.ProductName, &l.SKU, &unitID, &l.Quantity, &l.UnitPrice,
+			&l.AllocatedQuantity, &l.PickedQuantity, &l.ShippedQuantity, &l.BackorderedQuantity)
+		if err != nil {
+			return nil, err
+		}
+		l.UnitID = nullableInt(unitID)
+		l.OutstandingQuantity = l.Quantity - l.AllocatedQuantity - l.ShippedQuantity - l.BackorderedQuantity
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+func scanOrder(row scanner) (*models.Order, error) {
+	var o models.Order
+	var customerID, warehouseID, backorderOf, createdBy, tenantID sql.NullInt64
+	var requiredDate, shippedAt, deliveredAt sql.NullTime
+	var updatedAt sql.NullTime
+	err := row.Scan(&o.ID, &o.OrderNumber, &customerID, &o.Customer, &warehouseID, &o.Status, &o.OrderDate, &requiredDate,
+		&o.TotalAmount, &o.Notes, &backorderOf, &createdBy, &shippedAt, &deliveredAt, &tenantID, &o.CreatedAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	o.CustomerID = nullableInt(customerID)
+	o.WarehouseID = nullableInt(warehouseID)
+	o.BackorderOf = nullableInt(backorderOf)
+	o.CreatedBy = nullableInt(createdBy)
+	o.TenantID = nullableInt(tenantID)
+	o.RequiredDate = nullableTime(requiredDate)
+	o.ShippedAt = nullableTime(shippedAt)
+	o.DeliveredAt = nullableTime(deliveredAt)
+	if updatedAt.Valid {
+		o.UpdatedAt = updatedAt.Time
+	}
+	return &o, nil
+}
+
+func writeOrderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errOrderNotEditable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		writeAllocationError(c, err)
+	}
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeOrderLine is one order_lines row.
+type fakeOrderLine struct {
+	id, orderID                               int
+	quantity, allocated, shipped, backordered int
+}
+
+// fakeOrder serves order 1 of tenant 3 for product 1 on top of the
+// warehouse of newFakeLedger, and the backorder shipping may open.
+type fakeOrder struct {
+	*fakeLedger
+	status      string
+	warehouseID *int
+	lines       []*fakeOrderLine
+	backorder   bool
+}
+
+func newFakeOrder(status string, warehouseID *int, lines ...*fakeOrderLine) *fakeOrder {
+	tenant := 3
+	o := &fakeOrder{fakeLedger: newFakeLedger(models.AllocationFEFO), status: status, warehouseID: warehouseID, lines: lines}
+	row := func(id int, status string) []driver.Value {
+		return []driver.Value{int64(id), fmt.Sprintf("SO-%d", id), nil, "Toko Maju", nullInt(o.warehouseID), status, time.Now(), nil,
+			0.0, "", nil, nil, nil, nil, int64(tenant), time.Now(), time.Now()}
+	}
+
+	o.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[0]) != 1 || !inScope(st.Args[1], &tenant) ||
+			o.warehouseID != nil && !inScope(st.Args[2], o.warehouseID) {
+			return nil, nil
+		}
+		return &dbtest.Result{Rows: [][]driver.Value{row(1, o.status)}}, nil
+	}, "FROM orders", "FOR UPDATE")
+	o.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		res := &dbtest.Result{}
+		for _, l := range o.lines {
+			if l.orderID == argInt(st.Args[0]) {
+				res.Rows = append(res.Rows, []driver.Value{int64(l.id), int64(l.orderID), int64(1), "Gula", "GL-1", nil,
+					int64(l.quantity), 1.5, int64(l.allocated), int64(0), int64(l.shipped), int64(l.backordered)})
+			}
+		}
+		return res, nil
+	}, "FROM order_lines l")
+	o.on(o.updateLine(func(l *fakeOrderLine, n int) { l.allocated += n }), "UPDATE order_lines SET allocated_quantity = allocated_quantity +")
+	o.on(o.updateLine(func(l *fakeOrderLine, n int) { l.shipped += n; l.allocated = 0 }), "UPDATE order_lines SET shipped_quantity")
+	o.on(o.updateLine(func(l *fakeOrderLine, n int) { l.backordered += n }), "UPDATE order_lines SET backordered_quantity")
+	o.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		for _, l := range o.lines {
+			if l.orderID == argInt(st.Args[0]) {
+				l.allocated = 0
+			}
+		}
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE order_lines SET allocated_quantity = 0")
+	o.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		id := int(st.Args[0].(int64))
+		o.warehouseID = &id
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE orders SET warehouse_id")
+	o.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		o.status = st.Args[0].(string)
+		return &dbtest.Result{Rows: [][]driver.Value{row(1, o.status)}}, nil
+	}, "UPDATE orders SET status")
+	o.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		o.backorder = true
+		return &dbtest.Result{Rows: [][]driver.Value{row(2, models.OrderNew)}}, nil
+	}, "INSERT INTO orders")
+	o.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		o.lines = append(o.lines, &fakeOrderLine{id: 50 + len(o.lines), orderID: argInt(st.Args[0]), quantity: argInt(st.Args[3])})
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "INSERT INTO order_lines")
+	o.on(affected(1), "INSERT INTO issuing")
+	o.on(affected(1), "INSERT INTO dispatches")
+	o.on(affected(1), "UPDATE dispatches SET status")
+	o.on(affected(1), "UPDATE orders SET", "_at = NOW() WHERE id = $1")
+	return o
+}
+
+// updateLine applies an "UPDATE order_lines ... $1 ... WHERE id = $2".
+func (o *fakeOrder) updateLine(apply func(*fakeOrderLine, int)) dbtest.Handler {
+	return func(st dbtest.Statement) (*dbtest.Result, error) {
+		for _, l := range o.lines {
+			if l.id == argInt(st.Args[1]) {
+				apply(l, argInt(st.Args[0]))
+				return &dbtest.Result{RowsAffected: 1}, nil
+			}
+		}
+		return &dbtest.Result{}, nil
+	}
+}
+
+func TestAdvanceOrder(t *testing.T) {
+	other, site, otherSite := 4, 1, 2
+	staff := auth.Claims{UserID: 7}
+	// held is line 41 with 4 units reserved on lot L2.
+	held := func(o *fakeOrder) {
+		o.lines[0].allocated = 4
+		o.balance(1, 11, "L2").reserved = 4
+		o.reservations = append(o.reservations, &fakeReservation{id: 1, productID: 1, locationID: 11, batch: "L2", quantity: 4,
+			referenceType: models.RefOrderLine, referenceID: 41, status: models.ReservationActive})
+	}
+	tests := []struct {
+		name          string
+		claims        auth.Claims
+		action        string
+		status        string
+		warehouseID   *int
+		quantity      int
+		setup         func(*fakeOrder)
+		wantCode      int
+		wantStatus    string
+		wantReserved  []string
+		wantMovements []string
+		wantBackorder bool
+	}{
+		{name: "allocate reserves by expiry", claims: staff, action: "allocate", status: models.OrderNew, quantity: 6,
+			wantCode: http.StatusOK, wantStatus: models.OrderAllocated, wantReserved: []string{"4 11/L2", "2 10/L1"}},
+		{name: "allocate what there is", claims: staff, action: "allocate", status: models.OrderNew, quantity: 25,
+			wantCode: http.StatusOK, wantStatus: models.OrderAllocated, wantReserved: []string{"4 11/L2", "5 10/L1", "10 12/L3"}},
+		{name: "allocate with nothing in stock", claims: staff, action: "allocate", status: models.OrderNew, quantity: 6,
+			setup: func(o *fakeOrder) { o.balances = nil }, wantCode: http.StatusConflict},
+		{name: "allocate after shipping", claims: staff, action: "allocate", status: models.OrderShipped, quantity: 6,
+			wantCode: http.StatusConflict},
+		{name: "pick", claims: staff, action: "pick", status: models.OrderAllocated, quantity: 6,
+			wantCode: http.StatusOK, wantStatus: models.OrderPicking},
+		{name: "pack before picking", claims: staff, action: "pack", status: models.OrderAllocated, quantity: 6,
+			wantCode: http.StatusConflict},
+		{name: "ship takes the reserved stock and backorders the rest", claims: staff, action: "ship", status: models.OrderPacked, quantity: 6,
+			setup: held, wantCode: http.StatusOK, wantStatus: models.OrderShipped,
+			wantMovements: []string{"OUT 4 11/L2"}, wantBackorder: true},
+		{name: "ship with nothing allocated", claims: staff, action: "ship", status: models.OrderPacked, quantity: 6,
+			wantCode: http.StatusConflict},
+		{name: "deliver", claims: staff, action: "deliver", status: models.OrderShipped, quantity: 6,
+			wantCode: http.StatusOK, wantStatus: models.OrderDelivered},
+		{name: "cancel releases the reservation", claims: staff, action: "cancel", status: models.OrderAllocated, quantity: 6,
+			setup: held, wantCode: http.StatusOK, wantStatus: models.OrderCancelled},
+		{name: "cancel after shipping", claims: staff, action: "cancel", status: models.OrderShipped, quantity: 6,
+			wantCode: http.StatusConflict},
+		{name: "other tenants' orders", claims: auth.Claims{UserID: 7, TenantID: &other}, action: "pick", status: models.OrderAllocated, quantity: 6,
+			wantCode: http.StatusNotFound},
+		{name: "orders allocated to another site", claims: auth.Claims{UserID: 7, WarehouseID: &otherSite}, action: "pick",
+			status: models.OrderAllocated, warehouseID: &site, quantity: 6, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newFakeOrder(tt.status, tt.warehouseID, &fakeOrderLine{id: 41, orderID: 1, quantity: tt.quantity})
+			if tt.setup != nil {
+				tt.setup(o)
+			}
+			h := &Handler{DB: o.open(t)}
+			handler := map[string]gin.HandlerFunc{"allocate": h.AllocateOrder, "pick": h.PickOrder, "pack": h.PackOrder,
+				"ship": h.ShipOrder, "deliver": h.DeliverOrder, "cancel": h.CancelOrder}[tt.action]
+
+			w := serveAs(&tt.claims, "/orders/:id/"+tt.action, handler, jsonRequest(http.MethodPost, "/orders/1/"+tt.action, ""))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if o.committed != (tt.wantCode == http.StatusOK) {
+				t.Errorf("committed = %v on a %d", o.committed, w.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if o.status != tt.wantStatus {
+				t.Errorf("order is %s, want %s", o.status, tt.wantStatus)
+			}
+			if got := o.reserved(); !reflect.DeepEqual(got, tt.wantReserved) {
+				t.Errorf("reserved = %v, want %v", got, tt.wantReserved)
+			}
+			if !reflect.DeepEqual(o.movements, tt.wantMovements) {
+				t.Errorf("movements = %v, want %v", o.movements, tt.wantMovements)
+			}
+			if o.backorder != tt.wantBackorder {
+				t.Errorf("backorder = %v, want %v", o.backorder, tt.wantBackorder)
+			}
+		})
+	}
+}
+
+func TestAllocateOrderReportsShortages(t *testing.T) {
+	o := newFakeOrder(models.OrderNew, nil, &fakeOrderLine{id: 41, orderID: 1, quantity: 25})
+	h := &Handler{DB: o.open(t)}
+
+	w := serveAs(&auth.Claims{UserID: 7}, "/orders/:id/allocate", h.AllocateOrder, jsonRequest(http.MethodPost, "/orders/1/allocate", ""))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Shortages []models.OrderShortage `json:"shortages"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	want := []models.OrderShortage{{LineID: 41, ProductID: 1, Requested: 25, Available: 19}}
+	if !reflect.DeepEqual(body.Shortages, want) {
+		t.Errorf("shortages = %+v, want %+v", body.Shortages, want)
+	}
+	if o.warehouseID == nil || *o.warehouseID != 1 {
+		t.Errorf("order warehouse = %v, want 1", nullInt(o.warehouseID))
+	}
+}
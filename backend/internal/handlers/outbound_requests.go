@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errOutboundNotFound    = errors.New("outbound request not found")
+	errOutboundNotEditable = errors.New("outbound request cannot change in its current status")
+)
+
+type outboundRequest struct {
+	id        int
+	productID int
+	quantity  int
+	status    string
+	tenantID  *int
+}
+
+// ConfirmOutboundRequest reserves the requested stock. When not all of it
+// is available nothing is reserved and the response says what is.
+func (h *Handler) ConfirmOutboundRequest(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	// The body is optional
+	var req models.OutboundConfirmRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	scope := allocationScope{WarehouseID: middleware.WarehouseID(c), LocationID: req.LocationID}
+	if req.LocationID != 0 {
+		warehouseID, err := h.locationWarehouse(c, req.LocationID)
+		if err != nil {
+			writeAllocationError(c, locationLookupError(err))
+			return
+		}
+		scope.WarehouseID = warehouseID
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := lockOutboundRequest(tx, c, id)
+	if err != nil {
+		writeOutboundError(c, err)
+		return
+	}
+	if r.status != models.OutboundPending {
+		writeOutboundError(c, fmt.Errorf("%w: it is %s", errOutboundNotEditable, r.status))
+		return
+	}
+
+	plan, err := reserveStock(tx, r.productID, r.quantity, scope, reservationRef{
+		Type:     models.RefOutbound,
+		ID:       r.id,
+		UserID:   middleware.CurrentUserID(c),
+		TenantID: r.tenantID,
+	})
+	if errors.Is(err, errInsufficientStock) && plan.Short > 0 {
+		writeInsufficientStock(c, plan)
+		return
+	}
+	if err != nil {
+		writeOutboundError(c, err)
+		return
+	}
+
+	_, err = tx.Exec(`UPDATE outbound_requests SET status = $1, warehouse_id = $2, updated_at = NOW() WHERE id = $3`,
+		models.OutboundConfirmed, plan.Lines[0].WarehouseID, r.id)
+	if err != nil {
+		writeOutboundError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Outbound request confirmed", "id": r.id, "allocation": plan})
+}
+
+// ShipOutboundRequest takes the reserved stock out of inventory.
+func (h *Handler) ShipOutboundRequest(c *gin.Context) {
+	h.advanceOutboundRequest(c, models.OutboundConfirmed, models.OutboundShipped, func(tx *sql.Tx, r *outboundRequest) error {
+		held, err := consumeReservations(tx, models.RefOutbound, r.id, stockMovement{
+			Reference:     fmt.Sprintf("OBR-%06d", r.id),
+			ReferenceType: models.RefOutbound,
+			UserID:        middleware.CurrentUserID(c),
+			TenantID:      r.tenantID,
+		})
+		if err == nil && len(held) == 0 {
+			err = fmt.Errorf("%w: no stock is reserved for it", errOutboundNotEditable)
+		}
+		return err
+	})
+}
+
+// CancelOutboundRequest releases whatever stock was reserved for it.
+func (h *Handler) CancelOutboundRequest(c *gin.Context) {
+	h.advanceOutboundRequest(c, "", models.OutboundCancelled, func(tx *sql.Tx, r *outboundRequest) error {
+		return releaseReservations(tx, models.RefOutbound, r.id)
+	})
+}
+
+// advanceOutboundRequest moves a request from status from, or from any
+// open status when from is empty, to status to after running step.
+func (h *Handler) advanceOutboundRequest(c *gin.Context, from, to string, step func(*sql.Tx, *outboundRequest) error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := lockOutboundRequest(tx, c, id)
+	if err != nil {
+		writeOutboundError(c, err)
+		return
+	}
+	switch {
+	case from == "" && (r.status == models.OutboundPending || r.status == models.OutboundConfirmed):
+	case r.status != from:
+		writeOutboundError(c, fmt.Errorf("%w: it is %s", errOutboundNotEditable, r.status))
+		return
+	}
+
+	if err := step(tx, r); err != nil {
+		writeOutboundError(c, err)
+		return
+	}
+	if _, err := tx.Exec(`UPDATE outbound_requests SET status = $1, updated_at = NOW() WHERE id = $2`, to, r.id); err != nil {
+		writeOutboundError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Outbound request " + to, "id": r.id, "status": to})
+}
+
+func lockOutboundRequest(tx *sql.Tx, c *gin.Context, id int) (*outboundRequest, error) {
+	var r outboundRequest
+	var tenantID sql.NullInt64
+	err := tx.QueryRow(`
+		SELECT id, product_id, quantity, COALESCE(status, $3), tenant_id FROM outbound_requests
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2)
+		  AND ($4::int IS NULL OR warehouse_id IS NULL OR warehouse_id = $4)
+		FOR UPDATE`, id, middleware.TenantID(c), models.OutboundPending, middleware.PinnedWarehouseID(c)).Scan(&r.id, &r.productID, &r.quantity, &r.status, &tenantID)
+	if err == sql.ErrNoRows {
+		return nil, errOutboundNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.tenantID = nullableInt(tenantID)
+	return &r, nil
+}
+
+func writeOutboundError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errOutboundNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errOutboundNotEditable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		writeAllocationError(c, err)
+	}
+}
@@ -0,0 +1,524 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errDispatchNotFound    = errors.New("dispatch not found")
+	errDispatchNotEditable = errors.New("dispatch cannot change in its current status")
+	errCartonNotFound      = errors.New("carton not found")
+	errInvalidPacking      = errors.New("invalid packing")
+)
+
+type dispatch struct {
+	id          int
+	productID   *int
+	quantity    int
+	status      string
+	customer    string
+	warehouseID *int
+	tenantID    *int
+}
+
+// PickDispatch reserves the dispatch's stock. When not all of it is
+// available nothing is reserved and the response says what is.
+func (h *Handler) PickDispatch(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	// The body is optional
+	var req models.DispatchPickRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	var locationWarehouseID *int
+	if req.LocationID != 0 {
+		if locationWarehouseID, err = h.locationWarehouse(c, req.LocationID); err != nil {
+			writeAllocationError(c, locationLookupError(err))
+			return
+		}
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	d, err := lockDispatch(tx, c, id)
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+	if d.status != models.DispatchPending {
+		writeShipmentError(c, fmt.Errorf("%w: it is %s", errDispatchNotEditable, d.status))
+		return
+	}
+	if d.productID == nil {
+		writeShipmentError(c, fmt.Errorf("%w: the dispatch is for an unknown product", errInvalidPacking))
+		return
+	}
+
+	scope := allocationScope{WarehouseID: d.warehouseID, LocationID: req.LocationID}
+	if scope.WarehouseID == nil {
+		scope.WarehouseID = middleware.WarehouseID(c)
+	}
+	if req.LocationID != 0 {
+		scope.WarehouseID = locationWarehouseID
+	}
+
+	plan, err := reserveStock(tx, *d.productID, d.quantity, scope, reservationRef{
+		Type:     models.RefDispatch,
+		ID:       d.id,
+		UserID:   middleware.CurrentUserID(c),
+		TenantID: d.tenantID,
+	})
+	if errors.Is(err, errInsufficientStock) && plan.Short > 0 {
+		writeInsufficientStock(c, plan)
+		return
+	}
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE dispatches SET status = $1, warehouse_id = $2, location = $3, picked_at = NOW(), updated_at = NOW()
+		WHERE id = $4`,
+		models.DispatchPicked, plan.Lines[0].WarehouseID, plan.Lines[0].LocationCode, d.id)
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dispatch picked", "id": d.id, "status": models.DispatchPicked, "allocation": plan})
+}
+
+// CancelDispatch releases the dispatch's stock and unpacks it. Cartons
+// left empty are discarded.
+func (h *Handler) CancelDispatch(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	d, err := lockDispatch(tx, c, id)
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+	switch d.status {
+	case models.DispatchPending, models.DispatchPicked, models.DispatchPacked:
+	default:
+		writeShipmentError(c, fmt.Errorf("%w: it is %s", errDispatchNotEditable, d.status))
+		return
+	}
+
+	if err := releaseReservations(tx, models.RefDispatch, d.id); err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+	_, err = tx.Exec(`
+		WITH unpacked AS (DELETE FROM carton_lines WHERE dispatch_id = $1 RETURNING carton_id)
+		DELETE FROM cartons WHERE id IN (SELECT carton_id FROM unpacked)
+		  AND NOT EXISTS (SELECT 1 FROM carton_lines cl WHERE cl.carton_id = cartons.id AND cl.dispatch_id <> $1)`, d.id)
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+	_, err = tx.Exec(`UPDATE dispatches SET status = $1, updated_at = NOW() WHERE id = $2`, models.DispatchCancelled, d.id)
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dispatch cancelled", "id": d.id, "status": models.DispatchCancelled})
+}
+
+// GetCartons lists cartons, newest first. dispatch_id and shipment_id
+// filter them; unassigned=true lists those not on a shipment yet.
+func (h *Handler) GetCartons(c *gin.Context) {
+	dispatchID, _ := strconv.Atoi(c.Query("dispatch_id"))
+	shipmentID, _ := strconv.Atoi(c.Query("shipment_id"))
+
+	rows, err := h.DB.Query(`
+		SELECT `+cartonColumns+` FROM cartons
+		WHERE ($1::int IS NULL OR tenant_id = $1) AND ($2::int IS NULL OR warehouse_id = $2)
+		  AND ($3 = 0 OR id IN (SELECT carton_id FROM carton_lines WHERE dispatch_id = $3))
+		  AND ($4 = 0 OR shipment_id = $4) AND (NOT $5 OR shipment_id IS NULL)
+		ORDER BY created_at DESC, id DESC`,
+		middleware.TenantID(c), middleware.WarehouseID(c), dispatchID, shipmentID, c.Query("unassigned") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cartons"})
+		return
+	}
+	cartons, err := scanCartons(rows)
+	if err == nil {
+		err = addCartonLines(h.DB, cartons)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cartons"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cartons})
+}
+
+func (h *Handler) GetCarton(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	carton, err := findCarton(h.DB, c, id, false)
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, carton)
+}
+
+// CreateCarton packs picked dispatches into a new carton with its own
+// SSCC. A dispatch is packed once all of its quantity is in cartons.
+func (h *Handler) CreateCarton(c *gin.Context) {
+	var req models.CartonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var warehouseID *int
+	remaining := map[int]int{}
+	for i, line := range req.Lines {
+		left, seen := remaining[line.DispatchID]
+		if !seen {
+			d, err := lockDispatch(tx, c, line.DispatchID)
+			if err != nil {
+				writeShipmentError(c, err)
+				return
+			}
+			if d.status != models.DispatchPicked {
+				writeShipmentError(c, fmt.Errorf("%w: dispatch %d is %s", errDispatchNotEditable, d.id, d.status))
+				return
+			}
+			if i > 0 && !sameWarehouse(warehouseID, d.warehouseID) {
+				writeShipmentError(c, fmt.Errorf("%w: a carton holds dispatches of one warehouse", errInvalidPacking))
+				return
+			}
+			warehouseID = d.warehouseID
+
+			var packed int
+			err = tx.QueryRow(`SELECT COALESCE(SUM(quantity), 0) FROM carton_lines WHERE dispatch_id = $1`, d.id).Scan(&packed)
+			if err != nil {
+				writeShipmentError(c, err)
+				return
+			}
+			left = d.quantity - packed
+		}
+		if line.Quantity > left {
+			writeShipmentError(c, fmt.Errorf("%w: only %d of dispatch %d is left to pack", errInvalidPacking, left, line.DispatchID))
+			return
+		}
+		remaining[line.DispatchID] = left - line.Quantity
+	}
+
+	sscc, err := nextSSCC(tx, h.SSCCPrefix)
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+
+	var cartonID int
+	err = tx.QueryRow(`
+		INSERT INTO cartons (sscc, warehouse_id, weight_kg, length_cm, width_cm, height_cm, packed_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		sscc, warehouseID, req.WeightKg, req.LengthCm, req.WidthCm, req.HeightCm, middleware.CurrentUserID(c),
+		middleware.TenantID(c)).Scan(&cartonID)
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+	for _, line := range req.Lines {
+		_, err := tx.Exec(`INSERT INTO carton_lines (carton_id, dispatch_id, quantity) VALUES ($1, $2, $3)`,
+			cartonID, line.DispatchID, line.Quantity)
+		if err != nil {
+			writeShipmentError(c, err)
+			return
+		}
+	}
+	for dispatchID, left := range remaining {
+		if left > 0 {
+			continue
+		}
+		_, err := tx.Exec(`UPDATE dispatches SET status = $1, packed_at = NOW(), updated_at = NOW() WHERE id = $2`,
+			models.DispatchPacked, dispatchID)
+		if err != nil {
+			writeShipmentError(c, err)
+			return
+		}
+	}
+
+	carton, err := findCarton(tx, c, cartonID, false)
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, carton)
+}
+
+// DeleteCarton unpacks a carton that has not shipped. Its dispatches go
+// back to picked.
+func (h *Handler) DeleteCarton(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	carton, err := findCarton(tx, c, id, true)
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+	if carton.ShipmentID != nil {
+		var status string
+		if err := tx.QueryRow(`SELECT status FROM shipments WHERE id = $1`, *carton.ShipmentID).Scan(&status); err != nil {
+			writeShipmentError(c, err)
+			return
+		}
+		if status != models.ShipmentOpen {
+			writeShipmentError(c, fmt.Errorf("%w: the carton's shipment is %s", errShipmentNotEditable, status))
+			return
+		}
+	}
+
+	for _, line := range carton.Lines {
+		d, err := lockDispatch(tx, c, line.DispatchID)
+		if err != nil {
+			writeShipmentError(c, err)
+			return
+		}
+		if d.status != models.DispatchPicked && d.status != models.DispatchPacked {
+			writeShipmentError(c, fmt.Errorf("%w: dispatch %d is %s", errDispatchNotEditable, d.id, d.status))
+			return
+		}
+		_, err = tx.Exec(`UPDATE dispatches SET status = $1, packed_at = NULL, updated_at = NOW() WHERE id = $2`,
+			models.DispatchPicked, d.id)
+		if err != nil {
+			writeShipmentError(c, err)
+			return
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM cartons WHERE id = $1`, carton.ID); err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Carton unpacked"})
+}
+
+// nextSSCC builds an SSCC-18: extension digit, GS1 company prefix, serial
+// reference and check digit.
+func nextSSCC(q dbtx, prefix string) (string, error) {
+	if len(prefix) < 7 || len(prefix) > 10 || !allDigits(prefix) {
+		return "", fmt.Errorf("%w: the SSCC company prefix must be 7 to 10 digits", errInvalidPacking)
+	}
+	var serial int64
+	if err := q.QueryRow(`SELECT nextval('carton_serial_seq')`).Scan(&serial); err != nil {
+		return "", err
+	}
+
+	digits := 16 - len(prefix)
+	limit := int64(1)
+	for i := 0; i < digits; i++ {
+		limit *= 10
+	}
+	body := fmt.Sprintf("0%s%0*d", prefix, digits, serial%limit)
+	return body + strconv.Itoa(gs1CheckDigit(body)), nil
+}
+
+// gs1CheckDigit is the GS1 mod 10 check digit: weights 3 and 1 alternate
+// from the rightmost digit.
+func gs1CheckDigit(body string) int {
+	sum := 0
+	for i := len(body) - 1; i >= 0; i-- {
+		d := int(body[i] - '0')
+		if (len(body)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func lockDispatch(tx *sql.Tx, c *gin.Context, id int) (*dispatch, error) {
+	var d dispatch
+	var productID, warehouseID, tenantID sql.NullInt64
+	err := tx.QueryRow(`
+		SELECT id, product_id, quantity, status, COALESCE(customer, ''), warehouse_id, tenant_id FROM dispatches
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2)
+		  AND ($3::int IS NULL OR warehouse_id IS NULL OR warehouse_id = $3)
+		FOR UPDATE`, id, middleware.TenantID(c), middleware.PinnedWarehouseID(c)).Scan(&d.id, &productID, &d.quantity, &d.status,
+		&d.customer, &warehouseID, &tenantID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %d", errDispatchNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.productID = nullableInt(productID)
+	d.warehouseID = nullableInt(warehouseID)
+	d.tenantID = nullableInt(tenantID)
+	return &d, nil
+}
+
+const cartonColumns = `id, sscc, shipment_id, warehouse_id, weight_kg, length_cm, width_cm, height_cm, packed_by, created_at`
+
+// findCarton loads a carton with its lines, locking it when lock is set.
+func findCarton(q dbtx, c *gin.Context, id int, lock bool) (*models.Carton, error) {
+	query := `SELECT ` + cartonColumns + ` FROM cartons
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2) AND ($3::int IS NULL OR warehouse_id = $3)`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	rows, err := q.Query(query, id, middleware.TenantID(c), middleware.WarehouseID(c))
+	if err != nil {
+		return nil, err
+	}
+	cartons, err := scanCartons(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(cartons) == 0 {
+		return nil, fmt.Errorf("%w: %d", errCartonNotFound, id)
+	}
+	if err := addCartonLines(q, cartons); err != nil {
+		return nil, err
+	}
+	return &cartons[0], nil
+}
+
+func scanCartons(rows *sql.Rows) ([]models.Carton, error) {
+	defer rows.Close()
+
+	var cartons []models.Carton
+	for rows.Next() {
+		var ct models.Carton
+		var shipmentID, warehouseID, packedBy sql.NullInt64
+		var weight, length, width, height sql.NullFloat64
+		err := rows.Scan(&ct.ID, &ct.SSCC, &shipmentID, &warehouseID, &weight, &length, &width, &height, &packedBy, &ct.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		ct.ShipmentID = nullableInt(shipmentID)
+		ct.WarehouseID = nullableInt(warehouseID)
+		ct.PackedBy = nullableInt(packedBy)
+		ct.WeightKg, ct.LengthCm = nullableFloat(weight), nullableFloat(length)
+		ct.WidthCm, ct.HeightCm = nullableFloat(width), nullableFloat(height)
+		cartons = append(cartons, ct)
+	}
+	return cartons, rows.Err()
+}
+
+// addCartonLines fills in the lines of cartons.
+func addCartonLines(q dbtx, cartons []models.Carton) error {
+	for i := range cartons {
+		rows, err := q.Query(`
+			SELECT cl.id, cl.dispatch_id, d.product_name, COALESCE(d.customer, ''), cl.quantity
+			FROM carton_lines cl
+			JOIN dispatches d ON cl.dispatch_id = d.id
+			WHERE cl.carton_id = $1
+			ORDER BY cl.id`, cartons[i].ID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var line models.CartonLine
+			if err := rows.Scan(&line.ID, &line.DispatchID, &line.ProductName, &line.Customer, &line.Quantity); err != nil {
+				rows.Close()
+				return err
+			}
+			cartons[i].Lines = append(cartons[i].Lines, line)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func nullableFloat(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
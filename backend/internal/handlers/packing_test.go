@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+)
+
+// fakeDispatch is one dispatches row of tenant 3 in warehouse 1, with what
+// is already packed of it.
+type fakeDispatch struct {
+	quantity, packed int
+	status           string
+}
+
+// onDispatches answers the dispatch locks of the packing and shipping
+// handlers from dispatches and records the statuses they set. Updates by
+// shipment apply to every dispatch.
+func onDispatches(db *fakeDB, dispatches map[int]*fakeDispatch) {
+	tenant, site := 3, 1
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		d, ok := dispatches[argInt(st.Args[0])]
+		if !ok || !inScope(st.Args[1], &tenant) || !inScope(st.Args[2], &site) {
+			return nil, nil
+		}
+		return &dbtest.Result{Rows: [][]driver.Value{{st.Args[0], int64(1), int64(d.quantity), d.status, "Toko Maju",
+			int64(site), int64(tenant)}}}, nil
+	}, "FROM dispatches", "FOR UPDATE")
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		for _, d := range dispatches {
+			d.status = st.Args[0].(string)
+		}
+		return &dbtest.Result{RowsAffected: int64(len(dispatches))}, nil
+	}, "UPDATE dispatches SET status", "WHERE id IN")
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		dispatches[argInt(st.Args[1])].status = st.Args[0].(string)
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE dispatches SET status")
+}
+
+// statuses maps each dispatch to its status.
+func statuses(dispatches map[int]*fakeDispatch) map[int]string {
+	got := map[int]string{}
+	for id, d := range dispatches {
+		got[id] = d.status
+	}
+	return got
+}
+
+func TestCreateCarton(t *testing.T) {
+	other := 4
+	staff := auth.Claims{UserID: 7}
+	tests := []struct {
+		name         string
+		claims       auth.Claims
+		lines        string
+		prefix       string
+		wantCode     int
+		wantStatuses map[int]string
+		wantPacked   []string
+	}{
+		{name: "packing the rest of a dispatch packs it", claims: staff, lines: `{"dispatch_id": 81, "quantity": 2}, {"dispatch_id": 82, "quantity": 1}`,
+			wantCode:     http.StatusCreated,
+			wantStatuses: map[int]string{81: models.DispatchPacked, 82: models.DispatchPicked, 83: models.DispatchPending},
+			wantPacked:   []string{"81:2", "82:1"}},
+		{name: "more than is left", claims: staff, lines: `{"dispatch_id": 81, "quantity": 2}, {"dispatch_id": 81, "quantity": 1}`,
+			wantCode: http.StatusBadRequest},
+		{name: "dispatch not picked", claims: staff, lines: `{"dispatch_id": 83, "quantity": 1}`,
+			wantCode: http.StatusConflict},
+		{name: "bad company prefix", claims: staff, lines: `{"dispatch_id": 82, "quantity": 1}`, prefix: "12AB567",
+			wantCode: http.StatusBadRequest},
+		{name: "other tenants' dispatches", claims: auth.Claims{UserID: 7, TenantID: &other}, lines: `{"dispatch_id": 81, "quantity": 1}`,
+			wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dispatches := map[int]*fakeDispatch{
+				81: {quantity: 4, packed: 2, status: models.DispatchPicked},
+				82: {quantity: 3, status: models.DispatchPicked},
+				83: {quantity: 1, status: models.DispatchPending},
+			}
+			db := &fakeDB{}
+			onDispatches(db, dispatches)
+			db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+				return &dbtest.Result{Rows: [][]driver.Value{{int64(dispatches[argInt(st.Args[0])].packed)}}}, nil
+			}, "SELECT COALESCE(SUM(quantity), 0) FROM carton_lines")
+			db.on(rows([]driver.Value{int64(42)}), "nextval('carton_serial_seq')")
+			db.on(rows([]driver.Value{int64(5)}), "INSERT INTO cartons")
+			var packed []string
+			db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+				packed = append(packed, fmt.Sprintf("%d:%d", argInt(st.Args[1]), argInt(st.Args[2])))
+				return &dbtest.Result{RowsAffected: 1}, nil
+			}, "INSERT INTO carton_lines")
+			db.on(rows([]driver.Value{int64(5), "006141411000000423", nil, int64(1), nil, nil, nil, nil, int64(7), time.Now()}),
+				"FROM cartons WHERE id = $1")
+			db.on(rows(), "FROM carton_lines cl", "WHERE cl.carton_id = $1")
+			prefix := tt.prefix
+			if prefix == "" {
+				prefix = "0614141"
+			}
+			h := &Handler{DB: db.open(t), SSCCPrefix: prefix}
+
+			w := serveAs(&tt.claims, "/cartons", h.CreateCarton, jsonRequest(http.MethodPost, "/cartons", `{"lines": [`+tt.lines+`]}`))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if db.committed != (tt.wantCode == http.StatusCreated) {
+				t.Errorf("committed = %v on a %d", db.committed, w.Code)
+			}
+			if tt.wantCode != http.StatusCreated {
+				return
+			}
+			if got := statuses(dispatches); !reflect.DeepEqual(got, tt.wantStatuses) {
+				t.Errorf("dispatches = %v, want %v", got, tt.wantStatuses)
+			}
+			if !reflect.DeepEqual(packed, tt.wantPacked) {
+				t.Errorf("packed = %v, want %v", packed, tt.wantPacked)
+			}
+		})
+	}
+}
+
+func TestGS1CheckDigit(t *testing.T) {
+	// 4006381333931 is a valid EAN-13; SSCCs use the same mod 10 scheme.
+	if got := gs1CheckDigit("400638133393"); got != 1 {
+		t.Errorf("gs1CheckDigit(400638133393) = %d, want 1", got)
+	}
+
+	db := &fakeDB{}
+	db.on(rows([]driver.Value{int64(42)}), "nextval('carton_serial_seq')")
+	tx := beginTestTx(t, db.handle)
+	sscc, err := nextSSCC(tx, "0614141")
+	if err != nil {
+		t.Fatalf("nextSSCC() error = %v", err)
+	}
+	if len(sscc) != 18 || sscc[:17] != "00614141000000042" || int(sscc[17]-'0') != gs1CheckDigit(sscc[:17]) {
+		t.Errorf("nextSSCC() = %s, want 00614141000000042 and its check digit", sscc)
+	}
+}
@@ -0,0 +1,643 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var (
+	errPickListNotFound    = errors.New("pick list not found")
+	errPickListNotEditable = errors.New("pick list cannot change in its current status")
+	errInvalidPick         = errors.New("invalid pick")
+)
+
+const pickListColumns = `id, document_number, warehouse_id, mode, zone_id, status, assigned_to, created_by,
+	completed_at, tenant_id, created_at, updated_at`
+
+// pickCandidatesSQL lists the reservations of the given orders that are
+// not on a live pick line yet, in walk order: pick sequence, then code.
+const pickCandidatesSQL = `
+	SELECT r.id, ol.order_id, ol.id, r.product_id, r.location_id, r.batch, r.quantity
+	FROM stock_reservations r
+	JOIN order_lines ol ON r.reference_type = $2 AND r.reference_id = ol.id
+	JOIN locations l ON r.location_id = l.id
+	WHERE ol.order_id = ANY($1) AND r.status = $3
+	  AND NOT EXISTS (SELECT 1 FROM pick_list_lines pl WHERE pl.reservation_id = r.id AND pl.status <> $4)
+	ORDER BY l.pick_sequence NULLS LAST, l.code, r.batch, ol.order_id, ol.id`
+
+type pickCandidate struct {
+	reservationID int
+	orderID       int
+	orderLineID   int
+	productID     int
+	locationID    int
+	batch         string
+	quantity      int
+}
+
+// GetPickLists lists pick lists, newest first. assigned_to=me shows the
+// caller's own lists.
+func (h *Handler) GetPickLists(c *gin.Context) {
+	assignedTo := 0
+	if param := c.Query("assigned_to"); param == "me" {
+		assignedTo = middleware.CurrentUserID(c)
+	} else if param != "" {
+		assignedTo, _ = strconv.Atoi(param)
+	}
+
+	rows, err := h.DB.Query(`
+		SELECT `+pickListColumns+` FROM pick_lists
+		WHERE ($1::int IS NULL OR tenant_id = $1) AND ($2::int IS NULL OR warehouse_id = $2)
+		  AND ($3 = '' OR status = $3) AND ($4 = 0 OR assigned_to = $4)
+		ORDER BY created_at DESC`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("status"), assignedTo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pick lists"})
+		return
+	}
+	defer rows.Close()
+
+	var lists []models.PickList
+	for rows.Next() {
+		p, err := scanPickList(rows)
+		if err != nil {
+			continue
+		}
+		lists = append(lists, *p)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": lists})
+}
+
+func (h *Handler) GetPickList(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	p, err := scanPickList(h.DB.QueryRow(`
+		SELECT `+pickListColumns+` FROM pick_lists
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2) AND ($3::int IS NULL OR warehouse_id = $3)`,
+		id, middleware.TenantID(c), middleware.WarehouseID(c)))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pick list not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pick list"})
+		return
+	}
+
+	if p.Lines, err = pickListLines(h.DB, p.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pick lines"})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// CreatePickLists builds pick lists for allocated orders of one warehouse
+// and moves the orders to picking. Zone mode returns one list per zone;
+// the other modes return a single list.
+func (h *Handler) CreatePickLists(c *gin.Context) {
+	var req models.PickListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch req.Mode {
+	case models.PickSingle:
+		if len(req.OrderIDs) != 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Single picking takes exactly one order"})
+			return
+		}
+	case models.PickBatch, models.PickZone:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be single, batch or zone"})
+		return
+	}
+
+	userID := middleware.CurrentUserID(c)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var orders []*models.Order
+	for i, id := range req.OrderIDs {
+		o, err := h.lockOrder(tx, c, id)
+		if err != nil {
+			writePickError(c, err)
+			return
+		}
+		if o.Status != models.OrderAllocated && o.Status != models.OrderPicking {
+			writePickError(c, fmt.Errorf("%w: order %s is %s", errOrderNotEditable, o.OrderNumber, o.Status))
+			return
+		}
+		if i > 0 && !sameWarehouse(orders[0].WarehouseID, o.WarehouseID) {
+			writePickError(c, fmt.Errorf("%w: all orders must be allocated from one warehouse", errInvalidPick))
+			return
+		}
+		orders = append(orders, o)
+	}
+
+	candidates, err := pickCandidates(tx, req.OrderIDs)
+	if err != nil {
+		writePickError(c, err)
+		return
+	}
+	if len(candidates) == 0 {
+		writePickError(c, fmt.Errorf("%w: nothing is left to pick for these orders", errPickListNotEditable))
+		return
+	}
+
+	// Candidates are in walk order, which every group keeps
+	var zones []int
+	groups := map[int][]pickCandidate{}
+	zoneOf := map[int]int{}
+	for _, cand := range candidates {
+		zone := 0
+		if req.Mode == models.PickZone {
+			z, seen := zoneOf[cand.locationID]
+			if !seen {
+				if z, err = locationZone(tx, cand.locationID); err != nil {
+					writePickError(c, err)
+					return
+				}
+				zoneOf[cand.locationID] = z
+			}
+			zone = z
+		}
+		if _, ok := groups[zone]; !ok {
+			zones = append(zones, zone)
+		}
+		groups[zone] = append(groups[zone], cand)
+	}
+
+	var lists []models.PickList
+	for _, zone := range zones {
+		var zoneID *int
+		if zone != 0 {
+			z := zone
+			zoneID = &z
+		}
+		p, err := scanPickList(tx.QueryRow(`
+			INSERT INTO pick_lists (warehouse_id, mode, zone_id, assigned_to, created_by, tenant_id)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+pickListColumns,
+			orders[0].WarehouseID, req.Mode, zoneID, req.AssignedTo, userID, middleware.TenantID(c)))
+		if err != nil {
+			writePickError(c, err)
+			return
+		}
+		if err := addPickLines(tx, p.ID, groups[zone]); err != nil {
+			writePickError(c, err)
+			return
+		}
+		if p.Lines, err = pickListLines(tx, p.ID); err != nil {
+			writePickError(c, err)
+			return
+		}
+		lists = append(lists, *p)
+	}
+
+	for _, o := range orders {
+		if o.Status == models.OrderAllocated {
+			if err := setOrderStatus(tx, o, models.OrderPicking); err != nil {
+				writePickError(c, err)
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": lists})
+}
+
+func (h *Handler) AssignPickList(c *gin.Context) {
+	var req models.PickAssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.updatePickList(c, func(tx *sql.Tx, p *models.PickList) error {
+		_, err := tx.Exec(`UPDATE pick_lists SET assigned_to = $1, updated_at = NOW() WHERE id = $2`, req.UserID, p.ID)
+		return err
+	})
+}
+
+// CancelPickList drops the lines not picked yet. Their stock stays
+// reserved for the orders and can go on a new list.
+func (h *Handler) CancelPickList(c *gin.Context) {
+	h.updatePickList(c, func(tx *sql.Tx, p *models.PickList) error {
+		_, err := tx.Exec(`UPDATE pick_list_lines SET status = $1 WHERE pick_list_id = $2 AND status = $3`,
+			models.PickLineCancelled, p.ID, models.PickLinePending)
+		if err == nil {
+			_, err = tx.Exec(`UPDATE pick_lists SET status = $1, updated_at = NOW() WHERE id = $2`, models.PickListCancelled, p.ID)
+		}
+		return err
+	})
+}
+
+// ConfirmPickLine records a pick from a handheld. Picking less than the
+// line asks for is handled as a short pick.
+func (h *Handler) ConfirmPickLine(c *gin.Context) {
+	var req models.PickConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.updatePickLine(c, func(tx *sql.Tx, p *models.PickList, line *models.PickListLine, userID int) (int, error) {
+		if req.LocationCode != "" && !strings.EqualFold(req.LocationCode, line.LocationCode) {
+			return 0, fmt.Errorf("%w: scanned %s, the line is at %s", errInvalidPick, req.LocationCode, line.LocationCode)
+		}
+		quantity := line.Quantity
+		if req.Quantity != nil {
+			quantity = *req.Quantity
+		}
+		if quantity > line.Quantity {
+			return 0, fmt.Errorf("%w: only %d to pick", errInvalidPick, line.Quantity)
+		}
+		if quantity < line.Quantity {
+			return shortPick(tx, p, line, quantity, req.Notes, userID)
+		}
+		return 0, pickLine(tx, line, quantity, models.PickLinePicked, req.Notes, userID)
+	})
+}
+
+// ShortPickLine records that less than the line was found. The missing
+// stock is released and reallocated from other locations onto this list.
+func (h *Handler) ShortPickLine(c *gin.Context) {
+	var req models.PickShortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.updatePickLine(c, func(tx *sql.Tx, p *models.PickList, line *models.PickListLine, userID int) (int, error) {
+		if req.PickedQuantity >= line.Quantity {
+			return 0, fmt.Errorf("%w: picked_quantity must be less than %d", errInvalidPick, line.Quantity)
+		}
+		return shortPick(tx, p, line, req.PickedQuantity, req.Notes, userID)
+	})
+}
+
+// updatePickList locks an open or started pick list and runs step.
+func (h *Handler) updatePickList(c *gin.Context, step func(*sql.Tx, *models.PickList) error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	p, err := h.lockPickList(tx, c, id)
+	if err != nil {
+		writePickError(c, err)
+		return
+	}
+	if err := step(tx, p); err != nil {
+		writePickError(c, err)
+		return
+	}
+	h.commitPickList(c, tx, p.ID, nil)
+}
+
+// updatePickLine locks a pending line of an open or started list and runs
+// step, which returns how much it reallocated.
+func (h *Handler) updatePickLine(c *gin.Context, step func(*sql.Tx, *models.PickList, *models.PickListLine, int) (int, error)) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	lineID, err := strconv.Atoi(c.Param("lineId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid line ID"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	p, err := h.lockPickList(tx, c, id)
+	if err != nil {
+		writePickError(c, err)
+		return
+	}
+	line, err := findPickLine(tx, p.ID, lineID)
+	if err != nil {
+		writePickError(c, err)
+		return
+	}
+	if line.Status != models.PickLinePending {
+		writePickError(c, fmt.Errorf("%w: line is %s", errPickListNotEditable, line.Status))
+		return
+	}
+
+	reallocated, err := step(tx, p, line, middleware.CurrentUserID(c))
+	if err != nil {
+		writePickError(c, err)
+		return
+	}
+	if err := completePickList(tx, p.ID); err != nil {
+		writePickError(c, err)
+		return
+	}
+	h.commitPickList(c, tx, p.ID, gin.H{"reallocated": reallocated})
+}
+
+func (h *Handler) commitPickList(c *gin.Context, tx *sql.Tx, id int, extra gin.H) {
+	p, err := scanPickList(tx.QueryRow(`SELECT `+pickListColumns+` FROM pick_lists WHERE id = $1`, id))
+	if err == nil {
+		p.Lines, err = pickListLines(tx, id)
+	}
+	if err != nil {
+		writePickError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	if extra == nil {
+		c.JSON(http.StatusOK, p)
+		return
+	}
+	extra["pick_list"] = p
+	c.JSON(http.StatusOK, extra)
+}
+
+// pickLine closes a line with what was picked and counts it on the order
+// line.
+func pickLine(tx *sql.Tx, line *models.PickListLine, picked int, status, notes string, userID int) error {
+	_, err := tx.Exec(`
+		UPDATE pick_list_lines SET picked_quantity = $1, status = $2, notes = $3, picked_by = $4, picked_at = NOW()
+		WHERE id = $5`, picked, status, notes, userID, line.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE order_lines SET picked_quantity = picked_quantity + $1 WHERE id = $2`, picked, line.OrderLineID)
+	return err
+}
+
+// shortPick closes a line with less than asked, gives the missing stock
+// back and tries to reserve it elsewhere in the warehouse, away from the
+// location that came up short. Whatever is found goes on this list as new
+// lines; the rest is no longer allocated and ends up on the backorder.
+func shortPick(tx *sql.Tx, p *models.PickList, line *models.PickListLine, picked int, notes string, userID int) (int, error) {
+	if err := pickLine(tx, line, picked, models.PickLineShort, notes, userID); err != nil {
+		return 0, err
+	}
+	missing := line.Quantity - picked
+	if err := shrinkReservation(tx, line.ReservationID, missing); err != nil {
+		return 0, err
+	}
+
+	plan, err := planAllocation(tx, line.ProductID, missing, allocationScope{
+		WarehouseID:       p.WarehouseID,
+		ExcludeLocationID: line.LocationID,
+		Lock:              true,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if plan.Allocated > 0 {
+		var tenantID *int
+		if err := tx.QueryRow(`SELECT tenant_id FROM orders WHERE id = $1`, line.OrderID).Scan(&tenantID); err != nil {
+			return 0, err
+		}
+		err := reservePlan(tx, plan, reservationRef{Type: models.RefOrderLine, ID: line.OrderLineID, UserID: userID, TenantID: tenantID})
+		if err != nil {
+			return 0, err
+		}
+		candidates, err := pickCandidates(tx, []int{line.OrderID})
+		if err != nil {
+			return 0, err
+		}
+		var replacements []pickCandidate
+		for _, cand := range candidates {
+			if cand.orderLineID == line.OrderLineID {
+				replacements = append(replacements, cand)
+			}
+		}
+		if err := addPickLines(tx, p.ID, replacements); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE order_lines SET allocated_quantity = allocated_quantity - $1 WHERE id = $2`,
+		plan.Short, line.OrderLineID)
+	return plan.Allocated, err
+}
+
+// completePickList closes a list once no line is left to pick, and starts
+// it otherwise.
+func completePickList(tx *sql.Tx, id int) error {
+	_, err := tx.Exec(`
+		UPDATE pick_lists
+		SET status = CASE WHEN EXISTS (SELECT 1 FROM pick_list_lines WHERE pick_list_id = $1 AND status = $2)
+		                  THEN $3 ELSE $4 END,
+		    completed_at = CASE WHEN EXISTS (SELECT 1 FROM pick_list_lines WHERE pick_list_id = $1 AND status = $2)
+		                        THEN NULL ELSE NOW() END,
+		    updated_at = NOW()
+		WHERE id = $1`,
+		id, models.PickLinePending, models.PickListInProgress, models.PickListCompleted)
+	return err
+}
+
+func pickCandidates(tx *sql.Tx, orderIDs []int) ([]pickCandidate, error) {
+	rows, err := tx.Query(pickCandidatesSQL, pq.Array(orderIDs), models.RefOrderLine, models.ReservationActive,
+		models.PickLineCancelled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []pickCandidate
+	for rows.Next() {
+		var cand pickCandidate
+		err := rows.Scan(&cand.reservationID, &cand.orderID, &cand.orderLineID, &cand.productID, &cand.locationID,
+			&cand.batch, &cand.quantity)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, cand)
+	}
+	return candidates, rows.Err()
+}
+
+// addPickLines appends candidates to a list after its existing lines.
+func addPickLines(tx *sql.Tx, pickListID int, candidates []pickCandidate) error {
+	var sequence int
+	err := tx.QueryRow(`SELECT COALESCE(MAX(sequence), 0) FROM pick_list_lines WHERE pick_list_id = $1`, pickListID).Scan(&sequence)
+	if err != nil {
+		return err
+	}
+	for _, cand := range candidates {
+		sequence++
+		_, err := tx.Exec(`
+			INSERT INTO pick_list_lines (pick_list_id, order_id, order_line_id, reservation_id, product_id, location_id,
+			                             batch, quantity, sequence)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			pickListID, cand.orderID, cand.orderLineID, cand.reservationID, cand.productID, cand.locationID,
+			cand.batch, cand.quantity, sequence)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// locationZone returns the zone a location sits in, or 0 when it is
+// outside every zone.
+func locationZone(q dbtx, locationID int) (int, error) {
+	var zoneID sql.NullInt64
+	err := q.QueryRow(`
+		WITH RECURSIVE up AS (
+			SELECT id, parent_id, location_type, 0 AS depth FROM locations WHERE id = $1
+			UNION ALL
+			SELECT l.id, l.parent_id, l.location_type, up.depth + 1
+			FROM locations l JOIN up ON l.id = up.parent_id
+			WHERE up.depth < 10
+		)
+		SELECT id FROM up WHERE location_type = $2 ORDER BY depth LIMIT 1`,
+		locationID, models.LocationZone).Scan(&zoneID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return int(zoneID.Int64), err
+}
+
+// lockPickList loads a pick list for update; it must still be open or in
+// progress.
+func (h *Handler) lockPickList(tx *sql.Tx, c *gin.Context, id int) (*models.PickList, error) {
+	p, err := scanPickList(tx.QueryRow(`
+		SELECT `+pickListColumns+` FROM pick_lists
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2) AND ($3::int IS NULL OR warehouse_id = $3)
+		FOR UPDATE`,
+		id, middleware.TenantID(c), middleware.PinnedWarehouseID(c)))
+	if err == sql.ErrNoRows {
+		return nil, errPickListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != models.PickListOpen && p.Status != models.PickListInProgress {
+		return nil, fmt.Errorf("%w: pick list is %s", errPickListNotEditable, p.Status)
+	}
+	return p, nil
+}
+
+const pickLineColumns = `pl.id, pl.pick_list_id, pl.sequence, pl.order_id, o.order_number, pl.order_line_id, pl.reservation_id,
+	pl.product_id, p.name, p.sku, pl.location_id, l.code, pl.batch, pl.quantity, pl.picked_quantity, pl.status,
+	pl.notes, pl.picked_by, pl.picked_at`
+
+const pickLineFrom = ` FROM pick_list_lines pl
+	JOIN orders o ON pl.order_id = o.id
+	JOIN warehouse_product p ON pl.product_id = p.id
+	JOIN locations l ON pl.location_id = l.id`
+
+func findPickLine(tx *sql.Tx, pickListID, lineID int) (*models.PickListLine, error) {
+	line, err := scanPickLine(tx.QueryRow(`SELECT `+pickLineColumns+pickLineFrom+`
+		WHERE pl.id = $1 AND pl.pick_list_id = $2
+		FOR UPDATE OF pl`, lineID, pickListID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: line %d is not on this list", errPickListNotFound, lineID)
+	}
+	return line, err
+}
+
+func pickListLines(q dbtx, pickListID int) ([]models.PickListLine, error) {
+	rows, err := q.Query(`SELECT `+pickLineColumns+pickLineFrom+`
+		WHERE pl.pick_list_id = $1
+		ORDER BY pl.sequence`, pickListID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []models.PickListLine
+	for rows.Next() {
+		line, err := scanPickLine(rows)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, *line)
+	}
+	return lines, rows.Err()
+}
+
+func scanPickLine(row scanner) (*models.PickListLine, error) {
+	var l models.PickListLine
+	var pickedBy sql.NullInt64
+	var pickedAt sql.NullTime
+	err := row.Scan(&l.ID, &l.PickListID, &l.Sequence, &l.OrderID, &l.OrderNumber, &l.OrderLineID, &l.ReservationID,
+		&l.ProductID, &l.ProductName, &l.SKU, &l.LocationID, &l.LocationCode, &l.Batch, &l.Quantity, &l.PickedQuantity,
+		&l.Status, &l.Notes, &pickedBy, &pickedAt)
+	if err != nil {
+		return nil, err
+	}
+	l.PickedBy = nullableInt(pickedBy)
+	l.PickedAt = nullableTime(pickedAt)
+	return &l, nil
+}
+
+func scanPickList(row scanner) (*models.PickList, error) {
+	var p models.PickList
+	var warehouseID, zoneID, assignedTo, createdBy, tenantID sql.NullInt64
+	var completedAt sql.NullTime
+	err := row.Scan(&p.ID, &p.DocumentNumber, &warehouseID, &p.Mode, &zoneID, &p.Status, &assignedTo, &createdBy,
+		&completedAt, &tenantID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.WarehouseID = nullableInt(warehouseID)
+	p.ZoneID = nullableInt(zoneID)
+	p.AssignedTo = nullableInt(assignedTo)
+	p.CreatedBy = nullableInt(createdBy)
+	p.TenantID = nullableInt(tenantID)
+	p.CompletedAt = nullableTime(completedAt)
+	return &p, nil
+}
+
+func writePickError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errPickListNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errPickListNotEditable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidPick):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		writeOrderError(c, err)
+	}
+}
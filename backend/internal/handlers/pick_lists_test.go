@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+)
+
+// fakePickLine is one pick_list_lines row.
+type fakePickLine struct {
+	id, reservationID, locationID int
+	batch                         string
+	quantity, picked, sequence    int
+	status                        string
+}
+
+// fakePickList serves pick list 1 of tenant 3 in warehouse 1 on top of the
+// warehouse of newFakeLedger. Its line 61 picks the 4 units of lot L2 held
+// for order line 41 of order 1.
+type fakePickList struct {
+	*fakeLedger
+	status      string
+	lines       []*fakePickLine
+	picked      int
+	unallocated int
+}
+
+func newFakePickList(status, lineStatus string) *fakePickList {
+	tenant, site := 3, 1
+	p := &fakePickList{fakeLedger: newFakeLedger(models.AllocationFEFO), status: status}
+	p.lines = []*fakePickLine{{id: 61, reservationID: 1, locationID: 11, batch: "L2", quantity: 4, sequence: 1, status: lineStatus}}
+	p.balance(1, 11, "L2").reserved = 4
+	p.reservations = append(p.reservations, &fakeReservation{id: 1, productID: 1, locationID: 11, batch: "L2", quantity: 4,
+		referenceType: models.RefOrderLine, referenceID: 41, status: models.ReservationActive})
+
+	list := func() *dbtest.Result {
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(1), "PL-1", int64(site), models.PickSingle, nil, p.status,
+			nil, nil, nil, int64(tenant), time.Now(), time.Now()}}}
+	}
+	codes := map[int]string{10: "A-01", 11: "A-02", 12: "A-03"}
+	line := func(l *fakePickLine) []driver.Value {
+		return []driver.Value{int64(l.id), int64(1), int64(l.sequence), int64(1), "SO-1", int64(41), int64(l.reservationID),
+			int64(1), "Gula", "GL-1", int64(l.locationID), codes[l.locationID], l.batch, int64(l.quantity), int64(l.picked),
+			l.status, "", nil, nil}
+	}
+
+	p.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[0]) != 1 || !inScope(st.Args[1], &tenant) || !inScope(st.Args[2], &site) {
+			return nil, nil
+		}
+		return list(), nil
+	}, "FROM pick_lists", "FOR UPDATE")
+	p.on(func(dbtest.Statement) (*dbtest.Result, error) { return list(), nil }, "FROM pick_lists WHERE id = $1")
+	p.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		for _, l := range p.lines {
+			if l.id == argInt(st.Args[0]) {
+				return &dbtest.Result{Rows: [][]driver.Value{line(l)}}, nil
+			}
+		}
+		return nil, nil
+	}, "FROM pick_list_lines pl", "WHERE pl.id = $1")
+	p.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		res := &dbtest.Result{}
+		for _, l := range p.lines {
+			res.Rows = append(res.Rows, line(l))
+		}
+		return res, nil
+	}, "FROM pick_list_lines pl", "ORDER BY pl.sequence")
+	p.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		for _, l := range p.lines {
+			if l.id == argInt(st.Args[4]) {
+				l.picked, l.status = argInt(st.Args[0]), st.Args[1].(string)
+			}
+		}
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE pick_list_lines SET picked_quantity")
+	p.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		for _, l := range p.lines {
+			if l.status == st.Args[2] {
+				l.status = st.Args[0].(string)
+			}
+		}
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE pick_list_lines SET status")
+	p.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		p.status = st.Args[3].(string)
+		for _, l := range p.lines {
+			if l.status == st.Args[1] {
+				p.status = st.Args[2].(string)
+			}
+		}
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE pick_lists", "CASE WHEN EXISTS")
+	p.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		p.status = st.Args[0].(string)
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE pick_lists SET status")
+	p.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		p.picked += argInt(st.Args[0])
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE order_lines SET picked_quantity")
+	p.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		p.unallocated += argInt(st.Args[0])
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE order_lines SET allocated_quantity = allocated_quantity -")
+	p.on(rows([]driver.Value{int64(tenant)}), "SELECT tenant_id FROM orders")
+	p.on(p.candidates, "FROM stock_reservations r", "JOIN order_lines ol")
+	p.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(len(p.lines))}}}, nil
+	}, "SELECT COALESCE(MAX(sequence), 0) FROM pick_list_lines")
+	p.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		p.lines = append(p.lines, &fakePickLine{id: 60 + argInt(st.Args[8]), reservationID: argInt(st.Args[3]),
+			locationID: argInt(st.Args[5]), batch: st.Args[6].(string), quantity: argInt(st.Args[7]),
+			sequence: argInt(st.Args[8]), status: models.PickLinePending})
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "INSERT INTO pick_list_lines")
+	return p
+}
+
+// candidates answers pickCandidatesSQL: active reservations of order line
+// 41 that no live pick line covers.
+func (p *fakePickList) candidates(dbtest.Statement) (*dbtest.Result, error) {
+	res := &dbtest.Result{}
+	for _, r := range p.reservations {
+		listed := false
+		for _, l := range p.lines {
+			listed = listed || l.reservationID == r.id && l.status != models.PickLineCancelled
+		}
+		if r.status == models.ReservationActive && !listed {
+			res.Rows = append(res.Rows, []driver.Value{int64(r.id), int64(1), int64(41), int64(r.productID),
+				int64(r.locationID), r.batch, int64(r.quantity)})
+		}
+	}
+	return res, nil
+}
+
+// summary lists the pick lines as location/lot quantity status.
+func (p *fakePickList) summary() []string {
+	var lines []string
+	for _, l := range p.lines {
+		lines = append(lines, fmt.Sprintf("%d/%s %d %s", l.locationID, l.batch, l.quantity, l.status))
+	}
+	return lines
+}
+
+func TestUpdatePickList(t *testing.T) {
+	other, otherSite := 4, 2
+	staff := auth.Claims{UserID: 7}
+	tests := []struct {
+		name            string
+		claims          auth.Claims
+		target          string
+		body            string
+		status          string
+		lineStatus      string
+		onlyLot         bool
+		wantCode        int
+		wantStatus      string
+		wantLines       []string
+		wantPicked      int
+		wantUnallocated int
+		wantReserved    []string
+	}{
+		{name: "confirm the whole line", claims: staff, target: "lines/61/confirm", body: `{"location_code": "a-02"}`,
+			status: models.PickListOpen, lineStatus: models.PickLinePending, wantCode: http.StatusOK,
+			wantStatus: models.PickListCompleted, wantLines: []string{"11/L2 4 picked"}, wantPicked: 4,
+			wantReserved: []string{"4 11/L2"}},
+		{name: "scan the wrong bin", claims: staff, target: "lines/61/confirm", body: `{"location_code": "A-01"}`,
+			status: models.PickListOpen, lineStatus: models.PickLinePending, wantCode: http.StatusBadRequest},
+		{name: "pick more than the line", claims: staff, target: "lines/61/confirm", body: `{"quantity": 5}`,
+			status: models.PickListOpen, lineStatus: models.PickLinePending, wantCode: http.StatusBadRequest},
+		{name: "short pick is reallocated elsewhere", claims: staff, target: "lines/61/short", body: `{"picked_quantity": 1}`,
+			status: models.PickListOpen, lineStatus: models.PickLinePending, wantCode: http.StatusOK,
+			wantStatus: models.PickListInProgress, wantLines: []string{"11/L2 4 short", "10/L1 3 pending"}, wantPicked: 1,
+			wantReserved: []string{"1 11/L2", "3 10/L1"}},
+		{name: "short pick with nothing elsewhere", claims: staff, target: "lines/61/confirm", body: `{"quantity": 1}`,
+			status: models.PickListInProgress, lineStatus: models.PickLinePending, onlyLot: true, wantCode: http.StatusOK,
+			wantStatus: models.PickListCompleted, wantLines: []string{"11/L2 4 short"}, wantPicked: 1, wantUnallocated: 3,
+			wantReserved: []string{"1 11/L2"}},
+		{name: "line already picked", claims: staff, target: "lines/61/confirm", body: `{}`,
+			status: models.PickListInProgress, lineStatus: models.PickLinePicked, wantCode: http.StatusConflict},
+		{name: "line on another list", claims: staff, target: "lines/62/confirm", body: `{}`,
+			status: models.PickListOpen, lineStatus: models.PickLinePending, wantCode: http.StatusNotFound},
+		{name: "cancel keeps the stock reserved", claims: staff, target: "cancel",
+			status: models.PickListOpen, lineStatus: models.PickLinePending, wantCode: http.StatusOK,
+			wantStatus: models.PickListCancelled, wantLines: []string{"11/L2 4 cancelled"}, wantReserved: []string{"4 11/L2"}},
+		{name: "cancel a completed list", claims: staff, target: "cancel",
+			status: models.PickListCompleted, lineStatus: models.PickLinePicked, wantCode: http.StatusConflict},
+		{name: "other tenants' lists", claims: auth.Claims{UserID: 7, TenantID: &other}, target: "cancel",
+			status: models.PickListOpen, lineStatus: models.PickLinePending, wantCode: http.StatusNotFound},
+		{name: "other sites' lists", claims: auth.Claims{UserID: 7, WarehouseID: &otherSite}, target: "lines/61/confirm", body: `{}`,
+			status: models.PickListOpen, lineStatus: models.PickLinePending, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newFakePickList(tt.status, tt.lineStatus)
+			if tt.onlyLot {
+				p.balances = p.balances[1:2]
+			}
+			h := &Handler{DB: p.open(t)}
+			route, handler := "/pick-lists/:id/cancel", h.CancelPickList
+			switch {
+			case tt.target == "lines/61/short":
+				route, handler = "/pick-lists/:id/lines/:lineId/short", h.ShortPickLine
+			case tt.target != "cancel":
+				route, handler = "/pick-lists/:id/lines/:lineId/confirm", h.ConfirmPickLine
+			}
+
+			w := serveAs(&tt.claims, route, handler, jsonRequest(http.MethodPut, "/pick-lists/1/"+tt.target, tt.body))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if p.committed != (tt.wantCode == http.StatusOK) {
+				t.Errorf("committed = %v on a %d", p.committed, w.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if p.status != tt.wantStatus {
+				t.Errorf("pick list is %s, want %s", p.status, tt.wantStatus)
+			}
+			if got := p.summary(); !reflect.DeepEqual(got, tt.wantLines) {
+				t.Errorf("lines = %v, want %v", got, tt.wantLines)
+			}
+			if p.picked != tt.wantPicked || p.unallocated != tt.wantUnallocated {
+				t.Errorf("order line picked %d, unallocated %d; want %d, %d", p.picked, p.unallocated, tt.wantPicked, tt.wantUnallocated)
+			}
+			if reserved := p.reserved(); !reflect.DeepEqual(reserved, tt.wantReserved) {
+				t.Errorf("reserved = %v, want %v", reserved, tt.wantReserved)
+			}
+		})
+	}
+}
@@ -1,14 +1,24 @@
 package handlers
 
 import (
+	"database/sql"
 	"net/http"
+	"time"
+	"wms-backend/internal/middleware"
 	"wms-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 func (h *Handler) GetProductsGin(c *gin.Context) {
-	rows, err := h.DB.Query("SELECT id, name, sku, category_id, description, price, stock, created_at FROM products ORDER BY id")
+	rows, err := h.DB.Query(`
+		SELECT p.id, p.name, p.sku, COALESCE(p.category_id, 0), p.description, p.price,
+		       COALESCE((SELECT SUM(i.quantity) FROM inventory i WHERE i.product_id = p.id), 0) as stock,
+		       p.temperature_controlled, p.hazmat, p.unit_weight, p.unit_volume,
+		       p.allocation_strategy, p.fixed_location_id, p.created_at
+		FROM warehouse_product p
+		WHERE ($1::int IS NULL OR p.tenant_id = $1)
+		ORDER BY p.name`, middleware.TenantID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -18,11 +28,17 @@ func (h *Handler) GetProductsGin(c *gin.Context) {
 	var products []models.Product
 	for rows.Next() {
 		var product models.Product
-		err := rows.Scan(&product.ID, &product.Name, &product.SKU, &product.CategoryID, &product.Description, &product.Price, &product.Stock, &product.CreatedAt)
+		var createdAt time.Time
+		var fixedLocationID sql.NullInt64
+		err := rows.Scan(&product.ID, &product.Name, &product.SKU, &product.CategoryID, &product.Description, &product.Price, &product.Stock,
+			&product.TemperatureControlled, &product.Hazmat, &product.UnitWeight, &product.UnitVolume,
+			&product.AllocationStrategy, &fixedLocationID, &createdAt)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		product.FixedLocationID = nullableInt(fixedLocationID)
+		product.CreatedAt = createdAt.Format(time.RFC3339)
 		products = append(products, product)
 	}
 
@@ -40,24 +56,40 @@ func (h *Handler) CreateProductGin(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Name and SKU are required"})
 		return
 	}
+	if product.AllocationStrategy == "" {
+		product.AllocationStrategy = models.AllocationFEFO
+	}
+	if err := h.validateAllocationStrategy(c, product.AllocationStrategy, product.FixedLocationID); err != nil {
+		writeAllocationError(c, err)
+		return
+	}
 
-	var productID int
+	var createdAt time.Time
 	err := h.DB.QueryRow(
-		"INSERT INTO products (name, sku, category_id, description, price, stock, created_at) VALUES ($1, $2, $3, $4, $5, $6, NOW()) RETURNING id",
-		product.Name, product.SKU, product.CategoryID, product.Description, product.Price, product.Stock,
-	).Scan(&productID)
+		`INSERT INTO warehouse_product (name, sku, category_id, description, price, tenant_id, temperature_controlled, hazmat, unit_weight, unit_volume,
+		                               allocation_strategy, fixed_location_id)
+		VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, created_at`,
+		product.Name, product.SKU, product.CategoryID, product.Description, product.Price, middleware.TenantID(c),
+		product.TemperatureControlled, product.Hazmat, product.UnitWeight, product.UnitVolume,
+		product.AllocationStrategy, product.FixedLocationID,
+	).Scan(&product.ID, &createdAt)
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Product already exists or invalid data"})
 		return
 	}
 
-	product.ID = productID
+	product.Stock = 0
+	product.CreatedAt = createdAt.Format(time.RFC3339)
 	c.JSON(http.StatusCreated, product)
 }
 
 func (h *Handler) GetCategoriesGin(c *gin.Context) {
-	rows, err := h.DB.Query("SELECT id, name, description, created_at FROM categories ORDER BY id")
+	// Categories without a tenant are shared by everyone
+	rows, err := h.DB.Query(`
+		SELECT id, name, description, created_at FROM warehouse_category
+		WHERE ($1::int IS NULL OR tenant_id = $1 OR tenant_id IS NULL)
+		ORDER BY name`, middleware.TenantID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -67,13 +99,42 @@ func (h *Handler) GetCategoriesGin(c *gin.Context) {
 	var categories []models.Category
 	for rows.Next() {
 		var category models.Category
-		err := rows.Scan(&category.ID, &category.Name, &category.Description, &category.CreatedAt)
+		var createdAt time.Time
+		err := rows.Scan(&category.ID, &category.Name, &category.Description, &createdAt)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		category.CreatedAt = createdAt.Format(time.RFC3339)
 		categories = append(categories, category)
 	}
 
 	c.JSON(http.StatusOK, categories)
-}
\ No newline at end of file
+}
+
+func (h *Handler) CreateCategoryGin(c *gin.Context) {
+	var category models.Category
+	if err := c.ShouldBindJSON(&category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	if category.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+
+	var createdAt time.Time
+	err := h.DB.QueryRow(
+		"INSERT INTO warehouse_category (name, description, tenant_id) VALUES ($1, $2, $3) RETURNING id, created_at",
+		category.Name, category.Description, middleware.TenantID(c),
+	).Scan(&category.ID, &createdAt)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+		return
+	}
+
+	category.CreatedAt = createdAt.Format(time.RFC3339)
+	c.JSON(http.StatusCreated, category)
+}
@@ -0,0 +1,583 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errPutawayNotFound    = errors.New("put-away task not found")
+	errPutawayNotPending  = errors.New("put-away task is no longer pending")
+	errInvalidPutaway     = errors.New("invalid put-away task")
+	errLocationUnsuitable = errors.New("location is not suitable for this product")
+)
+
+// putawayCandidatesSQL ranks the storage bins of a warehouse for a product.
+// Flags and category dedication are inherited down the location tree.
+// Capacity counts stock on hand plus pending put-aways headed to the bin.
+//
+// $1 warehouse, $2 product, $3 quantity, $4 only this location (0 = any),
+// $5 put-away task to leave out of the reserved space, $6 limit.
+const putawayCandidatesSQL = `
+	WITH RECURSIVE tree AS (
+		SELECT id, temperature_controlled AS cold, hazmat, category_id, pick_sequence
+		FROM locations WHERE parent_id IS NULL AND ($1::int IS NULL OR warehouse_id = $1)
+		UNION ALL
+		SELECT l.id, t.cold OR l.temperature_controlled, t.hazmat OR l.hazmat,
+		       COALESCE(l.category_id, t.category_id), COALESCE(l.pick_sequence, t.pick_sequence)
+		FROM locations l JOIN tree t ON l.parent_id = t.id
+	),
+	product AS (
+		SELECT category_id, temperature_controlled AS cold, hazmat,
+		       COALESCE(unit_weight, 0) AS unit_weight, COALESCE(unit_volume, 0) AS unit_volume
+		FROM warehouse_product WHERE id = $2
+	),
+	stored AS (
+		SELECT i.location_id, SUM(i.quantity) AS units,
+		       COALESCE(SUM(i.quantity) FILTER (WHERE i.product_id = $2), 0) AS same_units,
+		       SUM(i.quantity * COALESCE(p.unit_weight, 0)) AS weight,
+		       SUM(i.quantity * COALESCE(p.unit_volume, 0)) AS volume
+		FROM inventory i JOIN warehouse_product p ON i.product_id = p.id
+		WHERE i.quantity > 0
+		GROUP BY i.location_id
+	),
+	reserved AS (
+		SELECT t.suggested_location_id AS location_id, SUM(t.quantity) AS units,
+		       SUM(t.quantity * COALESCE(p.unit_weight, 0)) AS weight,
+		       SUM(t.quantity * COALESCE(p.unit_volume, 0)) AS volume
+		FROM putaway_tasks t JOIN warehouse_product p ON t.product_id = p.id
+		WHERE t.status = 'pending' AND t.id <> $5::int
+		GROUP BY t.suggested_location_id
+	)
+	SELECT l.id, l.code, l.location_type, t.pick_sequence, COALESCE(s.units, 0),
+	       COALESCE(s.same_units, 0) > 0,
+	       t.category_id IS NOT NULL,
+	       t.cold,
+	       l.max_units - COALESCE(s.units, 0) - COALESCE(r.units, 0)
+	FROM tree t
+	JOIN locations l ON l.id = t.id
+	CROSS JOIN product pr
+	LEFT JOIN stored s ON s.location_id = l.id
+	LEFT JOIN reserved r ON r.location_id = l.id
+	WHERE l.is_active
+	  AND l.location_type NOT IN ('dock', 'staging', 'returns')
+	  AND NOT EXISTS (SELECT 1 FROM locations child WHERE child.parent_id = l.id)
+	  AND ($4::int = 0 OR l.id = $4::int)
+	  AND (t.cold OR NOT pr.cold)
+	  AND t.hazmat = pr.hazmat
+	  AND (t.category_id IS NULL OR t.category_id = pr.category_id)
+	  AND (l.max_units IS NULL OR l.max_units - COALESCE(s.units, 0) - COALESCE(r.units, 0) >= $3::int)
+	  AND (l.max_weight IS NULL OR l.max_weight - COALESCE(s.weight, 0) - COALESCE(r.weight, 0) >= $3::int * pr.unit_weight)
+	  AND (l.max_volume IS NULL OR l.max_volume - COALESCE(s.volume, 0) - COALESCE(r.volume, 0) >= $3::int * pr.unit_volume)
+	ORDER BY COALESCE(s.same_units, 0) > 0 DESC,
+	         t.category_id IS NOT NULL DESC,
+	         t.cold AND NOT pr.cold,
+	         COALESCE(s.units, 0) > COALESCE(s.same_units, 0),
+	         t.pick_sequence NULLS LAST,
+	         l.code
+	LIMIT $6::int`
+
+const putawayTaskColumns = `t.id, t.warehouse_id, t.product_id, p.name, p.sku, t.quantity, t.batch, t.expiry_date,
+	t.from_location_id, f.code, t.suggested_location_id, COALESCE(sl.code, ''), t.to_location_id, COALESCE(tl.code, ''),
+	t.status, t.reference, t.receipt_line_id, t.assigned_to, t.created_by, t.confirmed_by, t.confirmed_at,
+	t.tenant_id, t.created_at, t.updated_at`
+
+const putawayTaskFrom = ` FROM putaway_tasks t
+	JOIN warehouse_product p ON t.product_id = p.id
+	JOIN locations f ON t.from_location_id = f.id
+	LEFT JOIN locations sl ON t.suggested_location_id = sl.id
+	LEFT JOIN locations tl ON t.to_location_id = tl.id`
+
+// GetPutawaySuggestions proposes bins for product_id and quantity in the
+// current warehouse. limit defaults to 5.
+func (h *Handler) GetPutawaySuggestions(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "product_id is required"})
+		return
+	}
+	quantity, err := strconv.Atoi(c.DefaultQuery("quantity", "1"))
+	if err != nil || quantity < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be a positive number"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+	if ok, err := h.belongsToTenant(c, "warehouse_product", productID); err != nil || !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown product"})
+		return
+	}
+
+	suggestions, err := suggestPutaway(h.DB, middleware.WarehouseID(c), productID, quantity, 0, 0, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute put-away suggestions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": suggestions})
+}
+
+// GetPutawayTasks lists tasks of the current site. Filters: status and
+// assigned_to, where "me" means the calling user.
+func (h *Handler) GetPutawayTasks(c *gin.Context) {
+	assignedTo := 0
+	if v := c.Query("assigned_to"); v == "me" {
+		assignedTo = middleware.CurrentUserID(c)
+	} else if v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assigned_to"})
+			return
+		}
+		assignedTo = id
+	}
+
+	rows, err := h.DB.Query(`SELECT `+putawayTaskColumns+putawayTaskFrom+`
+		WHERE ($1::int IS NULL OR t.tenant_id = $1) AND ($2::int IS NULL OR t.warehouse_id = $2)
+		  AND ($3 = '' OR t.status = $3) AND ($4 = 0 OR t.assigned_to = $4)
+		ORDER BY t.created_at, t.id`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("status"), assignedTo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch put-away tasks"})
+		return
+	}
+	defer rows.Close()
+
+	var tasks []models.PutawayTask
+	for rows.Next() {
+		t, err := scanPutawayTask(rows)
+		if err != nil {
+			continue
+		}
+		tasks = append(tasks, *t)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tasks})
+}
+
+func (h *Handler) GetPutawayTask(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	t, err := scanPutawayTask(h.DB.QueryRow(`SELECT `+putawayTaskColumns+putawayTaskFrom+`
+		WHERE t.id = $1 AND ($2::int IS NULL OR t.tenant_id = $2) AND ($3::int IS NULL OR t.warehouse_id = $3)`,
+		id, middleware.TenantID(c), middleware.WarehouseID(c)))
+	if err == sql.ErrNoRows {
+		writePutawayError(c, errPutawayNotFound)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch put-away task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, t)
+}
+
+// CreatePutawayTask queues stock already sitting at a dock or staging
+// location for put-away. Receipts posted to a dock create their tasks on
+// their own.
+func (h *Handler) CreatePutawayTask(c *gin.Context) {
+	var req models.PutawayTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if ok, err := h.belongsToTenant(c, "warehouse_product", req.ProductID); err != nil || !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown product"})
+		return
+	}
+	warehouseID, err := h.locationWarehouse(c, req.FromLocationID)
+	if err == errLocationNotAccessible {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown location"})
+		return
+	}
+
+	var expiry *time.Time
+	if req.ExpiryDate != "" {
+		d, err := time.Parse("2006-01-02", req.ExpiryDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiry_date must be YYYY-MM-DD"})
+			return
+		}
+		expiry = &d
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	id, err := createPutawayTask(tx, putawayTask{
+		WarehouseID:    warehouseID,
+		ProductID:      req.ProductID,
+		Quantity:       req.Quantity,
+		Batch:          req.Batch,
+		ExpiryDate:     expiry,
+		FromLocationID: req.FromLocationID,
+		AssignedTo:     req.AssignedTo,
+		UserID:         middleware.CurrentUserID(c),
+		TenantID:       middleware.TenantID(c),
+	})
+	if err != nil {
+		writePutawayError(c, err)
+		return
+	}
+	t, err := findPutawayTask(tx, id)
+	if err != nil {
+		writePutawayError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, t)
+}
+
+func (h *Handler) AssignPutawayTask(c *gin.Context) {
+	var req models.PutawayAssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.updatePutawayTask(c, func(tx *sql.Tx, t *models.PutawayTask, userID int) error {
+		_, err := tx.Exec(`UPDATE putaway_tasks SET assigned_to = $1, updated_at = NOW() WHERE id = $2`, req.UserID, t.ID)
+		return err
+	})
+}
+
+// ConfirmPutawayTask moves the task's stock from the dock into the bin the
+// operator scanned. Any bin that would have been suggested is accepted.
+func (h *Handler) ConfirmPutawayTask(c *gin.Context) {
+	var req models.PutawayConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.LocationCode == "" && req.LocationID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Scan a location_code or send a location_id"})
+		return
+	}
+
+	h.updatePutawayTask(c, func(tx *sql.Tx, t *models.PutawayTask, userID int) error {
+		return confirmPutawayTask(tx, t, req, userID)
+	})
+}
+
+func (h *Handler) CancelPutawayTask(c *gin.Context) {
+	h.updatePutawayTask(c, func(tx *sql.Tx, t *models.PutawayTask, userID int) error {
+		_, err := tx.Exec(`UPDATE putaway_tasks SET status = $1, updated_at = NOW() WHERE id = $2`, models.PutawayCancelled, t.ID)
+		return err
+	})
+}
+
+// updatePutawayTask locks a pending task of the user's site, applies step
+// and responds with the task as it stands afterwards.
+func (h *Handler) updatePutawayTask(c *gin.Context, step func(*sql.Tx, *models.PutawayTask, int) error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`
+		SELECT status FROM putaway_tasks
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2) AND ($3::int IS NULL OR warehouse_id = $3)
+		FOR UPDATE`, id, middleware.TenantID(c), middleware.PinnedWarehouseID(c)).Scan(&status)
+	if err == sql.ErrNoRows {
+		writePutawayError(c, errPutawayNotFound)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch put-away task"})
+		return
+	}
+	if status != models.PutawayPending {
+		writePutawayError(c, fmt.Errorf("%w: task is %s", errPutawayNotPending, status))
+		return
+	}
+
+	t, err := findPutawayTask(tx, id)
+	if err != nil {
+		writePutawayError(c, err)
+		return
+	}
+	if err := step(tx, t, middleware.CurrentUserID(c)); err != nil {
+		writePutawayError(c, err)
+		return
+	}
+	if t, err = findPutawayTask(tx, id); err != nil {
+		writePutawayError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, t)
+}
+
+// putawayTask describes a task to queue; see createPutawayTask.
+type putawayTask struct {
+	WarehouseID    *int
+	ProductID      int
+	Quantity       int
+	Batch          string
+	ExpiryDate     *time.Time
+	FromLocationID int
+	Reference      string
+	ReceiptLineID  *int
+	AssignedTo     *int
+	UserID         int
+	TenantID       *int
+}
+
+// createPutawayTask queues a task with the best bin suggested. A task
+// without a suggestion is still created; the operator then picks the bin.
+func createPutawayTask(tx *sql.Tx, p putawayTask) (int, error) {
+	suggestions, err := suggestPutaway(tx, p.WarehouseID, p.ProductID, p.Quantity, 0, 0, 1)
+	if err != nil {
+		return 0, err
+	}
+	var suggested *int
+	if len(suggestions) > 0 {
+		suggested = &suggestions[0].LocationID
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO putaway_tasks (warehouse_id, product_id, quantity, batch, expiry_date, from_location_id,
+		                           suggested_location_id, reference, receipt_line_id, assigned_to, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id`,
+		p.WarehouseID, p.ProductID, p.Quantity, p.Batch, p.ExpiryDate, p.FromLocationID,
+		suggested, p.Reference, p.ReceiptLineID, p.AssignedTo, p.UserID, p.TenantID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	if p.Reference == "" {
+		_, err = tx.Exec(`UPDATE putaway_tasks SET reference = 'PUT-' || LPAD(id::TEXT, 6, '0') WHERE id = $1`, id)
+	}
+	return id, err
+}
+
+// confirmPutawayTask resolves the scanned bin, checks it still fits and
+// books the move as a paired OUT/IN under the PUTAWAY reference type.
+func confirmPutawayTask(tx *sql.Tx, t *models.PutawayTask, req models.PutawayConfirmRequest, userID int) error {
+	var locationID int
+	err := tx.QueryRow(`
+		SELECT id FROM locations
+		WHERE ($1 = 0 OR id = $1) AND ($2 = '' OR code = $2) AND ($3::int IS NULL OR warehouse_id = $3)`,
+		req.LocationID, req.LocationCode, t.WarehouseID).Scan(&locationID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: scanned location is not in this warehouse", errInvalidPutaway)
+	}
+	if err != nil {
+		return err
+	}
+
+	fits, err := suggestPutaway(tx, t.WarehouseID, t.ProductID, t.Quantity, locationID, t.ID, 1)
+	if err != nil {
+		return err
+	}
+	if len(fits) == 0 {
+		return errLocationUnsuitable
+	}
+
+	for _, locID := range []int{t.FromLocationID, locationID} {
+		if err := ensureStockNotFrozen(tx, t.ProductID, locID); err != nil {
+			return err
+		}
+	}
+
+	onHand, err := lockedOnHand(tx, t.ProductID, t.FromLocationID, t.Batch)
+	if err != nil {
+		return err
+	}
+	if onHand < t.Quantity {
+		return fmt.Errorf("%w: %s at %s has %d, put-away needs %d",
+			errInsufficientStock, t.ProductName, t.FromLocationCode, onHand, t.Quantity)
+	}
+
+	m := stockMovement{
+		ProductID:     t.ProductID,
+		LocationID:    t.FromLocationID,
+		Quantity:      t.Quantity,
+		Reference:     t.Reference,
+		ReferenceType: models.RefPutaway,
+		Batch:         t.Batch,
+		UserID:        userID,
+		TenantID:      t.TenantID,
+	}
+	lots, err := takeStock(tx, m)
+	if err != nil {
+		return err
+	}
+	for _, lot := range lots {
+		in := m
+		in.LocationID = locationID
+		in.Type = models.MovementIn
+		in.Batch, in.ExpiryDate, in.Quantity = lot.Batch, lot.ExpiryDate, lot.Quantity
+		if err := applyStockMovement(tx, in); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE putaway_tasks SET status = $1, to_location_id = $2, confirmed_by = $3, confirmed_at = NOW(), updated_at = NOW()
+		WHERE id = $4`, models.PutawayCompleted, locationID, userID, t.ID)
+	return err
+}
+
+// suggestPutaway returns up to limit bins for quantity units of a product,
+// best first. onlyLocation narrows the search to one bin; excludeTask keeps
+// a task's own reservation from counting against it.
+func suggestPutaway(q dbtx, warehouseID *int, productID, quantity, onlyLocation, excludeTask, limit int) ([]models.PutawaySuggestion, error) {
+	rows, err := q.Query(putawayCandidatesSQL, warehouseID, productID, quantity, onlyLocation, excludeTask, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []models.PutawaySuggestion
+	for rows.Next() {
+		var s models.PutawaySuggestion
+		var pickSequence, remaining sql.NullInt64
+		var sameSKU, categoryZone, cold bool
+		err := rows.Scan(&s.LocationID, &s.LocationCode, &s.LocationType, &pickSequence, &s.UnitsOnHand,
+			&sameSKU, &categoryZone, &cold, &remaining)
+		if err != nil {
+			return nil, err
+		}
+		s.PickSequence = nullableInt(pickSequence)
+		s.RemainingUnits = nullableInt(remaining)
+
+		if sameSKU {
+			s.Reasons = append(s.Reasons, "same SKU already stored here")
+		} else if s.UnitsOnHand == 0 {
+			s.Reasons = append(s.Reasons, "empty bin")
+		}
+		if categoryZone {
+			s.Reasons = append(s.Reasons, "category zone")
+		}
+		if cold {
+			s.Reasons = append(s.Reasons, "temperature controlled")
+		}
+		if s.RemainingUnits != nil {
+			s.Reasons = append(s.Reasons, fmt.Sprintf("room for %d more units", *s.RemainingUnits))
+		}
+		if s.PickSequence != nil {
+			s.Reasons = append(s.Reasons, fmt.Sprintf("pick sequence %d", *s.PickSequence))
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
+
+func findPutawayTask(q dbtx, id int) (*models.PutawayTask, error) {
+	t, err := scanPutawayTask(q.QueryRow(`SELECT `+putawayTaskColumns+putawayTaskFrom+` WHERE t.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, errPutawayNotFound
+	}
+	return t, err
+}
+
+// receiptPutawayTasks lists the tasks a goods receipt queued.
+func receiptPutawayTasks(q dbtx, receiptID int) ([]models.PutawayTask, error) {
+	rows, err := q.Query(`SELECT `+putawayTaskColumns+putawayTaskFrom+`
+		JOIN goods_receipt_lines grl ON t.receipt_line_id = grl.id
+		WHERE grl.receipt_id = $1
+		ORDER BY t.id`, receiptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []models.PutawayTask
+	for rows.Next() {
+		t, err := scanPutawayTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *t)
+	}
+	return tasks, rows.Err()
+}
+
+func scanPutawayTask(row scanner) (*models.PutawayTask, error) {
+	var t models.PutawayTask
+	var warehouseID, suggested, to, receiptLine, assignedTo, createdBy, confirmedBy, tenantID sql.NullInt64
+	var expiry, confirmedAt sql.NullTime
+	err := row.Scan(&t.ID, &warehouseID, &t.ProductID, &t.ProductName, &t.SKU, &t.Quantity, &t.Batch, &expiry,
+		&t.FromLocationID, &t.FromLocationCode, &suggested, &t.SuggestedLocationCode, &to, &t.ToLocationCode,
+		&t.Status, &t.Reference, &receiptLine, &assignedTo, &createdBy, &confirmedBy, &confirmedAt,
+		&tenantID, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.WarehouseID = nullableInt(warehouseID)
+	t.SuggestedLocationID = nullableInt(suggested)
+	t.ToLocationID = nullableInt(to)
+	t.ReceiptLineID = nullableInt(receiptLine)
+	t.AssignedTo = nullableInt(assignedTo)
+	t.CreatedBy = nullableInt(createdBy)
+	t.ConfirmedBy = nullableInt(confirmedBy)
+	t.TenantID = nullableInt(tenantID)
+	if expiry.Valid {
+		t.ExpiryDate = &expiry.Time
+	}
+	if confirmedAt.Valid {
+		t.ConfirmedAt = &confirmedAt.Time
+	}
+	return &t, nil
+}
+
+func writePutawayError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errPutawayNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidPutaway):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errPutawayNotPending), errors.Is(err, errLocationUnsuitable),
+		errors.Is(err, errInsufficientStock), errors.Is(err, errStockFrozen):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process put-away task"})
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newFakePutaway serves put-away task 1 of tenant 3 in warehouse 1, which
+// moves quantity units of lot L3 from location 12. Of the bins B-01 (20)
+// and B-02 (21) only B-01 has room for it.
+func newFakePutaway(status string, quantity int) (*fakeLedger, *string) {
+	tenant, site := 3, 1
+	ledger := newFakeLedger(models.AllocationFEFO)
+	bins := map[int]string{20: "B-01", 21: "B-02"}
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[0]) != 1 || !inScope(st.Args[1], &tenant) || !inScope(st.Args[2], &site) {
+			return nil, nil
+		}
+		return &dbtest.Result{Rows: [][]driver.Value{{status}}}, nil
+	}, "SELECT status FROM putaway_tasks", "FOR UPDATE")
+	ledger.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(1), int64(site), int64(1), "Gula", "GL-1", int64(quantity), "L3", nil,
+			int64(12), "A-03", int64(20), "B-01", nil, "", status, "PUT-000001", nil, nil, nil, nil, nil,
+			int64(tenant), time.Now(), time.Now()}}}, nil
+	}, "FROM putaway_tasks t", "WHERE t.id = $1")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		for id, code := range bins {
+			if (argInt(st.Args[0]) == 0 || argInt(st.Args[0]) == id) && (st.Args[1] == "" || st.Args[1] == code) {
+				return &dbtest.Result{Rows: [][]driver.Value{{int64(id)}}}, nil
+			}
+		}
+		return nil, nil
+	}, "SELECT id FROM locations")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if only := argInt(st.Args[3]); only != 0 && only != 20 {
+			return nil, nil
+		}
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(20), "B-01", models.LocationBin, nil, int64(0),
+			false, false, false, nil}}}, nil
+	}, "WITH RECURSIVE tree", "FROM putaway_tasks t")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		status = st.Args[0].(string)
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE putaway_tasks SET status")
+	return ledger, &status
+}
+
+func TestUpdatePutawayTask(t *testing.T) {
+	other, otherSite := 4, 2
+	staff := auth.Claims{UserID: 7}
+	tests := []struct {
+		name          string
+		claims        auth.Claims
+		action        string
+		status        string
+		quantity      int
+		scan          string
+		frozen        []int
+		wantCode      int
+		wantStatus    string
+		wantMovements []string
+	}{
+		{name: "confirm moves the lot into the scanned bin", claims: staff, action: "confirm", status: models.PutawayPending, quantity: 4,
+			scan: `{"location_code": "B-01"}`, wantCode: http.StatusOK, wantStatus: models.PutawayCompleted,
+			wantMovements: []string{"OUT 4 12/L3", "IN 4 20/L3"}},
+		{name: "bin without room", claims: staff, action: "confirm", status: models.PutawayPending, quantity: 4,
+			scan: `{"location_code": "B-02"}`, wantCode: http.StatusConflict},
+		{name: "bin in another warehouse", claims: staff, action: "confirm", status: models.PutawayPending, quantity: 4,
+			scan: `{"location_code": "C-01"}`, wantCode: http.StatusBadRequest},
+		{name: "dock holds less than the task", claims: staff, action: "confirm", status: models.PutawayPending, quantity: 11,
+			scan: `{"location_id": 20}`, wantCode: http.StatusConflict},
+		{name: "bin under count", claims: staff, action: "confirm", status: models.PutawayPending, quantity: 4,
+			scan: `{"location_id": 20}`, frozen: []int{20}, wantCode: http.StatusConflict},
+		{name: "confirm twice", claims: staff, action: "confirm", status: models.PutawayCompleted, quantity: 4,
+			scan: `{"location_id": 20}`, wantCode: http.StatusConflict},
+		{name: "cancel", claims: staff, action: "cancel", status: models.PutawayPending, quantity: 4,
+			wantCode: http.StatusOK, wantStatus: models.PutawayCancelled},
+		{name: "cancel a cancelled task", claims: staff, action: "cancel", status: models.PutawayCancelled, quantity: 4,
+			wantCode: http.StatusConflict},
+		{name: "other tenants' tasks", claims: auth.Claims{UserID: 7, TenantID: &other}, action: "cancel", status: models.PutawayPending, quantity: 4,
+			wantCode: http.StatusNotFound},
+		{name: "other sites' tasks", claims: auth.Claims{UserID: 7, WarehouseID: &otherSite}, action: "cancel", status: models.PutawayPending, quantity: 4,
+			wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger, status := newFakePutaway(tt.status, tt.quantity)
+			for _, id := range tt.frozen {
+				ledger.frozen[id] = true
+			}
+			h := &Handler{DB: ledger.open(t)}
+			handler := map[string]gin.HandlerFunc{"confirm": h.ConfirmPutawayTask, "cancel": h.CancelPutawayTask}[tt.action]
+
+			w := serveAs(&tt.claims, "/putaway-tasks/:id/"+tt.action, handler,
+				jsonRequest(http.MethodPost, "/putaway-tasks/1/"+tt.action, tt.scan))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if ledger.committed != (tt.wantCode == http.StatusOK) {
+				t.Errorf("committed = %v on a %d", ledger.committed, w.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if *status != tt.wantStatus {
+				t.Errorf("task is %s, want %s", *status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(ledger.movements, tt.wantMovements) {
+				t.Errorf("movements = %v, want %v", ledger.movements, tt.wantMovements)
+			}
+		})
+	}
+}
@@ -0,0 +1,1002 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var (
+	errInspectionNotFound    = errors.New("inspection not found")
+	errInspectionNotEditable = errors.New("inspection cannot change in its current status")
+	errInvalidInspection     = errors.New("invalid inspection")
+	errHoldNotFound          = errors.New("quarantine hold not found")
+	errHoldNotEditable       = errors.New("quarantine hold cannot change in its current status")
+)
+
+const inspectionColumns = `q.id, q.document_number, q.check_type, q.receipt_line_id, q.dispatch_id, q.return_id,
+	COALESCE(gr.document_number, rt.document_number, 'DSP-' || LPAD(q.dispatch_id::TEXT, 6, '0'), ''),
+	q.product_id, p.name, COALESCE(p.sku, ''), q.location_id, COALESCE(l.code, ''), q.batch, q.quantity,
+	q.checked_quantity, q.passed_quantity, q.failed_quantity, q.status, q.notes, q.inspected_by, q.inspected_at,
+	qh.id, COALESCE(qh.status, ''), q.template_id, q.sampling_plan_id, COALESCE(q.code_letter, ''), q.sample_size,
+	q.accept_number, q.reject_number, q.defects, q.warehouse_id, q.created_by, q.created_at, q.updated_at`
+
+const inspectionFrom = ` FROM qc_inspections q
+	JOIN warehouse_product p ON q.product_id = p.id
+	LEFT JOIN goods_receipt_lines grl ON q.receipt_line_id = grl.id
+	LEFT JOIN goods_receipts gr ON grl.receipt_id = gr.id
+	LEFT JOIN returns rt ON q.return_id = rt.id
+	LEFT JOIN locations l ON q.location_id = l.id
+	LEFT JOIN quarantine_holds qh ON qh.inspection_id = q.id`
+
+const holdColumns = `h.id, h.inspection_id, q.document_number, h.product_id, p.name, h.location_id, l.code, h.batch,
+	h.quantity, h.status, h.reason, h.created_by, h.released_by, h.released_at, h.created_at`
+
+const holdFrom = ` FROM quarantine_holds h
+	JOIN qc_inspections q ON h.inspection_id = q.id
+	JOIN warehouse_product p ON h.product_id = p.id
+	JOIN locations l ON h.location_id = l.id`
+
+// inspectionSource is what the inspected document says about the goods.
+type inspectionSource struct {
+	productID   int
+	locationID  *int
+	batch       string
+	warehouseID *int
+	// The most one inspection may cover
+	quantity int
+	// Whether the goods are in inventory and have to be held meanwhile
+	hold bool
+}
+
+// GetQCInspections lists inspections, newest first. status and check_type
+// filter them.
+func (h *Handler) GetQCInspections(c *gin.Context) {
+	rows, err := h.DB.Query(`SELECT `+inspectionColumns+inspectionFrom+`
+		WHERE ($1::int IS NULL OR q.tenant_id = $1) AND ($2::int IS NULL OR q.warehouse_id = $2)
+		  AND ($3 = '' OR q.status = $3) AND ($4 = '' OR q.check_type = $4)
+		ORDER BY q.created_at DESC, q.id DESC`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("status"), strings.ToUpper(c.Query("check_type")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inspections"})
+		return
+	}
+	defer rows.Close()
+
+	inspections := []models.QCInspection{}
+	for rows.Next() {
+		q, err := scanInspection(rows)
+		if err != nil {
+			continue
+		}
+		inspections = append(inspections, *q)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": inspections})
+}
+
+func (h *Handler) GetQCInspection(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	q, err := scanInspection(h.DB.QueryRow(`SELECT `+inspectionColumns+inspectionFrom+`
+		WHERE q.id = $1 AND ($2::int IS NULL OR q.tenant_id = $2) AND ($3::int IS NULL OR q.warehouse_id = $3)`,
+		id, middleware.TenantID(c), middleware.WarehouseID(c)))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inspection not found"})
+		return
+	}
+	if err == nil {
+		err = inspectionDetail(h.DB, q)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inspection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, q)
+}
+
+// CreateQCInspection opens an inspection. Stock already in inventory, for
+// outgoing and return inspections, is held in quarantine until the result
+// is in.
+func (h *Handler) CreateQCInspection(c *gin.Context) {
+	var req models.QCInspectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.CheckType = strings.ToUpper(req.CheckType)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	src, err := h.lockInspectionSource(tx, c, req)
+	if err != nil {
+		writeInspectionError(c, err)
+		return
+	}
+	quantity := src.quantity
+	if req.Quantity != nil {
+		quantity = *req.Quantity
+	}
+	if quantity <= 0 || quantity > src.quantity {
+		writeInspectionError(c, fmt.Errorf("%w: only %d can be inspected", errInvalidInspection, src.quantity))
+		return
+	}
+	if req.CheckType == models.QCIncoming && quantity != src.quantity {
+		writeInspectionError(c, fmt.Errorf("%w: an incoming inspection covers the whole receipt line", errInvalidInspection))
+		return
+	}
+
+	// The product's checklist and sample are fixed when the inspection opens
+	template, err := qcTemplateFor(tx, src.productID)
+	if err != nil {
+		writeInspectionError(c, err)
+		return
+	}
+	var templateID, samplingPlanID, sampleSize, acceptNumber, rejectNumber *int
+	var codeLetter *string
+	if template != nil {
+		templateID, samplingPlanID = &template.ID, template.SamplingPlanID
+	}
+	if samplingPlanID != nil {
+		var sampling models.Sampling
+		plan, err := qcSamplingPlan(tx, *samplingPlanID)
+		if err == nil {
+			sampling, err = sampleLot(*plan, quantity)
+		}
+		if err != nil {
+			writeInspectionError(c, err)
+			return
+		}
+		codeLetter, sampleSize = &sampling.CodeLetter, &sampling.SampleSize
+		acceptNumber, rejectNumber = &sampling.AcceptNumber, &sampling.RejectNumber
+	}
+
+	var id int
+	var documentNumber string
+	userID := middleware.CurrentUserID(c)
+	err = tx.QueryRow(`
+		INSERT INTO qc_inspections (check_type, receipt_line_id, dispatch_id, return_id, product_id, location_id, batch,
+		                            quantity, notes, template_id, sampling_plan_id, code_letter, sample_size,
+		                            accept_number, reject_number, warehouse_id, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		RETURNING id, document_number`,
+		req.CheckType, req.ReceiptLineID, req.DispatchID, req.ReturnID, src.productID, src.locationID, src.batch,
+		quantity, req.Notes, templateID, samplingPlanID, codeLetter, sampleSize, acceptNumber, rejectNumber, src.warehouseID, userID, middleware.TenantID(c)).Scan(&id, &documentNumber)
+	if err != nil {
+		writeInspectionError(c, err)
+		return
+	}
+
+	if src.hold {
+		reason := req.Notes
+		if reason == "" {
+			reason = "Under inspection " + documentNumber
+		}
+		err := placeQuarantineHold(tx, id, src.productID, intValue(src.locationID), src.batch, quantity, reason, userID,
+			middleware.TenantID(c))
+		if err != nil {
+			writeInspectionError(c, err)
+			return
+		}
+	}
+
+	h.commitInspection(c, tx, id, http.StatusCreated)
+}
+
+// RecordQCInspectionResult splits the inspected quantity into passed and
+// failed units and stores the answer to every checklist item. Passed
+// stock is released for allocation, failed stock stays held in
+// quarantine. For an incoming inspection the receipt line takes the
+// verdict instead; its failed units go to quarantine stock when the
+// receipt is posted.
+func (h *Handler) RecordQCInspectionResult(c *gin.Context) {
+	var req models.QCResultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.advanceInspection(c, models.InspectionCompleted, func(tx *sql.Tx, q *models.QCInspection) error {
+		var checklist []models.QCTemplateItem
+		if q.TemplateID != nil {
+			var err error
+			if checklist, err = qcTemplateItems(tx, *q.TemplateID); err != nil {
+				return err
+			}
+		}
+		results, defects, err := checkQCItems(checklist, req.Items)
+		if err != nil {
+			return err
+		}
+
+		var passed, failed int
+		switch {
+		case req.PassedQuantity != nil && req.FailedQuantity != nil:
+			passed, failed = *req.PassedQuantity, *req.FailedQuantity
+		case req.PassedQuantity == nil && req.FailedQuantity == nil && q.Sampling != nil:
+			// The sample decides for the whole lot
+			if defects <= q.Sampling.AcceptNumber {
+				passed = q.Quantity
+			} else {
+				failed = q.Quantity
+			}
+		default:
+			return fmt.Errorf("%w: passed_quantity and failed_quantity are required", errInvalidInspection)
+		}
+		if passed+failed != q.Quantity {
+			return fmt.Errorf("%w: passed and failed quantities must add up to %d", errInvalidInspection, q.Quantity)
+		}
+		checked := q.Quantity
+		if q.Sampling != nil {
+			checked = q.Sampling.SampleSize
+		}
+		if req.CheckedQuantity != nil {
+			checked = *req.CheckedQuantity
+		}
+		if checked > q.Quantity {
+			return fmt.Errorf("%w: only %d are under inspection", errInvalidInspection, q.Quantity)
+		}
+
+		userID := middleware.CurrentUserID(c)
+		if q.CheckType == models.QCIncoming {
+			if err := h.recordIncomingResult(tx, c, q, passed, failed, req.Notes); err != nil {
+				return err
+			}
+		} else {
+			hold, err := lockInspectionHold(tx, q.ID)
+			if err != nil {
+				return err
+			}
+			if failed == 0 {
+				err = releaseQuarantineHold(tx, hold, hold.Quantity, models.HoldReleased, userID)
+			} else if passed > 0 {
+				err = releaseQuarantineHold(tx, hold, passed, models.HoldReleased, userID)
+			}
+			if err == nil && failed > 0 && req.Notes != "" {
+				_, err = tx.Exec(`UPDATE quarantine_holds SET reason = $1, updated_at = NOW() WHERE id = $2`, req.Notes, hold.ID)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, result := range results {
+			_, err := tx.Exec(`
+				INSERT INTO qc_inspection_results (inspection_id, template_item_id, label, item_type, passed, measured_value,
+				                                   defects, photo_url, notes)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+				q.ID, result.TemplateItemID, result.Label, result.ItemType, result.Passed, result.Value, result.Defects,
+				result.PhotoURL, result.Notes)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(`
+			UPDATE qc_inspections
+			SET checked_quantity = $1, passed_quantity = $2, failed_quantity = $3, defects = $4,
+			    notes = CASE WHEN $5 = '' THEN notes ELSE $5 END, inspected_by = $6, inspected_at = NOW()
+			WHERE id = $7`, checked, passed, failed, defects, req.Notes, userID, q.ID)
+		return err
+	})
+}
+
+// CancelQCInspection drops an open inspection and releases what it held.
+func (h *Handler) CancelQCInspection(c *gin.Context) {
+	h.advanceInspection(c, models.InspectionCancelled, func(tx *sql.Tx, q *models.QCInspection) error {
+		if q.HoldID == nil {
+			return nil
+		}
+		hold, err := lockInspectionHold(tx, q.ID)
+		if err != nil {
+			return err
+		}
+		return releaseQuarantineHold(tx, hold, hold.Quantity, models.HoldReleased, middleware.CurrentUserID(c))
+	})
+}
+
+// GetQuarantineHolds lists stock held in quarantine, newest first. status
+// filters the holds.
+func (h *Handler) GetQuarantineHolds(c *gin.Context) {
+	rows, err := h.DB.Query(`SELECT `+holdColumns+holdFrom+`
+		WHERE ($1::int IS NULL OR h.tenant_id = $1) AND ($2::int IS NULL OR l.warehouse_id = $2)
+		  AND ($3 = '' OR h.status = $3)
+		ORDER BY h.created_at DESC, h.id DESC`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quarantine holds"})
+		return
+	}
+	defer rows.Close()
+
+	holds := []models.QuarantineHold{}
+	for rows.Next() {
+		hold, err := scanQuarantineHold(rows)
+		if err != nil {
+			continue
+		}
+		holds = append(holds, *hold)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": holds})
+}
+
+// ReleaseQuarantineHold makes held stock available again.
+func (h *Handler) ReleaseQuarantineHold(c *gin.Context) {
+	h.closeQuarantineHold(c, models.HoldReleased)
+}
+
+// ScrapQuarantineHold writes held stock off.
+func (h *Handler) ScrapQuarantineHold(c *gin.Context) {
+	h.closeQuarantineHold(c, models.HoldScrapped)
+}
+
+func (h *Handler) closeQuarantineHold(c *gin.Context, status string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	// The body is optional
+	var req models.QuarantineHoldRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	hold, err := scanQuarantineHold(tx.QueryRow(`SELECT `+holdColumns+holdFrom+`
+		WHERE h.id = $1 AND ($2::int IS NULL OR h.tenant_id = $2)
+		  AND ($3::int IS NULL OR l.warehouse_id IS NULL OR l.warehouse_id = $3)
+		FOR UPDATE OF h`, id, middleware.TenantID(c), middleware.PinnedWarehouseID(c)))
+	if err == sql.ErrNoRows {
+		writeInspectionError(c, errHoldNotFound)
+		return
+	}
+	if err != nil {
+		writeInspectionError(c, err)
+		return
+	}
+	if hold.Status != models.HoldActive {
+		writeInspectionError(c, fmt.Errorf("%w: it is %s", errHoldNotEditable, hold.Status))
+		return
+	}
+	var inspectionStatus string
+	var returned bool
+	err = tx.QueryRow(`SELECT status, return_id IS NOT NULL FROM qc_inspections WHERE id = $1`,
+		hold.InspectionID).Scan(&inspectionStatus, &returned)
+	if err != nil {
+		writeInspectionError(c, err)
+		return
+	}
+	if inspectionStatus == models.InspectionOpen {
+		writeInspectionError(c, fmt.Errorf("%w: %s is still open", errHoldNotEditable, hold.DocumentNumber))
+		return
+	}
+	if returned && status == models.HoldScrapped {
+		writeInspectionError(c, fmt.Errorf("%w: returned goods are scrapped through a return disposition", errHoldNotEditable))
+		return
+	}
+
+	userID := middleware.CurrentUserID(c)
+	if err := releaseQuarantineHold(tx, hold, hold.Quantity, status, userID); err != nil {
+		writeInspectionError(c, err)
+		return
+	}
+	if status == models.HoldScrapped {
+		if err := ensureStockNotFrozen(tx, hold.ProductID, hold.LocationID); err != nil {
+			writeInspectionError(c, err)
+			return
+		}
+		err := applyStockMovement(tx, stockMovement{
+			ProductID:     hold.ProductID,
+			LocationID:    hold.LocationID,
+			Type:          models.MovementOut,
+			Quantity:      hold.Quantity,
+			Reference:     hold.DocumentNumber,
+			ReferenceType: models.RefQualityCheck,
+			ReasonCode:    "SCRAPPED",
+			Batch:         hold.Batch,
+			Notes:         req.Notes,
+			UserID:        userID,
+			TenantID:      middleware.TenantID(c),
+		})
+		if err != nil {
+			writeInspectionError(c, err)
+			return
+		}
+	}
+
+	hold, err = scanQuarantineHold(tx.QueryRow(`SELECT `+holdColumns+holdFrom+` WHERE h.id = $1`, id))
+	if err != nil {
+		writeInspectionError(c, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+// GetQCDefectRates sums up completed inspections per supplier of the
+// inspected goods, with how often each checklist item failed. from and to
+// limit it to inspections recorded in that date range.
+func (h *Handler) GetQCDefectRates(c *gin.Context) {
+	var from, to *time.Time
+	for _, bound := range []struct {
+		param string
+		dest  **time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		d, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + bound.param + " date"})
+			return
+		}
+		*bound.dest = &d
+	}
+
+	// Goods are traced to a supplier through their receipt or, for
+	// supplier returns, the return
+	const scope = ` FROM qc_inspections q
+		LEFT JOIN goods_receipt_lines grl ON q.receipt_line_id = grl.id
+		LEFT JOIN goods_receipts gr ON grl.receipt_id = gr.id
+		LEFT JOIN returns rt ON q.return_id = rt.id
+		LEFT JOIN suppliers s ON s.id = COALESCE(gr.supplier_id, rt.supplier_id)`
+	const filter = `
+		WHERE q.status = 'completed' AND ($1::int IS NULL OR q.tenant_id = $1) AND ($2::int IS NULL OR q.warehouse_id = $2)
+		  AND ($3::date IS NULL OR q.inspected_at >= $3::date) AND ($4::date IS NULL OR q.inspected_at < $4::date + 1)`
+	args := []interface{}{middleware.TenantID(c), middleware.WarehouseID(c), from, to}
+
+	rows, err := h.DB.Query(`
+		SELECT s.id, COALESCE(s.name, gr.supplier_name, ''), COUNT(*), COUNT(*) FILTER (WHERE q.failed_quantity > 0),
+		       COALESCE(SUM(q.checked_quantity), 0), COALESCE(SUM(q.defects), 0)`+scope+filter+`
+		GROUP BY 1, 2
+		ORDER BY 6 DESC, 2`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch defect rates"})
+		return
+	}
+	type supplierKey struct {
+		id   sql.NullInt64
+		name string
+	}
+	rates := []models.QCDefectRate{}
+	index := map[supplierKey]int{}
+	for rows.Next() {
+		var rate models.QCDefectRate
+		var supplierID sql.NullInt64
+		err := rows.Scan(&supplierID, &rate.SupplierName, &rate.Inspections, &rate.RejectedLots, &rate.CheckedQuantity,
+			&rate.Defects)
+		if err != nil {
+			continue
+		}
+		rate.SupplierID = nullableInt(supplierID)
+		if rate.CheckedQuantity > 0 {
+			rate.DefectRate = math.Round(float64(rate.Defects)*10000/float64(rate.CheckedQuantity)) / 100
+		}
+		rate.Items = []models.QCItemDefectRate{}
+		index[supplierKey{supplierID, rate.SupplierName}] = len(rates)
+		rates = append(rates, rate)
+	}
+	rows.Close()
+
+	rows, err = h.DB.Query(`
+		SELECT s.id, COALESCE(s.name, gr.supplier_name, ''), r.label, COUNT(*), COUNT(*) FILTER (WHERE NOT r.passed),
+		       COALESCE(SUM(r.defects), 0)`+scope+`
+		JOIN qc_inspection_results r ON r.inspection_id = q.id`+filter+`
+		GROUP BY 1, 2, 3
+		ORDER BY 6 DESC, 3`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch defect rates"})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.QCItemDefectRate
+		var supplierID sql.NullInt64
+		var supplierName string
+		if err := rows.Scan(&supplierID, &supplierName, &item.Label, &item.Results, &item.Failed, &item.Defects); err != nil {
+			continue
+		}
+		if i, ok := index[supplierKey{supplierID, supplierName}]; ok {
+			rates[i].Items = append(rates[i].Items, item)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rates})
+}
+
+// recordIncomingResult hands an incoming inspection's verdict to its
+// receipt line. A line that partly failed is accepted with its failed
+// units set aside.
+func (h *Handler) recordIncomingResult(tx *sql.Tx, c *gin.Context, q *models.QCInspection, passed, failed int, notes string) error {
+	lineID := intValue(q.ReceiptLineID)
+	var receiptID int
+	if err := tx.QueryRow(`SELECT receipt_id FROM goods_receipt_lines WHERE id = $1`, lineID).Scan(&receiptID); err != nil {
+		return err
+	}
+	r, err := h.lockGoodsReceipt(tx, c, receiptID)
+	if err != nil {
+		return err
+	}
+
+	verdict := models.QCAccepted
+	if passed == 0 {
+		verdict = models.QCRejected
+	}
+	if err := setGoodsReceiptLineQC(tx, r, lineID, verdict, notes, middleware.CurrentUserID(c)); err != nil {
+		return err
+	}
+	if verdict == models.QCAccepted && failed > 0 {
+		_, err = tx.Exec(`UPDATE goods_receipt_lines SET rejected_quantity = $1 WHERE id = $2`, failed, lineID)
+	}
+	return err
+}
+
+// lockInspectionSource loads and locks the document a new inspection looks
+// at and works out how much of it is still undecided.
+func (h *Handler) lockInspectionSource(tx *sql.Tx, c *gin.Context, req models.QCInspectionRequest) (*inspectionSource, error) {
+	var src inspectionSource
+	var err error
+	tenantID, pinnedID := middleware.TenantID(c), middleware.PinnedWarehouseID(c)
+	switch {
+	case req.CheckType == models.QCIncoming && req.ReceiptLineID != nil && req.DispatchID == nil && req.ReturnID == nil:
+		var productID, locationID, warehouseID sql.NullInt64
+		var status string
+		var posted bool
+		err = tx.QueryRow(`
+			SELECT l.product_id, l.location_id, COALESCE(l.batch, ''), l.quantity, gr.warehouse_id, gr.status,
+			       l.posted_at IS NOT NULL
+			FROM goods_receipt_lines l
+			JOIN goods_receipts gr ON l.receipt_id = gr.id
+			WHERE l.id = $1 AND ($2::int IS NULL OR gr.tenant_id = $2) AND ($3::int IS NULL OR gr.warehouse_id = $3)
+			FOR UPDATE OF l`, *req.ReceiptLineID, tenantID, pinnedID).Scan(&productID, &locationID, &src.batch,
+			&src.quantity, &warehouseID, &status, &posted)
+		if err == nil && (posted || (status != models.GoodsReceiptReceived && status != models.GoodsReceiptQC)) {
+			return nil, fmt.Errorf("%w: only lines of received receipts are inspected", errInvalidInspection)
+		}
+		if err == nil && !productID.Valid {
+			return nil, fmt.Errorf("%w: the receipt line is not linked to a product", errInvalidInspection)
+		}
+		if err == nil {
+			var open int
+			err = tx.QueryRow(`SELECT COUNT(*) FROM qc_inspections WHERE receipt_line_id = $1 AND status = $2`,
+				*req.ReceiptLineID, models.InspectionOpen).Scan(&open)
+			if err == nil && open > 0 {
+				return nil, fmt.Errorf("%w: the receipt line is already under inspection", errInvalidInspection)
+			}
+		}
+		src.productID = int(productID.Int64)
+		src.locationID = nullableInt(locationID)
+		src.warehouseID = nullableInt(warehouseID)
+	case req.CheckType == models.QCReturn && req.ReturnID != nil && req.ReceiptLineID == nil && req.DispatchID == nil:
+		var r *models.Return
+		if r, err = lockReturn(tx, c, *req.ReturnID); err == errReturnNotFound {
+			err = sql.ErrNoRows
+		}
+		if err == nil && r.Status != models.ReturnReceived {
+			return nil, fmt.Errorf("%w: only received returns are inspected", errInvalidInspection)
+		}
+		var decided, held int
+		if err == nil {
+			err = tx.QueryRow(`SELECT COALESCE(SUM(quantity), 0) FROM return_dispositions WHERE return_id = $1`, r.ID).Scan(&decided)
+		}
+		if err == nil {
+			held, err = returnHeld(tx, r.ID, false)
+		}
+		if err == nil {
+			src.productID, src.locationID, src.batch, src.warehouseID = r.ProductID, r.LocationID, r.Batch, r.WarehouseID
+			src.quantity = r.ReceivedQuantity - decided - held
+			src.hold = true
+		}
+	case req.CheckType == models.QCOutgoing && req.ReceiptLineID == nil && req.ReturnID == nil:
+		if req.ProductID == 0 || req.LocationID == 0 || req.Quantity == nil {
+			return nil, fmt.Errorf("%w: an outgoing inspection needs product_id, location_id and quantity", errInvalidInspection)
+		}
+		if ok, err := h.belongsToTenant(c, "warehouse_product", req.ProductID); err != nil || !ok {
+			return nil, fmt.Errorf("%w: unknown product", errInvalidInspection)
+		}
+		if src.warehouseID, err = h.locationWarehouse(c, req.LocationID); err != nil {
+			return nil, locationLookupError(err)
+		}
+		if req.DispatchID != nil {
+			var productID sql.NullInt64
+			err = tx.QueryRow(`SELECT product_id FROM dispatches WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2)`,
+				*req.DispatchID, tenantID).Scan(&productID)
+			if err == nil && int(productID.Int64) != req.ProductID {
+				return nil, fmt.Errorf("%w: dispatch %d is for another product", errInvalidInspection, *req.DispatchID)
+			}
+		}
+		src.productID, src.locationID, src.batch = req.ProductID, &req.LocationID, req.Batch
+		src.quantity, src.hold = *req.Quantity, true
+	case req.CheckType != models.QCIncoming && req.CheckType != models.QCOutgoing && req.CheckType != models.QCReturn:
+		return nil, fmt.Errorf("%w: check_type must be INCOMING, OUTGOING or RETURN", errInvalidInspection)
+	default:
+		return nil, fmt.Errorf("%w: an incoming inspection needs receipt_line_id, a return inspection return_id", errInvalidInspection)
+	}
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: the inspected document was not found", errInvalidInspection)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &src, nil
+}
+
+// checkQCItems matches answers to the checklist. Measurements pass within
+// their tolerance, every required item needs an answer and photo-required
+// items a photo. It returns the results with the defects they add up to.
+func checkQCItems(checklist []models.QCTemplateItem, answers []models.QCItemResultRequest) ([]models.QCItemResult, int, error) {
+	if len(checklist) == 0 && len(answers) > 0 {
+		return nil, 0, fmt.Errorf("%w: the inspection has no checklist", errInvalidInspection)
+	}
+	items := map[int]models.QCTemplateItem{}
+	for _, item := range checklist {
+		items[item.ID] = item
+	}
+
+	answered := map[int]bool{}
+	var results []models.QCItemResult
+	defects := 0
+	for _, answer := range answers {
+		item, ok := items[answer.TemplateItemID]
+		if !ok {
+			return nil, 0, fmt.Errorf("%w: item %d is not on the checklist", errInvalidInspection, answer.TemplateItemID)
+		}
+		if answered[item.ID] {
+			return nil, 0, fmt.Errorf("%w: %s is answered twice", errInvalidInspection, item.Label)
+		}
+		answered[item.ID] = true
+
+		itemID := item.ID
+		result := models.QCItemResult{
+			TemplateItemID: &itemID,
+			Label:          item.Label,
+			ItemType:       item.ItemType,
+			Defects:        answer.Defects,
+			PhotoURL:       answer.PhotoURL,
+			Notes:          answer.Notes,
+		}
+		switch {
+		case item.ItemType == models.QCItemMeasurement && answer.Value != nil:
+			result.Value = answer.Value
+			result.Passed = (item.MinValue == nil || *answer.Value >= *item.MinValue) &&
+				(item.MaxValue == nil || *answer.Value <= *item.MaxValue)
+		case item.ItemType == models.QCItemMeasurement:
+			return nil, 0, fmt.Errorf("%w: %s needs a measured value", errInvalidInspection, item.Label)
+		case answer.Passed != nil:
+			result.Passed = *answer.Passed
+		default:
+			return nil, 0, fmt.Errorf("%w: %s needs passed true or false", errInvalidInspection, item.Label)
+		}
+		if item.PhotoRequired && answer.PhotoURL == "" {
+			return nil, 0, fmt.Errorf("%w: %s needs a photo", errInvalidInspection, item.Label)
+		}
+		if !result.Passed && result.Defects == 0 {
+			result.Defects = 1
+		}
+		defects += result.Defects
+		results = append(results, result)
+	}
+
+	for _, item := range checklist {
+		if item.IsRequired && !answered[item.ID] {
+			return nil, 0, fmt.Errorf("%w: %s needs an answer", errInvalidInspection, item.Label)
+		}
+	}
+	return results, defects, nil
+}
+
+// advanceInspection moves an open inspection to status to after running
+// step.
+func (h *Handler) advanceInspection(c *gin.Context, to string, step func(*sql.Tx, *models.QCInspection) error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	q, err := scanInspection(tx.QueryRow(`SELECT `+inspectionColumns+inspectionFrom+`
+		WHERE q.id = $1 AND ($2::int IS NULL OR q.tenant_id = $2)
+		  AND ($3::int IS NULL OR q.warehouse_id IS NULL OR q.warehouse_id = $3)
+		FOR UPDATE OF q`, id, middleware.TenantID(c), middleware.PinnedWarehouseID(c)))
+	if err == sql.ErrNoRows {
+		writeInspectionError(c, errInspectionNotFound)
+		return
+	}
+	if err != nil {
+		writeInspectionError(c, err)
+		return
+	}
+	if q.Status != models.InspectionOpen {
+		writeInspectionError(c, fmt.Errorf("%w: it is %s", errInspectionNotEditable, q.Status))
+		return
+	}
+
+	if err := step(tx, q); err != nil {
+		writeInspectionError(c, err)
+		return
+	}
+	if _, err := tx.Exec(`UPDATE qc_inspections SET status = $1, updated_at = NOW() WHERE id = $2`, to, q.ID); err != nil {
+		writeInspectionError(c, err)
+		return
+	}
+
+	h.commitInspection(c, tx, q.ID, http.StatusOK)
+}
+
+func (h *Handler) commitInspection(c *gin.Context, tx *sql.Tx, id, status int) {
+	q, err := scanInspection(tx.QueryRow(`SELECT `+inspectionColumns+inspectionFrom+` WHERE q.id = $1`, id))
+	if err == nil {
+		err = inspectionDetail(tx, q)
+	}
+	if err != nil {
+		writeInspectionError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(status, q)
+}
+
+// inspectionDetail loads the checklist an inspection is answered against
+// and the answers recorded so far.
+func inspectionDetail(db dbtx, q *models.QCInspection) error {
+	if q.TemplateID != nil {
+		var err error
+		if q.Checklist, err = qcTemplateItems(db, *q.TemplateID); err != nil {
+			return err
+		}
+	}
+
+	rows, err := db.Query(`
+		SELECT id, template_item_id, label, item_type, passed, measured_value, defects, photo_url, notes, created_at
+		FROM qc_inspection_results
+		WHERE inspection_id = $1
+		ORDER BY id`, q.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.QCItemResult
+		var templateItemID sql.NullInt64
+		var value sql.NullFloat64
+		err := rows.Scan(&r.ID, &templateItemID, &r.Label, &r.ItemType, &r.Passed, &value, &r.Defects, &r.PhotoURL,
+			&r.Notes, &r.CreatedAt)
+		if err != nil {
+			return err
+		}
+		r.TemplateItemID = nullableInt(templateItemID)
+		r.Value = nullableFloat(value)
+		q.Results = append(q.Results, r)
+	}
+	return rows.Err()
+}
+
+// placeQuarantineHold holds quantity of one balance for an inspection. The
+// balance must have that much on hand that is not already held.
+func placeQuarantineHold(tx *sql.Tx, inspectionID, productID, locationID int, batch string, quantity int, reason string,
+	userID int, tenantID *int) error {
+	if err := adjustQuarantine(tx, productID, locationID, batch, quantity); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO quarantine_holds (inspection_id, product_id, location_id, batch, quantity, reason, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		inspectionID, productID, locationID, batch, quantity, reason, userID, tenantID)
+	return err
+}
+
+// releaseQuarantineHold gives quantity of a hold back to its balance. A
+// hold given back in full is closed with status.
+func releaseQuarantineHold(tx *sql.Tx, hold *models.QuarantineHold, quantity int, status string, userID int) error {
+	if err := adjustQuarantine(tx, hold.ProductID, hold.LocationID, hold.Batch, -quantity); err != nil {
+		return err
+	}
+	if quantity < hold.Quantity {
+		_, err := tx.Exec(`UPDATE quarantine_holds SET quantity = quantity - $1, updated_at = NOW() WHERE id = $2`,
+			quantity, hold.ID)
+		return err
+	}
+	_, err := tx.Exec(`
+		UPDATE quarantine_holds SET status = $1, released_by = $2, released_at = NOW(), updated_at = NOW()
+		WHERE id = $3`, status, userID, hold.ID)
+	return err
+}
+
+// adjustQuarantine changes how much of a balance is held by delta.
+func adjustQuarantine(tx *sql.Tx, productID, locationID int, batch string, delta int) error {
+	result, err := tx.Exec(`
+		UPDATE inventory SET quarantined_quantity = quarantined_quantity + $1, updated_at = NOW()
+		WHERE product_id = $2 AND location_id = $3 AND batch = $4`, delta, productID, locationID, batch)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "inventory_quarantine_within_quantity" {
+		return fmt.Errorf("%w: not enough stock of product %d at location %d to hold", errInsufficientStock, productID, locationID)
+	}
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: no stock of product %d at location %d", errInsufficientStock, productID, locationID)
+	}
+	return nil
+}
+
+// returnHeld is how much of a return inspections hold. With decidedOnly
+// only holds of completed inspections, on failed units, count.
+func returnHeld(tx *sql.Tx, returnID int, decidedOnly bool) (int, error) {
+	var held int
+	err := tx.QueryRow(`
+		SELECT COALESCE(SUM(h.quantity), 0)
+		FROM quarantine_holds h
+		JOIN qc_inspections q ON h.inspection_id = q.id
+		WHERE q.return_id = $1 AND h.status = $2 AND (NOT $3 OR q.status = $4)`,
+		returnID, models.HoldActive, decidedOnly, models.InspectionCompleted).Scan(&held)
+	return held, err
+}
+
+// releaseReturnHolds gives back quantity held on failed units of a
+// return so a disposition can move them.
+func releaseReturnHolds(tx *sql.Tx, returnID, quantity, userID int) error {
+	rows, err := tx.Query(`SELECT `+holdColumns+holdFrom+`
+		WHERE q.return_id = $1 AND q.status = $2 AND h.status = $3
+		ORDER BY h.id
+		FOR UPDATE OF h`, returnID, models.InspectionCompleted, models.HoldActive)
+	if err != nil {
+		return err
+	}
+	var holds []*models.QuarantineHold
+	for rows.Next() {
+		hold, err := scanQuarantineHold(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		holds = append(holds, hold)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, hold := range holds {
+		if quantity == 0 {
+			break
+		}
+		release := hold.Quantity
+		if release > quantity {
+			release = quantity
+		}
+		if err := releaseQuarantineHold(tx, hold, release, models.HoldReleased, userID); err != nil {
+			return err
+		}
+		quantity -= release
+	}
+	if quantity > 0 {
+		return fmt.Errorf("%w: %d are still under inspection", errInvalidReturn, quantity)
+	}
+	return nil
+}
+
+func lockInspectionHold(tx *sql.Tx, inspectionID int) (*models.QuarantineHold, error) {
+	hold, err := scanQuarantineHold(tx.QueryRow(`SELECT `+holdColumns+holdFrom+`
+		WHERE h.inspection_id = $1 AND h.status = $2
+		FOR UPDATE OF h`, inspectionID, models.HoldActive))
+	if err == sql.ErrNoRows {
+		return nil, errHoldNotFound
+	}
+	return hold, err
+}
+
+func scanInspection(row scanner) (*models.QCInspection, error) {
+	var q models.QCInspection
+	var receiptLineID, dispatchID, returnID, locationID, inspectedBy, holdID, warehouseID, createdBy sql.NullInt64
+	var templateID, samplingPlanID, sampleSize, acceptNumber, rejectNumber sql.NullInt64
+	var codeLetter string
+	var inspectedAt sql.NullTime
+	err := row.Scan(&q.ID, &q.DocumentNumber, &q.CheckType, &receiptLineID, &dispatchID, &returnID, &q.SourceDocument,
+		&q.ProductID, &q.ProductName, &q.SKU, &locationID, &q.LocationCode, &q.Batch, &q.Quantity,
+		&q.CheckedQuantity, &q.PassedQuantity, &q.FailedQuantity, &q.Status, &q.Notes, &inspectedBy, &inspectedAt,
+		&holdID, &q.HoldStatus, &templateID, &samplingPlanID, &codeLetter, &sampleSize, &acceptNumber, &rejectNumber,
+		&q.Defects, &warehouseID, &createdBy, &q.CreatedAt, &q.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if sampleSize.Valid {
+		q.Sampling = &models.Sampling{
+			LotSize:      q.Quantity,
+			CodeLetter:   codeLetter,
+			SampleSize:   int(sampleSize.Int64),
+			AcceptNumber: int(acceptNumber.Int64),
+			RejectNumber: int(rejectNumber.Int64),
+		}
+	}
+	q.TemplateID = nullableInt(templateID)
+	q.SamplingPlanID = nullableInt(samplingPlanID)
+	q.ReceiptLineID = nullableInt(receiptLineID)
+	q.DispatchID = nullableInt(dispatchID)
+	q.ReturnID = nullableInt(returnID)
+	q.LocationID = nullableInt(locationID)
+	q.InspectedBy = nullableInt(inspectedBy)
+	q.InspectedAt = nullableTime(inspectedAt)
+	q.HoldID = nullableInt(holdID)
+	q.WarehouseID = nullableInt(warehouseID)
+	q.CreatedBy = nullableInt(createdBy)
+	return &q, nil
+}
+
+func scanQuarantineHold(row scanner) (*models.QuarantineHold, error) {
+	var hold models.QuarantineHold
+	var createdBy, releasedBy sql.NullInt64
+	var releasedAt sql.NullTime
+	err := row.Scan(&hold.ID, &hold.InspectionID, &hold.DocumentNumber, &hold.ProductID, &hold.ProductName,
+		&hold.LocationID, &hold.LocationCode, &hold.Batch, &hold.Quantity, &hold.Status, &hold.Reason, &createdBy,
+		&releasedBy, &releasedAt, &hold.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	hold.CreatedBy = nullableInt(createdBy)
+	hold.ReleasedBy = nullableInt(releasedBy)
+	hold.ReleasedAt = nullableTime(releasedAt)
+	return &hold, nil
+}
+
+func writeInspectionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errInspectionNotFound), errors.Is(err, errHoldNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errInspectionNotEditable), errors.Is(err, errHoldNotEditable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidInspection):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errReceiptNotFound), errors.Is(err, errReceiptLineNotFound), errors.Is(err, errIllegalTransition),
+		errors.Is(err, errReceiptNotEditable), errors.Is(err, errInvalidReceipt):
+		writeGoodsReceiptError(c, err)
+	default:
+		writeAllocationError(c, err)
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// fakeInspection is outgoing inspection 1 of tenant 3 in warehouse 1 on
+// the 4 units of lot L2 in location 11. It samples 2 of them against a
+// checklist of one Brix measurement between 10 and 12, accepting none
+// failed. holdStatus is the status of its quarantine hold, 1, on the
+// units not yet passed.
+type fakeInspection struct {
+	status     string
+	holdStatus string
+	held       int
+	results    []string
+}
+
+// newFakeInspection serves q on top of the warehouse of newFakeLedger,
+// with the units held quarantined in location 11.
+func newFakeInspection(q *fakeInspection) *fakeLedger {
+	tenant, site := 3, 1
+	ledger := newFakeLedger(models.AllocationFEFO)
+	ledger.balance(1, 11, "L2").quarantined = q.held
+	inspection := func() *dbtest.Result {
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(1), "QC-1", models.QCOutgoing, nil, int64(2), nil, "DSP-000002",
+			int64(1), "Gula", "GL-1", int64(11), "A-02", "L2", int64(4), int64(0), int64(0), int64(0), q.status, "", nil, nil,
+			int64(1), q.holdStatus, int64(1), nil, "A", int64(2), int64(0), int64(1), int64(0), int64(site), int64(7),
+			time.Now(), time.Now()}}}
+	}
+	hold := func() *dbtest.Result {
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(1), int64(1), "QC-1", int64(1), "Gula", int64(11), "A-02", "L2",
+			int64(q.held), q.holdStatus, "", int64(7), nil, nil, time.Now()}}}
+	}
+
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[0]) != 1 || !inScope(st.Args[1], &tenant) || !inScope(st.Args[2], &site) {
+			return nil, nil
+		}
+		return inspection(), nil
+	}, "FROM qc_inspections q", "FOR UPDATE OF q")
+	ledger.on(func(dbtest.Statement) (*dbtest.Result, error) { return inspection(), nil }, "FROM qc_inspections q", "WHERE q.id = $1")
+	ledger.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		return &dbtest.Result{Rows: [][]driver.Value{{q.status, false}}}, nil
+	}, "SELECT status, return_id IS NOT NULL FROM qc_inspections")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		q.status = st.Args[0].(string)
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE qc_inspections SET status")
+	ledger.on(affected(1), "UPDATE qc_inspections")
+	ledger.on(rows([]driver.Value{int64(1), int64(1), "Brix", models.QCItemMeasurement, "%", nil, 10.0, 12.0, false, true}),
+		"FROM qc_template_items")
+	ledger.on(rows(), "FROM qc_inspection_results")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		verdict := "failed"
+		if st.Args[4].(bool) {
+			verdict = "passed"
+		}
+		q.results = append(q.results, st.Args[2].(string)+" "+verdict)
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "INSERT INTO qc_inspection_results")
+
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[0]) != 1 || !inScope(st.Args[1], &tenant) || !inScope(st.Args[2], &site) {
+			return nil, nil
+		}
+		return hold(), nil
+	}, "FROM quarantine_holds h", "FOR UPDATE OF h", "h.tenant_id = $2")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if q.holdStatus != st.Args[1] {
+			return nil, nil
+		}
+		return hold(), nil
+	}, "FROM quarantine_holds h", "FOR UPDATE OF h", "h.inspection_id = $1")
+	ledger.on(func(dbtest.Statement) (*dbtest.Result, error) { return hold(), nil }, "FROM quarantine_holds h", "WHERE h.id = $1")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		q.held -= argInt(st.Args[0])
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE quarantine_holds SET quantity = quantity -")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		q.holdStatus = st.Args[0].(string)
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE quarantine_holds SET status")
+	ledger.on(affected(1), "UPDATE quarantine_holds SET reason")
+
+	// adjustQuarantine, within the inventory check constraint
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		b := ledger.balance(argInt(st.Args[1]), argInt(st.Args[2]), st.Args[3].(string))
+		if b == nil {
+			return &dbtest.Result{}, nil
+		}
+		quarantined := b.quarantined + argInt(st.Args[0])
+		if quarantined < 0 || quarantined > b.quantity {
+			return nil, &pq.Error{Code: "23514", Constraint: "inventory_quarantine_within_quantity"}
+		}
+		b.quarantined = quarantined
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE inventory SET quarantined_quantity")
+	return ledger
+}
+
+func TestAdvanceQCInspection(t *testing.T) {
+	other, otherSite := 4, 2
+	staff := auth.Claims{UserID: 7}
+	open := func() *fakeInspection {
+		return &fakeInspection{status: models.InspectionOpen, holdStatus: models.HoldActive, held: 4}
+	}
+	decided := func(holdStatus string, held int) *fakeInspection {
+		return &fakeInspection{status: models.InspectionCompleted, holdStatus: holdStatus, held: held}
+	}
+	tests := []struct {
+		name            string
+		claims          auth.Claims
+		action          string
+		body            string
+		inspection      *fakeInspection
+		frozen          []int
+		wantCode        int
+		wantStatus      string
+		wantHold        string
+		wantQuarantined int
+		wantResults     []string
+		wantMovements   []string
+	}{
+		{name: "a passed sample releases the lot", claims: staff, action: "result",
+			body: `{"items": [{"template_item_id": 1, "value": 11}]}`, inspection: open(), wantCode: http.StatusOK,
+			wantStatus: models.InspectionCompleted, wantHold: models.HoldReleased, wantResults: []string{"Brix passed"}},
+		{name: "a failed sample keeps the lot held", claims: staff, action: "result",
+			body: `{"items": [{"template_item_id": 1, "value": 14}]}`, inspection: open(), wantCode: http.StatusOK,
+			wantStatus: models.InspectionCompleted, wantHold: models.HoldActive, wantQuarantined: 4,
+			wantResults: []string{"Brix failed"}},
+		{name: "only the failed units stay held", claims: staff, action: "result",
+			body:       `{"passed_quantity": 3, "failed_quantity": 1, "items": [{"template_item_id": 1, "value": 11}]}`,
+			inspection: open(), wantCode: http.StatusOK, wantStatus: models.InspectionCompleted, wantHold: models.HoldActive,
+			wantQuarantined: 1, wantResults: []string{"Brix passed"}},
+		{name: "quantities not adding up", claims: staff, action: "result",
+			body:       `{"passed_quantity": 3, "failed_quantity": 2, "items": [{"template_item_id": 1, "value": 11}]}`,
+			inspection: open(), wantCode: http.StatusBadRequest},
+		{name: "required item unanswered", claims: staff, action: "result", body: `{}`, inspection: open(),
+			wantCode: http.StatusBadRequest},
+		{name: "record twice", claims: staff, action: "result",
+			body: `{"items": [{"template_item_id": 1, "value": 11}]}`, inspection: decided(models.HoldActive, 4),
+			wantCode: http.StatusConflict},
+		{name: "cancel releases the lot", claims: staff, action: "cancel", inspection: open(), wantCode: http.StatusOK,
+			wantStatus: models.InspectionCancelled, wantHold: models.HoldReleased},
+		{name: "cancel once recorded", claims: staff, action: "cancel", inspection: decided(models.HoldActive, 4),
+			wantCode: http.StatusConflict},
+		{name: "other tenants' inspections", claims: auth.Claims{UserID: 7, TenantID: &other}, action: "cancel",
+			inspection: open(), wantCode: http.StatusNotFound},
+		{name: "other sites' inspections", claims: auth.Claims{UserID: 7, WarehouseID: &otherSite}, action: "cancel",
+			inspection: open(), wantCode: http.StatusNotFound},
+
+		{name: "release a hold", claims: staff, action: "release", inspection: decided(models.HoldActive, 4),
+			wantCode: http.StatusOK, wantStatus: models.InspectionCompleted, wantHold: models.HoldReleased},
+		{name: "scrap a hold", claims: staff, action: "scrap", inspection: decided(models.HoldActive, 4),
+			wantCode: http.StatusOK, wantStatus: models.InspectionCompleted, wantHold: models.HoldScrapped,
+			wantMovements: []string{"OUT 4 11/L2"}},
+		{name: "scrap under count", claims: staff, action: "scrap", inspection: decided(models.HoldActive, 4),
+			frozen: []int{11}, wantCode: http.StatusConflict},
+		{name: "release while inspecting", claims: staff, action: "release", inspection: open(),
+			wantCode: http.StatusConflict},
+		{name: "release twice", claims: staff, action: "release", inspection: decided(models.HoldReleased, 4),
+			wantCode: http.StatusConflict},
+		{name: "other tenants' holds", claims: auth.Claims{UserID: 7, TenantID: &other}, action: "release",
+			inspection: decided(models.HoldActive, 4), wantCode: http.StatusNotFound},
+		{name: "other sites' holds", claims: auth.Claims{UserID: 7, WarehouseID: &otherSite}, action: "scrap",
+			inspection: decided(models.HoldActive, 4), wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newFakeInspection(tt.inspection)
+			for _, id := range tt.frozen {
+				ledger.frozen[id] = true
+			}
+			h := &Handler{DB: ledger.open(t)}
+			handler := map[string]gin.HandlerFunc{"result": h.RecordQCInspectionResult, "cancel": h.CancelQCInspection,
+				"release": h.ReleaseQuarantineHold, "scrap": h.ScrapQuarantineHold}[tt.action]
+
+			w := serveAs(&tt.claims, "/qc/:id/"+tt.action, handler, jsonRequest(http.MethodPost, "/qc/1/"+tt.action, tt.body))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if ledger.committed != (tt.wantCode == http.StatusOK) {
+				t.Errorf("committed = %v on a %d", ledger.committed, w.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if tt.inspection.status != tt.wantStatus || tt.inspection.holdStatus != tt.wantHold {
+				t.Errorf("inspection is %s with the hold %s, want %s with %s", tt.inspection.status, tt.inspection.holdStatus,
+					tt.wantStatus, tt.wantHold)
+			}
+			if quarantined := ledger.balance(1, 11, "L2").quarantined; quarantined != tt.wantQuarantined {
+				t.Errorf("quarantined = %d, want %d", quarantined, tt.wantQuarantined)
+			}
+			if !reflect.DeepEqual(tt.inspection.results, tt.wantResults) {
+				t.Errorf("results = %v, want %v", tt.inspection.results, tt.wantResults)
+			}
+			if !reflect.DeepEqual(ledger.movements, tt.wantMovements) {
+				t.Errorf("movements = %v, want %v", ledger.movements, tt.wantMovements)
+			}
+		})
+	}
+}
@@ -0,0 +1,610 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var (
+	errQCTemplateNotFound = errors.New("QC template not found")
+	errInvalidQCTemplate  = errors.New("invalid QC template")
+)
+
+// ISO 2859-1 single sampling for normal inspection. Lot size and general
+// inspection level give a code letter (Table 1), the code letter a sample
+// size, and code letter and AQL an acceptance number (Table 2-A).
+var (
+	qcCodeLetters = "ABCDEFGHJKLMNPQR"
+	qcSampleSizes = []int{2, 3, 5, 8, 13, 20, 32, 50, 80, 125, 200, 315, 500, 800, 1250, 2000}
+	qcAQLs        = []float64{0.010, 0.015, 0.025, 0.040, 0.065, 0.10, 0.15, 0.25, 0.40, 0.65, 1.0, 1.5, 2.5, 4.0, 6.5, 10}
+	qcLevels      = map[string]int{"I": 0, "II": 1, "III": 2}
+)
+
+// qcLotLetters holds the code letters for levels I, II and III of every
+// lot size up to maxLot.
+var qcLotLetters = []struct {
+	maxLot  int
+	letters string
+}{
+	{8, "AAB"}, {15, "ABC"}, {25, "BCD"}, {50, "CDE"}, {90, "CEF"}, {150, "DFG"}, {280, "EGH"}, {500, "FHJ"},
+	{1200, "GJK"}, {3200, "HKL"}, {10000, "JLM"}, {35000, "KMN"}, {150000, "LNP"}, {500000, "MPQ"}, {math.MaxInt32, "NQR"},
+}
+
+// qcAcceptNumbers runs along a diagonal of Table 2-A: the first entry is
+// the AQL where a code letter accepts no defects, each next one AQL step
+// further. -1 marks the arrows, which send the inspector to the code letter
+// above (the first) or below (the second).
+var qcAcceptNumbers = []int{0, -1, -1, 1, 2, 3, 5, 7, 10, 14, 21}
+
+const qcTemplateColumns = `t.id, t.name, t.description, t.product_id, COALESCE(p.name, ''), t.category_id,
+	COALESCE(cat.name, ''), t.sampling_plan_id, t.is_active, t.created_by, t.created_at, t.updated_at`
+
+const qcTemplateFrom = ` FROM qc_templates t
+	LEFT JOIN warehouse_product p ON t.product_id = p.id
+	LEFT JOIN warehouse_category cat ON t.category_id = cat.id`
+
+const qcSamplingPlanColumns = `id, name, inspection_level, aql, created_at, updated_at`
+
+// sampleLot works out how many units of a lot plan asks to inspect and
+// how many defects it accepts. A sample as large as the lot means every
+// unit is inspected.
+func sampleLot(plan models.QCSamplingPlan, lotSize int) (models.Sampling, error) {
+	level, ok := qcLevels[plan.InspectionLevel]
+	aql := qcAQLIndex(plan.AQL)
+	if !ok || aql < 0 {
+		return models.Sampling{}, fmt.Errorf("%w: unsupported sampling plan", errInvalidQCTemplate)
+	}
+
+	letter := 0
+	for _, row := range qcLotLetters {
+		if lotSize <= row.maxLot {
+			letter = strings.IndexByte(qcCodeLetters, row.letters[level])
+			break
+		}
+	}
+
+	last := len(qcCodeLetters) - 1
+	accept := -1
+	for accept < 0 {
+		// Code letter A accepts no defects at AQL 6.5
+		step := letter + aql - 14
+		switch {
+		case step < 0, step == 2 && letter < last, step == 1 && letter == 0:
+			letter++
+		case step == 2:
+			accept = 1
+		case step == 1, step >= len(qcAcceptNumbers):
+			letter--
+		default:
+			accept = qcAcceptNumbers[step]
+		}
+	}
+
+	sample := qcSampleSizes[letter]
+	if sample > lotSize {
+		sample = lotSize
+	}
+	return models.Sampling{
+		LotSize:      lotSize,
+		CodeLetter:   string(qcCodeLetters[letter]),
+		SampleSize:   sample,
+		AcceptNumber: accept,
+		RejectNumber: accept + 1,
+	}, nil
+}
+
+func qcAQLIndex(aql float64) int {
+	for i, value := range qcAQLs {
+		if math.Abs(value-aql) < 1e-9 {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *Handler) GetQCSamplingPlans(c *gin.Context) {
+	rows, err := h.DB.Query(`SELECT `+qcSamplingPlanColumns+` FROM qc_sampling_plans
+		WHERE ($1::int IS NULL OR tenant_id = $1)
+		ORDER BY name`, middleware.TenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sampling plans"})
+		return
+	}
+	defer rows.Close()
+
+	plans := []models.QCSamplingPlan{}
+	for rows.Next() {
+		plan, err := scanQCSamplingPlan(rows)
+		if err != nil {
+			continue
+		}
+		plans = append(plans, *plan)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": plans})
+}
+
+func (h *Handler) CreateQCSamplingPlan(c *gin.Context) {
+	var req models.QCSamplingPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateQCSamplingPlan(&req); err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+
+	plan, err := scanQCSamplingPlan(h.DB.QueryRow(`
+		INSERT INTO qc_sampling_plans (name, inspection_level, aql, tenant_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+qcSamplingPlanColumns, req.Name, req.InspectionLevel, req.AQL, middleware.TenantID(c)))
+	if err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, plan)
+}
+
+// UpdateQCSamplingPlan changes a plan for inspections opened from now on.
+// Open inspections keep the sample they were given.
+func (h *Handler) UpdateQCSamplingPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var req models.QCSamplingPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateQCSamplingPlan(&req); err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+
+	plan, err := scanQCSamplingPlan(h.DB.QueryRow(`
+		UPDATE qc_sampling_plans SET name = $1, inspection_level = $2, aql = $3, updated_at = NOW()
+		WHERE id = $4 AND ($5::int IS NULL OR tenant_id = $5)
+		RETURNING `+qcSamplingPlanColumns, req.Name, req.InspectionLevel, req.AQL, id, middleware.TenantID(c)))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sampling plan not found"})
+		return
+	}
+	if err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// GetQCSample previews the sample a plan asks for on a lot of lot_size
+// units.
+func (h *Handler) GetQCSample(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	lotSize, err := strconv.Atoi(c.Query("lot_size"))
+	if err != nil || lotSize <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lot_size must be a positive number"})
+		return
+	}
+
+	plan, err := scanQCSamplingPlan(h.DB.QueryRow(`SELECT `+qcSamplingPlanColumns+` FROM qc_sampling_plans
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2)`, id, middleware.TenantID(c)))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sampling plan not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sampling plan"})
+		return
+	}
+
+	sampling, err := sampleLot(*plan, lotSize)
+	if err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sampling)
+}
+
+// GetQCTemplates lists templates with their items. product_id and
+// category_id filter them.
+func (h *Handler) GetQCTemplates(c *gin.Context) {
+	productID, _ := strconv.Atoi(c.Query("product_id"))
+	categoryID, _ := strconv.Atoi(c.Query("category_id"))
+	rows, err := h.DB.Query(`SELECT `+qcTemplateColumns+qcTemplateFrom+`
+		WHERE ($1::int IS NULL OR t.tenant_id = $1)
+		  AND ($2 = 0 OR t.product_id = $2) AND ($3 = 0 OR t.category_id = $3)
+		ORDER BY t.name, t.id`, middleware.TenantID(c), productID, categoryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch QC templates"})
+		return
+	}
+
+	templates := []models.QCTemplate{}
+	for rows.Next() {
+		t, err := scanQCTemplate(rows)
+		if err != nil {
+			continue
+		}
+		templates = append(templates, *t)
+	}
+	rows.Close()
+
+	for i := range templates {
+		if templates[i].Items, err = qcTemplateItems(h.DB, templates[i].ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch QC templates"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": templates})
+}
+
+func (h *Handler) GetQCTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	t, err := h.findQCTemplate(c, h.DB, id)
+	if err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, t)
+}
+
+// GetProductQCTemplate returns the template inspections of a product are
+// opened with: its own, or else its category's.
+func (h *Handler) GetProductQCTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if ok, err := h.belongsToTenant(c, "warehouse_product", id); err != nil || !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	t, err := qcTemplateFor(h.DB, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch QC template"})
+		return
+	}
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No QC template applies to this product"})
+		return
+	}
+
+	c.JSON(http.StatusOK, t)
+}
+
+func (h *Handler) CreateQCTemplate(c *gin.Context) {
+	var req models.QCTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validateQCTemplate(c, &req); err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO qc_templates (name, description, product_id, category_id, sampling_plan_id, is_active, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		req.Name, req.Description, req.ProductID, req.CategoryID, req.SamplingPlanID, req.IsActive == nil || *req.IsActive,
+		middleware.CurrentUserID(c), middleware.TenantID(c)).Scan(&id)
+	if err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+	if err := addQCTemplateItems(tx, id, req.Items); err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+
+	h.commitQCTemplate(c, tx, id, http.StatusCreated)
+}
+
+// UpdateQCTemplate replaces a template and its items. Results already
+// recorded keep the labels they were given.
+func (h *Handler) UpdateQCTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	var req models.QCTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validateQCTemplate(c, &req); err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := h.findQCTemplate(c, tx, id); err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+	_, err = tx.Exec(`
+		UPDATE qc_templates SET name = $1, description = $2, product_id = $3, category_id = $4, sampling_plan_id = $5,
+			is_active = $6, updated_at = NOW()
+		WHERE id = $7`,
+		req.Name, req.Description, req.ProductID, req.CategoryID, req.SamplingPlanID, req.IsActive == nil || *req.IsActive, id)
+	if err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+	if _, err := tx.Exec(`DELETE FROM qc_template_items WHERE template_id = $1`, id); err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+	if err := addQCTemplateItems(tx, id, req.Items); err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+
+	h.commitQCTemplate(c, tx, id, http.StatusOK)
+}
+
+// DeleteQCTemplate removes a template no inspection was opened with.
+// Templates in use are deactivated instead.
+func (h *Handler) DeleteQCTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	if _, err := h.findQCTemplate(c, h.DB, id); err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+
+	var used bool
+	if err := h.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM qc_inspections WHERE template_id = $1)`, id).Scan(&used); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check template usage"})
+		return
+	}
+	if used {
+		if _, err := h.DB.Exec(`UPDATE qc_templates SET is_active = FALSE, updated_at = NOW() WHERE id = $1`, id); err != nil {
+			writeQCTemplateError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "QC template is in use and was deactivated"})
+		return
+	}
+	if _, err := h.DB.Exec(`DELETE FROM qc_templates WHERE id = $1`, id); err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "QC template deleted successfully"})
+}
+
+func (h *Handler) commitQCTemplate(c *gin.Context, tx *sql.Tx, id, status int) {
+	t, err := h.findQCTemplate(c, tx, id)
+	if err != nil {
+		writeQCTemplateError(c, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(status, t)
+}
+
+// validateQCTemplate checks what a template is attached to and fills in
+// item defaults.
+func (h *Handler) validateQCTemplate(c *gin.Context, req *models.QCTemplateRequest) error {
+	switch {
+	case req.ProductID != nil && req.CategoryID == nil:
+		if ok, err := h.belongsToTenant(c, "warehouse_product", *req.ProductID); err != nil || !ok {
+			return fmt.Errorf("%w: unknown product", errInvalidQCTemplate)
+		}
+	case req.CategoryID != nil && req.ProductID == nil:
+		if ok, err := h.belongsToTenant(c, "warehouse_category", *req.CategoryID); err != nil || !ok {
+			return fmt.Errorf("%w: unknown category", errInvalidQCTemplate)
+		}
+	default:
+		return fmt.Errorf("%w: a template is attached to either product_id or category_id", errInvalidQCTemplate)
+	}
+	if req.SamplingPlanID != nil {
+		if ok, err := h.belongsToTenant(c, "qc_sampling_plans", *req.SamplingPlanID); err != nil || !ok {
+			return fmt.Errorf("%w: unknown sampling plan", errInvalidQCTemplate)
+		}
+	}
+
+	for i := range req.Items {
+		item := &req.Items[i]
+		if item.ItemType == "" {
+			item.ItemType = models.QCItemCheck
+		}
+		switch item.ItemType {
+		case models.QCItemCheck:
+			if item.TargetValue != nil || item.MinValue != nil || item.MaxValue != nil {
+				return fmt.Errorf("%w: %s is a check and takes no values", errInvalidQCTemplate, item.Label)
+			}
+		case models.QCItemMeasurement:
+			if item.MinValue == nil && item.MaxValue == nil {
+				return fmt.Errorf("%w: %s needs min_value or max_value", errInvalidQCTemplate, item.Label)
+			}
+			if item.MinValue != nil && item.MaxValue != nil && *item.MinValue > *item.MaxValue {
+				return fmt.Errorf("%w: %s has min_value above max_value", errInvalidQCTemplate, item.Label)
+			}
+		default:
+			return fmt.Errorf("%w: item_type must be check or measurement", errInvalidQCTemplate)
+		}
+	}
+	return nil
+}
+
+func validateQCSamplingPlan(req *models.QCSamplingPlanRequest) error {
+	if req.InspectionLevel == "" {
+		req.InspectionLevel = "II"
+	}
+	if _, ok := qcLevels[req.InspectionLevel]; !ok {
+		return fmt.Errorf("%w: inspection_level must be I, II or III", errInvalidQCTemplate)
+	}
+	if qcAQLIndex(req.AQL) < 0 {
+		return fmt.Errorf("%w: aql must be one of the ISO 2859-1 steps from 0.010 to 10", errInvalidQCTemplate)
+	}
+	return nil
+}
+
+func addQCTemplateItems(tx *sql.Tx, templateID int, items []models.QCTemplateItemRequest) error {
+	for i, item := range items {
+		_, err := tx.Exec(`
+			INSERT INTO qc_template_items (template_id, position, label, item_type, unit, target_value, min_value, max_value,
+			                               photo_required, is_required)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			templateID, i+1, item.Label, item.ItemType, item.Unit, item.TargetValue, item.MinValue, item.MaxValue,
+			item.PhotoRequired, item.IsRequired == nil || *item.IsRequired)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Handler) findQCTemplate(c *gin.Context, q dbtx, id int) (*models.QCTemplate, error) {
+	t, err := scanQCTemplate(q.QueryRow(`SELECT `+qcTemplateColumns+qcTemplateFrom+`
+		WHERE t.id = $1 AND ($2::int IS NULL OR t.tenant_id = $2)`, id, middleware.TenantID(c)))
+	if err == sql.ErrNoRows {
+		return nil, errQCTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Items, err = qcTemplateItems(q, t.ID)
+	return t, err
+}
+
+// qcTemplateFor finds the active template for a product, falling back to
+// its category's. It returns nil when neither has one.
+func qcTemplateFor(q dbtx, productID int) (*models.QCTemplate, error) {
+	t, err := scanQCTemplate(q.QueryRow(`SELECT `+qcTemplateColumns+qcTemplateFrom+`
+		WHERE t.is_active
+		  AND (t.product_id = $1 OR t.category_id = (SELECT category_id FROM warehouse_product WHERE id = $1))
+		ORDER BY t.product_id IS NULL
+		LIMIT 1`, productID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Items, err = qcTemplateItems(q, t.ID)
+	return t, err
+}
+
+func qcTemplateItems(q dbtx, templateID int) ([]models.QCTemplateItem, error) {
+	rows, err := q.Query(`
+		SELECT id, position, label, item_type, unit, target_value, min_value, max_value, photo_required, is_required
+		FROM qc_template_items
+		WHERE template_id = $1
+		ORDER BY position, id`, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.QCTemplateItem{}
+	for rows.Next() {
+		var item models.QCTemplateItem
+		var target, min, max sql.NullFloat64
+		err := rows.Scan(&item.ID, &item.Position, &item.Label, &item.ItemType, &item.Unit, &target, &min, &max,
+			&item.PhotoRequired, &item.IsRequired)
+		if err != nil {
+			return nil, err
+		}
+		item.TargetValue = nullableFloat(target)
+		item.MinValue = nullableFloat(min)
+		item.MaxValue = nullableFloat(max)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func qcSamplingPlan(q dbtx, id int) (*models.QCSamplingPlan, error) {
+	return scanQCSamplingPlan(q.QueryRow(`SELECT `+qcSamplingPlanColumns+` FROM qc_sampling_plans WHERE id = $1`, id))
+}
+
+func scanQCSamplingPlan(row scanner) (*models.QCSamplingPlan, error) {
+	var plan models.QCSamplingPlan
+	err := row.Scan(&plan.ID, &plan.Name, &plan.InspectionLevel, &plan.AQL, &plan.CreatedAt, &plan.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func scanQCTemplate(row scanner) (*models.QCTemplate, error) {
+	var t models.QCTemplate
+	var productID, categoryID, samplingPlanID, createdBy sql.NullInt64
+	err := row.Scan(&t.ID, &t.Name, &t.Description, &productID, &t.ProductName, &categoryID, &t.CategoryName,
+		&samplingPlanID, &t.IsActive, &createdBy, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.ProductID = nullableInt(productID)
+	t.CategoryID = nullableInt(categoryID)
+	t.SamplingPlanID = nullableInt(samplingPlanID)
+	t.CreatedBy = nullableInt(createdBy)
+	return &t, nil
+}
+
+func writeQCTemplateError(c *gin.Context, err error) {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, errQCTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidQCTemplate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		c.JSON(http.StatusConflict, gin.H{"error": "An active QC template already exists for this product or category"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save QC template"})
+	}
+}
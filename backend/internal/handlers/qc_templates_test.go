@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+func TestSampleLot(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		aql     float64
+		lotSize int
+		want    models.Sampling
+		wantErr bool
+	}{
+		{name: "level II, AQL 1.0", level: "II", aql: 1.0, lotSize: 500,
+			want: models.Sampling{LotSize: 500, CodeLetter: "H", SampleSize: 50, AcceptNumber: 1, RejectNumber: 2}},
+		{name: "level II, AQL 2.5", level: "II", aql: 2.5, lotSize: 100,
+			want: models.Sampling{LotSize: 100, CodeLetter: "F", SampleSize: 20, AcceptNumber: 1, RejectNumber: 2}},
+		{name: "level III, AQL 0.65", level: "III", aql: 0.65, lotSize: 1000,
+			want: models.Sampling{LotSize: 1000, CodeLetter: "K", SampleSize: 125, AcceptNumber: 2, RejectNumber: 3}},
+		{name: "arrow down to a larger sample", level: "II", aql: 1.0, lotSize: 50,
+			want: models.Sampling{LotSize: 50, CodeLetter: "E", SampleSize: 13, AcceptNumber: 0, RejectNumber: 1}},
+		{name: "a sample larger than the lot inspects all of it", level: "I", aql: 0.10, lotSize: 40,
+			want: models.Sampling{LotSize: 40, CodeLetter: "K", SampleSize: 40, AcceptNumber: 0, RejectNumber: 1}},
+		{name: "AQL off the table", level: "II", aql: 3.0, lotSize: 500, wantErr: true},
+		{name: "unknown level", level: "IV", aql: 1.0, lotSize: 500, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sampleLot(models.QCSamplingPlan{InspectionLevel: tt.level, AQL: tt.aql}, tt.lotSize)
+			if tt.wantErr {
+				if !errors.Is(err, errInvalidQCTemplate) {
+					t.Fatalf("sampleLot() error = %v, want %v", err, errInvalidQCTemplate)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sampleLot() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("sampleLot() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckQCItems(t *testing.T) {
+	min, max := 10.0, 12.0
+	checklist := []models.QCTemplateItem{
+		{ID: 1, Label: "Brix", ItemType: models.QCItemMeasurement, MinValue: &min, MaxValue: &max, IsRequired: true},
+		{ID: 2, Label: "Seal", ItemType: models.QCItemCheck, PhotoRequired: true, IsRequired: true},
+		{ID: 3, Label: "Label", ItemType: models.QCItemCheck},
+	}
+	value := func(v float64) *float64 { return &v }
+	passed := func(v bool) *bool { return &v }
+	seal := models.QCItemResultRequest{TemplateItemID: 2, Passed: passed(true), PhotoURL: "seal.jpg"}
+	tests := []struct {
+		name        string
+		checklist   []models.QCTemplateItem
+		answers     []models.QCItemResultRequest
+		wantPassed  []bool
+		wantDefects int
+		wantErr     bool
+	}{
+		{name: "within tolerance", checklist: checklist,
+			answers:    []models.QCItemResultRequest{{TemplateItemID: 1, Value: value(11)}, seal},
+			wantPassed: []bool{true, true}},
+		{name: "out of tolerance counts a defect", checklist: checklist,
+			answers:    []models.QCItemResultRequest{{TemplateItemID: 1, Value: value(12.5)}, seal},
+			wantPassed: []bool{false, true}, wantDefects: 1},
+		{name: "failed checks count their defects", checklist: checklist,
+			answers: []models.QCItemResultRequest{{TemplateItemID: 1, Value: value(10)}, seal,
+				{TemplateItemID: 3, Passed: passed(false), Defects: 3}},
+			wantPassed: []bool{true, true, false}, wantDefects: 3},
+		{name: "measurement without a value", checklist: checklist,
+			answers: []models.QCItemResultRequest{{TemplateItemID: 1, Passed: passed(true)}, seal}, wantErr: true},
+		{name: "photo missing", checklist: checklist,
+			answers: []models.QCItemResultRequest{{TemplateItemID: 1, Value: value(11)}, {TemplateItemID: 2, Passed: passed(true)}},
+			wantErr: true},
+		{name: "required item unanswered", checklist: checklist,
+			answers: []models.QCItemResultRequest{{TemplateItemID: 1, Value: value(11)}}, wantErr: true},
+		{name: "answered twice", checklist: checklist,
+			answers: []models.QCItemResultRequest{{TemplateItemID: 1, Value: value(11)}, seal, seal}, wantErr: true},
+		{name: "not on the checklist", checklist: checklist,
+			answers: []models.QCItemResultRequest{{TemplateItemID: 1, Value: value(11)}, seal, {TemplateItemID: 9, Passed: passed(true)}},
+			wantErr: true},
+		{name: "answers without a checklist",
+			answers: []models.QCItemResultRequest{{TemplateItemID: 1, Value: value(11)}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, defects, err := checkQCItems(tt.checklist, tt.answers)
+			if tt.wantErr {
+				if !errors.Is(err, errInvalidInspection) {
+					t.Fatalf("checkQCItems() error = %v, want %v", err, errInvalidInspection)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkQCItems() error = %v", err)
+			}
+			var gotPassed []bool
+			for _, r := range results {
+				gotPassed = append(gotPassed, r.Passed)
+			}
+			if !reflect.DeepEqual(gotPassed, tt.wantPassed) || defects != tt.wantDefects {
+				t.Errorf("checkQCItems() passed %v with %d defects, want %v with %d", gotPassed, defects, tt.wantPassed,
+					tt.wantDefects)
+			}
+		})
+	}
+}
+
+// fakeQCTemplate is template 1 of tenant 3, on product 1. Category 2 and
+// sampling plan 1 are the tenant's too. duplicate makes saving it clash
+// with another active template.
+type fakeQCTemplate struct {
+	items     []string
+	active    bool
+	used      bool
+	duplicate bool
+	deleted   bool
+}
+
+func newFakeQCTemplate(q *fakeQCTemplate) *fakeDB {
+	tenant := 3
+	db := &fakeDB{}
+	owned := func(id int) dbtest.Handler {
+		return func(st dbtest.Statement) (*dbtest.Result, error) {
+			return &dbtest.Result{Rows: [][]driver.Value{{argInt(st.Args[0]) == id && inScope(st.Args[1], &tenant)}}}, nil
+		}
+	}
+	db.on(owned(1), "SELECT EXISTS(SELECT 1 FROM warehouse_product")
+	db.on(owned(2), "SELECT EXISTS(SELECT 1 FROM warehouse_category")
+	db.on(owned(1), "SELECT EXISTS(SELECT 1 FROM qc_sampling_plans")
+	db.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		return &dbtest.Result{Rows: [][]driver.Value{{q.used}}}, nil
+	}, "SELECT EXISTS(SELECT 1 FROM qc_inspections")
+
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if q.duplicate {
+			return nil, &pq.Error{Code: "23505", Constraint: "qc_templates_active_product"}
+		}
+		q.active = st.Args[5].(bool)
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(1)}}}, nil
+	}, "INSERT INTO qc_templates")
+	db.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		q.active = false
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE qc_templates SET is_active = FALSE")
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if q.duplicate {
+			return nil, &pq.Error{Code: "23505", Constraint: "qc_templates_active_product"}
+		}
+		q.active = st.Args[5].(bool)
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE qc_templates SET name")
+	db.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		q.deleted = true
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "DELETE FROM qc_templates")
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[0]) != 1 || !inScope(st.Args[1], &tenant) {
+			return nil, nil
+		}
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(1), "Gula", "", int64(1), "Gula", nil, "", nil, q.active, int64(7),
+			time.Now(), time.Now()}}}, nil
+	}, "FROM qc_templates t")
+
+	db.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		q.items = nil
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "DELETE FROM qc_template_items")
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		q.items = append(q.items, st.Args[2].(string)+" "+st.Args[3].(string))
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "INSERT INTO qc_template_items")
+	db.on(rows(), "FROM qc_template_items")
+	return db
+}
+
+func TestSaveQCTemplate(t *testing.T) {
+	other := 4
+	staff := auth.Claims{UserID: 7}
+	tests := []struct {
+		name       string
+		claims     auth.Claims
+		method     string
+		body       string
+		duplicate  bool
+		wantCode   int
+		wantActive bool
+		wantItems  []string
+	}{
+		{name: "create on a product", claims: staff, method: http.MethodPost,
+			body: `{"name": "Gula", "product_id": 1, "items": [{"label": "Seal", "photo_required": true},
+				{"label": "Brix", "item_type": "measurement", "min_value": 10, "max_value": 12}]}`,
+			wantCode: http.StatusCreated, wantActive: true, wantItems: []string{"Seal check", "Brix measurement"}},
+		{name: "create on a category with a sampling plan", claims: staff, method: http.MethodPost,
+			body:     `{"name": "Sembako", "category_id": 2, "sampling_plan_id": 1, "items": [{"label": "Seal"}]}`,
+			wantCode: http.StatusCreated, wantActive: true, wantItems: []string{"Seal check"}},
+		{name: "create inactive", claims: staff, method: http.MethodPost,
+			body:     `{"name": "Gula", "product_id": 1, "is_active": false}`,
+			wantCode: http.StatusCreated},
+		{name: "both product and category", claims: staff, method: http.MethodPost,
+			body: `{"name": "Gula", "product_id": 1, "category_id": 2}`, wantCode: http.StatusBadRequest},
+		{name: "neither product nor category", claims: staff, method: http.MethodPost,
+			body: `{"name": "Gula"}`, wantCode: http.StatusBadRequest},
+		{name: "other tenants' products", claims: auth.Claims{UserID: 7, TenantID: &other}, method: http.MethodPost,
+			body: `{"name": "Gula", "product_id": 1}`, wantCode: http.StatusBadRequest},
+		{name: "unknown sampling plan", claims: staff, method: http.MethodPost,
+			body: `{"name": "Gula", "product_id": 1, "sampling_plan_id": 2}`, wantCode: http.StatusBadRequest},
+		{name: "measurement without a tolerance", claims: staff, method: http.MethodPost,
+			body:     `{"name": "Gula", "product_id": 1, "items": [{"label": "Brix", "item_type": "measurement"}]}`,
+			wantCode: http.StatusBadRequest},
+		{name: "tolerance upside down", claims: staff, method: http.MethodPost,
+			body:     `{"name": "Gula", "product_id": 1, "items": [{"label": "Brix", "item_type": "measurement", "min_value": 12, "max_value": 10}]}`,
+			wantCode: http.StatusBadRequest},
+		{name: "check with a tolerance", claims: staff, method: http.MethodPost,
+			body:     `{"name": "Gula", "product_id": 1, "items": [{"label": "Seal", "max_value": 1}]}`,
+			wantCode: http.StatusBadRequest},
+		{name: "a second active template", claims: staff, method: http.MethodPost,
+			body: `{"name": "Gula", "product_id": 1}`, duplicate: true, wantCode: http.StatusConflict},
+		{name: "update replaces the checklist", claims: staff, method: http.MethodPut,
+			body:     `{"name": "Gula", "product_id": 1, "is_active": false, "items": [{"label": "Label"}]}`,
+			wantCode: http.StatusOK, wantItems: []string{"Label check"}},
+		{name: "reactivate next to another active template", claims: staff, method: http.MethodPut,
+			body: `{"name": "Gula", "product_id": 1}`, duplicate: true, wantCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := &fakeQCTemplate{active: true, duplicate: tt.duplicate}
+			db := newFakeQCTemplate(template)
+			h := &Handler{DB: db.open(t)}
+
+			handler, route, target := h.CreateQCTemplate, "/qc-templates", "/qc-templates"
+			if tt.method == http.MethodPut {
+				template.items = []string{"Seal check"}
+				handler, route, target = h.UpdateQCTemplate, "/qc-templates/:id", "/qc-templates/1"
+			}
+			rec := serveAs(&tt.claims, route, handler, jsonRequest(tt.method, target, tt.body))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			success := tt.wantCode == http.StatusOK || tt.wantCode == http.StatusCreated
+			if db.committed != success {
+				t.Errorf("committed = %v on a %d", db.committed, rec.Code)
+			}
+			if !success {
+				return
+			}
+			if template.active != tt.wantActive {
+				t.Errorf("active = %v, want %v", template.active, tt.wantActive)
+			}
+			if !reflect.DeepEqual(template.items, tt.wantItems) {
+				t.Errorf("items = %v, want %v", template.items, tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestDeleteQCTemplate(t *testing.T) {
+	other := 4
+	tests := []struct {
+		name        string
+		claims      auth.Claims
+		used        bool
+		wantCode    int
+		wantDeleted bool
+		wantActive  bool
+	}{
+		{name: "unused templates are deleted", claims: auth.Claims{UserID: 7}, wantCode: http.StatusOK, wantDeleted: true,
+			wantActive: true},
+		{name: "templates in use are deactivated", claims: auth.Claims{UserID: 7}, used: true, wantCode: http.StatusOK},
+		{name: "other tenants' templates", claims: auth.Claims{UserID: 7, TenantID: &other}, wantCode: http.StatusNotFound,
+			wantActive: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := &fakeQCTemplate{active: true, used: tt.used}
+			h := &Handler{DB: newFakeQCTemplate(template).open(t)}
+
+			w := serveAs(&tt.claims, "/qc-templates/:id", h.DeleteQCTemplate, jsonRequest(http.MethodDelete, "/qc-templates/1", ""))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if template.deleted != tt.wantDeleted || template.active != tt.wantActive {
+				t.Errorf("deleted = %v, active = %v, want %v and %v", template.deleted, template.active, tt.wantDeleted,
+					tt.wantActive)
+			}
+		})
+	}
+}
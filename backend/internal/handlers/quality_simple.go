@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"net/http"
+	"wms-backend/internal/middleware"
+
 	"github.com/gin-gonic/gin"
 )
 
 func (h *Handler) GetQualityChecksSimple(c *gin.Context) {
-	rows, err := h.DB.Query("SELECT id, reception_id, product_name, quantity, status, COALESCE(notes, '') FROM quality_checks ORDER BY checked_at DESC")
+	rows, err := h.DB.Query("SELECT id, reception_id, product_name, quantity, status, COALESCE(notes, '') FROM quality_checks WHERE ($1::int IS NULL OR tenant_id = $1) ORDER BY checked_at DESC", middleware.TenantID(c))
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
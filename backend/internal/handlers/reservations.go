@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reservationRef is the document stock is reserved for.
+type reservationRef struct {
+	Type     string
+	ID       int
+	UserID   int
+	TenantID *int
+}
+
+// reservedStock is one reservation as it was released or consumed.
+type reservedStock struct {
+	productID  int
+	locationID int
+	batch      string
+	quantity   int
+}
+
+// reserveStock allocates quantity of a product under its strategy and
+// holds it for ref. Either all of it is reserved or, with
+// errInsufficientStock, none; the plan says what was available.
+func reserveStock(tx *sql.Tx, productID, quantity int, scope allocationScope, ref reservationRef) (models.AllocationPlan, error) {
+	scope.IncludeReserved, scope.Lock = false, true
+	plan, err := planAllocation(tx, productID, quantity, scope)
+	if err != nil {
+		return plan, err
+	}
+	if plan.Short > 0 {
+		return plan, fmt.Errorf("%w: %d available, %d requested", errInsufficientStock, plan.Allocated, quantity)
+	}
+	return plan, reservePlan(tx, plan, ref)
+}
+
+// reservePlan holds the lines of a locked plan for ref.
+func reservePlan(tx *sql.Tx, plan models.AllocationPlan, ref reservationRef) error {
+	productID := plan.ProductID
+	for _, line := range plan.Lines {
+		// The rows are locked by the plan; the condition keeps the update
+		// safe even if they were not
+		res, err := tx.Exec(`
+			UPDATE inventory SET reserved_quantity = reserved_quantity + $4, updated_at = NOW()
+			WHERE product_id = $1 AND location_id = $2 AND batch = $3 AND quantity - reserved_quantity - quarantined_quantity >= $4`,
+			productID, line.LocationID, line.Batch, line.Quantity)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: stock at %s was taken meanwhile", errInsufficientStock, line.LocationCode)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO stock_reservations (product_id, location_id, batch, quantity, reference_type, reference_id, status, created_by, tenant_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, COALESCE((SELECT tenant_id FROM warehouse_product WHERE id = $1), $9))`,
+			productID, line.LocationID, line.Batch, line.Quantity, ref.Type, ref.ID, models.ReservationActive, ref.UserID, ref.TenantID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseReservations gives the stock held for a document back to
+// available.
+func releaseReservations(tx *sql.Tx, refType string, refID int) error {
+	_, err := closeReservations(tx, refType, refID, models.ReservationReleased)
+	return err
+}
+
+// shrinkReservation gives quantity of an active reservation back to
+// available, releasing the reservation when that is all of it.
+func shrinkReservation(tx *sql.Tx, id, quantity int) error {
+	var r reservedStock
+	err := tx.QueryRow(`
+		UPDATE stock_reservations
+		SET quantity = CASE WHEN quantity > $2 THEN quantity - $2 ELSE quantity END,
+		    status = CASE WHEN quantity > $2 THEN status ELSE $3 END,
+		    updated_at = NOW()
+		WHERE id = $1 AND status = $4
+		RETURNING product_id, location_id, batch`,
+		id, quantity, models.ReservationReleased, models.ReservationActive).Scan(&r.productID, &r.locationID, &r.batch)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE inventory SET reserved_quantity = reserved_quantity - $4, updated_at = NOW()
+		WHERE product_id = $1 AND location_id = $2 AND batch = $3`,
+		r.productID, r.locationID, r.batch, quantity)
+	return err
+}
+
+// consumeReservations books the stock held for a document as OUT movements
+// built from m and closes its reservations.
+func consumeReservations(tx *sql.Tx, refType string, refID int, m stockMovement) ([]reservedStock, error) {
+	held, err := closeReservations(tx, refType, refID, models.ReservationConsumed)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Type = models.MovementOut
+	for _, r := range held {
+		if err := ensureStockNotFrozen(tx, r.productID, r.locationID); err != nil {
+			return nil, err
+		}
+		out := m
+		out.ProductID, out.LocationID, out.Batch, out.Quantity = r.productID, r.locationID, r.batch, r.quantity
+		if err := applyStockMovement(tx, out); err != nil {
+			return nil, err
+		}
+	}
+	return held, nil
+}
+
+// closeReservations moves the active reservations of a document to status
+// and takes them off inventory.reserved_quantity.
+func closeReservations(tx *sql.Tx, refType string, refID int, status string) ([]reservedStock, error) {
+	rows, err := tx.Query(`
+		UPDATE stock_reservations SET status = $3, updated_at = NOW()
+		WHERE reference_type = $1 AND reference_id = $2 AND status = $4
+		RETURNING product_id, location_id, batch, quantity`,
+		refType, refID, status, models.ReservationActive)
+	if err != nil {
+		return nil, err
+	}
+	var held []reservedStock
+	for rows.Next() {
+		var r reservedStock
+		if err := rows.Scan(&r.productID, &r.locationID, &r.batch, &r.quantity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		held = append(held, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, r := range held {
+		_, err := tx.Exec(`
+			UPDATE inventory SET reserved_quantity = reserved_quantity - $4, updated_at = NOW()
+			WHERE product_id = $1 AND location_id = $2 AND batch = $3`,
+			r.productID, r.locationID, r.batch, r.quantity)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return held, nil
+}
+
+// GetStockReservations lists reservations, the active ones unless status
+// says otherwise. reference_type and reference_id find those of one
+// document.
+func (h *Handler) GetStockReservations(c *gin.Context) {
+	productID, _ := strconv.Atoi(c.Query("product_id"))
+	referenceID, _ := strconv.Atoi(c.Query("reference_id"))
+	status := c.DefaultQuery("status", models.ReservationActive)
+
+	rows, err := h.DB.Query(`
+		SELECT r.id, r.product_id, p.name, p.sku, r.location_id, l.code, r.batch, r.quantity,
+		       r.reference_type, r.reference_id, r.status, r.created_by, r.created_at, r.updated_at
+		FROM stock_reservations r
+		JOIN warehouse_product p ON r.product_id = p.id
+		JOIN locations l ON r.location_id = l.id
+		WHERE ($1 = 0 OR r.product_id = $1) AND ($2 = '' OR r.status = $2)
+		  AND ($3 = '' OR r.reference_type = $3) AND ($4 = 0 OR r.reference_id = $4)
+		  AND ($5::int IS NULL OR r.tenant_id = $5) AND ($6::int IS NULL OR l.warehouse_id = $6)
+		ORDER BY r.created_at DESC, r.id DESC`,
+		productID, status, c.Query("reference_type"), referenceID, middleware.TenantID(c), middleware.WarehouseID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reservations"})
+		return
+	}
+	defer rows.Close()
+
+	var reservations []models.StockReservation
+	for rows.Next() {
+		var r models.StockReservation
+		var createdBy sql.NullInt64
+		err := rows.Scan(&r.ID, &r.ProductID, &r.ProductName, &r.SKU, &r.LocationID, &r.LocationCode, &r.Batch, &r.Quantity,
+			&r.ReferenceType, &r.ReferenceID, &r.Status, &createdBy, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			continue
+		}
+		r.CreatedBy = nullableInt(createdBy)
+		reservations = append(reservations, r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reservations})
+}
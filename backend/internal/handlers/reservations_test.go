@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// fakeReservation is one stock_reservations row.
+type fakeReservation struct {
+	id            int
+	productID     int
+	locationID    int
+	batch         string
+	quantity      int
+	referenceType string
+	referenceID   int
+	status        string
+}
+
+// fakeLedger adds reservations and the stock movement ledger to a
+// fakeStock, enforcing the inventory constraints the way PostgreSQL does.
+type fakeLedger struct {
+	*fakeStock
+	reservations []*fakeReservation
+	movements    []string
+}
+
+func newFakeLedger(strategy string) *fakeLedger {
+	l := &fakeLedger{fakeStock: newFakeStock(strategy)}
+	l.on(l.reserve, "UPDATE inventory SET reserved_quantity", "reserved_quantity +")
+	l.on(l.unreserve, "UPDATE inventory SET reserved_quantity", "reserved_quantity -")
+	l.on(l.insertReservation, "INSERT INTO stock_reservations")
+	l.on(l.shrinkReservation, "UPDATE stock_reservations", "CASE WHEN")
+	l.on(l.closeReservations, "UPDATE stock_reservations", "RETURNING")
+	l.on(l.move, "INSERT INTO inventory")
+	l.on(l.onHand, "SELECT COALESCE(SUM(quantity), 0)", "FROM inventory")
+	l.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		args := st.Args
+		l.movements = append(l.movements, fmt.Sprintf("%s %d %d/%s", args[2], argInt(args[3]), argInt(args[1]), args[7]))
+		return nil, nil
+	}, "INSERT INTO stock_movements")
+	return l
+}
+
+// reserved lists the active reservations as quantity location/lot.
+func (l *fakeLedger) reserved() []string {
+	var held []string
+	for _, r := range l.reservations {
+		if r.status == models.ReservationActive {
+			held = append(held, fmt.Sprintf("%d %d/%s", r.quantity, r.locationID, r.batch))
+		}
+	}
+	return held
+}
+
+func (l *fakeLedger) reserve(st dbtest.Statement) (*dbtest.Result, error) {
+	args := st.Args
+	b := l.balance(argInt(args[0]), argInt(args[1]), args[2].(string))
+	quantity := argInt(args[3])
+	if b == nil || l.available(b, false) < quantity {
+		return &dbtest.Result{}, nil
+	}
+	b.reserved += quantity
+	return &dbtest.Result{RowsAffected: 1}, nil
+}
+
+func (l *fakeLedger) unreserve(st dbtest.Statement) (*dbtest.Result, error) {
+	args := st.Args
+	b := l.balance(argInt(args[0]), argInt(args[1]), args[2].(string))
+	b.reserved -= argInt(args[3])
+	return &dbtest.Result{RowsAffected: 1}, nil
+}
+
+func (l *fakeLedger) insertReservation(st dbtest.Statement) (*dbtest.Result, error) {
+	args := st.Args
+	l.reservations = append(l.reservations, &fakeReservation{
+		id: len(l.reservations) + 1, productID: argInt(args[0]), locationID: argInt(args[1]), batch: args[2].(string),
+		quantity: argInt(args[3]), referenceType: args[4].(string), referenceID: argInt(args[5]), status: args[6].(string),
+	})
+	return nil, nil
+}
+
+func (l *fakeLedger) shrinkReservation(st dbtest.Statement) (*dbtest.Result, error) {
+	args := st.Args
+	for _, r := range l.reservations {
+		if r.id == argInt(args[0]) && r.status == args[3].(string) {
+			if quantity := argInt(args[1]); r.quantity > quantity {
+				r.quantity -= quantity
+			} else {
+				r.status = args[2].(string)
+			}
+			return &dbtest.Result{Rows: [][]driver.Value{{int64(r.productID), int64(r.locationID), r.batch}}}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (l *fakeLedger) closeReservations(st dbtest.Statement) (*dbtest.Result, error) {
+	args := st.Args
+	res := &dbtest.Result{}
+	for _, r := range l.reservations {
+		if r.referenceType == args[0].(string) && r.referenceID == argInt(args[1]) && r.status == args[3].(string) {
+			r.status = args[2].(string)
+			res.Rows = append(res.Rows, []driver.Value{int64(r.productID), int64(r.locationID), r.batch, int64(r.quantity)})
+		}
+	}
+	return res, nil
+}
+
+// move books a quantity into a balance, enforcing the inventory check
+// constraints the way PostgreSQL does. A lot keeps its first expiry date.
+func (l *fakeLedger) move(st dbtest.Statement) (*dbtest.Result, error) {
+	args := st.Args
+	b := l.balance(argInt(args[0]), argInt(args[1]), args[2].(string))
+	if b == nil {
+		b = &fakeBalance{productID: argInt(args[0]), locationID: argInt(args[1]), batch: args[2].(string)}
+		l.balances = append(l.balances, b)
+	}
+	if expiry, ok := args[3].(time.Time); ok && b.expiry == nil {
+		b.expiry = &expiry
+	}
+	quantity := b.quantity + argInt(args[4])
+	if quantity < 0 {
+		return nil, &pq.Error{Code: "23514", Constraint: "inventory_quantity_non_negative"}
+	}
+	if quantity < b.quarantined {
+		return nil, &pq.Error{Code: "23514", Constraint: "inventory_quarantine_within_quantity"}
+	}
+	b.quantity = quantity
+	return nil, nil
+}
+
+// onHand answers lockedOnHand.
+func (l *fakeLedger) onHand(st dbtest.Statement) (*dbtest.Result, error) {
+	args := st.Args
+	total := 0
+	for _, b := range l.balances {
+		if b.productID == argInt(args[0]) && b.locationID == argInt(args[1]) && (args[2] == "" || b.batch == args[2]) {
+			total += b.quantity
+		}
+	}
+	return &dbtest.Result{Rows: [][]driver.Value{{int64(total)}}}, nil
+}
+
+// held renders the reservations of a document in a status as
+// location/batch:quantity.
+func (l *fakeLedger) held(refID int, status string) []string {
+	var held []string
+	for _, r := range l.reservations {
+		if r.referenceID == refID && r.status == status {
+			held = append(held, fmt.Sprintf("%d/%s:%d", r.locationID, r.batch, r.quantity))
+		}
+	}
+	return held
+}
+
+// stock renders every balance as location:quantity/reserved.
+func (l *fakeLedger) stock() []string {
+	var stock []string
+	for _, b := range l.balances {
+		stock = append(stock, fmt.Sprintf("%d:%d/%d", b.locationID, b.quantity, b.reserved))
+	}
+	sort.Strings(stock)
+	return stock
+}
+
+func orderRef(id int) reservationRef {
+	return reservationRef{Type: "order", ID: id, UserID: 7}
+}
+
+func TestReserveStock(t *testing.T) {
+	tests := []struct {
+		name      string
+		before    map[int]int
+		quantity  int
+		frozen    []int
+		wantErr   error
+		wantHeld  []string
+		wantStock []string
+	}{
+		{
+			name: "reserves in allocation order", quantity: 6,
+			wantHeld:  []string{"11/L2:4", "10/L1:2"},
+			wantStock: []string{"10:5/2", "11:4/4", "12:10/0"},
+		},
+		{
+			name: "stock held for another document is passed over", quantity: 6,
+			before:    map[int]int{2: 5},
+			wantHeld:  []string{"10/L1:4", "12/L3:2"},
+			wantStock: []string{"10:5/5", "11:4/4", "12:10/2"},
+		},
+		{
+			name: "frozen stock is not reserved", quantity: 6, frozen: []int{11},
+			wantHeld:  []string{"10/L1:5", "12/L3:1"},
+			wantStock: []string{"10:5/5", "11:4/0", "12:10/1"},
+		},
+		{
+			name: "shortage reserves nothing", quantity: 20,
+			wantErr:   errInsufficientStock,
+			wantStock: []string{"10:5/0", "11:4/0", "12:10/0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newFakeLedger(models.AllocationFEFO)
+			tx := beginTestTx(t, ledger.handle)
+			for refID, quantity := range tt.before {
+				if _, err := reserveStock(tx, 1, quantity, allocationScope{}, orderRef(refID)); err != nil {
+					t.Fatalf("reserveStock() for order %d error = %v", refID, err)
+				}
+			}
+			for _, id := range tt.frozen {
+				ledger.frozen[id] = true
+			}
+
+			plan, err := reserveStock(tx, 1, tt.quantity, allocationScope{}, orderRef(1))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("reserveStock() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && plan.Short == 0 {
+				t.Errorf("plan.Short = 0 on a failed reservation")
+			}
+			if got := ledger.held(1, models.ReservationActive); !reflect.DeepEqual(got, tt.wantHeld) {
+				t.Errorf("held = %v, want %v", got, tt.wantHeld)
+			}
+			if got := ledger.stock(); !reflect.DeepEqual(got, tt.wantStock) {
+				t.Errorf("stock = %v, want %v", got, tt.wantStock)
+			}
+		})
+	}
+}
+
+func TestReservePlanStockTakenMeanwhile(t *testing.T) {
+	ledger := newFakeLedger(models.AllocationFEFO)
+	tx := beginTestTx(t, ledger.handle)
+
+	plan, err := planAllocation(tx, 1, 4, allocationScope{Lock: true})
+	if err != nil {
+		t.Fatalf("planAllocation() error = %v", err)
+	}
+	ledger.balance(1, 11, "L2").quantity = 3
+
+	if err := reservePlan(tx, plan, orderRef(1)); !errors.Is(err, errInsufficientStock) {
+		t.Fatalf("reservePlan() error = %v, want %v", err, errInsufficientStock)
+	}
+	if held := ledger.held(1, models.ReservationActive); len(held) != 0 {
+		t.Errorf("held = %v, want nothing", held)
+	}
+}
+
+func TestCloseReservations(t *testing.T) {
+	tests := []struct {
+		name          string
+		consume       bool
+		frozen        []int
+		wantErr       error
+		wantStatus    string
+		wantMovements []string
+		wantStock     []string
+	}{
+		{
+			name: "consume books the held stock out", consume: true,
+			wantStatus:    models.ReservationConsumed,
+			wantMovements: []string{"OUT 4 11/L2", "OUT 2 10/L1"},
+			wantStock:     []string{"10:3/2", "11:0/0", "12:10/0"},
+		},
+		{
+			name: "consume refuses stock under count", consume: true, frozen: []int{10},
+			wantErr: errStockFrozen,
+		},
+		{
+			name:       "release gives the stock back",
+			wantStatus: models.ReservationReleased,
+			wantStock:  []string{"10:5/2", "11:4/0", "12:10/0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newFakeLedger(models.AllocationFEFO)
+			tx := beginTestTx(t, ledger.handle)
+			for _, r := range []struct{ ref, quantity int }{{1, 6}, {2, 2}} {
+				if _, err := reserveStock(tx, 1, r.quantity, allocationScope{}, orderRef(r.ref)); err != nil {
+					t.Fatalf("reserveStock() for order %d error = %v", r.ref, err)
+				}
+			}
+			for _, id := range tt.frozen {
+				ledger.frozen[id] = true
+			}
+
+			var err error
+			if tt.consume {
+				var held []reservedStock
+				held, err = consumeReservations(tx, "order", 1, stockMovement{Reference: "SO-1", UserID: 7})
+				if err == nil && len(held) != 2 {
+					t.Errorf("consumeReservations() returned %d reservations, want 2", len(held))
+				}
+			} else {
+				err = releaseReservations(tx, "order", 1)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got := ledger.held(1, tt.wantStatus); len(got) != 2 {
+				t.Errorf("%s reservations = %v, want both", tt.wantStatus, got)
+			}
+			if got := ledger.held(2, models.ReservationActive); !reflect.DeepEqual(got, []string{"10/L1:2"}) {
+				t.Errorf("other order holds %v, want [10/L1:2]", got)
+			}
+			if !reflect.DeepEqual(ledger.movements, tt.wantMovements) {
+				t.Errorf("movements = %v, want %v", ledger.movements, tt.wantMovements)
+			}
+			if got := ledger.stock(); !reflect.DeepEqual(got, tt.wantStock) {
+				t.Errorf("stock = %v, want %v", got, tt.wantStock)
+			}
+		})
+	}
+}
+
+func TestShrinkReservation(t *testing.T) {
+	tests := []struct {
+		name       string
+		quantity   int
+		wantStatus string
+		wantHeld   []string
+		wantStock  []string
+	}{
+		{
+			name: "partly", quantity: 1,
+			wantStatus: models.ReservationActive,
+			wantHeld:   []string{"11/L2:3"},
+			wantStock:  []string{"10:5/0", "11:4/3", "12:10/0"},
+		},
+		{
+			name: "all of it", quantity: 4,
+			wantStatus: models.ReservationReleased,
+			wantHeld:   []string{"11/L2:4"},
+			wantStock:  []string{"10:5/0", "11:4/0", "12:10/0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newFakeLedger(models.AllocationFEFO)
+			tx := beginTestTx(t, ledger.handle)
+			if _, err := reserveStock(tx, 1, 4, allocationScope{}, orderRef(1)); err != nil {
+				t.Fatalf("reserveStock() error = %v", err)
+			}
+
+			if err := shrinkReservation(tx, 1, tt.quantity); err != nil {
+				t.Fatalf("shrinkReservation() error = %v", err)
+			}
+			if got := ledger.held(1, tt.wantStatus); !reflect.DeepEqual(got, tt.wantHeld) {
+				t.Errorf("%s = %v, want %v", tt.wantStatus, got, tt.wantHeld)
+			}
+			if got := ledger.stock(); !reflect.DeepEqual(got, tt.wantStock) {
+				t.Errorf("stock = %v, want %v", got, tt.wantStock)
+			}
+		})
+	}
+}
+
+func TestApplyStockMovementConstraints(t *testing.T) {
+	tests := []struct {
+		name        string
+		movement    stockMovement
+		quarantined int
+		wantErr     error
+		wantStock   []string
+	}{
+		{
+			name:      "in creates a balance",
+			movement:  stockMovement{ProductID: 1, LocationID: 20, Type: models.MovementIn, Quantity: 3},
+			wantStock: []string{"10:5/0", "11:4/0", "12:10/0", "20:3/0"},
+		},
+		{
+			name:      "out draws the balance down",
+			movement:  stockMovement{ProductID: 1, LocationID: 10, Batch: "L1", Type: models.MovementOut, Quantity: 5},
+			wantStock: []string{"10:0/0", "11:4/0", "12:10/0"},
+		},
+		{
+			name:     "out below zero",
+			movement: stockMovement{ProductID: 1, LocationID: 10, Batch: "L1", Type: models.MovementOut, Quantity: 6},
+			wantErr:  errInsufficientStock,
+		},
+		{
+			name:        "out into quarantined stock",
+			movement:    stockMovement{ProductID: 1, LocationID: 10, Batch: "L1", Type: models.MovementOut, Quantity: 3},
+			quarantined: 3,
+			wantErr:     errInsufficientStock,
+		},
+		{
+			name:     "zero quantity",
+			movement: stockMovement{ProductID: 1, LocationID: 10, Type: models.MovementIn},
+			wantErr:  errInvalidMovement,
+		},
+		{
+			name:     "unknown type",
+			movement: stockMovement{ProductID: 1, LocationID: 10, Type: "MOVE", Quantity: 1},
+			wantErr:  errInvalidMovement,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newFakeLedger(models.AllocationFEFO)
+			ledger.balance(1, 10, "L1").quarantined = tt.quarantined
+			tx := beginTestTx(t, ledger.handle)
+
+			err := applyStockMovement(tx, tt.movement)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("applyStockMovement() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(ledger.movements) != 0 {
+					t.Errorf("movements = %v, want none", ledger.movements)
+				}
+				return
+			}
+			if got := ledger.stock(); !reflect.DeepEqual(got, tt.wantStock) {
+				t.Errorf("stock = %v, want %v", got, tt.wantStock)
+			}
+			if len(ledger.movements) != 1 {
+				t.Errorf("movements = %v, want one", ledger.movements)
+			}
+		})
+	}
+}
@@ -0,0 +1,655 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errReturnNotFound    = errors.New("return not found")
+	errReturnNotEditable = errors.New("return cannot change in its current status")
+	errInvalidReturn     = errors.New("invalid return")
+)
+
+const returnColumns = `r.id, r.document_number, r.return_type, r.issuing_id, r.dispatch_id, r.receipt_line_id,
+	COALESCE(i.document_number, 'DSP-' || LPAD(r.dispatch_id::TEXT, 6, '0'), gr.document_number, ''),
+	r.product_id, p.name, COALESCE(p.sku, ''), r.quantity, r.batch, r.customer_id, r.customer, r.supplier_id,
+	r.warehouse_id, r.reason, r.notes, r.status, r.location_id, COALESCE(l.code, ''), r.received_quantity,
+	r.decision_notes, r.decided_by, r.decided_at, r.received_by, r.received_at, r.completed_at, r.created_by,
+	r.created_at, r.updated_at`
+
+const returnFrom = ` FROM returns r
+	JOIN warehouse_product p ON r.product_id = p.id
+	LEFT JOIN issuing i ON r.issuing_id = i.id
+	LEFT JOIN goods_receipt_lines grl ON r.receipt_line_id = grl.id
+	LEFT JOIN goods_receipts gr ON grl.receipt_id = gr.id
+	LEFT JOIN locations l ON r.location_id = l.id`
+
+// returnSource is what the original document says about the goods.
+type returnSource struct {
+	productID   sql.NullInt64
+	quantity    int
+	batch       string
+	customerID  sql.NullInt64
+	customer    string
+	supplierID  sql.NullInt64
+	warehouseID sql.NullInt64
+	// The returns against the document so far, rejected and cancelled
+	// ones aside
+	returned int
+}
+
+// GetReturns lists returns, newest first, as the plain array the app
+// expects. status and return_type filter them.
+func (h *Handler) GetReturns(c *gin.Context) {
+	rows, err := h.DB.Query(`SELECT `+returnColumns+returnFrom+`
+		WHERE ($1::int IS NULL OR r.tenant_id = $1) AND ($2::int IS NULL OR r.warehouse_id = $2)
+		  AND ($3 = '' OR r.status = $3) AND ($4 = '' OR r.return_type = $4)
+		ORDER BY r.created_at DESC, r.id DESC`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("status"), strings.ToUpper(c.Query("return_type")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch returns"})
+		return
+	}
+	defer rows.Close()
+
+	returns := []models.Return{}
+	for rows.Next() {
+		r, err := scanReturn(rows)
+		if err != nil {
+			continue
+		}
+		returns = append(returns, *r)
+	}
+
+	c.JSON(http.StatusOK, returns)
+}
+
+func (h *Handler) GetReturn(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	r, err := scanReturn(h.DB.QueryRow(`SELECT `+returnColumns+returnFrom+`
+		WHERE r.id = $1 AND ($2::int IS NULL OR r.tenant_id = $2) AND ($3::int IS NULL OR r.warehouse_id = $3)`,
+		id, middleware.TenantID(c), middleware.WarehouseID(c)))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Return not found"})
+		return
+	}
+	if err == nil {
+		r.Dispositions, err = returnDispositions(h.DB, r.ID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch return"})
+		return
+	}
+
+	c.JSON(http.StatusOK, r)
+}
+
+// CreateReturn opens an RMA against the document the goods moved on. No
+// more can be returned against a document than it moved.
+func (h *Handler) CreateReturn(c *gin.Context) {
+	var req models.ReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ReturnType = strings.ToUpper(req.ReturnType)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	src, err := lockReturnSource(tx, c, req)
+	if err != nil {
+		writeReturnError(c, err)
+		return
+	}
+	if !src.productID.Valid {
+		writeReturnError(c, fmt.Errorf("%w: the original document has no known product", errInvalidReturn))
+		return
+	}
+	if req.Quantity > src.quantity-src.returned {
+		writeReturnError(c, fmt.Errorf("%w: only %d of %d can still be returned", errInvalidReturn,
+			src.quantity-src.returned, src.quantity))
+		return
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO returns (return_type, issuing_id, dispatch_id, receipt_line_id, product_id, quantity, batch,
+		                     customer_id, customer, supplier_id, warehouse_id, reason, notes, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING id`,
+		req.ReturnType, req.IssuingID, req.DispatchID, req.ReceiptLineID, src.productID, req.Quantity, src.batch,
+		src.customerID, src.customer, src.supplierID, src.warehouseID, req.Reason, req.Notes,
+		middleware.CurrentUserID(c), middleware.TenantID(c)).Scan(&id)
+	if err != nil {
+		writeReturnError(c, err)
+		return
+	}
+
+	h.commitReturn(c, tx, id, http.StatusCreated)
+}
+
+func (h *Handler) ApproveReturn(c *gin.Context) {
+	h.decideReturn(c, models.ReturnApproved)
+}
+
+func (h *Handler) RejectReturn(c *gin.Context) {
+	h.decideReturn(c, models.ReturnRejected)
+}
+
+func (h *Handler) decideReturn(c *gin.Context, to string) {
+	// The body is optional
+	var req models.ReturnDecisionRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	h.advanceReturn(c, []string{models.ReturnPending}, to, func(tx *sql.Tx, r *models.Return) error {
+		_, err := tx.Exec(`UPDATE returns SET decision_notes = $1, decided_by = $2, decided_at = NOW() WHERE id = $3`,
+			req.Notes, middleware.CurrentUserID(c), r.ID)
+		return err
+	})
+}
+
+// CancelReturn drops a return whose goods have not moved yet.
+func (h *Handler) CancelReturn(c *gin.Context) {
+	h.advanceReturn(c, []string{models.ReturnPending, models.ReturnApproved}, models.ReturnCancelled, func(*sql.Tx, *models.Return) error {
+		return nil
+	})
+}
+
+// ReceiveReturn books an approved customer return into a returns
+// location, where it waits for QC.
+func (h *Handler) ReceiveReturn(c *gin.Context) {
+	var req models.ReturnReceiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	warehouseID, err := h.returnsLocation(c, req.LocationID)
+	if err != nil {
+		writeReturnError(c, err)
+		return
+	}
+
+	h.advanceReturn(c, []string{models.ReturnApproved}, models.ReturnReceived, func(tx *sql.Tx, r *models.Return) error {
+		if r.ReturnType != models.ReturnCustomer {
+			return fmt.Errorf("%w: supplier returns are shipped, not received", errInvalidReturn)
+		}
+		quantity := r.Quantity
+		if req.Quantity != nil {
+			quantity = *req.Quantity
+		}
+		if quantity > r.Quantity {
+			return fmt.Errorf("%w: only %d were authorised", errInvalidReturn, r.Quantity)
+		}
+
+		if err := ensureStockNotFrozen(tx, r.ProductID, req.LocationID); err != nil {
+			return err
+		}
+		err := applyStockMovement(tx, stockMovement{
+			ProductID:     r.ProductID,
+			LocationID:    req.LocationID,
+			Type:          models.MovementIn,
+			Quantity:      quantity,
+			Reference:     r.DocumentNumber,
+			ReferenceType: models.RefReturn,
+			Batch:         r.Batch,
+			Notes:         r.Reason,
+			UserID:        middleware.CurrentUserID(c),
+			TenantID:      middleware.TenantID(c),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE returns SET location_id = $1, warehouse_id = $2, received_quantity = $3, received_by = $4, received_at = NOW()
+			WHERE id = $5`, req.LocationID, warehouseID, quantity, middleware.CurrentUserID(c), r.ID)
+		return err
+	})
+}
+
+// AddReturnDisposition records QC's decision on part of a received return
+// and moves the goods out of the returns location: back into stock,
+// into quarantine, back to the supplier or to scrap. The return is
+// completed once every received unit has a decision.
+func (h *Handler) AddReturnDisposition(c *gin.Context) {
+	var req models.ReturnDispositionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var targetWarehouseID *int
+	switch req.Disposition {
+	case models.DispositionRestock:
+		if req.LocationID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Restocked goods need a location_id"})
+			return
+		}
+		var err error
+		if targetWarehouseID, err = h.locationWarehouse(c, req.LocationID); err != nil {
+			writeReturnError(c, locationLookupError(err))
+			return
+		}
+	case models.DispositionQuarantine, models.DispositionReturnToSupplier, models.DispositionScrap:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "disposition must be restock, quarantine, scrap or return_to_supplier"})
+		return
+	}
+
+	h.advanceReturn(c, []string{models.ReturnReceived}, "", func(tx *sql.Tx, r *models.Return) error {
+		var decided int
+		err := tx.QueryRow(`SELECT COALESCE(SUM(quantity), 0) FROM return_dispositions WHERE return_id = $1`, r.ID).Scan(&decided)
+		if err != nil {
+			return err
+		}
+		if req.Quantity > r.ReceivedQuantity-decided {
+			return fmt.Errorf("%w: only %d are still waiting for a decision", errInvalidReturn, r.ReceivedQuantity-decided)
+		}
+
+		// Units that failed inspection are let out of their hold first
+		userID := middleware.CurrentUserID(c)
+		held, err := returnHeld(tx, r.ID, false)
+		if err != nil {
+			return err
+		}
+		if free := r.ReceivedQuantity - decided - held; req.Quantity > free {
+			if err := releaseReturnHolds(tx, r.ID, req.Quantity-free, userID); err != nil {
+				return err
+			}
+		}
+
+		out := stockMovement{
+			ProductID:     r.ProductID,
+			LocationID:    intValue(r.LocationID),
+			Type:          models.MovementOut,
+			Quantity:      req.Quantity,
+			Reference:     r.DocumentNumber,
+			ReferenceType: models.RefReturn,
+			Batch:         r.Batch,
+			Notes:         req.Notes,
+			UserID:        userID,
+			TenantID:      middleware.TenantID(c),
+		}
+		if req.Disposition == models.DispositionScrap {
+			out.ReasonCode = "SCRAPPED"
+		}
+		if err := ensureStockNotFrozen(tx, out.ProductID, out.LocationID); err != nil {
+			return err
+		}
+		if err := applyStockMovement(tx, out); err != nil {
+			return err
+		}
+
+		var locationID *int
+		switch req.Disposition {
+		case models.DispositionRestock:
+			if !sameWarehouse(r.WarehouseID, targetWarehouseID) {
+				return fmt.Errorf("%w: restock within the warehouse the goods were received in", errInvalidReturn)
+			}
+			if req.LocationID == out.LocationID {
+				return fmt.Errorf("%w: restock to a location outside the returns area", errInvalidReturn)
+			}
+			if err := ensureStockNotFrozen(tx, r.ProductID, req.LocationID); err != nil {
+				return err
+			}
+			in := out
+			in.LocationID, in.Type, in.ReasonCode = req.LocationID, models.MovementIn, ""
+			if err := applyStockMovement(tx, in); err != nil {
+				return err
+			}
+			locationID = &req.LocationID
+		case models.DispositionQuarantine, models.DispositionReturnToSupplier:
+			reason := req.Notes
+			if reason == "" {
+				reason = r.Reason
+			}
+			_, err := tx.Exec(`
+				INSERT INTO quarantine_stock (return_id, product_id, product_name, quantity, batch, supplier_id, supplier_name,
+				                              warehouse_id, disposition, reason, created_by, tenant_id)
+				VALUES ($1, $2, $3, $4, $5, $6, COALESCE((SELECT name FROM suppliers WHERE id = $6), ''), $7, $8, $9, $10, $11)`,
+				r.ID, r.ProductID, r.ProductName, req.Quantity, r.Batch, r.SupplierID, r.WarehouseID, req.Disposition,
+				reason, userID, middleware.TenantID(c))
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO return_dispositions (return_id, disposition, quantity, location_id, notes, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			r.ID, req.Disposition, req.Quantity, locationID, req.Notes, userID)
+		if err != nil {
+			return err
+		}
+
+		if decided+req.Quantity == r.ReceivedQuantity {
+			_, err = tx.Exec(`UPDATE returns SET status = $1, completed_at = NOW(), updated_at = NOW() WHERE id = $2`,
+				models.ReturnCompleted, r.ID)
+		}
+		return err
+	})
+}
+
+// ShipReturn sends an approved supplier return back from one location.
+func (h *Handler) ShipReturn(c *gin.Context) {
+	var req models.ReturnShipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	warehouseID, err := h.locationWarehouse(c, req.LocationID)
+	if err != nil {
+		writeReturnError(c, locationLookupError(err))
+		return
+	}
+
+	h.advanceReturn(c, []string{models.ReturnApproved}, models.ReturnCompleted, func(tx *sql.Tx, r *models.Return) error {
+		if r.ReturnType != models.ReturnSupplier {
+			return fmt.Errorf("%w: customer returns are received, not shipped", errInvalidReturn)
+		}
+
+		plan, err := planAllocation(tx, r.ProductID, r.Quantity, allocationScope{
+			WarehouseID: warehouseID,
+			LocationID:  req.LocationID,
+			Batch:       r.Batch,
+			Lock:        true,
+		})
+		if err != nil {
+			return err
+		}
+		if plan.Short > 0 {
+			return fmt.Errorf("%w: %d available at the location, %d to return", errInsufficientStock, plan.Allocated, r.Quantity)
+		}
+		err = postAllocation(tx, plan, stockMovement{
+			Reference:     r.DocumentNumber,
+			ReferenceType: models.RefReturn,
+			Notes:         r.Reason,
+			UserID:        middleware.CurrentUserID(c),
+			TenantID:      middleware.TenantID(c),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE returns SET location_id = $1, warehouse_id = $2, completed_at = NOW() WHERE id = $3`,
+			req.LocationID, warehouseID, r.ID)
+		return err
+	})
+}
+
+// advanceReturn moves a return from one of the statuses in from to status
+// to after running step. With to empty, step sets the status itself.
+func (h *Handler) advanceReturn(c *gin.Context, from []string, to string, step func(*sql.Tx, *models.Return) error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := lockReturn(tx, c, id)
+	if err != nil {
+		writeReturnError(c, err)
+		return
+	}
+	allowed := false
+	for _, status := range from {
+		allowed = allowed || r.Status == status
+	}
+	if !allowed {
+		writeReturnError(c, fmt.Errorf("%w: it is %s", errReturnNotEditable, r.Status))
+		return
+	}
+
+	if err := step(tx, r); err != nil {
+		writeReturnError(c, err)
+		return
+	}
+	if to != "" {
+		if _, err := tx.Exec(`UPDATE returns SET status = $1, updated_at = NOW() WHERE id = $2`, to, r.ID); err != nil {
+			writeReturnError(c, err)
+			return
+		}
+	}
+
+	h.commitReturn(c, tx, r.ID, http.StatusOK)
+}
+
+func (h *Handler) commitReturn(c *gin.Context, tx *sql.Tx, id, status int) {
+	r, err := scanReturn(tx.QueryRow(`SELECT `+returnColumns+returnFrom+` WHERE r.id = $1`, id))
+	if err == nil {
+		r.Dispositions, err = returnDispositions(tx, id)
+	}
+	if err != nil {
+		writeReturnError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(status, r)
+}
+
+// lockReturnSource loads and locks the document a new return is raised
+// against, so concurrent returns cannot exceed it.
+func lockReturnSource(tx *sql.Tx, c *gin.Context, req models.ReturnRequest) (*returnSource, error) {
+	var src returnSource
+	var err error
+	tenantID := middleware.TenantID(c)
+	switch {
+	case req.ReturnType == models.ReturnCustomer && req.IssuingID != nil && req.DispatchID == nil && req.ReceiptLineID == nil:
+		var docNumber string
+		err = tx.QueryRow(`
+			SELECT i.document_number, i.product_id, i.quantity, i.customer_id, COALESCE(cu.name, ''), i.warehouse_id
+			FROM issuing i
+			LEFT JOIN customers cu ON i.customer_id = cu.id
+			WHERE i.id = $1 AND ($2::int IS NULL OR i.tenant_id = $2)
+			FOR UPDATE OF i`, *req.IssuingID, tenantID).Scan(&docNumber, &src.productID, &src.quantity, &src.customerID,
+			&src.customer, &src.warehouseID)
+		if err == nil {
+			src.batch, err = sourceBatch(tx, docNumber, models.RefIssuing)
+		}
+		if err == nil {
+			err = tx.QueryRow(`SELECT COALESCE(SUM(quantity), 0) FROM returns WHERE issuing_id = $1 AND status NOT IN ($2, $3)`,
+				*req.IssuingID, models.ReturnRejected, models.ReturnCancelled).Scan(&src.returned)
+		}
+	case req.ReturnType == models.ReturnCustomer && req.DispatchID != nil && req.IssuingID == nil && req.ReceiptLineID == nil:
+		var status string
+		err = tx.QueryRow(`
+			SELECT product_id, quantity, COALESCE(customer, ''), warehouse_id, status
+			FROM dispatches
+			WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2)
+			FOR UPDATE`, *req.DispatchID, tenantID).Scan(&src.productID, &src.quantity, &src.customer, &src.warehouseID, &status)
+		if err == nil && status != models.DispatchShipped && status != models.DispatchDelivered {
+			return nil, fmt.Errorf("%w: dispatch %d has not shipped", errInvalidReturn, *req.DispatchID)
+		}
+		if err == nil {
+			src.batch, err = sourceBatch(tx, fmt.Sprintf("DSP-%06d", *req.DispatchID), models.RefDispatch)
+		}
+		if err == nil {
+			err = tx.QueryRow(`SELECT COALESCE(SUM(quantity), 0) FROM returns WHERE dispatch_id = $1 AND status NOT IN ($2, $3)`,
+				*req.DispatchID, models.ReturnRejected, models.ReturnCancelled).Scan(&src.returned)
+		}
+	case req.ReturnType == models.ReturnSupplier && req.ReceiptLineID != nil && req.IssuingID == nil && req.DispatchID == nil:
+		var qcStatus string
+		var posted bool
+		err = tx.QueryRow(`
+			SELECT l.product_id, l.quantity - COALESCE(l.rejected_quantity, 0), COALESCE(l.batch, ''), gr.supplier_id, gr.warehouse_id, l.qc_status,
+			       l.posted_at IS NOT NULL
+			FROM goods_receipt_lines l
+			JOIN goods_receipts gr ON l.receipt_id = gr.id
+			WHERE l.id = $1 AND ($2::int IS NULL OR gr.tenant_id = $2)
+			FOR UPDATE OF l`, *req.ReceiptLineID, tenantID).Scan(&src.productID, &src.quantity, &src.batch, &src.supplierID,
+			&src.warehouseID, &qcStatus, &posted)
+		if err == nil && (qcStatus != models.QCAccepted || !posted) {
+			return nil, fmt.Errorf("%w: only accepted receipt lines in stock can go back to the supplier", errInvalidReturn)
+		}
+		if err == nil {
+			err = tx.QueryRow(`SELECT COALESCE(SUM(quantity), 0) FROM returns WHERE receipt_line_id = $1 AND status NOT IN ($2, $3)`,
+				*req.ReceiptLineID, models.ReturnRejected, models.ReturnCancelled).Scan(&src.returned)
+		}
+	case req.ReturnType != models.ReturnCustomer && req.ReturnType != models.ReturnSupplier:
+		return nil, fmt.Errorf("%w: return_type must be CUSTOMER or SUPPLIER", errInvalidReturn)
+	default:
+		return nil, fmt.Errorf("%w: a customer return needs issuing_id or dispatch_id, a supplier return receipt_line_id", errInvalidReturn)
+	}
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: the original document was not found", errInvalidReturn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &src, nil
+}
+
+// sourceBatch returns the batch a document took its stock from, or ""
+// when it took several.
+func sourceBatch(tx *sql.Tx, reference, referenceType string) (string, error) {
+	var batches []string
+	rows, err := tx.Query(`
+		SELECT DISTINCT COALESCE(batch, '') FROM stock_movements
+		WHERE reference = $1 AND reference_type = $2 AND movement_type = $3`,
+		reference, referenceType, models.MovementOut)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var batch string
+		if err := rows.Scan(&batch); err != nil {
+			return "", err
+		}
+		batches = append(batches, batch)
+	}
+	if len(batches) != 1 {
+		return "", rows.Err()
+	}
+	return batches[0], rows.Err()
+}
+
+// returnsLocation checks that returned goods are received into a returns
+// area and returns its warehouse.
+func (h *Handler) returnsLocation(c *gin.Context, locationID int) (*int, error) {
+	warehouseID, err := h.locationWarehouse(c, locationID)
+	if err != nil {
+		return nil, locationLookupError(err)
+	}
+	var locationType string
+	if err := h.DB.QueryRow(`SELECT location_type FROM locations WHERE id = $1`, locationID).Scan(&locationType); err != nil {
+		return nil, err
+	}
+	if locationType != models.LocationReturns {
+		return nil, fmt.Errorf("%w: returned goods are received into a returns location", errInvalidReturn)
+	}
+	return warehouseID, nil
+}
+
+func lockReturn(tx *sql.Tx, c *gin.Context, id int) (*models.Return, error) {
+	r, err := scanReturn(tx.QueryRow(`SELECT `+returnColumns+returnFrom+`
+		WHERE r.id = $1 AND ($2::int IS NULL OR r.tenant_id = $2)
+		  AND ($3::int IS NULL OR r.warehouse_id IS NULL OR r.warehouse_id = $3)
+		FOR UPDATE OF r`, id, middleware.TenantID(c), middleware.PinnedWarehouseID(c)))
+	if err == sql.ErrNoRows {
+		return nil, errReturnNotFound
+	}
+	return r, err
+}
+
+func returnDispositions(q dbtx, returnID int) ([]models.ReturnDisposition, error) {
+	rows, err := q.Query(`
+		SELECT d.id, d.disposition, d.quantity, d.location_id, COALESCE(l.code, ''), d.notes, d.created_by, d.created_at
+		FROM return_dispositions d
+		LEFT JOIN locations l ON d.location_id = l.id
+		WHERE d.return_id = $1
+		ORDER BY d.id`, returnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dispositions []models.ReturnDisposition
+	for rows.Next() {
+		var d models.ReturnDisposition
+		var locationID, createdBy sql.NullInt64
+		err := rows.Scan(&d.ID, &d.Disposition, &d.Quantity, &locationID, &d.LocationCode, &d.Notes, &createdBy, &d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		d.LocationID = nullableInt(locationID)
+		d.CreatedBy = nullableInt(createdBy)
+		dispositions = append(dispositions, d)
+	}
+	return dispositions, rows.Err()
+}
+
+func scanReturn(row scanner) (*models.Return, error) {
+	var r models.Return
+	var issuingID, dispatchID, receiptLineID, customerID, supplierID, warehouseID, locationID sql.NullInt64
+	var decidedBy, receivedBy, createdBy sql.NullInt64
+	var decidedAt, receivedAt, completedAt sql.NullTime
+	err := row.Scan(&r.ID, &r.DocumentNumber, &r.ReturnType, &issuingID, &dispatchID, &receiptLineID, &r.SourceDocument,
+		&r.ProductID, &r.ProductName, &r.SKU, &r.Quantity, &r.Batch, &customerID, &r.Customer, &supplierID,
+		&warehouseID, &r.Reason, &r.Notes, &r.Status, &locationID, &r.LocationCode, &r.ReceivedQuantity,
+		&r.DecisionNotes, &decidedBy, &decidedAt, &receivedBy, &receivedAt, &completedAt, &createdBy,
+		&r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	r.IssuingID = nullableInt(issuingID)
+	r.DispatchID = nullableInt(dispatchID)
+	r.ReceiptLineID = nullableInt(receiptLineID)
+	r.CustomerID = nullableInt(customerID)
+	r.SupplierID = nullableInt(supplierID)
+	r.WarehouseID = nullableInt(warehouseID)
+	r.LocationID = nullableInt(locationID)
+	r.DecidedBy = nullableInt(decidedBy)
+	r.ReceivedBy = nullableInt(receivedBy)
+	r.CreatedBy = nullableInt(createdBy)
+	r.DecidedAt = nullableTime(decidedAt)
+	r.ReceivedAt = nullableTime(receivedAt)
+	r.CompletedAt = nullableTime(completedAt)
+	return &r, nil
+}
+
+func writeReturnError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errReturnNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errReturnNotEditable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidReturn):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		writeAllocationError(c, err)
+	}
+}
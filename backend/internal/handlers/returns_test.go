@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeReturn is return 1 of tenant 3 for 4 units of lot L2 of product 1.
+type fakeReturn struct {
+	returnType string
+	status     string
+	received   int
+	decided    int
+}
+
+// newFakeReturn serves r on top of the warehouse of newFakeLedger, with
+// what was received of it stored in returns location 30 of warehouse 1.
+// Location 40 is in warehouse 2.
+func newFakeReturn(r *fakeReturn) *fakeLedger {
+	tenant := 3
+	ledger := newFakeLedger(models.AllocationFEFO)
+	var locationID driver.Value
+	if r.received > 0 {
+		locationID = int64(30)
+		ledger.balances = append(ledger.balances, &fakeBalance{productID: 1, locationID: 30, code: "R-01", batch: "L2",
+			receivedAt: *day("2026-05-01"), quantity: r.received})
+	}
+	row := func() *dbtest.Result {
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(1), "RET-1", r.returnType, nil, nil, nil, "", int64(1), "Gula", "GL-1",
+			int64(4), "L2", nil, "Toko Maju", nil, int64(1), "damaged", "", r.status, locationID, "", int64(r.received),
+			"", nil, nil, nil, nil, nil, nil, time.Now(), time.Now()}}}
+	}
+
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[0]) != 1 || !inScope(st.Args[1], &tenant) || st.Args[2] != nil && argInt(st.Args[2]) != 1 {
+			return nil, nil
+		}
+		return row(), nil
+	}, "FROM returns r", "FOR UPDATE OF r")
+	ledger.on(func(dbtest.Statement) (*dbtest.Result, error) { return row(), nil }, "FROM returns r", "WHERE r.id = $1")
+	ledger.on(rows(), "FROM return_dispositions d")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		r.status = st.Args[0].(string)
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE returns SET status")
+	ledger.on(affected(1), "UPDATE returns SET")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		warehouseID := int64(1)
+		if argInt(st.Args[0]) == 40 {
+			warehouseID = 2
+		}
+		return &dbtest.Result{Rows: [][]driver.Value{{warehouseID}}}, nil
+	}, "SELECT warehouse_id FROM locations")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[0]) == 30 {
+			return &dbtest.Result{Rows: [][]driver.Value{{models.LocationReturns}}}, nil
+		}
+		return &dbtest.Result{Rows: [][]driver.Value{{models.LocationBin}}}, nil
+	}, "SELECT location_type FROM locations")
+	ledger.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(r.decided)}}}, nil
+	}, "FROM return_dispositions WHERE return_id = $1")
+	ledger.on(rows([]driver.Value{int64(0)}), "FROM quarantine_holds h", "SUM(h.quantity)")
+	ledger.on(affected(1), "INSERT INTO quarantine_stock")
+	ledger.on(affected(1), "INSERT INTO return_dispositions")
+	return ledger
+}
+
+func TestAdvanceReturn(t *testing.T) {
+	other := 4
+	staff := auth.Claims{UserID: 7}
+	customer := func(status string, received, decided int) *fakeReturn {
+		return &fakeReturn{returnType: models.ReturnCustomer, status: status, received: received, decided: decided}
+	}
+	tests := []struct {
+		name          string
+		claims        auth.Claims
+		action        string
+		body          string
+		ret           *fakeReturn
+		frozen        []int
+		wantCode      int
+		wantStatus    string
+		wantMovements []string
+	}{
+		{name: "approve", claims: staff, action: "approve", ret: customer(models.ReturnPending, 0, 0),
+			wantCode: http.StatusOK, wantStatus: models.ReturnApproved},
+		{name: "approve twice", claims: staff, action: "approve", ret: customer(models.ReturnApproved, 0, 0),
+			wantCode: http.StatusConflict},
+		{name: "reject", claims: staff, action: "reject", ret: customer(models.ReturnPending, 0, 0),
+			wantCode: http.StatusOK, wantStatus: models.ReturnRejected},
+		{name: "cancel", claims: staff, action: "cancel", ret: customer(models.ReturnApproved, 0, 0),
+			wantCode: http.StatusOK, wantStatus: models.ReturnCancelled},
+		{name: "cancel once received", claims: staff, action: "cancel", ret: customer(models.ReturnReceived, 4, 0),
+			wantCode: http.StatusConflict},
+		{name: "receive into the returns area", claims: staff, action: "receive", body: `{"location_id": 30}`,
+			ret: customer(models.ReturnApproved, 0, 0), wantCode: http.StatusOK, wantStatus: models.ReturnReceived,
+			wantMovements: []string{"IN 4 30/L2"}},
+		{name: "receive into a bin", claims: staff, action: "receive", body: `{"location_id": 10}`,
+			ret: customer(models.ReturnApproved, 0, 0), wantCode: http.StatusBadRequest},
+		{name: "receive more than authorised", claims: staff, action: "receive", body: `{"location_id": 30, "quantity": 5}`,
+			ret: customer(models.ReturnApproved, 0, 0), wantCode: http.StatusBadRequest},
+		{name: "receive a supplier return", claims: staff, action: "receive", body: `{"location_id": 30}`,
+			ret: &fakeReturn{returnType: models.ReturnSupplier, status: models.ReturnApproved}, wantCode: http.StatusBadRequest},
+		{name: "receive into a location under count", claims: staff, action: "receive", body: `{"location_id": 30}`,
+			ret: customer(models.ReturnApproved, 0, 0), frozen: []int{30}, wantCode: http.StatusConflict},
+		{name: "restock part", claims: staff, action: "dispositions", body: `{"disposition": "restock", "quantity": 3, "location_id": 10}`,
+			ret: customer(models.ReturnReceived, 4, 0), wantCode: http.StatusOK, wantStatus: models.ReturnReceived,
+			wantMovements: []string{"OUT 3 30/L2", "IN 3 10/L2"}},
+		{name: "scrapping the rest completes it", claims: staff, action: "dispositions", body: `{"disposition": "scrap", "quantity": 1}`,
+			ret: customer(models.ReturnReceived, 4, 3), wantCode: http.StatusOK, wantStatus: models.ReturnCompleted,
+			wantMovements: []string{"OUT 1 30/L2"}},
+		{name: "decide more than is waiting", claims: staff, action: "dispositions", body: `{"disposition": "scrap", "quantity": 2}`,
+			ret: customer(models.ReturnReceived, 4, 3), wantCode: http.StatusBadRequest},
+		{name: "restock into another warehouse", claims: staff, action: "dispositions", body: `{"disposition": "restock", "quantity": 1, "location_id": 40}`,
+			ret: customer(models.ReturnReceived, 4, 0), wantCode: http.StatusBadRequest},
+		{name: "decide before receiving", claims: staff, action: "dispositions", body: `{"disposition": "scrap", "quantity": 1}`,
+			ret: customer(models.ReturnApproved, 0, 0), wantCode: http.StatusConflict},
+		{name: "ship a supplier return", claims: staff, action: "ship", body: `{"location_id": 11}`,
+			ret: &fakeReturn{returnType: models.ReturnSupplier, status: models.ReturnApproved}, wantCode: http.StatusOK,
+			wantStatus: models.ReturnCompleted, wantMovements: []string{"OUT 4 11/L2"}},
+		{name: "ship from where the lot is not", claims: staff, action: "ship", body: `{"location_id": 10}`,
+			ret: &fakeReturn{returnType: models.ReturnSupplier, status: models.ReturnApproved}, wantCode: http.StatusConflict},
+		{name: "other tenants' returns", claims: auth.Claims{UserID: 7, TenantID: &other}, action: "approve",
+			ret: customer(models.ReturnPending, 0, 0), wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newFakeReturn(tt.ret)
+			for _, id := range tt.frozen {
+				ledger.frozen[id] = true
+			}
+			h := &Handler{DB: ledger.open(t)}
+			handler := map[string]gin.HandlerFunc{"approve": h.ApproveReturn, "reject": h.RejectReturn, "cancel": h.CancelReturn,
+				"receive": h.ReceiveReturn, "dispositions": h.AddReturnDisposition, "ship": h.ShipReturn}[tt.action]
+
+			w := serveAs(&tt.claims, "/returns/:id/"+tt.action, handler, jsonRequest(http.MethodPost, "/returns/1/"+tt.action, tt.body))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if ledger.committed != (tt.wantCode == http.StatusOK) {
+				t.Errorf("committed = %v on a %d", ledger.committed, w.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if tt.ret.status != tt.wantStatus {
+				t.Errorf("return is %s, want %s", tt.ret.status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(ledger.movements, tt.wantMovements) {
+				t.Errorf("movements = %v, want %v", ledger.movements, tt.wantMovements)
+			}
+		})
+	}
+}
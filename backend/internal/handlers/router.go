@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"database/sql"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"github.com/gin-gonic/gin"
+	"wms-backend/internal/auth"
 	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
 )
 
 func SetupRoutes(h *Handler) *gin.Engine {
@@ -33,54 +39,220 @@ func SetupRoutes(h *Handler) *gin.Engine {
 		// Auth routes
 		api.POST("/auth/login", h.LoginGin)
 		api.POST("/auth/register", h.RegisterGin)
-		
-		// Goods Receipt routes
-		api.POST("/penerimaan", h.CreatePenerimaan)
-		api.GET("/penerimaan", h.GetPenerimaan)
-		api.POST("/penerimaan/:id/detail", h.AddDetailPenerimaan)
-		api.GET("/penerimaan/:id/detail", h.GetDetailPenerimaan)
-		api.POST("/detail/:detailId/pemeriksaan", h.CreatePemeriksaanKualitas)
-		api.PUT("/penerimaan/:id/complete", h.CompletePenerimaan)
-		
-		// Inventory Management routes
-		api.GET("/inventory", h.GetInventoryData)
-		api.POST("/inventory", h.CreateInventoryItem)
-		api.GET("/stock-opnames", h.GetStockOpnames)
-		api.POST("/stock-opnames", h.CreateStockOpname)
-		api.GET("/stock-movements", h.GetStockMovements)
-		api.POST("/stock-movements", h.CreateStockMovement)
-		api.GET("/receptions", h.GetReceptions)
-		api.POST("/receptions", h.CreateReception)
-		api.PUT("/receptions/:id/status", h.UpdateReceptionStatus)
-		api.GET("/dispatches", h.GetDispatches)
-		api.POST("/dispatches", h.CreateDispatch)
-		api.GET("/returns", h.GetReturns)
-		api.POST("/returns", h.CreateReturn)
-		api.GET("/quality-checks", h.GetQualityChecksSimple)
-		api.POST("/quality-checks", h.CreateQualityCheckRecord)
-		api.GET("/inventory-monitoring", h.GetInventoryMonitoring)
-		
-		// Transaction routes
-		api.POST("/receiving", h.CreateReceiving)
-		api.GET("/receiving", h.GetReceivings)
-		api.POST("/issuing", h.CreateIssuing)
-		api.GET("/issuing", h.GetIssuings)
-		
-		// Master data routes
-		api.GET("/suppliers", h.GetSuppliers)
-		api.GET("/customers", h.GetCustomers)
-		api.GET("/units", h.GetUnits)
-		api.GET("/locations", h.GetLocations)
+		api.POST("/auth/refresh", h.RefreshTokenGin)
+		api.POST("/tenant/login", h.TenantLoginGin)
+		api.POST("/tenant/register", h.TenantRegisterGin)
+
+		// Signed attachment links, opened without a token
+		api.GET("/files/attachments/:id/:variant", h.DownloadAttachment)
 		
 		// Protected routes
 		protected := api.Group("/")
-		protected.Use(middleware.AuthGin())
+		protected.Use(middleware.AuthGin(h.Tokens), middleware.Tenant(), middleware.Warehouse())
 		{
-			protected.GET("/users", h.GetUsersGin)
-			protected.POST("/users", h.CreateUserGin)
-			protected.GET("/products", h.GetProductsGin)
-			protected.POST("/products", h.CreateProductGin)
-			protected.GET("/categories", h.GetCategoriesGin)
+			canView := middleware.RequirePermission(auth.PermViewInventory)
+			canReceive := middleware.RequirePermission(auth.PermReceive)
+			canQC := middleware.RequirePermission(auth.PermQualityCheck)
+			canIssue := middleware.RequirePermission(auth.PermIssue)
+			canPick := middleware.RequirePermission(auth.PermPick)
+			canAdjust := middleware.RequirePermission(auth.PermAdjustInventory)
+			canCount := middleware.RequirePermission(auth.PermCountStock)
+			canApproveCount := middleware.RequirePermission(auth.PermApproveCount)
+			canApproveReturn := middleware.RequirePermission(auth.PermApproveReturn)
+			canViewMaster := middleware.RequirePermission(auth.PermViewMasterData)
+			// Locations are bins shared by every tenant
+			staffOnly := middleware.RequireStaff()
+
+			canManageUsers := middleware.RequirePermission(auth.PermManageUsers)
+
+			// User management
+			protected.GET("/users", canManageUsers, h.GetUsersGin)
+			protected.POST("/users", canManageUsers, h.CreateUserGin)
+			protected.PUT("/users/:id", canManageUsers, h.UpdateUserGin)
+			protected.DELETE("/users/:id", canManageUsers, h.DeleteUserGin)
+			protected.GET("/roles", canManageUsers, h.GetRolesGin)
+
+			// Super admin routes
+			superadmin := protected.Group("/superadmin")
+			superadmin.Use(middleware.RequireSuperuser())
+			{
+				superadmin.POST("/warehouse-admins", h.CreateWarehouseAdmin)
+				superadmin.POST("/tenant-admins", h.CreateTenantAdmin)
+				superadmin.GET("/admins", h.GetAllAdmins)
+				superadmin.PUT("/admins/:id", h.UpdateAdmin)
+				superadmin.DELETE("/admins/:id", h.DeleteAdmin)
+			}
+
+			// Tenant request routes
+			protected.GET("/inbound-requests", canView, h.GetInboundRequests)
+			protected.POST("/inbound-requests", canReceive, h.CreateInboundRequest)
+			protected.GET("/outbound-requests", canView, h.GetOutboundRequests)
+			protected.POST("/outbound-requests", canIssue, h.CreateOutboundRequest)
+			protected.PUT("/outbound-requests/:id/confirm", canIssue, h.ConfirmOutboundRequest)
+			protected.PUT("/outbound-requests/:id/ship", canIssue, h.ShipOutboundRequest)
+			protected.PUT("/outbound-requests/:id/cancel", canIssue, h.CancelOutboundRequest)
+			protected.GET("/orders", canView, h.GetOrders)
+			protected.POST("/orders", canIssue, h.CreateOrder)
+			protected.GET("/orders/:id", canView, h.GetOrder)
+			protected.PUT("/orders/:id/allocate", canIssue, h.AllocateOrder)
+			protected.PUT("/orders/:id/pick", canIssue, h.PickOrder)
+			protected.PUT("/orders/:id/pack", canIssue, h.PackOrder)
+			protected.PUT("/orders/:id/ship", canIssue, h.ShipOrder)
+			protected.PUT("/orders/:id/deliver", canIssue, h.DeliverOrder)
+			protected.PUT("/orders/:id/cancel", canIssue, h.CancelOrder)
+			protected.GET("/pick-lists", canView, h.GetPickLists)
+			protected.POST("/pick-lists", canIssue, h.CreatePickLists)
+			protected.GET("/pick-lists/:id", canView, h.GetPickList)
+			protected.PUT("/pick-lists/:id/assign", canIssue, h.AssignPickList)
+			protected.PUT("/pick-lists/:id/cancel", canIssue, h.CancelPickList)
+			protected.PUT("/pick-lists/:id/lines/:lineId/confirm", canPick, h.ConfirmPickLine)
+			protected.PUT("/pick-lists/:id/lines/:lineId/short", canPick, h.ShortPickLine)
+
+			// Report routes
+			protected.GET("/reports/stock", middleware.RequirePermission(auth.PermViewReports), h.GetStockReport)
+			protected.GET("/reports/transactions", middleware.RequirePermission(auth.PermViewReports), h.GetTransactionReport)
+			protected.GET("/reports/qc-defects", middleware.RequirePermission(auth.PermViewReports), h.GetQCDefectRates)
+
+			// Goods Receipt routes
+			protected.GET("/goods-receipts", canView, h.GetGoodsReceipts)
+			protected.POST("/goods-receipts", canReceive, h.CreateGoodsReceipt)
+			protected.GET("/goods-receipts/:id", canView, h.GetGoodsReceipt)
+			protected.POST("/goods-receipts/:id/lines", canReceive, h.AddGoodsReceiptLine)
+			protected.PUT("/goods-receipts/:id/status", canReceive, h.UpdateGoodsReceiptStatus)
+			protected.GET("/goods-receipts/:id/history", canView, h.GetGoodsReceiptHistory)
+			protected.PUT("/goods-receipts/:id/lines/:lineId/qc", canQC, h.UpdateGoodsReceiptLineQC)
+			protected.PUT("/goods-receipts/:id/lines/:lineId/putaway", canReceive, h.UpdateGoodsReceiptLinePutaway)
+			protected.PUT("/goods-receipts/:id/lines/:lineId/product", canReceive, h.UpdateGoodsReceiptLineProduct)
+			protected.GET("/quarantine-stock", canView, h.GetQuarantineStock)
+
+			// Legacy penerimaan routes, backed by goods receipts
+			protected.POST("/penerimaan", canReceive, h.CreatePenerimaan)
+			protected.GET("/penerimaan", canView, h.GetPenerimaan)
+			protected.POST("/penerimaan/:id/detail", canReceive, h.AddDetailPenerimaan)
+			protected.GET("/penerimaan/:id/detail", canView, h.GetDetailPenerimaan)
+			protected.POST("/detail/:detailId/pemeriksaan", canQC, h.CreatePemeriksaanKualitas)
+			protected.PUT("/penerimaan/:id/complete", canReceive, h.CompletePenerimaan)
+
+			// Inventory Management routes
+			protected.GET("/inventory", canView, h.GetInventoryData)
+			protected.POST("/inventory", canAdjust, h.CreateInventoryItem)
+			protected.GET("/inventory/lots", canView, h.GetInventoryLots)
+			protected.GET("/inventory/expiring", canView, h.GetExpiringStock)
+			protected.GET("/lots/trace", canView, h.GetLotTrace)
+			protected.GET("/stock-opnames", canView, h.GetStockOpnames)
+			protected.POST("/stock-opnames", canAdjust, h.CreateStockOpname)
+			protected.GET("/stock-opnames/:id", canView, h.GetStockOpname)
+			protected.POST("/stock-opnames/:id/counts", canCount, h.RecordStockCount)
+			protected.PUT("/stock-opnames/:id/submit", canCount, h.SubmitStockOpname)
+			protected.PUT("/stock-opnames/:id/approve", canApproveCount, h.ApproveStockOpname)
+			protected.PUT("/stock-opnames/:id/reject", canApproveCount, h.RejectStockOpname)
+			protected.PUT("/stock-opnames/:id/cancel", canAdjust, h.CancelStockOpname)
+			protected.GET("/stock-movements", canView, h.GetStockMovements)
+			protected.POST("/stock-movements", canAdjust, h.CreateStockMovement)
+			protected.GET("/stock-movements/reason-codes", canView, h.GetStockReasonCodes)
+			protected.GET("/stock-movements/reconciliation", canView, h.GetStockReconciliation)
+			protected.GET("/allocation/plan", canView, h.GetAllocationPlan)
+			protected.GET("/reservations", canView, h.GetStockReservations)
+			protected.GET("/putaway/suggestions", canView, h.GetPutawaySuggestions)
+			protected.GET("/putaway-tasks", canView, h.GetPutawayTasks)
+			protected.POST("/putaway-tasks", canReceive, h.CreatePutawayTask)
+			protected.GET("/putaway-tasks/:id", canView, h.GetPutawayTask)
+			protected.PUT("/putaway-tasks/:id/assign", canReceive, h.AssignPutawayTask)
+			protected.PUT("/putaway-tasks/:id/confirm", canReceive, h.ConfirmPutawayTask)
+			protected.PUT("/putaway-tasks/:id/cancel", canReceive, h.CancelPutawayTask)
+			protected.GET("/transfers", canView, h.GetTransfers)
+			protected.POST("/transfers", canAdjust, h.CreateTransfer)
+			protected.GET("/transfers/in-transit", canView, h.GetInTransitStock)
+			protected.GET("/transfers/:id", canView, h.GetTransfer)
+			protected.PUT("/transfers/:id/send", canAdjust, h.SendTransfer)
+			protected.PUT("/transfers/:id/receive", canAdjust, h.ReceiveTransfer)
+			protected.PUT("/transfers/:id/cancel", canAdjust, h.CancelTransfer)
+			protected.GET("/receptions", canView, h.GetReceptions)
+			protected.POST("/receptions", canReceive, h.CreateReception)
+			protected.PUT("/receptions/:id/status", canReceive, h.UpdateReceptionStatus)
+			protected.GET("/receptions/:id/history", canView, h.GetGoodsReceiptHistory)
+			protected.GET("/dispatches", canView, h.GetDispatches)
+			protected.POST("/dispatches", canIssue, h.CreateDispatch)
+			protected.PUT("/dispatches/:id/pick", canIssue, h.PickDispatch)
+			protected.PUT("/dispatches/:id/cancel", canIssue, h.CancelDispatch)
+			protected.GET("/cartons", canView, h.GetCartons)
+			protected.POST("/cartons", canIssue, h.CreateCarton)
+			protected.GET("/cartons/:id", canView, h.GetCarton)
+			protected.DELETE("/cartons/:id", canIssue, h.DeleteCarton)
+			protected.GET("/shipments", canView, h.GetShipments)
+			protected.POST("/shipments", canIssue, h.CreateShipment)
+			protected.GET("/shipments/:id", canView, h.GetShipment)
+			protected.PUT("/shipments/:id", canIssue, h.UpdateShipment)
+			protected.PUT("/shipments/:id/ship", canIssue, h.ShipShipment)
+			protected.PUT("/shipments/:id/deliver", canIssue, h.DeliverShipment)
+			protected.PUT("/shipments/:id/cancel", canIssue, h.CancelShipment)
+			protected.GET("/returns", canView, h.GetReturns)
+			protected.POST("/returns", canReceive, h.CreateReturn)
+			protected.GET("/returns/:id", canView, h.GetReturn)
+			protected.PUT("/returns/:id/approve", canApproveReturn, h.ApproveReturn)
+			protected.PUT("/returns/:id/reject", canApproveReturn, h.RejectReturn)
+			protected.PUT("/returns/:id/cancel", canReceive, h.CancelReturn)
+			protected.PUT("/returns/:id/receive", canReceive, h.ReceiveReturn)
+			protected.POST("/returns/:id/dispositions", canQC, h.AddReturnDisposition)
+			protected.PUT("/returns/:id/ship", canIssue, h.ShipReturn)
+			protected.GET("/quality-checks", canView, h.GetQualityChecksSimple)
+			protected.POST("/quality-checks", canQC, h.CreateQualityCheckRecord)
+			protected.GET("/qc-inspections", canView, h.GetQCInspections)
+			protected.POST("/qc-inspections", canQC, h.CreateQCInspection)
+			protected.GET("/qc-inspections/:id", canView, h.GetQCInspection)
+			protected.PUT("/qc-inspections/:id/result", canQC, h.RecordQCInspectionResult)
+			protected.PUT("/qc-inspections/:id/cancel", canQC, h.CancelQCInspection)
+			protected.GET("/quarantine-holds", canView, h.GetQuarantineHolds)
+			protected.PUT("/quarantine-holds/:id/release", canQC, h.ReleaseQuarantineHold)
+			protected.PUT("/quarantine-holds/:id/scrap", canQC, h.ScrapQuarantineHold)
+			protected.GET("/qc-templates", canViewMaster, h.GetQCTemplates)
+			protected.POST("/qc-templates", middleware.RequirePermission(auth.PermManageMasterData), h.CreateQCTemplate)
+			protected.GET("/qc-templates/:id", canViewMaster, h.GetQCTemplate)
+			protected.PUT("/qc-templates/:id", middleware.RequirePermission(auth.PermManageMasterData), h.UpdateQCTemplate)
+			protected.DELETE("/qc-templates/:id", middleware.RequirePermission(auth.PermManageMasterData), h.DeleteQCTemplate)
+			protected.GET("/products/:id/qc-template", canViewMaster, h.GetProductQCTemplate)
+			protected.GET("/qc-sampling-plans", canViewMaster, h.GetQCSamplingPlans)
+			protected.POST("/qc-sampling-plans", middleware.RequirePermission(auth.PermManageMasterData), h.CreateQCSamplingPlan)
+			protected.PUT("/qc-sampling-plans/:id", middleware.RequirePermission(auth.PermManageMasterData), h.UpdateQCSamplingPlan)
+			protected.GET("/qc-sampling-plans/:id/sample", canViewMaster, h.GetQCSample)
+			protected.GET("/inventory-monitoring", canView, h.GetInventoryMonitoring)
+
+			// Attachment routes; changing one also needs the permission of
+			// the document it belongs to
+			protected.GET("/attachments", canView, h.GetAttachments)
+			protected.POST("/attachments", canView, h.UploadAttachment)
+			protected.GET("/attachments/:id", canView, h.GetAttachment)
+			protected.DELETE("/attachments/:id", canView, h.DeleteAttachment)
+
+			// Transaction routes
+			protected.POST("/receiving", canReceive, h.CreateReceiving)
+			protected.GET("/receiving", canView, h.GetReceivings)
+			protected.POST("/issuing", canIssue, h.CreateIssuing)
+			protected.GET("/issuing", canView, h.GetIssuings)
+
+			// Master data routes
+			protected.GET("/products", canViewMaster, h.GetProductsGin)
+			protected.POST("/products", middleware.RequirePermission(auth.PermManageMasterData), h.CreateProductGin)
+			protected.PUT("/products/:id/allocation", middleware.RequirePermission(auth.PermManageMasterData), h.UpdateProductAllocation)
+			protected.GET("/categories", canViewMaster, h.GetCategoriesGin)
+			protected.POST("/categories", middleware.RequirePermission(auth.PermManageMasterData), h.CreateCategoryGin)
+			protected.GET("/suppliers", canViewMaster, h.GetSuppliers)
+			protected.GET("/customers", canViewMaster, h.GetCustomers)
+			protected.GET("/units", canViewMaster, h.GetUnits)
+			protected.GET("/locations", canViewMaster, h.GetLocations)
+			protected.GET("/locations/:id", canViewMaster, h.GetLocation)
+			protected.POST("/locations", staffOnly, middleware.RequirePermission(auth.PermManageMasterData), h.CreateLocation)
+			protected.POST("/locations/range", staffOnly, middleware.RequirePermission(auth.PermManageMasterData), h.CreateLocationRange)
+			protected.PUT("/locations/:id", staffOnly, middleware.RequirePermission(auth.PermManageMasterData), h.UpdateLocation)
+			protected.DELETE("/locations/:id", staffOnly, middleware.RequirePermission(auth.PermManageMasterData), h.DeleteLocation)
+
+			// Warehouse routes
+			protected.GET("/warehouses", canViewMaster, h.GetWarehouses)
+			protected.GET("/warehouses/:id", canViewMaster, h.GetWarehouse)
+			protected.GET("/warehouses/:id/stock", canView, h.GetWarehouseStock)
+			protected.POST("/warehouses", middleware.RequireSuperuser(), h.CreateWarehouse)
+			protected.PUT("/warehouses/:id", middleware.RequireSuperuser(), h.UpdateWarehouse)
+			protected.DELETE("/warehouses/:id", middleware.RequireSuperuser(), h.DeleteWarehouse)
 		}
 	}
 
@@ -89,11 +261,14 @@ func SetupRoutes(h *Handler) *gin.Engine {
 
 
 
+// CreateDispatch records a pending dispatch. Its stock is reserved when it
+// is picked and only leaves inventory when the shipment carrying it ships.
 func (h *Handler) CreateDispatch(c *gin.Context) {
 	var req struct {
+		ProductID   int    `json:"product_id"`
 		ProductName string `json:"product_name" binding:"required"`
 		Customer    string `json:"customer"`
-		Quantity    int    `json:"quantity" binding:"required"`
+		Quantity    int    `json:"quantity" binding:"required,min=1"`
 		Location    string `json:"location"`
 		Notes       string `json:"notes"`
 		Status      string `json:"status"`
@@ -104,19 +279,48 @@ func (h *Handler) CreateDispatch(c *gin.Context) {
 		return
 	}
 
-	// Use provided status or default to pending
-	status := req.Status
-	if status == "" {
-		status = "pending"
+	// Older clients still send a status; anything but pending has to go
+	// through the pick, pack and ship steps
+	if req.Status != "" && !strings.EqualFold(req.Status, models.DispatchPending) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New dispatches start as pending; pick, pack and ship them to move them on"})
+		return
+	}
+	tenantID := middleware.TenantID(c)
+
+	// The product is matched by name for clients that only send that.
+	// Staff not scoped to a tenant dispatch for the product's tenant.
+	var productID, productTenant sql.NullInt64
+	if req.ProductID != 0 {
+		if ok, err := h.belongsToTenant(c, "warehouse_product", req.ProductID); err != nil || !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown product"})
+			return
+		}
+		productID = sql.NullInt64{Int64: int64(req.ProductID), Valid: true}
+		if err := h.DB.QueryRow(`SELECT tenant_id FROM warehouse_product WHERE id = $1`, req.ProductID).Scan(&productTenant); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dispatch"})
+			return
+		}
+	} else {
+		err := h.DB.QueryRow(`SELECT id, tenant_id FROM warehouse_product WHERE name = $1 AND ($2::int IS NULL OR tenant_id = $2) ORDER BY id LIMIT 1`,
+			req.ProductName, tenantID).Scan(&productID, &productTenant)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dispatch"})
+			return
+		}
+	}
+	if tenantID == nil {
+		tenantID = nullableInt(productTenant)
 	}
 
 	// Insert dispatch record
 	var dispatchID int
 	err := h.DB.QueryRow(`
-		INSERT INTO dispatches (product_name, customer, quantity, location, notes, dispatch_date, status)
-		VALUES ($1, $2, $3, $4, $5, NOW(), $6)
+		INSERT INTO dispatches (product_name, customer, quantity, location, notes, dispatch_date, status, created_by, tenant_id,
+		                        product_id, warehouse_id)
+		VALUES ($1, $2, $3, $4, $5, NOW(), $6, $7, $8, $9, $10)
 		RETURNING id`,
-		req.ProductName, req.Customer, req.Quantity, req.Location, req.Notes, status,
+		req.ProductName, req.Customer, req.Quantity, req.Location, req.Notes, models.DispatchPending, middleware.CurrentUserID(c),
+		tenantID, productID, middleware.WarehouseID(c),
 	).Scan(&dispatchID)
 
 	if err != nil {
@@ -127,26 +331,12 @@ func (h *Handler) CreateDispatch(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"id": dispatchID,
 		"message": "Dispatch created successfully",
+		"status": models.DispatchPending,
 	})
 }
 
-// Stub handlers
-func (h *Handler) GetStockOpnames(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Stock opnames endpoint"})
-}
-
-func (h *Handler) CreateStockOpname(c *gin.Context) {
-	c.JSON(http.StatusCreated, gin.H{"message": "Stock opname created"})
-}
-
-func (h *Handler) GetStockMovements(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Stock movements endpoint"})
-}
-
-func (h *Handler) CreateStockMovement(c *gin.Context) {
-	c.JSON(http.StatusCreated, gin.H{"message": "Stock movement created"})
-}
-
+// CreateReception opens a single-line goods receipt as a pending
+// reception. UpdateReceptionStatus moves it on from there.
 func (h *Handler) CreateReception(c *gin.Context) {
 	var req struct {
 		ProductName string `json:"product_name" binding:"required"`
@@ -161,77 +351,259 @@ func (h *Handler) CreateReception(c *gin.Context) {
 		return
 	}
 
-	// Always use quality_check for receptions
-	status := "quality_check"
-
-	// Insert reception record
-	var receptionID int
-	err := h.DB.QueryRow(`
-		INSERT INTO receptions (product_name, category, quantity, location, notes, received_date, status)
-		VALUES ($1, $2, $3, $4, $5, NOW(), $6)
-		RETURNING id`,
-		req.ProductName, req.Category, req.Quantity, req.Location, req.Notes, status,
-	).Scan(&receptionID)
-
+	tx, err := h.DB.Begin()
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// The screen's "category" field has always carried the supplier
+	r, err := h.insertGoodsReceipt(tx, c, models.GoodsReceiptRequest{
+		SupplierName: req.Category,
+		Notes:        req.Notes,
+		Lines: []models.GoodsReceiptLineRequest{{
+			ProductName:  req.ProductName,
+			Quantity:     req.Quantity,
+			LocationName: req.Location,
+		}},
+	}, models.SourceReception)
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reception"})
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"id": receptionID,
+		"id":      r.ID,
 		"message": "Reception created successfully",
+		"status":  receptionStatus(r.Status),
 	})
 }
 
+// receptionStatusSQL maps goods receipt statuses back to the ones the
+// reception screen knows.
+const receptionStatusSQL = `CASE r.status
+		WHEN 'draft' THEN 'pending'
+		WHEN 'qc' THEN 'quality_check'
+		WHEN 'cancelled' THEN 'rejected'
+		ELSE r.status
+	END`
+
 func (h *Handler) GetReceptions(c *gin.Context) {
 	rows, err := h.DB.Query(`
-		SELECT id, product_name, category, quantity, location, notes, received_date, status
-		FROM receptions
-		ORDER BY received_date DESC`)
-	
+		SELECT r.id, l.product_name, r.supplier_name, l.quantity, l.location_name, r.notes,
+		       TO_CHAR(r.created_at, 'YYYY-MM-DD'), `+receptionStatusSQL+`
+		FROM goods_receipts r
+		JOIN LATERAL (SELECT * FROM goods_receipt_lines WHERE receipt_id = r.id ORDER BY id LIMIT 1) l ON TRUE
+		WHERE r.source = $1 AND ($2::int IS NULL OR r.tenant_id = $2)
+		ORDER BY r.created_at DESC`, models.SourceReception, middleware.TenantID(c))
+
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receptions"})
 		return
 	}
 	defer rows.Close()
-	
+
 	var receptions []map[string]interface{}
 	for rows.Next() {
 		var id int
 		var productName, category, location, notes, status string
 		var quantity int
 		var receivedDate string
-		
+
 		err := rows.Scan(&id, &productName, &category, &quantity, &location, &notes, &receivedDate, &status)
 		if err != nil {
 			continue
 		}
-		
+
 		receptions = append(receptions, map[string]interface{}{
-			"id": id,
+			"id":           id,
 			"product_name": productName,
-			"supplier": category,
-			"quantity": quantity,
-			"location": location,
-			"notes": notes,
-			"date": receivedDate[:10],
-			"status": status,
+			"supplier":     category,
+			"quantity":     quantity,
+			"location":     location,
+			"notes":        notes,
+			"date":         receivedDate,
+			"status":       status,
 		})
 	}
-	
+
 	c.JSON(http.StatusOK, receptions)
 }
 
+// receptionTransitions lists the reception statuses each status may move
+// to. Every reception status maps onto a goods receipt status. Put-away has
+// passed inspection, so it can no longer be rejected.
+var receptionTransitions = map[string][]string{
+	"pending":       {"received", "rejected"},
+	"received":      {"quality_check", "rejected"},
+	"quality_check": {"putaway", "completed", "rejected"},
+	"putaway":       {"completed"},
+}
+
+var receptionGoodsReceiptStatus = map[string]string{
+	"pending":       models.GoodsReceiptDraft,
+	"received":      models.GoodsReceiptReceived,
+	"quality_check": models.GoodsReceiptQC,
+	"putaway":       models.GoodsReceiptPutaway,
+	"completed":     models.GoodsReceiptCompleted,
+	"rejected":      models.GoodsReceiptCancelled,
+}
+
+func receptionStatus(goodsReceiptStatus string) string {
+	for status, grStatus := range receptionGoodsReceiptStatus {
+		if grStatus == goodsReceiptStatus {
+			return status
+		}
+	}
+	return goodsReceiptStatus
+}
+
+// UpdateReceptionStatus moves a reception one step along
+// pending → received → quality_check → putaway → completed, or rejects it
+// before put-away. Completing books the accepted quantity to stock, so
+// clients doing so must not also post it through /inventory.
 func (h *Handler) UpdateReceptionStatus(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Reception status updated"})
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req struct {
+		Status     string `json:"status" binding:"required"`
+		LocationID int    `json:"location_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to := strings.ToLower(req.Status)
+	target, ok := receptionGoodsReceiptStatus[to]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown reception status"})
+		return
+	}
+
+	userID := middleware.CurrentUserID(c)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := h.lockGoodsReceipt(tx, c, id)
+	if err == errReceiptNotFound || (err == nil && r.Source != models.SourceReception) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reception not found"})
+		return
+	}
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+
+	from := receptionStatus(r.Status)
+	allowed := false
+	for _, next := range receptionTransitions[from] {
+		allowed = allowed || next == to
+	}
+	if !allowed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot move reception from " + from + " to " + to})
+		return
+	}
+
+	switch target {
+	case models.GoodsReceiptCancelled:
+		err = transitionGoodsReceipt(tx, r, target, userID)
+	case models.GoodsReceiptCompleted:
+		if err = h.prepareReceptionPosting(tx, c, r, req.LocationID); err == nil {
+			err = advanceGoodsReceipt(tx, r, target, userID)
+		}
+	default:
+		err = advanceGoodsReceipt(tx, r, target, userID)
+	}
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reception status updated", "id": r.ID, "status": to})
+}
+
+// prepareReceptionPosting links reception lines, which only carry free
+// text, to a product by name and to a location. locationID wins; otherwise
+// the location is matched by code or name in the receipt's warehouse, and a
+// line without a match fails rather than landing somewhere else.
+func (h *Handler) prepareReceptionPosting(tx *sql.Tx, c *gin.Context, r *models.GoodsReceipt, locationID int) error {
+	if err := linkGoodsReceiptProducts(tx, r); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT id, location_name FROM goods_receipt_lines WHERE receipt_id = $1 AND location_id IS NULL`, r.ID)
+	if err != nil {
+		return err
+	}
+	lines := map[int]string{}
+	for rows.Next() {
+		var lineID int
+		var name string
+		if err := rows.Scan(&lineID, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		lines[lineID] = name
+	}
+	rows.Close()
+
+	warehouseID := r.WarehouseID
+	if warehouseID == nil {
+		warehouseID = middleware.WarehouseID(c)
+	}
+	for lineID, name := range lines {
+		target := locationID
+		if target == 0 {
+			var match sql.NullInt64
+			var matches int
+			err := tx.QueryRow(`
+				SELECT MIN(id), COUNT(*) FROM locations
+				WHERE ($1::int IS NULL OR warehouse_id = $1) AND (code = $2 OR LOWER(name) = LOWER($2))`,
+				warehouseID, name).Scan(&match, &matches)
+			if err != nil {
+				return err
+			}
+			switch {
+			case matches == 0:
+				return fmt.Errorf("%w: no location %q in the reception's warehouse, send location_id", errReceiptIncomplete, name)
+			case matches > 1:
+				return fmt.Errorf("%w: location %q is ambiguous, send location_id", errReceiptIncomplete, name)
+			}
+			target = int(match.Int64)
+		}
+		if err := h.setGoodsReceiptLineLocation(tx, c, r, lineID, target); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (h *Handler) GetDispatches(c *gin.Context) {
 	rows, err := h.DB.Query(`
 		SELECT id, product_name, customer, quantity, location, notes, dispatch_date, status
 		FROM dispatches
-		ORDER BY dispatch_date DESC`)
+		WHERE ($1::int IS NULL OR tenant_id = $1)
+		ORDER BY dispatch_date DESC`, middleware.TenantID(c))
 	
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dispatches"})
@@ -266,57 +638,54 @@ func (h *Handler) GetDispatches(c *gin.Context) {
 	c.JSON(http.StatusOK, dispatches)
 }
 
-func (h *Handler) GetReturns(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Returns endpoint"})
-}
-
-func (h *Handler) CreateReturn(c *gin.Context) {
-	c.JSON(http.StatusCreated, gin.H{"message": "Return created"})
-}
-
 func (h *Handler) GetQualityChecks(c *gin.Context) {
 	rows, err := h.DB.Query(`
-		SELECT qc.id, qc.reception_id, qc.product_name, qc.quantity, qc.status, 
+		SELECT qc.id, qc.reception_id, qc.product_name, qc.quantity, qc.status,
 		       COALESCE(qc.notes, '') as notes,
-		       COALESCE(r.category, '') as supplier, 
-		       COALESCE(r.location, '') as location, 
-		       TO_CHAR(r.received_date, 'YYYY-MM-DD') as date
+		       COALESCE(r.supplier_name, '') as supplier,
+		       COALESCE(l.location_name, '') as location,
+		       TO_CHAR(r.created_at, 'YYYY-MM-DD') as date
 		FROM quality_checks qc
-		LEFT JOIN receptions r ON qc.reception_id = r.id
-		ORDER BY qc.checked_at DESC`)
-	
+		JOIN goods_receipts r ON qc.reception_id = r.id
+		LEFT JOIN LATERAL (SELECT location_name FROM goods_receipt_lines WHERE receipt_id = r.id ORDER BY id LIMIT 1) l ON TRUE
+		WHERE ($1::int IS NULL OR qc.tenant_id = $1)
+		ORDER BY qc.checked_at DESC`, middleware.TenantID(c))
+
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quality checks"})
 		return
 	}
 	defer rows.Close()
-	
+
 	var qualityChecks []map[string]interface{}
 	for rows.Next() {
 		var id, receptionID, quantity int
 		var productName, status, notes, supplier, location, date string
-		
+
 		err := rows.Scan(&id, &receptionID, &productName, &quantity, &status, &notes, &supplier, &location, &date)
 		if err != nil {
 			continue
 		}
-		
+
 		qualityChecks = append(qualityChecks, map[string]interface{}{
-			"id": receptionID,
+			"id":           receptionID,
 			"product_name": productName,
-			"supplier": supplier,
-			"quantity": quantity,
-			"location": location,
-			"notes": notes,
-			"date": date,
-			"status": status,
-			"qc_id": id,
+			"supplier":     supplier,
+			"quantity":     quantity,
+			"location":     location,
+			"notes":        notes,
+			"date":         date,
+			"status":       status,
+			"qc_id":        id,
 		})
 	}
-	
+
 	c.JSON(http.StatusOK, qualityChecks)
 }
 
+// CreateQualityCheckRecord records a PASS/FAIL verdict on a reception. The
+// verdict lands on the receipt line; stock is only booked when the receipt
+// is completed.
 func (h *Handler) CreateQualityCheckRecord(c *gin.Context) {
 	var req struct {
 		ReceptionID int    `json:"reception_id" binding:"required"`
@@ -331,17 +700,58 @@ func (h *Handler) CreateQualityCheckRecord(c *gin.Context) {
 		return
 	}
 
+	var verdict string
+	switch strings.ToUpper(req.Status) {
+	case "PASS":
+		verdict = models.QCAccepted
+	case "FAIL":
+		verdict = models.QCRejected
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be PASS or FAIL"})
+		return
+	}
+
+	userID := middleware.CurrentUserID(c)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := h.lockGoodsReceipt(tx, c, req.ReceptionID)
+	if err == errReceiptNotFound || (err == nil && r.Source != models.SourceReception) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reception not found"})
+		return
+	}
+	if err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+
+	var lineID int
+	if err := tx.QueryRow(`SELECT id FROM goods_receipt_lines WHERE receipt_id = $1 ORDER BY id LIMIT 1`, r.ID).Scan(&lineID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reception has no lines"})
+		return
+	}
+	if err := setGoodsReceiptLineQC(tx, r, lineID, verdict, req.Notes, userID); err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+
 	// Insert or update quality check record
 	var qcID int
-	err := h.DB.QueryRow(`
-		INSERT INTO quality_checks (reception_id, product_name, quantity, status, notes, checked_at)
-		VALUES ($1, $2, $3, $4, $5, NOW())
+	err = tx.QueryRow(`
+		INSERT INTO quality_checks (reception_id, product_name, quantity, status, notes, created_by, tenant_id, checked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
 		ON CONFLICT (reception_id) DO UPDATE SET
 			status = EXCLUDED.status,
 			notes = EXCLUDED.notes,
+			created_by = EXCLUDED.created_by,
 			checked_at = NOW()
 		RETURNING id`,
-		req.ReceptionID, req.ProductName, req.Quantity, req.Status, req.Notes,
+		req.ReceptionID, req.ProductName, req.Quantity, req.Status, req.Notes, userID, r.TenantID,
 	).Scan(&qcID)
 
 	if err != nil {
@@ -349,8 +759,13 @@ func (h *Handler) CreateQualityCheckRecord(c *gin.Context) {
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
-		"id": qcID,
+		"id":      qcID,
 		"message": "Quality check record created successfully",
 	})
 }
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// publicRoutes are reachable without a token.
+var publicRoutes = map[string]bool{
+	"GET /api/health":                         true,
+	"POST /api/auth/login":                    true,
+	"POST /api/auth/register":                 true,
+	"POST /api/auth/refresh":                  true,
+	"POST /api/tenant/login":                  true,
+	"POST /api/tenant/register":               true,
+	"GET /api/files/attachments/:id/:variant": true,
+}
+
+// routeTarget fills the path parameters of a gin route pattern.
+func routeTarget(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			parts[i] = "1"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := auth.NewTokenManager("test-secret", time.Minute, time.Hour)
+	r := SetupRoutes(&Handler{Tokens: tokens})
+	_, refresh, err := tokens.GeneratePair(auth.Claims{UserID: 7, Roles: []string{auth.RoleAdmin}})
+	if err != nil {
+		t.Fatalf("GeneratePair() error = %v", err)
+	}
+
+	for _, route := range r.Routes() {
+		if publicRoutes[route.Method+" "+route.Path] {
+			continue
+		}
+		for name, header := range map[string]string{"no token": "", "refresh token": "Bearer " + refresh} {
+			req := httptest.NewRequest(route.Method, routeTarget(route.Path), nil)
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with %s: status = %d, want %d", route.Method, route.Path, name, w.Code, http.StatusUnauthorized)
+			}
+		}
+	}
+}
+
+func TestProtectedRoutesCheckPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := auth.NewTokenManager("test-secret", time.Minute, time.Hour)
+	r := SetupRoutes(&Handler{Tokens: tokens})
+	access, _, err := tokens.GeneratePair(auth.Claims{UserID: 7, Roles: []string{auth.RolePicker}})
+	if err != nil {
+		t.Fatalf("GeneratePair() error = %v", err)
+	}
+
+	for _, target := range []string{"/api/goods-receipts", "/api/stock-movements", "/api/users"} {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer "+access)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("POST %s as picker: status = %d, want %d", target, w.Code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestCreateQualityCheckRecord(t *testing.T) {
+	tenant, other := 5, 9
+	tests := []struct {
+		name       string
+		claims     auth.Claims
+		source     string
+		status     string
+		wantCode   int
+		wantTenant driver.Value
+	}{
+		{
+			name: "staff file it under the reception's tenant", claims: auth.Claims{UserID: 7},
+			source: models.SourceReception, status: models.GoodsReceiptQC,
+			wantCode: http.StatusCreated, wantTenant: int64(tenant),
+		},
+		{
+			name: "another tenant does not see the reception", claims: auth.Claims{UserID: 7, TenantID: &other},
+			source: models.SourceReception, status: models.GoodsReceiptQC,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name: "only receptions take these records", claims: auth.Claims{UserID: 7},
+			source: models.SourceGoodsReceipt, status: models.GoodsReceiptQC,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name: "completed receptions are closed", claims: auth.Claims{UserID: 7},
+			source: models.SourceReception, status: models.GoodsReceiptCompleted,
+			wantCode: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{}
+			onReceipt(db, &models.GoodsReceipt{ID: 1, Status: tt.status, Source: tt.source, TenantID: &tenant})
+			db.on(rows([]driver.Value{int64(11)}), "SELECT id FROM goods_receipt_lines")
+			db.on(affected(1), "UPDATE goods_receipt_lines SET qc_status")
+			var inserted []driver.Value
+			db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+				inserted = st.Args
+				return &dbtest.Result{Rows: [][]driver.Value{{int64(3)}}}, nil
+			}, "INSERT INTO quality_checks")
+			h := &Handler{DB: db.open(t)}
+
+			w := serveAs(&tt.claims, "/quality-checks", h.CreateQualityCheckRecord, jsonRequest(http.MethodPost, "/quality-checks",
+				`{"reception_id": 1, "product_name": "Gula", "quantity": 4, "status": "PASS"}`))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode != http.StatusCreated {
+				if inserted != nil || db.committed {
+					t.Errorf("a record was written on a %d", w.Code)
+				}
+				return
+			}
+			if inserted[6] != tt.wantTenant {
+				t.Errorf("quality check tenant = %v, want %v", inserted[6], tt.wantTenant)
+			}
+			if !db.committed {
+				t.Errorf("transaction was not committed")
+			}
+		})
+	}
+}
+
+func TestReceptionStatus(t *testing.T) {
+	tests := map[string]string{
+		models.GoodsReceiptDraft:     "pending",
+		models.GoodsReceiptQC:        "quality_check",
+		models.GoodsReceiptPutaway:   "putaway",
+		models.GoodsReceiptCancelled: "rejected",
+	}
+	for status, want := range tests {
+		if got := receptionStatus(status); got != want {
+			t.Errorf("receptionStatus(%s) = %s, want %s", status, got, want)
+		}
+	}
+}
+
+func TestUpdateReceptionStatus(t *testing.T) {
+	tenant, other := 3, 4
+	staff := auth.Claims{UserID: 7}
+	tests := []struct {
+		name        string
+		claims      auth.Claims
+		source      string
+		from        string
+		to          string
+		wantCode    int
+		wantHistory []string
+	}{
+		{name: "receive", claims: staff, from: models.GoodsReceiptDraft, to: "received",
+			wantCode: http.StatusOK, wantHistory: []string{"draft>received"}},
+		{name: "reject during QC", claims: staff, from: models.GoodsReceiptQC, to: "rejected",
+			wantCode: http.StatusOK, wantHistory: []string{"qc>cancelled"}},
+		{name: "put away", claims: staff, from: models.GoodsReceiptQC, to: "putaway",
+			wantCode: http.StatusOK, wantHistory: []string{"qc>putaway"}},
+		{name: "put-away stock cannot be rejected", claims: staff, from: models.GoodsReceiptPutaway, to: "rejected",
+			wantCode: http.StatusConflict},
+		{name: "no skipping QC", claims: staff, from: models.GoodsReceiptReceived, to: "completed",
+			wantCode: http.StatusConflict},
+		{name: "unknown status", claims: staff, from: models.GoodsReceiptDraft, to: "shipped",
+			wantCode: http.StatusBadRequest},
+		{name: "other tenants' receptions", claims: auth.Claims{UserID: 7, TenantID: &other}, from: models.GoodsReceiptDraft, to: "received",
+			wantCode: http.StatusNotFound},
+		{name: "goods receipts are not receptions", claims: staff, source: models.SourceGoodsReceipt, from: models.GoodsReceiptDraft, to: "received",
+			wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.source
+			if source == "" {
+				source = models.SourceReception
+			}
+			receipt := newFakeReceipt(1, 0)
+			onReceipt(receipt.fakeDB, &models.GoodsReceipt{ID: 1, Status: tt.from, Source: source, TenantID: &tenant})
+			h := &Handler{DB: receipt.open(t)}
+
+			w := serveAs(&tt.claims, "/receptions/:id/status", h.UpdateReceptionStatus,
+				jsonRequest(http.MethodPut, "/receptions/1/status", `{"status": "`+tt.to+`"}`))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if !reflect.DeepEqual(receipt.history, tt.wantHistory) {
+				t.Errorf("history = %v, want %v", receipt.history, tt.wantHistory)
+			}
+			if receipt.committed != (tt.wantCode == http.StatusOK) {
+				t.Errorf("committed = %v on a %d", receipt.committed, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errShipmentNotFound    = errors.New("shipment not found")
+	errShipmentNotEditable = errors.New("shipment cannot change in its current status")
+)
+
+const shipmentColumns = `id, shipment_number, warehouse_id, carrier, tracking_number, ship_date, status, notes, created_by,
+	shipped_at, delivered_at, created_at, updated_at`
+
+// GetShipments lists shipments, newest first. tracking_number finds one
+// by its carrier reference.
+func (h *Handler) GetShipments(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		SELECT `+shipmentColumns+` FROM shipments
+		WHERE ($1::int IS NULL OR tenant_id = $1) AND ($2::int IS NULL OR warehouse_id = $2)
+		  AND ($3 = '' OR status = $3) AND ($4 = '' OR tracking_number = $4)
+		ORDER BY created_at DESC, id DESC`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("status"), c.Query("tracking_number"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shipments"})
+		return
+	}
+	defer rows.Close()
+
+	var shipments []models.Shipment
+	for rows.Next() {
+		s, err := scanShipment(rows)
+		if err != nil {
+			continue
+		}
+		shipments = append(shipments, *s)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": shipments})
+}
+
+func (h *Handler) GetShipment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	s, err := scanShipment(h.DB.QueryRow(`
+		SELECT `+shipmentColumns+` FROM shipments
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2) AND ($3::int IS NULL OR warehouse_id = $3)`,
+		id, middleware.TenantID(c), middleware.WarehouseID(c)))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if err == nil {
+		s.Cartons, err = shipmentCartons(h.DB, s.ID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shipment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+// CreateShipment opens a shipment for a carrier with the given cartons.
+func (h *Handler) CreateShipment(c *gin.Context) {
+	var req models.ShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	shipDate, err := parseShipDate(req.ShipDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO shipments (warehouse_id, carrier, tracking_number, ship_date, notes, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		middleware.WarehouseID(c), req.Carrier, req.TrackingNumber, shipDate, req.Notes, middleware.CurrentUserID(c),
+		middleware.TenantID(c)).Scan(&id)
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+	if err := setShipmentCartons(tx, c, id, req.CartonIDs); err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+
+	h.commitShipment(c, tx, id, http.StatusCreated)
+}
+
+// UpdateShipment edits an open shipment; the cartons on it are replaced
+// by carton_ids.
+func (h *Handler) UpdateShipment(c *gin.Context) {
+	var req models.ShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	shipDate, err := parseShipDate(req.ShipDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.advanceShipment(c, models.ShipmentOpen, models.ShipmentOpen, func(tx *sql.Tx, s *models.Shipment) error {
+		_, err := tx.Exec(`
+			UPDATE shipments SET carrier = $1, tracking_number = $2, ship_date = $3, notes = $4
+			WHERE id = $5`, req.Carrier, req.TrackingNumber, shipDate, req.Notes, s.ID)
+		if err != nil {
+			return err
+		}
+		return setShipmentCartons(tx, c, s.ID, req.CartonIDs)
+	})
+}
+
+// ShipShipment hands the cartons to the carrier. Every dispatch in them
+// must be fully packed into this shipment; its reserved stock is taken
+// out of inventory now.
+func (h *Handler) ShipShipment(c *gin.Context) {
+	// The body is optional
+	var req models.ShipmentShipRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+	shipDate, err := parseShipDate(req.ShipDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.advanceShipment(c, models.ShipmentOpen, models.ShipmentShipped, func(tx *sql.Tx, s *models.Shipment) error {
+		dispatchIDs, err := shipmentDispatches(tx, s.ID)
+		if err != nil {
+			return err
+		}
+		if len(dispatchIDs) == 0 {
+			return fmt.Errorf("%w: it has no cartons", errShipmentNotEditable)
+		}
+
+		for _, id := range dispatchIDs {
+			d, err := lockDispatch(tx, c, id)
+			if err != nil {
+				return err
+			}
+			if d.status != models.DispatchPacked {
+				return fmt.Errorf("%w: dispatch %d is %s", errDispatchNotEditable, d.id, d.status)
+			}
+			var elsewhere bool
+			err = tx.QueryRow(`
+				SELECT EXISTS (SELECT 1 FROM carton_lines cl JOIN cartons ct ON cl.carton_id = ct.id
+				               WHERE cl.dispatch_id = $1 AND ct.shipment_id IS DISTINCT FROM $2)`, d.id, s.ID).Scan(&elsewhere)
+			if err != nil {
+				return err
+			}
+			if elsewhere {
+				return fmt.Errorf("%w: dispatch %d has cartons outside this shipment", errShipmentNotEditable, d.id)
+			}
+
+			held, err := consumeReservations(tx, models.RefDispatch, d.id, stockMovement{
+				Reference:     fmt.Sprintf("DSP-%06d", d.id),
+				ReferenceType: models.RefDispatch,
+				Notes:         d.customer,
+				UserID:        middleware.CurrentUserID(c),
+				TenantID:      d.tenantID,
+			})
+			if err != nil {
+				return err
+			}
+			if len(held) == 0 {
+				return fmt.Errorf("%w: no stock is reserved for dispatch %d", errDispatchNotEditable, d.id)
+			}
+			_, err = tx.Exec(`
+				UPDATE dispatches SET status = $1, shipped_at = NOW(), posted_at = NOW(), updated_at = NOW()
+				WHERE id = $2`, models.DispatchShipped, d.id)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(`
+			UPDATE shipments SET tracking_number = COALESCE(NULLIF($1, ''), tracking_number),
+			                     ship_date = COALESCE($2, ship_date, CURRENT_DATE), shipped_at = NOW()
+			WHERE id = $3`, req.TrackingNumber, shipDate, s.ID)
+		return err
+	})
+}
+
+// DeliverShipment records that the carrier delivered the shipment.
+func (h *Handler) DeliverShipment(c *gin.Context) {
+	h.advanceShipment(c, models.ShipmentShipped, models.ShipmentDelivered, func(tx *sql.Tx, s *models.Shipment) error {
+		_, err := tx.Exec(`
+			UPDATE dispatches SET status = $1, delivered_at = NOW(), updated_at = NOW()
+			WHERE id IN (SELECT cl.dispatch_id FROM carton_lines cl JOIN cartons ct ON cl.carton_id = ct.id
+			             WHERE ct.shipment_id = $2)`, models.DispatchDelivered, s.ID)
+		if err == nil {
+			_, err = tx.Exec(`UPDATE shipments SET delivered_at = NOW() WHERE id = $1`, s.ID)
+		}
+		return err
+	})
+}
+
+// CancelShipment drops an open shipment. Its cartons stay packed and can
+// go on another shipment.
+func (h *Handler) CancelShipment(c *gin.Context) {
+	h.advanceShipment(c, models.ShipmentOpen, models.ShipmentCancelled, func(tx *sql.Tx, s *models.Shipment) error {
+		return setShipmentCartons(tx, c, s.ID, nil)
+	})
+}
+
+// advanceShipment moves a shipment from status from to status to after
+// running step.
+func (h *Handler) advanceShipment(c *gin.Context, from, to string, step func(*sql.Tx, *models.Shipment) error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	s, err := lockShipment(tx, c, id)
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+	if s.Status != from {
+		writeShipmentError(c, fmt.Errorf("%w: it is %s", errShipmentNotEditable, s.Status))
+		return
+	}
+
+	if err := step(tx, s); err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+	if _, err := tx.Exec(`UPDATE shipments SET status = $1, updated_at = NOW() WHERE id = $2`, to, s.ID); err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+
+	h.commitShipment(c, tx, s.ID, http.StatusOK)
+}
+
+func (h *Handler) commitShipment(c *gin.Context, tx *sql.Tx, id, status int) {
+	s, err := scanShipment(tx.QueryRow(`SELECT `+shipmentColumns+` FROM shipments WHERE id = $1`, id))
+	if err == nil {
+		s.Cartons, err = shipmentCartons(tx, id)
+	}
+	if err != nil {
+		writeShipmentError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(status, s)
+}
+
+// setShipmentCartons replaces the cartons on an open shipment. A carton
+// can only be on one shipment, and a shipment leaves one warehouse.
+func setShipmentCartons(tx *sql.Tx, c *gin.Context, shipmentID int, cartonIDs []int) error {
+	if _, err := tx.Exec(`UPDATE cartons SET shipment_id = NULL WHERE shipment_id = $1`, shipmentID); err != nil {
+		return err
+	}
+
+	var warehouseID *int
+	for i, id := range cartonIDs {
+		carton, err := findCarton(tx, c, id, true)
+		if err != nil {
+			return err
+		}
+		if carton.ShipmentID != nil {
+			return fmt.Errorf("%w: carton %s is on another shipment", errShipmentNotEditable, carton.SSCC)
+		}
+		if i > 0 && !sameWarehouse(warehouseID, carton.WarehouseID) {
+			return fmt.Errorf("%w: a shipment leaves from one warehouse", errInvalidPacking)
+		}
+		warehouseID = carton.WarehouseID
+
+		if _, err := tx.Exec(`UPDATE cartons SET shipment_id = $1 WHERE id = $2`, shipmentID, carton.ID); err != nil {
+			return err
+		}
+	}
+	if len(cartonIDs) > 0 {
+		_, err := tx.Exec(`UPDATE shipments SET warehouse_id = $1 WHERE id = $2`, warehouseID, shipmentID)
+		return err
+	}
+	return nil
+}
+
+// shipmentDispatches returns the dispatches packed in a shipment's
+// cartons, in id order so they lock in a stable order.
+func shipmentDispatches(tx *sql.Tx, shipmentID int) ([]int, error) {
+	rows, err := tx.Query(`
+		SELECT DISTINCT cl.dispatch_id FROM carton_lines cl JOIN cartons ct ON cl.carton_id = ct.id
+		WHERE ct.shipment_id = $1
+		ORDER BY cl.dispatch_id`, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func shipmentCartons(q dbtx, shipmentID int) ([]models.Carton, error) {
+	rows, err := q.Query(`SELECT `+cartonColumns+` FROM cartons WHERE shipment_id = $1 ORDER BY id`, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+	cartons, err := scanCartons(rows)
+	if err != nil {
+		return nil, err
+	}
+	return cartons, addCartonLines(q, cartons)
+}
+
+func lockShipment(tx *sql.Tx, c *gin.Context, id int) (*models.Shipment, error) {
+	s, err := scanShipment(tx.QueryRow(`
+		SELECT `+shipmentColumns+` FROM shipments
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2)
+		  AND ($3::int IS NULL OR warehouse_id IS NULL OR warehouse_id = $3)
+		FOR UPDATE`, id, middleware.TenantID(c), middleware.PinnedWarehouseID(c)))
+	if err == sql.ErrNoRows {
+		return nil, errShipmentNotFound
+	}
+	return s, err
+}
+
+func scanShipment(row scanner) (*models.Shipment, error) {
+	var s models.Shipment
+	var warehouseID, createdBy sql.NullInt64
+	var shipDate, shippedAt, deliveredAt sql.NullTime
+	err := row.Scan(&s.ID, &s.ShipmentNumber, &warehouseID, &s.Carrier, &s.TrackingNumber, &shipDate, &s.Status, &s.Notes,
+		&createdBy, &shippedAt, &deliveredAt, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.WarehouseID = nullableInt(warehouseID)
+	s.CreatedBy = nullableInt(createdBy)
+	s.ShipDate = nullableTime(shipDate)
+	s.ShippedAt = nullableTime(shippedAt)
+	s.DeliveredAt = nullableTime(deliveredAt)
+	return &s, nil
+}
+
+func parseShipDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	d, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, errors.New("ship_date must be YYYY-MM-DD")
+	}
+	return &d, nil
+}
+
+func writeShipmentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errDispatchNotFound), errors.Is(err, errCartonNotFound), errors.Is(err, errShipmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errDispatchNotEditable), errors.Is(err, errShipmentNotEditable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidPacking):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		writeAllocationError(c, err)
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newFakeShipment serves shipment 1 of tenant 3 on top of the warehouse of
+// newFakeLedger. Its cartons hold dispatch 81, for which the 4 units of
+// lot L2 are reserved.
+func newFakeShipment(status string, dispatches map[int]*fakeDispatch, elsewhere bool) (*fakeLedger, *string) {
+	tenant := 3
+	ledger := newFakeLedger(models.AllocationFEFO)
+	ledger.balance(1, 11, "L2").reserved = 4
+	ledger.reservations = append(ledger.reservations, &fakeReservation{id: 1, productID: 1, locationID: 11, batch: "L2", quantity: 4,
+		referenceType: models.RefDispatch, referenceID: 81, status: models.ReservationActive})
+	row := func() *dbtest.Result {
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(1), "SHP-1", int64(1), "JNE", "", nil, status, "",
+			nil, nil, nil, time.Now(), time.Now()}}}
+	}
+
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[0]) != 1 || !inScope(st.Args[1], &tenant) || st.Args[2] != nil && argInt(st.Args[2]) != 1 {
+			return nil, nil
+		}
+		return row(), nil
+	}, "FROM shipments", "FOR UPDATE")
+	ledger.on(func(dbtest.Statement) (*dbtest.Result, error) { return row(), nil }, "FROM shipments WHERE id = $1")
+	ledger.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		res := &dbtest.Result{}
+		for id := range dispatches {
+			res.Rows = append(res.Rows, []driver.Value{int64(id)})
+		}
+		return res, nil
+	}, "SELECT DISTINCT cl.dispatch_id")
+	onDispatches(ledger.fakeDB, dispatches)
+	ledger.on(rows([]driver.Value{elsewhere}), "SELECT EXISTS (SELECT 1 FROM carton_lines cl")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		status = st.Args[0].(string)
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE shipments SET status")
+	ledger.on(affected(1), "UPDATE shipments SET")
+	ledger.on(affected(0), "UPDATE cartons SET shipment_id = NULL")
+	ledger.on(rows(), "FROM cartons WHERE shipment_id = $1")
+	return ledger, &status
+}
+
+func TestAdvanceShipment(t *testing.T) {
+	other := 4
+	staff := auth.Claims{UserID: 7}
+	packed := func() map[int]*fakeDispatch {
+		return map[int]*fakeDispatch{81: {quantity: 4, status: models.DispatchPacked}}
+	}
+	tests := []struct {
+		name          string
+		claims        auth.Claims
+		action        string
+		status        string
+		dispatches    map[int]*fakeDispatch
+		elsewhere     bool
+		wantCode      int
+		wantStatus    string
+		wantDispatch  string
+		wantMovements []string
+	}{
+		{name: "ship takes the reserved stock", claims: staff, action: "ship", status: models.ShipmentOpen, dispatches: packed(),
+			wantCode: http.StatusOK, wantStatus: models.ShipmentShipped, wantDispatch: models.DispatchShipped,
+			wantMovements: []string{"OUT 4 11/L2"}},
+		{name: "ship without cartons", claims: staff, action: "ship", status: models.ShipmentOpen, dispatches: map[int]*fakeDispatch{},
+			wantCode: http.StatusConflict},
+		{name: "ship a dispatch still being packed", claims: staff, action: "ship", status: models.ShipmentOpen,
+			dispatches: map[int]*fakeDispatch{81: {quantity: 4, status: models.DispatchPicked}}, wantCode: http.StatusConflict},
+		{name: "ship part of a dispatch", claims: staff, action: "ship", status: models.ShipmentOpen, dispatches: packed(), elsewhere: true,
+			wantCode: http.StatusConflict},
+		{name: "ship twice", claims: staff, action: "ship", status: models.ShipmentShipped, dispatches: packed(),
+			wantCode: http.StatusConflict},
+		{name: "deliver", claims: staff, action: "deliver", status: models.ShipmentShipped, dispatches: packed(),
+			wantCode: http.StatusOK, wantStatus: models.ShipmentDelivered, wantDispatch: models.DispatchDelivered},
+		{name: "deliver before shipping", claims: staff, action: "deliver", status: models.ShipmentOpen, dispatches: packed(),
+			wantCode: http.StatusConflict},
+		{name: "cancel keeps the cartons packed", claims: staff, action: "cancel", status: models.ShipmentOpen, dispatches: packed(),
+			wantCode: http.StatusOK, wantStatus: models.ShipmentCancelled, wantDispatch: models.DispatchPacked},
+		{name: "other tenants' shipments", claims: auth.Claims{UserID: 7, TenantID: &other}, action: "ship", status: models.ShipmentOpen,
+			dispatches: packed(), wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger, status := newFakeShipment(tt.status, tt.dispatches, tt.elsewhere)
+			h := &Handler{DB: ledger.open(t)}
+			handler := map[string]gin.HandlerFunc{"ship": h.ShipShipment, "deliver": h.DeliverShipment, "cancel": h.CancelShipment}[tt.action]
+
+			w := serveAs(&tt.claims, "/shipments/:id/"+tt.action, handler, jsonRequest(http.MethodPost, "/shipments/1/"+tt.action, ""))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if ledger.committed != (tt.wantCode == http.StatusOK) {
+				t.Errorf("committed = %v on a %d", ledger.committed, w.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if *status != tt.wantStatus {
+				t.Errorf("shipment is %s, want %s", *status, tt.wantStatus)
+			}
+			if got := tt.dispatches[81].status; got != tt.wantDispatch {
+				t.Errorf("dispatch is %s, want %s", got, tt.wantDispatch)
+			}
+			if !reflect.DeepEqual(ledger.movements, tt.wantMovements) {
+				t.Errorf("movements = %v, want %v", ledger.movements, tt.wantMovements)
+			}
+		})
+	}
+}
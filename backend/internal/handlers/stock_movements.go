@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var errInvalidMovement = errors.New("invalid stock movement")
+
+// stockMovement is one ledger entry to book. applyStockMovement is the
+// only place that changes inventory.quantity, which keeps every balance
+// equal to the signed sum of its movements.
+type stockMovement struct {
+	ProductID     int
+	LocationID    int
+	Type          string
+	Quantity      int
+	Reference     string
+	ReferenceType string
+	ReasonCode    string
+	Batch         string
+	ExpiryDate    *time.Time
+	Notes         string
+	UserID        int
+	// TenantID is only used for products that belong to no tenant; stock
+	// of a tenant's product is always booked to that tenant
+	TenantID *int
+}
+
+func applyStockMovement(tx *sql.Tx, m stockMovement) error {
+	if m.Quantity <= 0 {
+		return fmt.Errorf("%w: quantity must be positive", errInvalidMovement)
+	}
+
+	delta := m.Quantity
+	switch m.Type {
+	case models.MovementIn:
+	case models.MovementOut:
+		delta = -m.Quantity
+	default:
+		return fmt.Errorf("%w: movement_type must be IN or OUT", errInvalidMovement)
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO inventory (product_id, location_id, batch, expiry_date, quantity, tenant_id, updated_at)
+		VALUES ($1, $2, $3, $4, $5, COALESCE((SELECT tenant_id FROM warehouse_product WHERE id = $1), $6), NOW())
+		ON CONFLICT (product_id, location_id, batch)
+		DO UPDATE SET quantity = inventory.quantity + EXCLUDED.quantity,
+		              expiry_date = COALESCE(inventory.expiry_date, EXCLUDED.expiry_date),
+		              received_at = CASE WHEN inventory.quantity <= 0 AND EXCLUDED.quantity > 0
+		                                 THEN NOW() ELSE inventory.received_at END,
+		              updated_at = NOW()`,
+		m.ProductID, m.LocationID, m.Batch, m.ExpiryDate, delta, m.TenantID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "inventory_quantity_non_negative" {
+		return fmt.Errorf("%w: not enough stock of product %d at location %d", errInsufficientStock, m.ProductID, m.LocationID)
+	}
+	if errors.As(err, &pqErr) && pqErr.Constraint == "inventory_quarantine_within_quantity" {
+		return fmt.Errorf("%w: stock of product %d at location %d is quarantined", errInsufficientStock, m.ProductID, m.LocationID)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO stock_movements (product_id, location_id, movement_type, quantity, reference, reference_type,
+		                             reason_code, batch, expiry_date, notes, created_by, tenant_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11,
+		        COALESCE((SELECT tenant_id FROM warehouse_product WHERE id = $1), $12), NOW())`,
+		m.ProductID, m.LocationID, m.Type, m.Quantity, m.Reference, m.ReferenceType,
+		m.ReasonCode, m.Batch, m.ExpiryDate, m.Notes, m.UserID, m.TenantID)
+	return err
+}
+
+// stockLot is the part of an outbound movement that fell on one lot.
+type stockLot struct {
+	Batch      string     `json:"batch"`
+	ExpiryDate *time.Time `json:"expiry_date"`
+	Quantity   int        `json:"quantity"`
+}
+
+// takeStock books m as OUT movements. With m.Batch set only that lot is
+// drawn down. Otherwise lots are consumed in the order of the product's
+// allocation strategy. Reserved stock is left alone and a shortfall fails
+// with errInsufficientStock. It returns what came out of each lot so
+// callers can book the matching IN elsewhere.
+func takeStock(tx *sql.Tx, m stockMovement) ([]stockLot, error) {
+	return drawStock(tx, m, false)
+}
+
+// takeCountedStock is takeStock for count corrections, which may take
+// reserved stock too: the count says it is no longer there. The
+// reservations stay and fail when shipped unless stock is found.
+func takeCountedStock(tx *sql.Tx, m stockMovement) ([]stockLot, error) {
+	return drawStock(tx, m, true)
+}
+
+func drawStock(tx *sql.Tx, m stockMovement, includeReserved bool) ([]stockLot, error) {
+	m.Type = models.MovementOut
+	if m.Quantity <= 0 {
+		return nil, fmt.Errorf("%w: quantity must be positive", errInvalidMovement)
+	}
+
+	plan, err := planAllocation(tx, m.ProductID, m.Quantity, allocationScope{
+		LocationID:      m.LocationID,
+		Batch:           m.Batch,
+		IncludeReserved: includeReserved,
+		Lock:            true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if plan.Short > 0 {
+		if m.Batch != "" {
+			return nil, fmt.Errorf("%w: lot %s has %d available, %d requested",
+				errInsufficientStock, m.Batch, plan.Allocated, m.Quantity)
+		}
+		return nil, fmt.Errorf("%w: %d available, %d requested", errInsufficientStock, plan.Allocated, m.Quantity)
+	}
+
+	var lots []stockLot
+	for _, line := range plan.Lines {
+		out := m
+		out.Batch, out.ExpiryDate, out.Quantity = line.Batch, line.ExpiryDate, line.Quantity
+		if err := applyStockMovement(tx, out); err != nil {
+			return nil, err
+		}
+		lots = append(lots, stockLot{Batch: line.Batch, ExpiryDate: line.ExpiryDate, Quantity: line.Quantity})
+	}
+	return lots, nil
+}
+
+// lockedOnHand locks the balances of a product at a location and returns
+// their total, or only that of batch when one is given.
+func lockedOnHand(tx *sql.Tx, productID, locationID int, batch string) (int, error) {
+	var onHand int
+	err := tx.QueryRow(`
+		SELECT COALESCE(SUM(quantity), 0) FROM (
+			SELECT quantity FROM inventory
+			WHERE product_id = $1 AND location_id = $2 AND ($3 = '' OR batch = $3)
+			FOR UPDATE
+		) locked`, productID, locationID, batch).Scan(&onHand)
+	return onHand, err
+}
+
+func nullableTime(v sql.NullTime) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Time
+}
+
+// GetStockMovements queries the ledger. All filters are optional; from and
+// to are inclusive dates (YYYY-MM-DD).
+func (h *Handler) GetStockMovements(c *gin.Context) {
+	productID, _ := strconv.Atoi(c.Query("product_id"))
+	locationID, _ := strconv.Atoi(c.Query("location_id"))
+	userID, _ := strconv.Atoi(c.Query("user_id"))
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	var from, to *time.Time
+	for _, bound := range []struct {
+		param string
+		dest  **time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		d, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + bound.param + " date"})
+			return
+		}
+		*bound.dest = &d
+	}
+
+	rows, err := h.DB.Query(`
+		SELECT sm.id, sm.product_id, p.name, p.sku, sm.location_id, COALESCE(loc.code, ''), sm.movement_type, sm.quantity,
+		       COALESCE(sm.reference, ''), COALESCE(sm.reference_type, ''), COALESCE(sm.reason_code, ''),
+		       COALESCE(sm.batch, ''), sm.expiry_date, COALESCE(sm.notes, ''), sm.created_by, COALESCE(u.username, ''),
+		       sm.created_at
+		FROM stock_movements sm
+		JOIN warehouse_product p ON sm.product_id = p.id
+		LEFT JOIN locations loc ON sm.location_id = loc.id
+		LEFT JOIN auth_user u ON sm.created_by = u.id
+		WHERE ($1::int IS NULL OR sm.tenant_id = $1)
+		  AND ($2::int IS NULL OR loc.warehouse_id = $2)
+		  AND ($3 = 0 OR sm.product_id = $3)
+		  AND ($4 = 0 OR sm.location_id = $4)
+		  AND ($5 = '' OR sm.movement_type = $5)
+		  AND ($6 = '' OR sm.reference ILIKE '%' || $6 || '%')
+		  AND ($7 = '' OR sm.reference_type = $7)
+		  AND ($8 = '' OR sm.reason_code = $8)
+		  AND ($9 = 0 OR sm.created_by = $9)
+		  AND ($10::date IS NULL OR sm.created_at >= $10::date)
+		  AND ($11::date IS NULL OR sm.created_at < $11::date + 1)
+		  AND ($14 = '' OR sm.batch = $14)
+		ORDER BY sm.created_at DESC, sm.id DESC
+		LIMIT $12 OFFSET $13`,
+		middleware.TenantID(c), middleware.WarehouseID(c), productID, locationID, c.Query("movement_type"),
+		c.Query("reference"), c.Query("reference_type"), c.Query("reason_code"), userID, from, to, limit, offset,
+		c.Query("batch"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock movements"})
+		return
+	}
+	defer rows.Close()
+
+	var entries []models.StockLedgerEntry
+	for rows.Next() {
+		var e models.StockLedgerEntry
+		var location, createdBy sql.NullInt64
+		var expiry sql.NullTime
+		err := rows.Scan(&e.ID, &e.ProductID, &e.ProductName, &e.SKU, &location, &e.LocationCode, &e.MovementType, &e.Quantity,
+			&e.Reference, &e.ReferenceType, &e.ReasonCode, &e.Batch, &expiry, &e.Notes, &createdBy, &e.CreatedByName,
+			&e.CreatedAt)
+		if err != nil {
+			continue
+		}
+		e.LocationID = nullableInt(location)
+		e.CreatedBy = nullableInt(createdBy)
+		if expiry.Valid {
+			e.ExpiryDate = &expiry.Time
+		}
+		entries = append(entries, e)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entries, "limit": limit, "offset": offset})
+}
+
+// CreateStockMovement books a manual adjustment. A reason code is
+// mandatory and must allow the movement's direction.
+func (h *Handler) CreateStockMovement(c *gin.Context) {
+	var req models.StockAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var direction string
+	err := h.DB.QueryRow(`SELECT direction FROM stock_reason_codes WHERE code = $1 AND active`, req.ReasonCode).Scan(&direction)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown reason code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up reason code"})
+		return
+	}
+	if direction != "BOTH" && direction != req.MovementType {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Reason %s only allows %s movements", req.ReasonCode, direction)})
+		return
+	}
+
+	if ok, err := h.belongsToTenant(c, "warehouse_product", req.ProductID); err != nil || !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown product"})
+		return
+	}
+	if _, err := h.locationWarehouse(c, req.LocationID); err == errLocationNotAccessible {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown location"})
+		return
+	}
+
+	var expiry *time.Time
+	if req.ExpiryDate != "" {
+		d, err := time.Parse("2006-01-02", req.ExpiryDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiry_date must be YYYY-MM-DD"})
+			return
+		}
+		expiry = &d
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := ensureStockNotFrozen(tx, req.ProductID, req.LocationID); err == errStockFrozen {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check open counts"})
+		return
+	}
+
+	var reference string
+	if err := tx.QueryRow(`SELECT 'ADJ-' || LPAD(nextval('stock_adjustment_number_seq')::TEXT, 6, '0')`).Scan(&reference); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to number adjustment"})
+		return
+	}
+	m := stockMovement{
+		ProductID:     req.ProductID,
+		LocationID:    req.LocationID,
+		Type:          req.MovementType,
+		Quantity:      req.Quantity,
+		Reference:     reference,
+		ReferenceType: models.RefAdjustment,
+		ReasonCode:    req.ReasonCode,
+		Batch:         req.Batch,
+		ExpiryDate:    expiry,
+		Notes:         req.Notes,
+		UserID:        middleware.CurrentUserID(c),
+		TenantID:      middleware.TenantID(c),
+	}
+	if m.Type == models.MovementOut {
+		_, err = takeStock(tx, m)
+	} else {
+		err = applyStockMovement(tx, m)
+	}
+	if errors.Is(err, errInvalidMovement) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errInsufficientStock) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record stock movement"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Stock movement created", "reference": reference})
+}
+
+func (h *Handler) GetStockReasonCodes(c *gin.Context) {
+	rows, err := h.DB.Query(`SELECT code, description, direction FROM stock_reason_codes WHERE active ORDER BY code`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reason codes"})
+		return
+	}
+	defer rows.Close()
+
+	var codes []models.StockReasonCode
+	for rows.Next() {
+		var rc models.StockReasonCode
+		if err := rows.Scan(&rc.Code, &rc.Description, &rc.Direction); err != nil {
+			continue
+		}
+		codes = append(codes, rc)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": codes})
+}
+
+// GetStockReconciliation compares every balance with the sum of its
+// movements and lists those that drifted. all=true lists every balance.
+func (h *Handler) GetStockReconciliation(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		WITH ledger AS (
+			SELECT product_id, location_id,
+			       SUM(CASE WHEN movement_type = 'OUT' THEN -quantity ELSE quantity END) AS quantity
+			FROM stock_movements
+			WHERE location_id IS NOT NULL AND ($1::int IS NULL OR tenant_id = $1)
+			GROUP BY product_id, location_id
+		), balances AS (
+			SELECT product_id, location_id, SUM(quantity) AS quantity FROM inventory
+			WHERE ($1::int IS NULL OR tenant_id = $1)
+			GROUP BY product_id, location_id
+		)
+		SELECT p.id, p.name, loc.id, loc.code, COALESCE(b.quantity, 0), COALESCE(l.quantity, 0)
+		FROM balances b
+		FULL OUTER JOIN ledger l ON b.product_id = l.product_id AND b.location_id = l.location_id
+		JOIN warehouse_product p ON p.id = COALESCE(b.product_id, l.product_id)
+		JOIN locations loc ON loc.id = COALESCE(b.location_id, l.location_id)
+		WHERE ($2::int IS NULL OR loc.warehouse_id = $2)
+		  AND ($3 OR COALESCE(b.quantity, 0) <> COALESCE(l.quantity, 0))
+		ORDER BY p.name, loc.code`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("all") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile stock"})
+		return
+	}
+	defer rows.Close()
+
+	var drifts []models.StockDrift
+	for rows.Next() {
+		var d models.StockDrift
+		if err := rows.Scan(&d.ProductID, &d.ProductName, &d.LocationID, &d.LocationCode, &d.InventoryQty, &d.LedgerQty); err != nil {
+			continue
+		}
+		d.Drift = d.InventoryQty - d.LedgerQty
+		drifts = append(drifts, d)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": drifts, "in_balance": !hasDrift(drifts)})
+}
+
+func hasDrift(drifts []models.StockDrift) bool {
+	for _, d := range drifts {
+		if d.Drift != 0 {
+			return true
+		}
+	}
+	return false
+}
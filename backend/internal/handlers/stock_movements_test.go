@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+)
+
+// onMasterData answers the product, location and reason code lookups of
+// the stock handlers. Product 1 belongs to tenant 3; every location is in
+// warehouse 1.
+func onMasterData(db *fakeDB) {
+	tenant := 3
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		return &dbtest.Result{Rows: [][]driver.Value{{argInt(st.Args[0]) == 1 && inScope(st.Args[1], &tenant)}}}, nil
+	}, "SELECT EXISTS(SELECT 1 FROM warehouse_product")
+	db.on(rows([]driver.Value{int64(1)}), "SELECT warehouse_id FROM locations")
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		direction := map[string]string{"FOUND": models.MovementIn, "DAMAGE": models.MovementOut, "CORRECTION": "BOTH"}[st.Args[0].(string)]
+		if direction == "" {
+			return nil, nil
+		}
+		return &dbtest.Result{Rows: [][]driver.Value{{direction}}}, nil
+	}, "SELECT direction FROM stock_reason_codes")
+}
+
+func TestCreateStockMovement(t *testing.T) {
+	other := 4
+	staff := auth.Claims{UserID: 7}
+	tests := []struct {
+		name          string
+		claims        auth.Claims
+		body          string
+		frozen        []int
+		wantCode      int
+		wantMovements []string
+	}{
+		{name: "found stock", claims: staff, body: `"location_id": 10, "movement_type": "IN", "quantity": 3, "reason_code": "FOUND"`,
+			wantCode: http.StatusCreated, wantMovements: []string{"IN 3 10/"}},
+		{name: "damage takes from the location", claims: staff, body: `"location_id": 11, "movement_type": "OUT", "quantity": 2, "reason_code": "DAMAGE"`,
+			wantCode: http.StatusCreated, wantMovements: []string{"OUT 2 11/L2"}},
+		{name: "more than on hand", claims: staff, body: `"location_id": 11, "movement_type": "OUT", "quantity": 5, "reason_code": "CORRECTION"`,
+			wantCode: http.StatusConflict},
+		{name: "location under count", claims: staff, body: `"location_id": 10, "movement_type": "IN", "quantity": 1, "reason_code": "FOUND"`,
+			frozen: []int{10}, wantCode: http.StatusConflict},
+		{name: "reason for the other direction", claims: staff, body: `"location_id": 10, "movement_type": "OUT", "quantity": 1, "reason_code": "FOUND"`,
+			wantCode: http.StatusBadRequest},
+		{name: "other tenants' products", claims: auth.Claims{UserID: 7, TenantID: &other},
+			body:     `"location_id": 10, "movement_type": "IN", "quantity": 1, "reason_code": "FOUND"`,
+			wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newFakeLedger(models.AllocationFEFO)
+			for _, id := range tt.frozen {
+				ledger.frozen[id] = true
+			}
+			onMasterData(ledger.fakeDB)
+			ledger.on(rows([]driver.Value{"ADJ-000001"}), "nextval('stock_adjustment_number_seq')")
+			h := &Handler{DB: ledger.open(t)}
+
+			w := serveAs(&tt.claims, "/stock-movements", h.CreateStockMovement,
+				jsonRequest(http.MethodPost, "/stock-movements", fmt.Sprintf(`{"product_id": 1, %s}`, tt.body)))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if ledger.committed != (tt.wantCode == http.StatusCreated) {
+				t.Errorf("committed = %v on a %d", ledger.committed, w.Code)
+			}
+			if tt.wantCode == http.StatusCreated && !reflect.DeepEqual(ledger.movements, tt.wantMovements) {
+				t.Errorf("movements = %v, want %v", ledger.movements, tt.wantMovements)
+			}
+		})
+	}
+}
@@ -0,0 +1,592 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"wms-backend/internal/auth"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var (
+	errStockFrozen       = errors.New("stock is frozen by an open stock count")
+	errOpnameNotFound    = errors.New("stock opname not found")
+	errOpnameNotEditable = errors.New("stock opname cannot be changed in its current status")
+	errInvalidOpname     = errors.New("invalid stock opname")
+)
+
+const stockOpnameColumns = `id, document_number, warehouse_id, location_id, blind, status, notes, review_notes,
+	created_by, reviewed_by, reviewed_at, tenant_id, created_at, updated_at`
+
+// stockCountOpen reports whether an open or submitted count covers
+// productID at locationID. productID 0 matches any product.
+func stockCountOpen(q dbtx, productID, locationID int) (bool, error) {
+	var open bool
+	err := q.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM stock_opnames o
+			WHERE o.status IN ('open', 'submitted')
+			  AND (o.location_id = $2 OR EXISTS(
+			      SELECT 1 FROM stock_opname_lines l
+			      WHERE l.opname_id = o.id AND l.location_id = $2 AND ($1 = 0 OR l.product_id = $1)))
+		)`, productID, locationID).Scan(&open)
+	return open, err
+}
+
+// ensureStockNotFrozen rejects stock movements on a product and location
+// that are being counted.
+func ensureStockNotFrozen(q dbtx, productID, locationID int) error {
+	open, err := stockCountOpen(q, productID, locationID)
+	if err != nil {
+		return err
+	}
+	if open {
+		return errStockFrozen
+	}
+	return nil
+}
+
+func (h *Handler) GetStockOpnames(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		SELECT `+stockOpnameColumns+` FROM stock_opnames
+		WHERE ($1::int IS NULL OR tenant_id = $1)
+		  AND ($2::int IS NULL OR warehouse_id = $2)
+		  AND ($3 = '' OR status = $3)
+		ORDER BY created_at DESC`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock opnames"})
+		return
+	}
+	defer rows.Close()
+
+	var opnames []models.StockOpnameSession
+	for rows.Next() {
+		o, err := scanStockOpname(rows)
+		if err != nil {
+			continue
+		}
+		opnames = append(opnames, *o)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": opnames})
+}
+
+// GetStockOpname returns a count with its lines. While a blind count is
+// open, only users who may approve it see system quantities.
+func (h *Handler) GetStockOpname(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	o, err := scanStockOpname(h.DB.QueryRow(`
+		SELECT `+stockOpnameColumns+` FROM stock_opnames
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2) AND ($3::int IS NULL OR warehouse_id = $3)`,
+		id, middleware.TenantID(c), middleware.WarehouseID(c)))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock opname not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock opname"})
+		return
+	}
+
+	rows, err := h.DB.Query(`
+		SELECT l.id, l.opname_id, l.product_id, p.name, p.sku, l.location_id, loc.code,
+		       l.system_quantity, l.counted_quantity, l.counted_by, l.counted_at, l.notes
+		FROM stock_opname_lines l
+		JOIN warehouse_product p ON l.product_id = p.id
+		JOIN locations loc ON l.location_id = loc.id
+		WHERE l.opname_id = $1
+		ORDER BY loc.code, p.name`, o.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock opname lines"})
+		return
+	}
+	defer rows.Close()
+
+	claims := middleware.GetClaims(c)
+	hideSystem := o.Blind && o.Status == models.StockOpnameOpen && (claims == nil || !claims.Can(auth.PermApproveCount))
+
+	for rows.Next() {
+		var l models.StockOpnameLine
+		var systemQty int
+		var counted, countedBy sql.NullInt64
+		var countedAt sql.NullTime
+		err := rows.Scan(&l.ID, &l.OpnameID, &l.ProductID, &l.ProductName, &l.SKU, &l.LocationID, &l.LocationCode,
+			&systemQty, &counted, &countedBy, &countedAt, &l.Notes)
+		if err != nil {
+			continue
+		}
+		l.CountedQuantity = nullableInt(counted)
+		l.CountedBy = nullableInt(countedBy)
+		if countedAt.Valid {
+			l.CountedAt = &countedAt.Time
+		}
+		if !hideSystem {
+			l.SystemQuantity = &systemQty
+			if l.CountedQuantity != nil {
+				diff := *l.CountedQuantity - systemQty
+				l.Difference = &diff
+			}
+		}
+		o.Lines = append(o.Lines, l)
+	}
+
+	c.JSON(http.StatusOK, o)
+}
+
+// CreateStockOpname opens a count over a location, or over a set of
+// products within the warehouse (optionally narrowed to one location), and
+// snapshots the system quantities.
+func (h *Handler) CreateStockOpname(c *gin.Context) {
+	var req models.StockOpnameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.LocationID == nil && len(req.ProductIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "location_id or product_ids is required"})
+		return
+	}
+
+	warehouseID := req.WarehouseID
+	if pinned := middleware.PinnedWarehouseID(c); pinned != nil {
+		warehouseID = pinned
+	}
+	if req.LocationID != nil {
+		locationWarehouseID, err := h.locationWarehouse(c, *req.LocationID)
+		if err == errLocationNotAccessible {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown location"})
+			return
+		}
+		warehouseID = locationWarehouseID
+	}
+	for _, productID := range req.ProductIDs {
+		if ok, err := h.belongsToTenant(c, "warehouse_product", productID); err != nil || !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown product %d", productID)})
+			return
+		}
+	}
+
+	blind := true
+	if req.Blind != nil {
+		blind = *req.Blind
+	}
+	tenantID := middleware.TenantID(c)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Staff counting products of a single tenant count for that tenant
+	if tenantID == nil && len(req.ProductIDs) > 0 {
+		var owner sql.NullInt64
+		var owners int
+		err := tx.QueryRow(`SELECT MIN(tenant_id), COUNT(DISTINCT tenant_id) FROM warehouse_product WHERE id = ANY($1)`,
+			pq.Array(req.ProductIDs)).Scan(&owner, &owners)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock opname"})
+			return
+		}
+		if owners == 1 {
+			tenantID = nullableInt(owner)
+		}
+	}
+
+	// A location count owns the whole location, so nothing else may count it
+	wholeLocation := len(req.ProductIDs) == 0
+	if wholeLocation {
+		open, err := stockCountOpen(tx, 0, *req.LocationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check open counts"})
+			return
+		}
+		if open {
+			c.JSON(http.StatusConflict, gin.H{"error": "Location is already being counted"})
+			return
+		}
+	}
+
+	var opnameLocation *int
+	if wholeLocation {
+		opnameLocation = req.LocationID
+	}
+	o, err := scanStockOpname(tx.QueryRow(`
+		INSERT INTO stock_opnames (warehouse_id, location_id, blind, notes, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+stockOpnameColumns,
+		warehouseID, opnameLocation, blind, req.Notes, middleware.CurrentUserID(c), tenantID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock opname"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO stock_opname_lines (opname_id, product_id, location_id, system_quantity)
+		SELECT $1, i.product_id, i.location_id, SUM(i.quantity)
+		FROM inventory i
+		JOIN locations loc ON i.location_id = loc.id
+		WHERE ($2::int IS NULL OR i.tenant_id = $2)
+		  AND ($3::int IS NULL OR loc.warehouse_id = $3)
+		  AND ($4::int IS NULL OR i.location_id = $4)
+		  AND (cardinality($5::int[]) = 0 OR i.product_id = ANY($5))
+		GROUP BY i.product_id, i.location_id
+		HAVING SUM(i.quantity) <> 0`,
+		o.ID, tenantID, warehouseID, req.LocationID, pq.Array(req.ProductIDs))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to snapshot stock"})
+		return
+	}
+
+	// Product counts must not overlap another open count
+	var overlapping int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM stock_opname_lines mine
+		JOIN stock_opname_lines other ON other.product_id = mine.product_id AND other.location_id = mine.location_id
+		JOIN stock_opnames o ON other.opname_id = o.id
+		WHERE mine.opname_id = $1 AND other.opname_id <> $1 AND o.status IN ('open', 'submitted')`, o.ID).Scan(&overlapping)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check open counts"})
+		return
+	}
+	if overlapping > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%d product location(s) are already being counted", overlapping)})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, o)
+}
+
+// RecordStockCount stores a counted quantity. Products found at a location
+// that the snapshot did not list are added with a system quantity of zero.
+func (h *Handler) RecordStockCount(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req models.StockOpnameCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if ok, err := h.belongsToTenant(c, "warehouse_product", req.ProductID); err != nil || !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown product"})
+		return
+	}
+	locationWarehouseID, err := h.locationWarehouse(c, req.LocationID)
+	if err == errLocationNotAccessible {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown location"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	o, err := h.lockStockOpname(tx, c, id)
+	if err != nil {
+		writeStockOpnameError(c, err)
+		return
+	}
+	if o.Status != models.StockOpnameOpen {
+		writeStockOpnameError(c, fmt.Errorf("%w: counting is closed", errOpnameNotEditable))
+		return
+	}
+	if o.LocationID != nil && *o.LocationID != req.LocationID {
+		writeStockOpnameError(c, fmt.Errorf("%w: location is outside this count", errInvalidOpname))
+		return
+	}
+	if o.WarehouseID != nil && (locationWarehouseID == nil || *locationWarehouseID != *o.WarehouseID) {
+		writeStockOpnameError(c, fmt.Errorf("%w: location is outside this count", errInvalidOpname))
+		return
+	}
+	if err := checkStockCountScope(tx, o, req.ProductID, req.LocationID); err != nil {
+		writeStockOpnameError(c, err)
+		return
+	}
+
+	var lineID int
+	err = tx.QueryRow(`
+		INSERT INTO stock_opname_lines (opname_id, product_id, location_id, system_quantity, counted_quantity, counted_by, counted_at, notes)
+		VALUES ($1, $2, $3,
+		        COALESCE((SELECT SUM(quantity) FROM inventory WHERE product_id = $2 AND location_id = $3), 0),
+		        $4, $5, NOW(), $6)
+		ON CONFLICT (opname_id, product_id, location_id) DO UPDATE SET
+			counted_quantity = EXCLUDED.counted_quantity,
+			counted_by = EXCLUDED.counted_by,
+			counted_at = NOW(),
+			notes = EXCLUDED.notes
+		RETURNING id`,
+		o.ID, req.ProductID, req.LocationID, *req.CountedQuantity, middleware.CurrentUserID(c), req.Notes).Scan(&lineID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record count"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Count recorded", "line_id": lineID})
+}
+
+// SubmitStockOpname closes counting once every line has a count.
+func (h *Handler) SubmitStockOpname(c *gin.Context) {
+	h.reviewStockOpname(c, models.StockOpnameOpen, models.StockOpnameSubmitted)
+}
+
+// ApproveStockOpname books the variances: inventory is set to the counted
+// quantity and each difference is recorded as an IN or OUT movement.
+func (h *Handler) ApproveStockOpname(c *gin.Context) {
+	h.reviewStockOpname(c, models.StockOpnameSubmitted, models.StockOpnameApproved)
+}
+
+func (h *Handler) RejectStockOpname(c *gin.Context) {
+	h.reviewStockOpname(c, models.StockOpnameSubmitted, models.StockOpnameRejected)
+}
+
+func (h *Handler) CancelStockOpname(c *gin.Context) {
+	h.reviewStockOpname(c, "", models.StockOpnameCancelled)
+}
+
+// reviewStockOpname moves a count from status from to status to. An empty
+// from accepts any status that still freezes stock.
+func (h *Handler) reviewStockOpname(c *gin.Context, from, to string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req models.StockOpnameReviewRequest
+	// The body is optional
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	userID := middleware.CurrentUserID(c)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	o, err := h.lockStockOpname(tx, c, id)
+	if err != nil {
+		writeStockOpnameError(c, err)
+		return
+	}
+	switch {
+	case from == "" && (o.Status == models.StockOpnameOpen || o.Status == models.StockOpnameSubmitted):
+	case o.Status != from:
+		writeStockOpnameError(c, fmt.Errorf("%w: cannot move from %s to %s", errOpnameNotEditable, o.Status, to))
+		return
+	}
+
+	switch to {
+	case models.StockOpnameSubmitted:
+		var uncounted int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM stock_opname_lines WHERE opname_id = $1 AND counted_quantity IS NULL`, o.ID).Scan(&uncounted)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check counts"})
+			return
+		}
+		if uncounted > 0 {
+			writeStockOpnameError(c, fmt.Errorf("%w: %d line(s) have not been counted", errOpnameNotEditable, uncounted))
+			return
+		}
+	case models.StockOpnameApproved:
+		if err := postStockOpname(tx, o, userID); err != nil {
+			writeStockOpnameError(c, err)
+			return
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE stock_opnames
+		SET status = $1, updated_at = NOW(),
+		    review_notes = CASE WHEN $1 IN ('approved', 'rejected') THEN $2 ELSE review_notes END,
+		    reviewed_by = CASE WHEN $1 IN ('approved', 'rejected') THEN $3 ELSE reviewed_by END,
+		    reviewed_at = CASE WHEN $1 IN ('approved', 'rejected') THEN NOW() ELSE reviewed_at END
+		WHERE id = $4`, to, req.Notes, userID, o.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock opname"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stock opname " + to, "id": o.ID, "status": to})
+}
+
+type opnameVariance struct {
+	productID  int
+	locationID int
+	counted    int
+}
+
+// postStockOpname books the difference between each counted quantity and
+// the current balance, which the freeze keeps equal to the snapshot.
+func postStockOpname(tx *sql.Tx, o *models.StockOpnameSession, userID int) error {
+	rows, err := tx.Query(`SELECT product_id, location_id, counted_quantity FROM stock_opname_lines WHERE opname_id = $1 ORDER BY id`, o.ID)
+	if err != nil {
+		return err
+	}
+	var variances []opnameVariance
+	for rows.Next() {
+		var v opnameVariance
+		if err := rows.Scan(&v.productID, &v.locationID, &v.counted); err != nil {
+			rows.Close()
+			return err
+		}
+		variances = append(variances, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, v := range variances {
+		current, err := lockedOnHand(tx, v.productID, v.locationID, "")
+		if err != nil {
+			return err
+		}
+
+		diff := v.counted - current
+		if diff == 0 {
+			continue
+		}
+
+		// Counts are per product, not per lot: shortages come out of lots
+		// in allocation order, reserved or not, and surpluses go to
+		// untracked stock
+		m := stockMovement{
+			ProductID:     v.productID,
+			LocationID:    v.locationID,
+			Type:          models.MovementIn,
+			Quantity:      diff,
+			Reference:     o.DocumentNumber,
+			ReferenceType: models.RefOpname,
+			UserID:        userID,
+			TenantID:      o.TenantID,
+		}
+		if diff < 0 {
+			m.Quantity = -diff
+			_, err = takeCountedStock(tx, m)
+		} else {
+			err = applyStockMovement(tx, m)
+		}
+		if err != nil {
+			return fmt.Errorf("count of product %d at location %d: %w", v.productID, v.locationID, err)
+		}
+	}
+	return nil
+}
+
+// checkStockCountScope makes sure a count may record productID at
+// locationID. A product-set count only takes the products it froze, and a
+// line it did not snapshot must not be covered by another open count.
+func checkStockCountScope(tx *sql.Tx, o *models.StockOpnameSession, productID, locationID int) error {
+	var inScope, listed, elsewhere bool
+	err := tx.QueryRow(`
+		SELECT $3::int IS NOT NULL OR EXISTS(SELECT 1 FROM stock_opname_lines WHERE opname_id = $1 AND product_id = $2),
+		       EXISTS(SELECT 1 FROM stock_opname_lines WHERE opname_id = $1 AND product_id = $2 AND location_id = $4),
+		       EXISTS(SELECT 1 FROM stock_opnames other
+		              WHERE other.id <> $1 AND other.status IN ('open', 'submitted')
+		                AND (other.location_id = $4 OR EXISTS(
+		                    SELECT 1 FROM stock_opname_lines l
+		                    WHERE l.opname_id = other.id AND l.product_id = $2 AND l.location_id = $4)))`,
+		o.ID, productID, o.LocationID, locationID).Scan(&inScope, &listed, &elsewhere)
+	if err != nil {
+		return err
+	}
+	if !inScope {
+		return fmt.Errorf("%w: product is outside this count", errInvalidOpname)
+	}
+	if !listed && elsewhere {
+		return fmt.Errorf("%w: product is being counted at this location by another count", errOpnameNotEditable)
+	}
+	return nil
+}
+
+func (h *Handler) lockStockOpname(tx *sql.Tx, c *gin.Context, id int) (*models.StockOpnameSession, error) {
+	o, err := scanStockOpname(tx.QueryRow(`
+		SELECT `+stockOpnameColumns+` FROM stock_opnames
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2) AND ($3::int IS NULL OR warehouse_id = $3)
+		FOR UPDATE`,
+		id, middleware.TenantID(c), middleware.PinnedWarehouseID(c)))
+	if err == sql.ErrNoRows {
+		return nil, errOpnameNotFound
+	}
+	return o, err
+}
+
+func scanStockOpname(row scanner) (*models.StockOpnameSession, error) {
+	var o models.StockOpnameSession
+	var warehouseID, locationID, createdBy, reviewedBy, tenantID sql.NullInt64
+	var reviewedAt sql.NullTime
+	err := row.Scan(&o.ID, &o.DocumentNumber, &warehouseID, &locationID, &o.Blind, &o.Status, &o.Notes, &o.ReviewNotes,
+		&createdBy, &reviewedBy, &reviewedAt, &tenantID, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	o.WarehouseID = nullableInt(warehouseID)
+	o.LocationID = nullableInt(locationID)
+	o.CreatedBy = nullableInt(createdBy)
+	o.ReviewedBy = nullableInt(reviewedBy)
+	o.TenantID = nullableInt(tenantID)
+	if reviewedAt.Valid {
+		o.ReviewedAt = &reviewedAt.Time
+	}
+	return &o, nil
+}
+
+func writeStockOpnameError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errOpnameNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidOpname):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errOpnameNotEditable), errors.Is(err, errInsufficientStock), errors.Is(err, errStockFrozen):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process stock opname"})
+	}
+}
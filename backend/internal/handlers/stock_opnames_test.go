@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// countedLine is a counted stock_opname_lines row.
+type countedLine struct {
+	productID, locationID, counted int
+}
+
+// newFakeOpname serves count 1 of tenant 3 in status on top of the
+// warehouse of newFakeLedger, and records the statuses it is moved to.
+func newFakeOpname(status string, uncounted int, lines []countedLine) (*fakeLedger, *[]string) {
+	tenant := 3
+	ledger := newFakeLedger(models.AllocationFEFO)
+	var moves []string
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[0]) != 1 || !inScope(st.Args[1], &tenant) || st.Args[2] != nil {
+			return nil, nil
+		}
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(1), "SO-1", nil, nil, false, status, "", "",
+			nil, nil, nil, int64(tenant), time.Now(), time.Now()}}}, nil
+	}, "FROM stock_opnames", "FOR UPDATE")
+	ledger.on(rows([]driver.Value{int64(uncounted)}), "FROM stock_opname_lines", "counted_quantity IS NULL")
+	ledger.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		res := &dbtest.Result{}
+		for _, l := range lines {
+			res.Rows = append(res.Rows, []driver.Value{int64(l.productID), int64(l.locationID), int64(l.counted)})
+		}
+		return res, nil
+	}, "SELECT product_id, location_id, counted_quantity FROM stock_opname_lines")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		moves = append(moves, st.Args[0].(string))
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE stock_opnames")
+	return ledger, &moves
+}
+
+func TestReviewStockOpname(t *testing.T) {
+	other := 4
+	staff := auth.Claims{UserID: 7}
+	tests := []struct {
+		name          string
+		claims        auth.Claims
+		action        string
+		status        string
+		uncounted     int
+		lines         []countedLine
+		wantCode      int
+		wantStatus    []string
+		wantMovements []string
+		wantStock     []string
+	}{
+		{name: "submit", claims: staff, action: "submit", status: models.StockOpnameOpen,
+			wantCode: http.StatusOK, wantStatus: []string{models.StockOpnameSubmitted}},
+		{name: "submit with lines left to count", claims: staff, action: "submit", status: models.StockOpnameOpen, uncounted: 2,
+			wantCode: http.StatusConflict},
+		{name: "approve before submitting", claims: staff, action: "approve", status: models.StockOpnameOpen,
+			wantCode: http.StatusConflict},
+		{
+			name: "approve books the variances", claims: staff, action: "approve", status: models.StockOpnameSubmitted,
+			lines: []countedLine{
+				{productID: 1, locationID: 10, counted: 7},
+				{productID: 1, locationID: 11, counted: 1},
+				{productID: 1, locationID: 12, counted: 10},
+			},
+			wantCode:      http.StatusOK,
+			wantStatus:    []string{models.StockOpnameApproved},
+			wantMovements: []string{"IN 2 10/", "OUT 3 11/L2"},
+			wantStock:     []string{"10:2/0", "10:5/0", "11:1/0", "12:10/0"},
+		},
+		{name: "reject", claims: staff, action: "reject", status: models.StockOpnameSubmitted,
+			wantCode: http.StatusOK, wantStatus: []string{models.StockOpnameRejected}},
+		{name: "cancel an open count", claims: staff, action: "cancel", status: models.StockOpnameOpen,
+			wantCode: http.StatusOK, wantStatus: []string{models.StockOpnameCancelled}},
+		{name: "approved counts stay approved", claims: staff, action: "cancel", status: models.StockOpnameApproved,
+			wantCode: http.StatusConflict},
+		{name: "other tenants' counts", claims: auth.Claims{UserID: 7, TenantID: &other}, action: "submit", status: models.StockOpnameOpen,
+			wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger, moves := newFakeOpname(tt.status, tt.uncounted, tt.lines)
+			h := &Handler{DB: ledger.open(t)}
+			handler := map[string]gin.HandlerFunc{
+				"submit": h.SubmitStockOpname, "approve": h.ApproveStockOpname,
+				"reject": h.RejectStockOpname, "cancel": h.CancelStockOpname,
+			}[tt.action]
+
+			w := serveAs(&tt.claims, "/stock-opnames/:id/"+tt.action, handler,
+				jsonRequest(http.MethodPost, "/stock-opnames/1/"+tt.action, ""))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if !reflect.DeepEqual(*moves, tt.wantStatus) {
+				t.Errorf("moved to %v, want %v", *moves, tt.wantStatus)
+			}
+			if ledger.committed != (tt.wantCode == http.StatusOK) {
+				t.Errorf("committed = %v on a %d", ledger.committed, w.Code)
+			}
+			if !reflect.DeepEqual(ledger.movements, tt.wantMovements) {
+				t.Errorf("movements = %v, want %v", ledger.movements, tt.wantMovements)
+			}
+			if tt.wantStock != nil && !reflect.DeepEqual(ledger.stock(), tt.wantStock) {
+				t.Errorf("stock = %v, want %v", ledger.stock(), tt.wantStock)
+			}
+		})
+	}
+}
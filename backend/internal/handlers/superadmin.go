@@ -1,22 +1,23 @@
 package handlers
 
 import (
-	"encoding/json"
+	"database/sql"
 	"net/http"
 	"strconv"
 	"strings"
-	"wms-backend/internal/database"
+
+	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type CreateWarehouseAdminRequest struct {
-	Username    string `json:"username"`
-	Email       string `json:"email"`
-	Password    string `json:"password"`
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	Password      string `json:"password"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
 	WarehouseName string `json:"warehouse_name"`
-	Location    string `json:"location"`
+	Location      string `json:"location"`
 }
 
 type CreateTenantAdminRequest struct {
@@ -29,159 +30,193 @@ type CreateTenantAdminRequest struct {
 }
 
 // Create Warehouse Admin
-func CreateWarehouseAdmin(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateWarehouseAdmin(c *gin.Context) {
 	var req CreateWarehouseAdminRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	if req.Username == "" || req.Password == "" || req.WarehouseName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username, password and warehouse name are required"})
+		return
+	}
+
+	// Hash password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing password"})
 		return
 	}
 
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
 	// Create warehouse first
 	var warehouseID int
-	err := database.DB.QueryRow(`
+	err = tx.QueryRow(`
 		INSERT INTO warehouses (name, location, created_at)
 		VALUES ($1, $2, NOW()) RETURNING id
 	`, req.WarehouseName, req.Location).Scan(&warehouseID)
 
 	if err != nil {
-		http.Error(w, "Error creating warehouse", http.StatusInternalServerError)
-		return
-	}
-
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		http.Error(w, "Error hashing password", http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating warehouse"})
 		return
 	}
 
 	// Create warehouse admin user
 	var userID int
-	err = database.DB.QueryRow(`
+	err = tx.QueryRow(`
 		INSERT INTO auth_user (username, email, password, first_name, last_name, user_type, warehouse_id, roles, is_staff, is_superuser, is_active, date_joined)
 		VALUES ($1, $2, $3, $4, $5, 'warehouse_admin', $6, 'admin', true, false, true, NOW()) RETURNING id
 	`, req.Username, req.Email, string(hashedPassword), req.FirstName, req.LastName, warehouseID).Scan(&userID)
 
 	if err != nil {
-		http.Error(w, "Error creating user", http.StatusInternalServerError)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username or email already exists"})
 		return
 	}
 
 	// Update warehouse manager_id
-	database.DB.Exec("UPDATE warehouses SET manager_id = $1 WHERE id = $2", userID, warehouseID)
+	if _, err := tx.Exec("UPDATE warehouses SET manager_id = $1 WHERE id = $2", userID, warehouseID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error assigning warehouse manager"})
+		return
+	}
 
-	response := map[string]interface{}{
-		"message": "Warehouse admin created successfully",
-		"user_id": userID,
-		"warehouse_id": warehouseID,
-		"warehouse_name": req.WarehouseName,
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        "Warehouse admin created successfully",
+		"user_id":        userID,
+		"warehouse_id":   warehouseID,
+		"warehouse_name": req.WarehouseName,
+	})
 }
 
 // Create Tenant Admin
-func CreateTenantAdmin(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateTenantAdmin(c *gin.Context) {
 	var req CreateTenantAdminRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	if req.Username == "" || req.Password == "" || req.CompanyName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username, password and company name are required"})
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, "Error hashing password", http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing password"})
+		return
+	}
+
+	// Find or create the tenant the admin will manage
+	var tenantID int
+	err = h.DB.QueryRow(`
+		INSERT INTO tenants (name, created_at) VALUES ($1, NOW())
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	`, req.CompanyName).Scan(&tenantID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating tenant"})
 		return
 	}
 
 	// Create tenant admin user
 	var userID int
-	err = database.DB.QueryRow(`
-		INSERT INTO auth_user (username, email, password, first_name, last_name, user_type, company_name, roles, is_staff, is_superuser, is_active, date_joined)
-		VALUES ($1, $2, $3, $4, $5, 'tenant_admin', $6, 'admin', true, false, true, NOW()) RETURNING id
-	`, req.Username, req.Email, string(hashedPassword), req.FirstName, req.LastName, req.CompanyName).Scan(&userID)
+	err = h.DB.QueryRow(`
+		INSERT INTO auth_user (username, email, password, first_name, last_name, user_type, company_name, tenant_id, roles, is_staff, is_superuser, is_active, date_joined)
+		VALUES ($1, $2, $3, $4, $5, 'tenant_admin', $6, $7, 'tenant_admin', true, false, true, NOW()) RETURNING id
+	`, req.Username, req.Email, string(hashedPassword), req.FirstName, req.LastName, req.CompanyName, tenantID).Scan(&userID)
 
 	if err != nil {
-		http.Error(w, "Error creating user", http.StatusInternalServerError)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username or email already exists"})
 		return
 	}
 
-	response := map[string]interface{}{
-		"message": "Tenant admin created successfully",
-		"user_id": userID,
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Tenant admin created successfully",
+		"user_id":      userID,
+		"tenant_id":    tenantID,
 		"company_name": req.CompanyName,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	})
 }
 
 // Get All Admins
-func GetAllAdmins(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query(`
-		SELECT u.id, u.username, u.email, u.first_name, u.last_name, u.user_type, 
-		       u.company_name, u.warehouse_id, w.name as warehouse_name, u.is_active
+func (h *Handler) GetAllAdmins(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		SELECT u.id, u.username, u.email, u.first_name, u.last_name, u.user_type,
+		       u.company_name, u.tenant_id, u.warehouse_id, w.name as warehouse_name, u.is_active
 		FROM auth_user u
 		LEFT JOIN warehouses w ON u.warehouse_id = w.id
 		WHERE u.user_type IN ('warehouse_admin', 'tenant_admin')
 		ORDER BY u.user_type, u.username
 	`)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
 	var admins []map[string]interface{}
 	for rows.Next() {
-		var admin map[string]interface{} = make(map[string]interface{})
 		var id int
 		var username, email, firstName, lastName, userType, companyName string
 		var isActive bool
-		var warehouseIDPtr *int
-		var warehouseNamePtr *string
-
-		rows.Scan(&id, &username, &email, &firstName, &lastName, &userType, &companyName, &warehouseIDPtr, &warehouseNamePtr, &isActive)
-
-		admin["id"] = id
-		admin["username"] = username
-		admin["email"] = email
-		admin["first_name"] = firstName
-		admin["last_name"] = lastName
-		admin["user_type"] = userType
-		admin["company_name"] = companyName
-		admin["is_active"] = isActive
-
-		if warehouseIDPtr != nil {
-			admin["warehouse_id"] = *warehouseIDPtr
+		var tenantID, warehouseID sql.NullInt64
+		var warehouseName sql.NullString
+
+		if err := rows.Scan(&id, &username, &email, &firstName, &lastName, &userType, &companyName, &tenantID, &warehouseID, &warehouseName, &isActive); err != nil {
+			continue
 		}
-		if warehouseNamePtr != nil {
-			admin["warehouse_name"] = *warehouseNamePtr
+
+		admin := map[string]interface{}{
+			"id":           id,
+			"username":     username,
+			"email":        email,
+			"first_name":   firstName,
+			"last_name":    lastName,
+			"user_type":    userType,
+			"company_name": companyName,
+			"is_active":    isActive,
+		}
+		if tenantID.Valid {
+			admin["tenant_id"] = tenantID.Int64
+		}
+		if warehouseID.Valid {
+			admin["warehouse_id"] = warehouseID.Int64
+		}
+		if warehouseName.Valid {
+			admin["warehouse_name"] = warehouseName.String
 		}
 
 		admins = append(admins, admin)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(admins)
+	c.JSON(http.StatusOK, admins)
 }
 
 // Update Admin
-func UpdateAdmin(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/superadmin/admins/")
-	id, err := strconv.Atoi(idStr)
+func (h *Handler) UpdateAdmin(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
 	var req map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
 		return
 	}
 
@@ -190,88 +225,53 @@ func UpdateAdmin(w http.ResponseWriter, r *http.Request) {
 	args := []interface{}{}
 	argIndex := 1
 
-	if email, ok := req["email"]; ok {
-		setParts = append(setParts, "email = $"+strconv.Itoa(argIndex))
-		args = append(args, email)
-		argIndex++
-	}
-	if firstName, ok := req["first_name"]; ok {
-		setParts = append(setParts, "first_name = $"+strconv.Itoa(argIndex))
-		args = append(args, firstName)
-		argIndex++
-	}
-	if lastName, ok := req["last_name"]; ok {
-		setParts = append(setParts, "last_name = $"+strconv.Itoa(argIndex))
-		args = append(args, lastName)
-		argIndex++
-	}
-	if isActive, ok := req["is_active"]; ok {
-		setParts = append(setParts, "is_active = $"+strconv.Itoa(argIndex))
-		args = append(args, isActive)
-		argIndex++
+	for _, field := range []string{"email", "first_name", "last_name", "is_active"} {
+		if value, ok := req[field]; ok {
+			setParts = append(setParts, field+" = $"+strconv.Itoa(argIndex))
+			args = append(args, value)
+			argIndex++
+		}
 	}
 
 	if len(setParts) == 0 {
-		http.Error(w, "No fields to update", http.StatusBadRequest)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
 		return
 	}
 
-	query := "UPDATE auth_user SET " + strings.Join(setParts, ", ") + " WHERE id = $" + strconv.Itoa(argIndex)
+	query := "UPDATE auth_user SET " + strings.Join(setParts, ", ") +
+		" WHERE id = $" + strconv.Itoa(argIndex) + " AND user_type IN ('warehouse_admin', 'tenant_admin')"
 	args = append(args, id)
 
-	_, err = database.DB.Exec(query, args...)
+	result, err := h.DB.Exec(query, args...)
 	if err != nil {
-		http.Error(w, "Error updating admin", http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating admin"})
 		return
 	}
-
-	response := map[string]interface{}{
-		"message": "Admin updated successfully",
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Admin not found"})
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	c.JSON(http.StatusOK, gin.H{"message": "Admin updated successfully"})
 }
 
 // Delete Admin
-func DeleteAdmin(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/superadmin/admins/")
-	id, err := strconv.Atoi(idStr)
+func (h *Handler) DeleteAdmin(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	_, err = database.DB.Exec("UPDATE auth_user SET is_active = false WHERE id = $1", id)
+	result, err := h.DB.Exec("UPDATE auth_user SET is_active = false WHERE id = $1 AND user_type IN ('warehouse_admin', 'tenant_admin')", id)
 	if err != nil {
-		http.Error(w, "Error deleting admin", http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting admin"})
 		return
 	}
-
-	response := map[string]interface{}{
-		"message": "Admin deleted successfully",
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Admin not found"})
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	c.JSON(http.StatusOK, gin.H{"message": "Admin deleted successfully"})
 }
-
-// Route handlers
-func SuperAdminHandler(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/superadmin/")
-	
-	switch {
-	case path == "warehouse-admins" && r.Method == "POST":
-		CreateWarehouseAdmin(w, r)
-	case path == "tenant-admins" && r.Method == "POST":
-		CreateTenantAdmin(w, r)
-	case path == "admins" && r.Method == "GET":
-		GetAllAdmins(w, r)
-	case strings.HasPrefix(path, "admins/") && r.Method == "PUT":
-		UpdateAdmin(w, r)
-	case strings.HasPrefix(path, "admins/") && r.Method == "DELETE":
-		DeleteAdmin(w, r)
-	default:
-		http.Error(w, "Not found", http.StatusNotFound)
-	}
-}
\ No newline at end of file
@@ -1,302 +1,249 @@
 package handlers
 
 import (
-	"encoding/json"
+	"database/sql"
 	"net/http"
-	"strings"
-	"wms-backend/internal/database"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
 )
 
 // Inbound Requests
-func GetInboundRequests(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query(`
-		SELECT ir.id, ir.product_id, p.name as product_name, ir.quantity, 
+func (h *Handler) GetInboundRequests(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		SELECT ir.id, ir.product_id, p.name as product_name, ir.quantity,
 		       ir.supplier, ir.status, ir.notes, ir.created_at
 		FROM inbound_requests ir
 		JOIN warehouse_product p ON ir.product_id = p.id
+		WHERE ($1::int IS NULL OR ir.tenant_id = $1)
 		ORDER BY ir.created_at DESC
-	`)
+	`, middleware.TenantID(c))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
 	var requests []map[string]interface{}
 	for rows.Next() {
-		var req map[string]interface{} = make(map[string]interface{})
 		var id, productId, quantity int
 		var productName, supplier, status, notes, createdAt string
-		
-		rows.Scan(&id, &productId, &productName, &quantity, &supplier, &status, &notes, &createdAt)
-		
-		req["id"] = id
-		req["product_id"] = productId
-		req["product_name"] = productName
-		req["quantity"] = quantity
-		req["supplier"] = supplier
-		req["status"] = status
-		req["notes"] = notes
-		req["created_at"] = createdAt
-		
-		requests = append(requests, req)
+
+		if err := rows.Scan(&id, &productId, &productName, &quantity, &supplier, &status, &notes, &createdAt); err != nil {
+			continue
+		}
+
+		requests = append(requests, map[string]interface{}{
+			"id":           id,
+			"product_id":   productId,
+			"product_name": productName,
+			"quantity":     quantity,
+			"supplier":     supplier,
+			"status":       status,
+			"notes":        notes,
+			"created_at":   createdAt,
+		})
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(requests)
+	c.JSON(http.StatusOK, requests)
 }
 
-func CreateInboundRequest(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateInboundRequest(c *gin.Context) {
 	var req struct {
-		ProductID int    `json:"product_id"`
-		Quantity  int    `json:"quantity"`
-		Supplier  string `json:"supplier"`
+		ProductID int    `json:"product_id" binding:"required"`
+		Quantity  int    `json:"quantity" binding:"required,min=1"`
+		Supplier  string `json:"supplier" binding:"required"`
 		Notes     string `json:"notes"`
 	}
-	
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if ok, err := h.belongsToTenant(c, "warehouse_product", req.ProductID); err != nil || !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown product"})
 		return
 	}
 
 	var id int
-	err := database.DB.QueryRow(`
-		INSERT INTO inbound_requests (product_id, quantity, supplier, status, notes, created_at)
-		VALUES ($1, $2, $3, 'pending', $4, NOW()) RETURNING id
-	`, req.ProductID, req.Quantity, req.Supplier, req.Notes).Scan(&id)
+	err := h.DB.QueryRow(`
+		INSERT INTO inbound_requests (product_id, quantity, supplier, status, notes, tenant_id, created_at)
+		VALUES ($1, $2, $3, 'pending', $4, $5, NOW()) RETURNING id
+	`, req.ProductID, req.Quantity, req.Supplier, req.Notes, middleware.TenantID(c)).Scan(&id)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	response := map[string]interface{}{
+	c.JSON(http.StatusCreated, gin.H{
 		"id":      id,
 		"message": "Inbound request created successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	})
 }
 
 // Outbound Requests
-func GetOutboundRequests(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query(`
-		SELECT or.id, or.product_id, p.name as product_name, or.quantity, 
-		       or.destination, or.status, or.notes, or.created_at
-		FROM outbound_requests or
-		JOIN warehouse_product p ON or.product_id = p.id
-		ORDER BY or.created_at DESC
-	`)
+func (h *Handler) GetOutboundRequests(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		SELECT obr.id, obr.product_id, p.name as product_name, obr.quantity,
+		       obr.destination, obr.status, obr.notes, obr.created_at, obr.warehouse_id,
+		       COALESCE((SELECT SUM(r.quantity) FROM stock_reservations r
+		                 WHERE r.reference_type = $2 AND r.reference_id = obr.id AND r.status = $3), 0)
+		FROM outbound_requests obr
+		JOIN warehouse_product p ON obr.product_id = p.id
+		WHERE ($1::int IS NULL OR obr.tenant_id = $1)
+		ORDER BY obr.created_at DESC
+	`, middleware.TenantID(c), models.RefOutbound, models.ReservationActive)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
 	var requests []map[string]interface{}
 	for rows.Next() {
-		var req map[string]interface{} = make(map[string]interface{})
-		var id, productId, quantity int
+		var id, productId, quantity, reserved int
 		var productName, destination, status, notes, createdAt string
-		
-		rows.Scan(&id, &productId, &productName, &quantity, &destination, &status, &notes, &createdAt)
-		
-		req["id"] = id
-		req["product_id"] = productId
-		req["product_name"] = productName
-		req["quantity"] = quantity
-		req["destination"] = destination
-		req["status"] = status
-		req["notes"] = notes
-		req["created_at"] = createdAt
-		
-		requests = append(requests, req)
-	}
+		var warehouseID sql.NullInt64
+
+		if err := rows.Scan(&id, &productId, &productName, &quantity, &destination, &status, &notes, &createdAt,
+			&warehouseID, &reserved); err != nil {
+			continue
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(requests)
+		requests = append(requests, map[string]interface{}{
+			"id":           id,
+			"product_id":   productId,
+			"product_name": productName,
+			"quantity":     quantity,
+			"destination":  destination,
+			"status":       status,
+			"notes":        notes,
+			"created_at":   createdAt,
+			"warehouse_id": nullableInt(warehouseID),
+			"reserved":     reserved,
+		})
+	}
+
+	c.JSON(http.StatusOK, requests)
 }
 
-func CreateOutboundRequest(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateOutboundRequest(c *gin.Context) {
 	var req struct {
-		ProductID   int    `json:"product_id"`
-		Quantity    int    `json:"quantity"`
-		Destination string `json:"destination"`
+		ProductID   int    `json:"product_id" binding:"required"`
+		Quantity    int    `json:"quantity" binding:"required,min=1"`
+		Destination string `json:"destination" binding:"required"`
 		Notes       string `json:"notes"`
 	}
-	
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
-		return
-	}
 
-	var id int
-	err := database.DB.QueryRow(`
-		INSERT INTO outbound_requests (product_id, quantity, destination, status, notes, created_at)
-		VALUES ($1, $2, $3, 'pending', $4, NOW()) RETURNING id
-	`, req.ProductID, req.Quantity, req.Destination, req.Notes).Scan(&id)
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	response := map[string]interface{}{
-		"id":      id,
-		"message": "Outbound request created successfully",
+	if ok, err := h.belongsToTenant(c, "warehouse_product", req.ProductID); err != nil || !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown product"})
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
-}
+	var id int
+	err := h.DB.QueryRow(`
+		INSERT INTO outbound_requests (product_id, quantity, destination, status, notes, tenant_id, created_at)
+		VALUES ($1, $2, $3, 'pending', $4, $5, NOW()) RETURNING id
+	`, req.ProductID, req.Quantity, req.Destination, req.Notes, middleware.TenantID(c)).Scan(&id)
 
-// Orders
-func GetOrders(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query(`
-		SELECT id, order_number, customer, status, total_amount, created_at
-		FROM orders ORDER BY created_at DESC
-	`)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
-
-	var orders []map[string]interface{}
-	for rows.Next() {
-		var order map[string]interface{} = make(map[string]interface{})
-		var id int
-		var orderNumber, customer, status, createdAt string
-		var totalAmount float64
-		
-		rows.Scan(&id, &orderNumber, &customer, &status, &totalAmount, &createdAt)
-		
-		order["id"] = id
-		order["order_number"] = orderNumber
-		order["customer"] = customer
-		order["status"] = status
-		order["total_amount"] = totalAmount
-		order["created_at"] = createdAt
-		
-		orders = append(orders, order)
-	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(orders)
+	c.JSON(http.StatusCreated, gin.H{
+		"id":      id,
+		"message": "Outbound request created successfully",
+	})
 }
 
 // Reports
-func GetStockReport(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query(`
-		SELECT p.id, p.name, p.sku, COALESCE(i.quantity, 0) as stock_level,
-		       COALESCE(i.min_stock, 0) as min_stock
+func (h *Handler) GetStockReport(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		SELECT p.id, p.name, p.sku, COALESCE(SUM(i.quantity), 0) as stock_level,
+		       COALESCE(MAX(i.min_stock), 0) as min_stock
 		FROM warehouse_product p
 		LEFT JOIN inventory i ON p.id = i.product_id
+		     AND ($2::int IS NULL OR i.location_id IN (SELECT id FROM locations WHERE warehouse_id = $2))
+		WHERE ($1::int IS NULL OR p.tenant_id = $1)
+		GROUP BY p.id, p.name, p.sku
 		ORDER BY p.name
-	`)
+	`, middleware.TenantID(c), middleware.WarehouseID(c))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
 	var report []map[string]interface{}
 	for rows.Next() {
-		var item map[string]interface{} = make(map[string]interface{})
 		var id, stockLevel, minStock int
 		var name, sku string
-		
-		rows.Scan(&id, &name, &sku, &stockLevel, &minStock)
-		
-		item["id"] = id
-		item["name"] = name
-		item["sku"] = sku
-		item["stock_level"] = stockLevel
-		item["min_stock"] = minStock
-		item["status"] = "normal"
+
+		if err := rows.Scan(&id, &name, &sku, &stockLevel, &minStock); err != nil {
+			continue
+		}
+
+		status := "normal"
 		if stockLevel <= minStock {
-			item["status"] = "low"
+			status = "low"
 		}
-		
-		report = append(report, item)
+
+		report = append(report, map[string]interface{}{
+			"id":          id,
+			"name":        name,
+			"sku":         sku,
+			"stock_level": stockLevel,
+			"min_stock":   minStock,
+			"status":      status,
+		})
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(report)
+	c.JSON(http.StatusOK, report)
 }
 
-func GetTransactionReport(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query(`
+func (h *Handler) GetTransactionReport(c *gin.Context) {
+	rows, err := h.DB.Query(`
 		SELECT sm.id, p.name as product_name, sm.movement_type, sm.quantity,
 		       sm.reference, sm.created_at
 		FROM stock_movements sm
 		JOIN warehouse_product p ON sm.product_id = p.id
+		WHERE ($1::int IS NULL OR sm.tenant_id = $1)
 		ORDER BY sm.created_at DESC
 		LIMIT 100
-	`)
+	`, middleware.TenantID(c))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
 	var transactions []map[string]interface{}
 	for rows.Next() {
-		var txn map[string]interface{} = make(map[string]interface{})
 		var id, quantity int
 		var productName, movementType, reference, createdAt string
-		
-		rows.Scan(&id, &productName, &movementType, &quantity, &reference, &createdAt)
-		
-		txn["id"] = id
-		txn["product_name"] = productName
-		txn["movement_type"] = movementType
-		txn["quantity"] = quantity
-		txn["reference"] = reference
-		txn["created_at"] = createdAt
-		
-		transactions = append(transactions, txn)
-	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(transactions)
-}
+		if err := rows.Scan(&id, &productName, &movementType, &quantity, &reference, &createdAt); err != nil {
+			continue
+		}
 
-// Route handlers
-func InboundRequestsHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		GetInboundRequests(w, r)
-	case "POST":
-		CreateInboundRequest(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		transactions = append(transactions, map[string]interface{}{
+			"id":            id,
+			"product_name":  productName,
+			"movement_type": movementType,
+			"quantity":      quantity,
+			"reference":     reference,
+			"created_at":    createdAt,
+		})
 	}
-}
 
-func OutboundRequestsHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		GetOutboundRequests(w, r)
-	case "POST":
-		CreateOutboundRequest(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
+	c.JSON(http.StatusOK, transactions)
 }
-
-func ReportsHandler(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/reports/")
-	
-	switch path {
-	case "stock":
-		GetStockReport(w, r)
-	case "transactions":
-		GetTransactionReport(w, r)
-	default:
-		http.Error(w, "Report not found", http.StatusNotFound)
-	}
-}
\ No newline at end of file
@@ -1,12 +1,10 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
-	"wms-backend/internal/database"
+
+	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
-	"fmt"
-	"time"
 )
 
 type TenantRegisterRequest struct {
@@ -23,105 +21,122 @@ type TenantLoginRequest struct {
 	Password string `json:"password"`
 }
 
-func TenantRegister(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) TenantRegisterGin(c *gin.Context) {
 	var req TenantRegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
 		return
 	}
 
 	if req.Username == "" || req.Password == "" || req.Email == "" || req.CompanyName == "" {
-		http.Error(w, "Username, password, email, and company name are required", http.StatusBadRequest)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username, password, email, and company name are required"})
 		return
 	}
 
 	// Check if username exists
 	var count int
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM auth_user WHERE username = $1", req.Username).Scan(&count)
+	err := h.DB.QueryRow("SELECT COUNT(*) FROM auth_user WHERE username = $1", req.Username).Scan(&count)
 	if err != nil || count > 0 {
-		http.Error(w, "Username already exists", http.StatusConflict)
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, "Error hashing password", http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing password"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Each company gets its own tenant; registering into an existing one is not allowed
+	var tenantID int
+	err = tx.QueryRow(`INSERT INTO tenants (name, created_at) VALUES ($1, NOW()) RETURNING id`, req.CompanyName).Scan(&tenantID)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Company already registered"})
 		return
 	}
 
 	// Insert tenant user
 	var userID int
-	err = database.DB.QueryRow(`
-		INSERT INTO auth_user (username, email, password, first_name, last_name, user_type, company_name, roles, is_staff, is_superuser, is_active, date_joined) 
-		VALUES ($1, $2, $3, $4, $5, 'tenant', $6, 'tenant', false, false, true, NOW()) RETURNING id
-	`, req.Username, req.Email, string(hashedPassword), req.FirstName, req.LastName, req.CompanyName).Scan(&userID)
+	err = tx.QueryRow(`
+		INSERT INTO auth_user (username, email, password, first_name, last_name, user_type, company_name, tenant_id, roles, is_staff, is_superuser, is_active, date_joined) 
+		VALUES ($1, $2, $3, $4, $5, 'tenant', $6, $7, 'tenant', false, false, true, NOW()) RETURNING id
+	`, req.Username, req.Email, string(hashedPassword), req.FirstName, req.LastName, req.CompanyName, tenantID).Scan(&userID)
 
 	if err != nil {
-		http.Error(w, "Error creating user: "+err.Error(), http.StatusInternalServerError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating user"})
 		return
 	}
 
-	response := map[string]interface{}{
-		"message": "Tenant registered successfully",
-		"user_id": userID,
-		"user_type": "tenant",
-		"company_name": req.CompanyName,
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Tenant registered successfully",
+		"user_id":      userID,
+		"tenant_id":    tenantID,
+		"user_type":    "tenant",
+		"company_name": req.CompanyName,
+	})
 }
 
-func TenantLogin(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) TenantLoginGin(c *gin.Context) {
 	var req TenantLoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
 		return
 	}
 
 	if req.Username == "" || req.Password == "" {
-		http.Error(w, "Username and password are required", http.StatusBadRequest)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username and password are required"})
 		return
 	}
 
 	// Get user from database
 	var userID int
 	var hashedPassword, userType, companyName, firstName, lastName string
-	err := database.DB.QueryRow(`
+	err := h.DB.QueryRow(`
 		SELECT id, password, user_type, company_name, first_name, last_name 
 		FROM auth_user 
 		WHERE username = $1 AND user_type = 'tenant' AND is_active = true
 	`, req.Username).Scan(&userID, &hashedPassword, &userType, &companyName, &firstName, &lastName)
 
 	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password)); err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate token
-	token := fmt.Sprintf("tenant_%d_%d", userID, time.Now().Unix())
+	tokens, err := h.issueTokens(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not issue token"})
+		return
+	}
 
-	response := map[string]interface{}{
-		"access": token,
-		"refresh": token,
-		"user": map[string]interface{}{
-			"id": userID,
-			"username": req.Username,
-			"user_type": userType,
+	c.JSON(http.StatusOK, gin.H{
+		"access":  tokens.Access,
+		"refresh": tokens.Refresh,
+		"user": gin.H{
+			"id":           userID,
+			"username":     req.Username,
+			"user_type":    userType,
 			"company_name": companyName,
-			"first_name": firstName,
-			"last_name": lastName,
+			"first_name":   firstName,
+			"last_name":    lastName,
 		},
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+	})
+}
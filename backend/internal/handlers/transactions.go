@@ -1,15 +1,21 @@
 package handlers
 
 import (
-	"fmt"
+	"database/sql"
 	"net/http"
 	"time"
+	"wms-backend/internal/middleware"
 	"wms-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 // Receiving Handlers
+
+// CreateReceiving books a single-line goods receipt straight to stock, the
+// way the receiving screen always has: the line is accepted and posted to
+// the given location in the same transaction. Receipts posted to a dock
+// come back with the put-away tasks they queued.
 func (h *Handler) CreateReceiving(c *gin.Context) {
 	var req models.ReceivingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -17,14 +23,24 @@ func (h *Handler) CreateReceiving(c *gin.Context) {
 		return
 	}
 
-	// Generate document number
-	docNumber := fmt.Sprintf("RCV-%d", time.Now().Unix())
-
-	// Parse date
-	receiveDate, err := time.Parse("2006-01-02", req.ReceiveDate)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
-		return
+	userID := middleware.CurrentUserID(c)
+
+	// Without a location the goods land on the site's receiving dock and
+	// are put away from there
+	if req.LocationID == 0 {
+		err := h.DB.QueryRow(`
+			SELECT id FROM locations
+			WHERE location_type = $1 AND is_active AND ($2::int IS NULL OR warehouse_id = $2)
+			ORDER BY pick_sequence NULLS LAST, code
+			LIMIT 1`, models.LocationDock, middleware.WarehouseID(c)).Scan(&req.LocationID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "location_id is required when the warehouse has no receiving dock"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up receiving dock"})
+			return
+		}
 	}
 
 	// Begin transaction
@@ -35,40 +51,37 @@ func (h *Handler) CreateReceiving(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	// Insert receiving record
-	var receivingID int
-	err = tx.QueryRow(`
-		INSERT INTO receiving (document_number, receive_date, supplier_id, product_id, quantity, unit_id, location_id, remarks, created_by)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 1)
-		RETURNING id
-	`, docNumber, receiveDate, req.SupplierID, req.ProductID, req.Quantity, req.UnitID, req.LocationID, req.Remarks).Scan(&receivingID)
-	
+	r, err := h.insertGoodsReceipt(tx, c, models.GoodsReceiptRequest{
+		ReceiptDate: req.ReceiveDate,
+		SupplierID:  &req.SupplierID,
+		Notes:       req.Remarks,
+		Lines: []models.GoodsReceiptLineRequest{{
+			ProductID:  &req.ProductID,
+			Quantity:   req.Quantity,
+			UnitID:     &req.UnitID,
+			LocationID: &req.LocationID,
+		}},
+	}, models.SourceReceiving)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create receiving record"})
+		writeGoodsReceiptError(c, err)
 		return
 	}
 
-	// Update inventory
-	_, err = tx.Exec(`
-		INSERT INTO inventory (product_id, quantity, location_id, updated_at)
-		VALUES ($1, $2, $3, NOW())
-		ON CONFLICT (product_id, location_id)
-		DO UPDATE SET quantity = inventory.quantity + $2, updated_at = NOW()
-	`, req.ProductID, req.Quantity, req.LocationID)
-	
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
+	if err := advanceGoodsReceipt(tx, r, models.GoodsReceiptReceived, userID); err != nil {
+		writeGoodsReceiptError(c, err)
 		return
 	}
-
-	// Insert stock movement
-	_, err = tx.Exec(`
-		INSERT INTO stock_movements (product_id, movement_type, quantity, reference, created_at)
-		VALUES ($1, 'IN', $2, $3, NOW())
-	`, req.ProductID, req.Quantity, docNumber)
-	
+	if err := setGoodsReceiptLineQC(tx, r, r.Lines[0].ID, models.QCAccepted, "", userID); err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+	if err := advanceGoodsReceipt(tx, r, models.GoodsReceiptCompleted, userID); err != nil {
+		writeGoodsReceiptError(c, err)
+		return
+	}
+	tasks, err := receiptPutawayTasks(tx, r.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record stock movement"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch put-away tasks"})
 		return
 	}
 
@@ -78,23 +91,26 @@ func (h *Handler) CreateReceiving(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Receiving created successfully",
-		"id": receivingID,
-		"document_number": docNumber,
+		"message":         "Receiving created successfully",
+		"id":              r.ID,
+		"document_number": r.DocumentNumber,
+		"putaway_tasks":   tasks,
 	})
 }
 
 func (h *Handler) GetReceivings(c *gin.Context) {
 	rows, err := h.DB.Query(`
-		SELECT r.id, r.document_number, r.receive_date, r.quantity, r.remarks, r.created_at,
-			   s.name as supplier_name, p.name as product_name, u.symbol as unit_symbol, l.name as location_name
-		FROM receiving r
-		JOIN suppliers s ON r.supplier_id = s.id
-		JOIN warehouse_product p ON r.product_id = p.id
-		JOIN units u ON r.unit_id = u.id
-		JOIN locations l ON r.location_id = l.id
+		SELECT r.id, r.document_number, r.receipt_date, l.quantity, r.warehouse_id, r.notes, r.created_at,
+			   COALESCE(s.name, r.supplier_name), l.product_name, COALESCE(u.symbol, l.unit_name), COALESCE(loc.name, l.location_name)
+		FROM goods_receipts r
+		JOIN goods_receipt_lines l ON l.receipt_id = r.id
+		LEFT JOIN suppliers s ON r.supplier_id = s.id
+		LEFT JOIN units u ON l.unit_id = u.id
+		LEFT JOIN locations loc ON l.location_id = loc.id
+		WHERE r.source = $1
+		  AND ($2::int IS NULL OR r.tenant_id = $2) AND ($3::int IS NULL OR r.warehouse_id = $3)
 		ORDER BY r.created_at DESC
-	`)
+	`, models.SourceReceiving, middleware.TenantID(c), middleware.WarehouseID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receivings"})
 		return
@@ -104,7 +120,7 @@ func (h *Handler) GetReceivings(c *gin.Context) {
 	var receivings []models.Receiving
 	for rows.Next() {
 		var r models.Receiving
-		err := rows.Scan(&r.ID, &r.DocumentNumber, &r.ReceiveDate, &r.Quantity, &r.Remarks, &r.CreatedAt,
+		err := rows.Scan(&r.ID, &r.DocumentNumber, &r.ReceiveDate, &r.Quantity, &r.WarehouseID, &r.Remarks, &r.CreatedAt,
 			&r.SupplierName, &r.ProductName, &r.UnitSymbol, &r.LocationName)
 		if err != nil {
 			continue
@@ -123,25 +139,31 @@ func (h *Handler) CreateIssuing(c *gin.Context) {
 		return
 	}
 
-	// Check stock availability
-	var currentStock int
-	err := h.DB.QueryRow(`
-		SELECT COALESCE(SUM(quantity), 0) FROM inventory 
-		WHERE product_id = $1 AND location_id = $2
-	`, req.ProductID, req.LocationID).Scan(&currentStock)
-	
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check stock"})
+	if ok, err := h.belongsToTenant(c, "warehouse_product", req.ProductID); err != nil || !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown product"})
 		return
 	}
-
-	if currentStock < req.Quantity {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient stock"})
+	if ok, err := h.belongsToTenant(c, "customers", req.CustomerID); err != nil || !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown customer"})
 		return
 	}
+	// Without a location the stock is allocated across the selected
+	// warehouse, or every warehouse when none is selected
+	scope := allocationScope{WarehouseID: middleware.WarehouseID(c), LocationID: req.LocationID, Batch: req.Batch, Lock: true}
+	if req.LocationID != 0 {
+		warehouseID, err := h.locationWarehouse(c, req.LocationID)
+		if err == errLocationNotAccessible {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown location"})
+			return
+		}
+		scope.WarehouseID = warehouseID
+	}
 
-	// Generate document number
-	docNumber := fmt.Sprintf("ISS-%d", time.Now().Unix())
+	userID := middleware.CurrentUserID(c)
 
 	// Parse date
 	issueDate, err := time.Parse("2006-01-02", req.IssueDate)
@@ -158,38 +180,51 @@ func (h *Handler) CreateIssuing(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	// Insert issuing record
-	var issuingID int
-	err = tx.QueryRow(`
-		INSERT INTO issuing (document_number, issue_date, customer_id, product_id, quantity, unit_id, location_id, remarks, created_by)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 1)
-		RETURNING id
-	`, docNumber, issueDate, req.CustomerID, req.ProductID, req.Quantity, req.UnitID, req.LocationID, req.Remarks).Scan(&issuingID)
-	
+	var docNumber string
+	if err := tx.QueryRow(`SELECT 'ISS-' || LPAD(nextval('issuing_number_seq')::TEXT, 6, '0')`).Scan(&docNumber); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to number issuing"})
+		return
+	}
+
+	plan, err := planAllocation(tx, req.ProductID, req.Quantity, scope)
+	if err != nil {
+		writeAllocationError(c, err)
+		return
+	}
+	if plan.Short > 0 {
+		writeInsufficientStock(c, plan)
+		return
+	}
+
+	// The issuing belongs to the product's tenant when staff issue for one
+	tenantID, err := ownerTenant(c, tx, "warehouse_product", req.ProductID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create issuing record"})
 		return
 	}
 
-	// Update inventory
-	_, err = tx.Exec(`
-		UPDATE inventory SET quantity = quantity - $1, updated_at = NOW()
-		WHERE product_id = $2 AND location_id = $3
-	`, req.Quantity, req.ProductID, req.LocationID)
+	// Insert issuing record against the first location picked from
+	locationID, warehouseID := plan.Lines[0].LocationID, plan.Lines[0].WarehouseID
+	var issuingID int
+	err = tx.QueryRow(`
+		INSERT INTO issuing (document_number, issue_date, customer_id, product_id, quantity, unit_id, location_id, warehouse_id, remarks, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`, docNumber, issueDate, req.CustomerID, req.ProductID, req.Quantity, req.UnitID, locationID, warehouseID, req.Remarks, userID, tenantID).Scan(&issuingID)
 	
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create issuing record"})
 		return
 	}
 
-	// Insert stock movement
-	_, err = tx.Exec(`
-		INSERT INTO stock_movements (product_id, movement_type, quantity, reference, created_at)
-		VALUES ($1, 'OUT', $2, $3, NOW())
-	`, req.ProductID, req.Quantity, docNumber)
-	
+	err = postAllocation(tx, plan, stockMovement{
+		Reference:     docNumber,
+		ReferenceType: models.RefIssuing,
+		UserID:        userID,
+		TenantID:      tenantID,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record stock movement"})
+		writeAllocationError(c, err)
 		return
 	}
 
@@ -202,20 +237,22 @@ func (h *Handler) CreateIssuing(c *gin.Context) {
 		"message": "Issuing created successfully",
 		"id": issuingID,
 		"document_number": docNumber,
+		"allocation": plan,
 	})
 }
 
 func (h *Handler) GetIssuings(c *gin.Context) {
 	rows, err := h.DB.Query(`
-		SELECT i.id, i.document_number, i.issue_date, i.quantity, i.remarks, i.created_at,
+		SELECT i.id, i.document_number, i.issue_date, i.quantity, i.warehouse_id, i.remarks, i.created_at,
 			   c.name as customer_name, p.name as product_name, u.symbol as unit_symbol, l.name as location_name
 		FROM issuing i
 		JOIN customers c ON i.customer_id = c.id
 		JOIN warehouse_product p ON i.product_id = p.id
 		JOIN units u ON i.unit_id = u.id
 		JOIN locations l ON i.location_id = l.id
+		WHERE ($1::int IS NULL OR i.tenant_id = $1) AND ($2::int IS NULL OR i.warehouse_id = $2)
 		ORDER BY i.created_at DESC
-	`)
+	`, middleware.TenantID(c), middleware.WarehouseID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch issuings"})
 		return
@@ -225,7 +262,7 @@ func (h *Handler) GetIssuings(c *gin.Context) {
 	var issuings []models.Issuing
 	for rows.Next() {
 		var i models.Issuing
-		err := rows.Scan(&i.ID, &i.DocumentNumber, &i.IssueDate, &i.Quantity, &i.Remarks, &i.CreatedAt,
+		err := rows.Scan(&i.ID, &i.DocumentNumber, &i.IssueDate, &i.Quantity, &i.WarehouseID, &i.Remarks, &i.CreatedAt,
 			&i.CustomerName, &i.ProductName, &i.UnitSymbol, &i.LocationName)
 		if err != nil {
 			continue
@@ -238,7 +275,7 @@ func (h *Handler) GetIssuings(c *gin.Context) {
 
 // Master Data Handlers
 func (h *Handler) GetSuppliers(c *gin.Context) {
-	rows, err := h.DB.Query("SELECT id, name, contact_person, phone, email FROM suppliers ORDER BY name")
+	rows, err := h.DB.Query("SELECT id, name, contact_person, phone, email FROM suppliers WHERE ($1::int IS NULL OR tenant_id = $1) ORDER BY name", middleware.TenantID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch suppliers"})
 		return
@@ -259,7 +296,7 @@ func (h *Handler) GetSuppliers(c *gin.Context) {
 }
 
 func (h *Handler) GetCustomers(c *gin.Context) {
-	rows, err := h.DB.Query("SELECT id, name, contact_person, phone, email FROM customers ORDER BY name")
+	rows, err := h.DB.Query("SELECT id, name, contact_person, phone, email FROM customers WHERE ($1::int IS NULL OR tenant_id = $1) ORDER BY name", middleware.TenantID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch customers"})
 		return
@@ -298,25 +335,4 @@ func (h *Handler) GetUnits(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{"data": units})
-}
-
-func (h *Handler) GetLocations(c *gin.Context) {
-	rows, err := h.DB.Query("SELECT id, name, code, description FROM locations ORDER BY name")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch locations"})
-		return
-	}
-	defer rows.Close()
-
-	var locations []models.Location
-	for rows.Next() {
-		var l models.Location
-		err := rows.Scan(&l.ID, &l.Name, &l.Code, &l.Description)
-		if err != nil {
-			continue
-		}
-		locations = append(locations, l)
-	}
-
-	c.JSON(http.StatusOK, gin.H{"data": locations})
 }
\ No newline at end of file
@@ -0,0 +1,484 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errTransferNotFound    = errors.New("transfer not found")
+	errTransferNotEditable = errors.New("transfer cannot be changed in its current status")
+	errInsufficientStock   = errors.New("insufficient stock")
+)
+
+const transferColumns = `id, document_number, from_warehouse_id, to_warehouse_id, mode, status, notes,
+	created_by, sent_by, sent_at, received_by, received_at, tenant_id, created_at, updated_at`
+
+func (h *Handler) GetTransfers(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		SELECT `+transferColumns+` FROM transfers
+		WHERE ($1::int IS NULL OR tenant_id = $1)
+		  AND ($2::int IS NULL OR from_warehouse_id = $2 OR to_warehouse_id = $2)
+		  AND ($3 = '' OR status = $3)
+		ORDER BY created_at DESC`,
+		middleware.TenantID(c), middleware.WarehouseID(c), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transfers"})
+		return
+	}
+	defer rows.Close()
+
+	var transfers []models.Transfer
+	for rows.Next() {
+		t, err := scanTransfer(rows)
+		if err != nil {
+			continue
+		}
+		transfers = append(transfers, *t)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": transfers})
+}
+
+func (h *Handler) GetTransfer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	t, err := scanTransfer(h.DB.QueryRow(`
+		SELECT `+transferColumns+` FROM transfers
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2)
+		  AND ($3::int IS NULL OR from_warehouse_id = $3 OR to_warehouse_id = $3)`,
+		id, middleware.TenantID(c), middleware.WarehouseID(c)))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transfer"})
+		return
+	}
+
+	if t.Lines, err = transferLines(h.DB, t.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transfer lines"})
+		return
+	}
+
+	c.JSON(http.StatusOK, t)
+}
+
+// GetInTransitStock sums what has been sent but not yet received, per
+// product and destination warehouse.
+func (h *Handler) GetInTransitStock(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		SELECT l.product_id, p.name, p.sku, t.to_warehouse_id, SUM(l.quantity)
+		FROM transfer_lines l
+		JOIN transfers t ON l.transfer_id = t.id
+		JOIN warehouse_product p ON l.product_id = p.id
+		WHERE t.status = 'in_transit'
+		  AND ($1::int IS NULL OR t.tenant_id = $1)
+		  AND ($2::int IS NULL OR t.from_warehouse_id = $2 OR t.to_warehouse_id = $2)
+		GROUP BY l.product_id, p.name, p.sku, t.to_warehouse_id
+		ORDER BY p.name`, middleware.TenantID(c), middleware.WarehouseID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch in-transit stock"})
+		return
+	}
+	defer rows.Close()
+
+	var stock []map[string]interface{}
+	for rows.Next() {
+		var productID, quantity int
+		var name, sku string
+		var toWarehouseID sql.NullInt64
+		if err := rows.Scan(&productID, &name, &sku, &toWarehouseID, &quantity); err != nil {
+			continue
+		}
+		stock = append(stock, map[string]interface{}{
+			"product_id":      productID,
+			"product_name":    name,
+			"sku":             sku,
+			"to_warehouse_id": nullableInt(toWarehouseID),
+			"quantity":        quantity,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": stock})
+}
+
+// CreateTransfer records a transfer. All source locations must share one
+// warehouse, as must all destinations. Within a warehouse the transfer is
+// booked immediately unless mode is two_step; between warehouses it always
+// waits to be sent and received.
+func (h *Handler) CreateTransfer(c *gin.Context) {
+	var req models.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var fromWarehouse, toWarehouse *int
+	for i, line := range req.Lines {
+		if line.FromLocationID == line.ToLocationID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Line %d moves stock to its own location", i+1)})
+			return
+		}
+		if ok, err := h.belongsToTenant(c, "warehouse_product", line.ProductID); err != nil || !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Line %d: unknown product", i+1)})
+			return
+		}
+
+		// The sender must work at the source; the destination may be any site
+		from, err := h.locationWarehouse(c, line.FromLocationID)
+		if err == errLocationNotAccessible {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Line %d: unknown source location", i+1)})
+			return
+		}
+		var to sql.NullInt64
+		if err := h.DB.QueryRow(`SELECT warehouse_id FROM locations WHERE id = $1`, line.ToLocationID).Scan(&to); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Line %d: unknown destination location", i+1)})
+			return
+		}
+
+		if i == 0 {
+			fromWarehouse, toWarehouse = from, nullableInt(to)
+		} else if !sameWarehouse(fromWarehouse, from) || !sameWarehouse(toWarehouse, nullableInt(to)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "All lines must share one source and one destination warehouse"})
+			return
+		}
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = models.TransferDirect
+		if !sameWarehouse(fromWarehouse, toWarehouse) {
+			mode = models.TransferTwoStep
+		}
+	}
+	if mode != models.TransferDirect && mode != models.TransferTwoStep {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be direct or two_step"})
+		return
+	}
+	if mode == models.TransferDirect && !sameWarehouse(fromWarehouse, toWarehouse) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transfers between warehouses must be sent and received"})
+		return
+	}
+
+	userID := middleware.CurrentUserID(c)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	t, err := scanTransfer(tx.QueryRow(`
+		INSERT INTO transfers (from_warehouse_id, to_warehouse_id, mode, notes, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+transferColumns,
+		fromWarehouse, toWarehouse, mode, req.Notes, userID, middleware.TenantID(c)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transfer"})
+		return
+	}
+
+	for _, line := range req.Lines {
+		_, err := tx.Exec(`
+			INSERT INTO transfer_lines (transfer_id, product_id, batch, from_location_id, to_location_id, quantity)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			t.ID, line.ProductID, line.Batch, line.FromLocationID, line.ToLocationID, line.Quantity)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transfer lines"})
+			return
+		}
+	}
+
+	if mode == models.TransferDirect {
+		if err := sendTransfer(tx, t, userID); err != nil {
+			writeTransferError(c, err)
+			return
+		}
+		if err := receiveTransfer(tx, t, userID); err != nil {
+			writeTransferError(c, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, t)
+}
+
+// SendTransfer takes a two-step transfer's stock out of its source
+// locations; it stays in transit until received.
+func (h *Handler) SendTransfer(c *gin.Context) {
+	h.advanceTransfer(c, models.TransferPending, sendTransfer)
+}
+
+// ReceiveTransfer books an in-transit transfer into its destinations. Only
+// staff at the destination (or unpinned users) may receive it.
+func (h *Handler) ReceiveTransfer(c *gin.Context) {
+	pinned := middleware.PinnedWarehouseID(c)
+	h.advanceTransfer(c, models.TransferInTransit, func(tx *sql.Tx, t *models.Transfer, userID int) error {
+		if pinned != nil && !sameWarehouse(pinned, t.ToWarehouseID) {
+			return errLocationNotAccessible
+		}
+		return receiveTransfer(tx, t, userID)
+	})
+}
+
+func (h *Handler) CancelTransfer(c *gin.Context) {
+	h.advanceTransfer(c, models.TransferPending, func(tx *sql.Tx, t *models.Transfer, userID int) error {
+		return setTransferStatus(tx, t, models.TransferCancelled)
+	})
+}
+
+func (h *Handler) advanceTransfer(c *gin.Context, from string, step func(*sql.Tx, *models.Transfer, int) error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	t, err := h.lockTransfer(tx, c, id)
+	if err != nil {
+		writeTransferError(c, err)
+		return
+	}
+	if t.Status != from {
+		writeTransferError(c, fmt.Errorf("%w: transfer is %s", errTransferNotEditable, t.Status))
+		return
+	}
+	if err := step(tx, t, middleware.CurrentUserID(c)); err != nil {
+		writeTransferError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, t)
+}
+
+// sendTransfer books an OUT movement at every source location. Lines
+// without a lot are split into one line per lot taken, so the destination
+// receives exactly the lots that left.
+func sendTransfer(tx *sql.Tx, t *models.Transfer, userID int) error {
+	lines, err := transferLines(tx, t.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if err := ensureStockNotFrozen(tx, line.ProductID, line.FromLocationID); err != nil {
+			return err
+		}
+
+		onHand, err := lockedOnHand(tx, line.ProductID, line.FromLocationID, line.Batch)
+		if err != nil {
+			return err
+		}
+		if onHand < line.Quantity {
+			return fmt.Errorf("%w: %s at %s has %d, transfer needs %d",
+				errInsufficientStock, line.ProductName, line.FromLocationCode, onHand, line.Quantity)
+		}
+
+		lots, err := takeStock(tx, stockMovement{
+			ProductID:     line.ProductID,
+			LocationID:    line.FromLocationID,
+			Quantity:      line.Quantity,
+			Reference:     t.DocumentNumber,
+			ReferenceType: models.RefTransfer,
+			Batch:         line.Batch,
+			UserID:        userID,
+			TenantID:      t.TenantID,
+		})
+		if err != nil {
+			return err
+		}
+
+		for i, lot := range lots {
+			if i == 0 {
+				_, err = tx.Exec(`UPDATE transfer_lines SET batch = $1, expiry_date = $2, quantity = $3 WHERE id = $4`,
+					lot.Batch, lot.ExpiryDate, lot.Quantity, line.ID)
+			} else {
+				_, err = tx.Exec(`
+					INSERT INTO transfer_lines (transfer_id, product_id, batch, expiry_date, from_location_id, to_location_id, quantity)
+					VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+					t.ID, line.ProductID, lot.Batch, lot.ExpiryDate, line.FromLocationID, line.ToLocationID, lot.Quantity)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE transfers SET sent_by = $1, sent_at = NOW() WHERE id = $2`, userID, t.ID)
+	if err != nil {
+		return err
+	}
+	return setTransferStatus(tx, t, models.TransferInTransit)
+}
+
+// receiveTransfer books the matching IN movement at every destination.
+func receiveTransfer(tx *sql.Tx, t *models.Transfer, userID int) error {
+	lines, err := transferLines(tx, t.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if err := ensureStockNotFrozen(tx, line.ProductID, line.ToLocationID); err != nil {
+			return err
+		}
+		err := applyStockMovement(tx, stockMovement{
+			ProductID:     line.ProductID,
+			LocationID:    line.ToLocationID,
+			Type:          models.MovementIn,
+			Quantity:      line.Quantity,
+			Reference:     t.DocumentNumber,
+			ReferenceType: models.RefTransfer,
+			Batch:         line.Batch,
+			ExpiryDate:    line.ExpiryDate,
+			UserID:        userID,
+			TenantID:      t.TenantID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE transfers SET received_by = $1, received_at = NOW() WHERE id = $2`, userID, t.ID)
+	if err != nil {
+		return err
+	}
+	return setTransferStatus(tx, t, models.TransferCompleted)
+}
+
+func setTransferStatus(tx *sql.Tx, t *models.Transfer, status string) error {
+	updated, err := scanTransfer(tx.QueryRow(`
+		UPDATE transfers SET status = $1, updated_at = NOW() WHERE id = $2
+		RETURNING `+transferColumns, status, t.ID))
+	if err != nil {
+		return err
+	}
+	*t = *updated
+	return nil
+}
+
+// lockTransfer loads a transfer for update. Users pinned to a warehouse
+// see transfers leaving or arriving at it.
+func (h *Handler) lockTransfer(tx *sql.Tx, c *gin.Context, id int) (*models.Transfer, error) {
+	t, err := scanTransfer(tx.QueryRow(`
+		SELECT `+transferColumns+` FROM transfers
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2)
+		  AND ($3::int IS NULL OR from_warehouse_id = $3 OR to_warehouse_id = $3)
+		FOR UPDATE`,
+		id, middleware.TenantID(c), middleware.PinnedWarehouseID(c)))
+	if err == sql.ErrNoRows {
+		return nil, errTransferNotFound
+	}
+	return t, err
+}
+
+func transferLines(q dbtx, transferID int) ([]models.TransferLine, error) {
+	rows, err := q.Query(`
+		SELECT l.id, l.transfer_id, l.product_id, p.name, p.sku, l.batch, l.expiry_date, l.from_location_id, f.code,
+		       l.to_location_id, t.code, l.quantity
+		FROM transfer_lines l
+		JOIN warehouse_product p ON l.product_id = p.id
+		JOIN locations f ON l.from_location_id = f.id
+		JOIN locations t ON l.to_location_id = t.id
+		WHERE l.transfer_id = $1
+		ORDER BY l.id`, transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []models.TransferLine
+	for rows.Next() {
+		var l models.TransferLine
+		var expiry sql.NullTime
+		err := rows.Scan(&l.ID, &l.TransferID, &l.ProductID, &l.ProductName, &l.SKU, &l.Batch, &expiry,
+			&l.FromLocationID, &l.FromLocationCode, &l.ToLocationID, &l.ToLocationCode, &l.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		l.ExpiryDate = nullableTime(expiry)
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+func scanTransfer(row scanner) (*models.Transfer, error) {
+	var t models.Transfer
+	var fromWarehouse, toWarehouse, createdBy, sentBy, receivedBy, tenantID sql.NullInt64
+	var sentAt, receivedAt sql.NullTime
+	err := row.Scan(&t.ID, &t.DocumentNumber, &fromWarehouse, &toWarehouse, &t.Mode, &t.Status, &t.Notes,
+		&createdBy, &sentBy, &sentAt, &receivedBy, &receivedAt, &tenantID, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.FromWarehouseID = nullableInt(fromWarehouse)
+	t.ToWarehouseID = nullableInt(toWarehouse)
+	t.CreatedBy = nullableInt(createdBy)
+	t.SentBy = nullableInt(sentBy)
+	t.ReceivedBy = nullableInt(receivedBy)
+	t.TenantID = nullableInt(tenantID)
+	if sentAt.Valid {
+		t.SentAt = &sentAt.Time
+	}
+	if receivedAt.Valid {
+		t.ReceivedAt = &receivedAt.Time
+	}
+	return &t, nil
+}
+
+func sameWarehouse(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func writeTransferError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errTransferNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errTransferNotEditable), errors.Is(err, errInsufficientStock), errors.Is(err, errStockFrozen):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errLocationNotAccessible):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, errInvalidMovement):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process transfer"})
+	}
+}
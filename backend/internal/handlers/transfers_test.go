@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newFakeTransfer serves transfer 1 of tenant 3, from warehouse 1 to 2, on
+// top of the warehouse of newFakeLedger. Its one line moves quantity of
+// product 1 from location 12 to 20.
+func newFakeTransfer(status, batch string, quantity int) (*fakeLedger, *string) {
+	tenant, from, to := 3, 1, 2
+	ledger := newFakeLedger(models.AllocationFEFO)
+	row := func() []driver.Value {
+		return []driver.Value{int64(1), "TR-1", int64(from), int64(to), models.TransferTwoStep, status, "",
+			nil, nil, nil, nil, nil, int64(tenant), time.Now(), time.Now()}
+	}
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		if argInt(st.Args[0]) != 1 || !inScope(st.Args[1], &tenant) ||
+			st.Args[2] != nil && argInt(st.Args[2]) != from && argInt(st.Args[2]) != to {
+			return nil, nil
+		}
+		return &dbtest.Result{Rows: [][]driver.Value{row()}}, nil
+	}, "FROM transfers", "FOR UPDATE")
+	ledger.on(func(dbtest.Statement) (*dbtest.Result, error) {
+		return &dbtest.Result{Rows: [][]driver.Value{{int64(31), int64(1), int64(1), "Gula", "GL-1", batch, nil,
+			int64(12), "A-03", int64(20), "B-01", int64(quantity)}}}, nil
+	}, "FROM transfer_lines l")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		batch = st.Args[0].(string)
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE transfer_lines SET batch")
+	ledger.on(affected(1), "UPDATE transfers SET", "_by = $1")
+	ledger.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		status = st.Args[0].(string)
+		return &dbtest.Result{Rows: [][]driver.Value{row()}}, nil
+	}, "UPDATE transfers SET status")
+	return ledger, &status
+}
+
+func TestAdvanceTransfer(t *testing.T) {
+	other, source := 4, 1
+	staff := auth.Claims{UserID: 7}
+	tests := []struct {
+		name          string
+		claims        auth.Claims
+		action        string
+		status        string
+		batch         string
+		quantity      int
+		frozen        []int
+		wantCode      int
+		wantStatus    string
+		wantMovements []string
+	}{
+		{name: "send takes the stock out", claims: staff, action: "send", status: models.TransferPending, quantity: 4,
+			wantCode: http.StatusOK, wantStatus: models.TransferInTransit, wantMovements: []string{"OUT 4 12/L3"}},
+		{name: "send more than on hand", claims: staff, action: "send", status: models.TransferPending, quantity: 11,
+			wantCode: http.StatusConflict},
+		{name: "send from a location under count", claims: staff, action: "send", status: models.TransferPending, quantity: 4,
+			frozen: []int{12}, wantCode: http.StatusConflict},
+		{name: "send twice", claims: staff, action: "send", status: models.TransferInTransit, quantity: 4,
+			wantCode: http.StatusConflict},
+		{name: "receive books the lot in", claims: staff, action: "receive", status: models.TransferInTransit, batch: "L3", quantity: 4,
+			wantCode: http.StatusOK, wantStatus: models.TransferCompleted, wantMovements: []string{"IN 4 20/L3"}},
+		{name: "receive at the source warehouse", claims: auth.Claims{UserID: 7, WarehouseID: &source}, action: "receive",
+			status: models.TransferInTransit, batch: "L3", quantity: 4, wantCode: http.StatusForbidden},
+		{name: "receive into a location under count", claims: staff, action: "receive", status: models.TransferInTransit, batch: "L3", quantity: 4,
+			frozen: []int{20}, wantCode: http.StatusConflict},
+		{name: "cancel", claims: staff, action: "cancel", status: models.TransferPending, quantity: 4,
+			wantCode: http.StatusOK, wantStatus: models.TransferCancelled},
+		{name: "cancel in transit", claims: staff, action: "cancel", status: models.TransferInTransit, quantity: 4,
+			wantCode: http.StatusConflict},
+		{name: "other tenants' transfers", claims: auth.Claims{UserID: 7, TenantID: &other}, action: "send", status: models.TransferPending, quantity: 4,
+			wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger, status := newFakeTransfer(tt.status, tt.batch, tt.quantity)
+			for _, id := range tt.frozen {
+				ledger.frozen[id] = true
+			}
+			h := &Handler{DB: ledger.open(t)}
+			handler := map[string]gin.HandlerFunc{"send": h.SendTransfer, "receive": h.ReceiveTransfer, "cancel": h.CancelTransfer}[tt.action]
+
+			w := serveAs(&tt.claims, "/transfers/:id/"+tt.action, handler, jsonRequest(http.MethodPost, "/transfers/1/"+tt.action, ""))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if ledger.committed != (tt.wantCode == http.StatusOK) {
+				t.Errorf("committed = %v on a %d", ledger.committed, w.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if *status != tt.wantStatus {
+				t.Errorf("transfer is %s, want %s", *status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(ledger.movements, tt.wantMovements) {
+				t.Errorf("movements = %v, want %v", ledger.movements, tt.wantMovements)
+			}
+		})
+	}
+}
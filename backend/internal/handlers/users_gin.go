@@ -1,15 +1,43 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"wms-backend/internal/auth"
+	"wms-backend/internal/middleware"
 	"wms-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	errUserNotFound      = errors.New("User not found")
+	errNoRoles           = errors.New("At least one role is required")
+	errInvalidRole       = errors.New("Invalid role")
+	errRoleNotAssignable = errors.New("Role grants more than you may assign")
+	errUserNotManageable = errors.New("User holds roles you may not assign")
+)
+
+// assignableRoles are the floor roles that can be given to staff accounts.
+var assignableRoles = []map[string]string{
+	{"value": auth.RoleWarehouseManagement, "label": "Warehouse Management"},
+	{"value": auth.RoleOperatorGudang, "label": "Operator Gudang"},
+	{"value": auth.RoleChecker, "label": "Checker"},
+	{"value": auth.RoleQC, "label": "Quality Control (QC)"},
+	{"value": auth.RolePicker, "label": "Picker"},
+}
+
 func (h *Handler) GetUsersGin(c *gin.Context) {
-	rows, err := h.DB.Query("SELECT id, username, email, is_staff, role, first_name, last_name, is_active FROM auth_user ORDER BY id")
+	rows, err := h.DB.Query(`
+		SELECT id, username, email, is_staff, COALESCE(role, ''), COALESCE(roles, ''), first_name, last_name, is_active, warehouse_id
+		FROM auth_user
+		WHERE ($1::int IS NULL OR tenant_id = $1) AND ($2::int IS NULL OR warehouse_id = $2)
+		ORDER BY id`, middleware.TenantID(c), middleware.WarehouseID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -19,11 +47,13 @@ func (h *Handler) GetUsersGin(c *gin.Context) {
 	var users []models.User
 	for rows.Next() {
 		var user models.User
-		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.IsStaff, &user.Role, &user.FirstName, &user.LastName, &user.IsActive)
+		var rolesStr string
+		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.IsStaff, &user.Role, &rolesStr, &user.FirstName, &user.LastName, &user.IsActive, &user.WarehouseID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		user.Roles = parseRoles(rolesStr)
 		users = append(users, user)
 	}
 
@@ -42,6 +72,28 @@ func (h *Handler) CreateUserGin(c *gin.Context) {
 		return
 	}
 
+	roles, err := requestedRoles(middleware.GetClaims(c), req)
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+
+	// Warehouse admins can only create staff for their own site
+	warehouseID := req.WarehouseID
+	if pinned := middleware.PinnedWarehouseID(c); pinned != nil {
+		warehouseID = pinned
+	} else if warehouseID != nil {
+		var exists bool
+		if err := h.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM warehouses WHERE id = $1)`, *warehouseID).Scan(&exists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check warehouse"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown warehouse"})
+			return
+		}
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not hash password"})
@@ -50,8 +102,8 @@ func (h *Handler) CreateUserGin(c *gin.Context) {
 
 	var userID int
 	err = h.DB.QueryRow(
-		"INSERT INTO auth_user (username, email, password, first_name, last_name, role, is_staff, is_superuser, is_active, date_joined) VALUES ($1, $2, $3, $4, $5, $6, false, false, true, NOW()) RETURNING id",
-		req.Username, req.Email, string(hashedPassword), req.FirstName, req.LastName, req.Role,
+		"INSERT INTO auth_user (username, email, password, first_name, last_name, role, roles, tenant_id, warehouse_id, is_staff, is_superuser, is_active, date_joined) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, false, false, true, NOW()) RETURNING id",
+		req.Username, req.Email, string(hashedPassword), req.FirstName, req.LastName, roles[0], strings.Join(roles, ","), middleware.TenantID(c), warehouseID,
 	).Scan(&userID)
 
 	if err != nil {
@@ -60,15 +112,191 @@ func (h *Handler) CreateUserGin(c *gin.Context) {
 	}
 
 	user := models.User{
-		ID:        userID,
-		Username:  req.Username,
-		Email:     req.Email,
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		Role:      req.Role,
-		IsStaff:   false,
-		IsActive:  true,
+		ID:          userID,
+		Username:    req.Username,
+		Email:       req.Email,
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		Role:        roles[0],
+		Roles:       roles,
+		WarehouseID: warehouseID,
+		IsStaff:     false,
+		IsActive:    true,
 	}
 
 	c.JSON(http.StatusCreated, user)
-}
\ No newline at end of file
+}
+
+func (h *Handler) UpdateUserGin(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req models.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	roles, err := requestedRoles(middleware.GetClaims(c), req)
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := lockManagedUser(tx, c, userID); err != nil {
+		writeUserError(c, err)
+		return
+	}
+	_, err = tx.Exec(`UPDATE auth_user SET first_name = $1, last_name = $2, role = $3, roles = $4 WHERE id = $5`,
+		req.FirstName, req.LastName, roles[0], strings.Join(roles, ","), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+}
+
+func (h *Handler) DeleteUserGin(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := lockManagedUser(tx, c, userID); err != nil {
+		writeUserError(c, err)
+		return
+	}
+	if _, err := tx.Exec(`UPDATE auth_user SET is_active = false WHERE id = $1`, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate user"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deactivated successfully"})
+}
+
+// GetRolesGin lists the roles the current user may hand out.
+func (h *Handler) GetRolesGin(c *gin.Context) {
+	claims := middleware.GetClaims(c)
+	roles := []map[string]string{}
+	for _, role := range assignableRoles {
+		if claims != nil && claims.CanAssign(role["value"]) {
+			roles = append(roles, role)
+		}
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// requestedRoles validates the roles in a create/update request. The roles
+// array wins over the legacy single role field, and every role must be one
+// the caller may assign.
+func requestedRoles(claims *auth.Claims, req models.CreateUserRequest) ([]string, error) {
+	roles := req.Roles
+	if len(roles) == 0 && req.Role != "" {
+		roles = []string{req.Role}
+	}
+	if len(roles) == 0 {
+		return nil, errNoRoles
+	}
+
+	for _, role := range roles {
+		valid := false
+		for _, assignable := range assignableRoles {
+			if assignable["value"] == role {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("%w: %s", errInvalidRole, role)
+		}
+		if claims == nil || !claims.CanAssign(role) {
+			return nil, fmt.Errorf("%w: %s", errRoleNotAssignable, role)
+		}
+	}
+
+	return roles, nil
+}
+
+// lockManagedUser locks a user in the caller's tenant and warehouse. Only
+// superusers may touch superusers, and nobody may touch a user holding a
+// role they could not have assigned themselves.
+func lockManagedUser(tx *sql.Tx, c *gin.Context, userID int) error {
+	var superuser bool
+	var rolesStr, role string
+	err := tx.QueryRow(`
+		SELECT is_superuser, COALESCE(roles, ''), COALESCE(role, '') FROM auth_user
+		WHERE id = $1 AND ($2::int IS NULL OR tenant_id = $2) AND ($3::int IS NULL OR warehouse_id = $3)
+		FOR UPDATE`,
+		userID, middleware.TenantID(c), middleware.PinnedWarehouseID(c),
+	).Scan(&superuser, &rolesStr, &role)
+	if err == sql.ErrNoRows {
+		return errUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return errUserNotManageable
+	}
+	if claims.IsSuperuser {
+		return nil
+	}
+	if superuser {
+		return errUserNotManageable
+	}
+	held := parseRoles(rolesStr)
+	if len(held) == 0 && role != "" {
+		held = []string{role}
+	}
+	for _, r := range held {
+		if !claims.CanAssign(r) {
+			return errUserNotManageable
+		}
+	}
+	return nil
+}
+
+// writeUserError maps user management errors to HTTP responses.
+func writeUserError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errRoleNotAssignable), errors.Is(err, errUserNotManageable):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, errNoRoles), errors.Is(err, errInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+	}
+}
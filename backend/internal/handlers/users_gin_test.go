@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"wms-backend/internal/auth"
+	"wms-backend/internal/dbtest"
+)
+
+// fakeUser is one auth_user row as the user handlers see it.
+type fakeUser struct {
+	id          int
+	roles       string
+	superuser   bool
+	tenantID    *int
+	warehouseID *int
+}
+
+// newFakeUsers answers the user lookups of the user handlers from users
+// and records the updates they make.
+func newFakeUsers(users ...fakeUser) (*fakeDB, *[]string) {
+	db := &fakeDB{}
+	var updates []string
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		for _, u := range users {
+			if u.id == argInt(st.Args[0]) && inScope(st.Args[1], u.tenantID) && inScope(st.Args[2], u.warehouseID) {
+				return &dbtest.Result{Rows: [][]driver.Value{{u.superuser, u.roles, ""}}}, nil
+			}
+		}
+		return nil, nil
+	}, "FROM auth_user", "FOR UPDATE")
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		updates = append(updates, st.Args[3].(string))
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE auth_user SET first_name")
+	db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+		updates = append(updates, "deactivated")
+		return &dbtest.Result{RowsAffected: 1}, nil
+	}, "UPDATE auth_user SET is_active = false")
+	return db, &updates
+}
+
+func TestUpdateUserGin(t *testing.T) {
+	tenant, site, otherSite := 3, 1, 2
+	users := []fakeUser{
+		{id: 10, roles: auth.RolePicker, warehouseID: &site},
+		{id: 11, roles: auth.RolePicker, warehouseID: &otherSite},
+		{id: 12, roles: auth.RoleAdmin, superuser: true},
+		{id: 13, roles: auth.RoleWarehouseManagement, tenantID: &tenant},
+		{id: 14, roles: auth.RolePicker, tenantID: &tenant},
+	}
+	management := auth.Claims{UserID: 1, Roles: []string{auth.RoleWarehouseManagement}}
+	siteManagement := auth.Claims{UserID: 1, Roles: []string{auth.RoleWarehouseManagement}, WarehouseID: &site}
+	tenantAdmin := auth.Claims{UserID: 1, Roles: []string{auth.RoleTenantAdmin}, TenantID: &tenant}
+
+	tests := []struct {
+		name        string
+		claims      auth.Claims
+		userID      string
+		roles       string
+		wantCode    int
+		wantUpdates []string
+	}{
+		{name: "management changes floor roles", claims: management, userID: "11", roles: `["picker", "qc"]`,
+			wantCode: http.StatusOK, wantUpdates: []string{"picker,qc"}},
+		{name: "site management stays on its site", claims: siteManagement, userID: "11", roles: `["qc"]`,
+			wantCode: http.StatusNotFound},
+		{name: "superusers are off limits", claims: management, userID: "12", roles: `["picker"]`,
+			wantCode: http.StatusForbidden},
+		{name: "tenant admins promote within their tenant", claims: tenantAdmin, userID: "13", roles: `["warehouse_management"]`,
+			wantCode: http.StatusOK, wantUpdates: []string{"warehouse_management"}},
+		{name: "tenant admins cannot hand out floor roles", claims: tenantAdmin, userID: "13", roles: `["picker"]`,
+			wantCode: http.StatusForbidden},
+		{name: "tenant admins cannot touch users with floor roles", claims: tenantAdmin, userID: "14", roles: `["warehouse_management"]`,
+			wantCode: http.StatusForbidden},
+		{name: "tenant admins do not see other tenants", claims: tenantAdmin, userID: "10", roles: `["warehouse_management"]`,
+			wantCode: http.StatusNotFound},
+		{name: "unknown role", claims: management, userID: "10", roles: `["owner"]`,
+			wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, updates := newFakeUsers(users...)
+			h := &Handler{DB: db.open(t)}
+
+			w := serveAs(&tt.claims, "/users/:id", h.UpdateUserGin,
+				jsonRequest(http.MethodPut, "/users/"+tt.userID, `{"first_name": "Sari", "roles": `+tt.roles+`}`))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if !reflect.DeepEqual(*updates, tt.wantUpdates) {
+				t.Errorf("updates = %v, want %v", *updates, tt.wantUpdates)
+			}
+			if db.committed != (tt.wantCode == http.StatusOK) {
+				t.Errorf("committed = %v on a %d", db.committed, w.Code)
+			}
+		})
+	}
+}
+
+func TestDeleteUserGinKeepsSuperusers(t *testing.T) {
+	db, updates := newFakeUsers(fakeUser{id: 12, roles: auth.RoleAdmin, superuser: true})
+	h := &Handler{DB: db.open(t)}
+	claims := auth.Claims{UserID: 1, Roles: []string{auth.RoleWarehouseManagement}}
+
+	w := serveAs(&claims, "/users/:id", h.DeleteUserGin, jsonRequest(http.MethodDelete, "/users/12", ""))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusForbidden, w.Body)
+	}
+	if len(*updates) != 0 {
+		t.Errorf("updates = %v, want none", *updates)
+	}
+}
+
+func TestCreateUserGinWarehouse(t *testing.T) {
+	site := 1
+	tests := []struct {
+		name          string
+		claims        auth.Claims
+		warehouseID   string
+		wantCode      int
+		wantWarehouse driver.Value
+	}{
+		{name: "known warehouse", claims: auth.Claims{UserID: 1, Roles: []string{auth.RoleWarehouseManagement}},
+			warehouseID: "2", wantCode: http.StatusCreated, wantWarehouse: int64(2)},
+		{name: "unknown warehouse", claims: auth.Claims{UserID: 1, Roles: []string{auth.RoleWarehouseManagement}},
+			warehouseID: "99", wantCode: http.StatusBadRequest},
+		{name: "site management creates for their site", claims: auth.Claims{UserID: 1, Roles: []string{auth.RoleWarehouseManagement}, WarehouseID: &site},
+			warehouseID: "99", wantCode: http.StatusCreated, wantWarehouse: int64(site)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{}
+			db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+				return &dbtest.Result{Rows: [][]driver.Value{{argInt(st.Args[0]) <= 2}}}, nil
+			}, "FROM warehouses")
+			var inserted []driver.Value
+			db.on(func(st dbtest.Statement) (*dbtest.Result, error) {
+				inserted = st.Args
+				return &dbtest.Result{Rows: [][]driver.Value{{int64(20)}}}, nil
+			}, "INSERT INTO auth_user")
+			h := &Handler{DB: db.open(t)}
+
+			w := serveAs(&tt.claims, "/users", h.CreateUserGin, jsonRequest(http.MethodPost, "/users",
+				`{"username": "sari", "email": "sari@example.com", "password": "secret", "roles": ["picker"], "warehouse_id": `+tt.warehouseID+`}`))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode != http.StatusCreated {
+				if inserted != nil {
+					t.Errorf("user was inserted on a %d", w.Code)
+				}
+				return
+			}
+			if inserted[8] != tt.wantWarehouse {
+				t.Errorf("warehouse = %v, want %v", inserted[8], tt.wantWarehouse)
+			}
+		})
+	}
+}
+
+func TestGetRolesGin(t *testing.T) {
+	tenant := 3
+	tests := []struct {
+		name   string
+		claims auth.Claims
+		want   []string
+	}{
+		{name: "management", claims: auth.Claims{Roles: []string{auth.RoleWarehouseManagement}},
+			want: []string{auth.RoleWarehouseManagement, auth.RoleOperatorGudang, auth.RoleChecker, auth.RoleQC, auth.RolePicker}},
+		{name: "tenant admin", claims: auth.Claims{Roles: []string{auth.RoleTenantAdmin}, TenantID: &tenant},
+			want: []string{auth.RoleWarehouseManagement}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{}
+			w := serveAs(&tt.claims, "/roles", h.GetRolesGin, jsonRequest(http.MethodGet, "/roles", ""))
+
+			var roles []map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &roles); err != nil {
+				t.Fatalf("decoding %s: %v", w.Body, err)
+			}
+			var got []string
+			for _, role := range roles {
+				got = append(got, role["value"])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("roles = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
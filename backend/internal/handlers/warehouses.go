@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"wms-backend/internal/middleware"
+	"wms-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errLocationNotAccessible = errors.New("location is not in your warehouse")
+
+func (h *Handler) GetWarehouses(c *gin.Context) {
+	rows, err := h.DB.Query(`
+		SELECT id, name, location, manager_id, created_at FROM warehouses
+		WHERE ($1::int IS NULL OR id = $1)
+		ORDER BY name
+	`, middleware.PinnedWarehouseID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch warehouses"})
+		return
+	}
+	defer rows.Close()
+
+	var warehouses []models.Warehouse
+	for rows.Next() {
+		var w models.Warehouse
+		if err := rows.Scan(&w.ID, &w.Name, &w.Location, &w.ManagerID, &w.CreatedAt); err != nil {
+			continue
+		}
+		warehouses = append(warehouses, w)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": warehouses})
+}
+
+func (h *Handler) GetWarehouse(c *gin.Context) {
+	id, ok := h.accessibleWarehouseParam(c)
+	if !ok {
+		return
+	}
+
+	var w models.Warehouse
+	err := h.DB.QueryRow(`SELECT id, name, location, manager_id, created_at FROM warehouses WHERE id = $1`, id).
+		Scan(&w.ID, &w.Name, &w.Location, &w.ManagerID, &w.CreatedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch warehouse"})
+		return
+	}
+
+	c.JSON(http.StatusOK, w)
+}
+
+func (h *Handler) CreateWarehouse(c *gin.Context) {
+	var req models.WarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var w models.Warehouse
+	err := h.DB.QueryRow(`
+		INSERT INTO warehouses (name, location, manager_id, created_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING id, name, location, manager_id, created_at
+	`, req.Name, req.Location, req.ManagerID).Scan(&w.ID, &w.Name, &w.Location, &w.ManagerID, &w.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create warehouse"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, w)
+}
+
+func (h *Handler) UpdateWarehouse(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req models.WarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var w models.Warehouse
+	err = h.DB.QueryRow(`
+		UPDATE warehouses SET name = $1, location = $2, manager_id = $3
+		WHERE id = $4
+		RETURNING id, name, location, manager_id, created_at
+	`, req.Name, req.Location, req.ManagerID, id).Scan(&w.ID, &w.Name, &w.Location, &w.ManagerID, &w.CreatedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update warehouse"})
+		return
+	}
+
+	c.JSON(http.StatusOK, w)
+}
+
+func (h *Handler) DeleteWarehouse(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var inUse bool
+	err = h.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM locations WHERE warehouse_id = $1)
+		    OR EXISTS(SELECT 1 FROM auth_user WHERE warehouse_id = $1 AND is_active = true)
+	`, id).Scan(&inUse)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check warehouse usage"})
+		return
+	}
+	if inUse {
+		c.JSON(http.StatusConflict, gin.H{"error": "Warehouse still has locations or active users"})
+		return
+	}
+
+	result, err := h.DB.Exec(`DELETE FROM warehouses WHERE id = $1`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete warehouse"})
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Warehouse deleted successfully"})
+}
+
+// GetWarehouseStock lists on-hand stock per product, location and lot for
+// one site.
+func (h *Handler) GetWarehouseStock(c *gin.Context) {
+	id, ok := h.accessibleWarehouseParam(c)
+	if !ok {
+		return
+	}
+
+	rows, err := h.DB.Query(`
+		SELECT p.id, p.name, p.sku, l.id, l.name, i.batch, i.expiry_date, i.quantity, i.reserved_quantity, i.quarantined_quantity, i.min_stock
+		FROM inventory i
+		JOIN warehouse_product p ON i.product_id = p.id
+		JOIN locations l ON i.location_id = l.id
+		WHERE l.warehouse_id = $1 AND ($2::int IS NULL OR i.tenant_id = $2)
+		ORDER BY p.name, l.name, i.expiry_date NULLS LAST, i.batch
+	`, id, middleware.TenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock"})
+		return
+	}
+	defer rows.Close()
+
+	var stock []map[string]interface{}
+	for rows.Next() {
+		var productID, locationID, quantity, reserved, quarantined, minStock int
+		var productName, sku, locationName, batch string
+		var expiry sql.NullTime
+		if err := rows.Scan(&productID, &productName, &sku, &locationID, &locationName, &batch, &expiry, &quantity, &reserved, &quarantined, &minStock); err != nil {
+			continue
+		}
+		stock = append(stock, map[string]interface{}{
+			"product_id":    productID,
+			"product_name":  productName,
+			"sku":           sku,
+			"location_id":   locationID,
+			"location_name": locationName,
+			"batch":         batch,
+			"expiry_date":   nullableTime(expiry),
+			"quantity":      quantity,
+			"reserved":      reserved,
+			"quarantined":   quarantined,
+			"available":     quantity - reserved - quarantined,
+			"min_stock":     minStock,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"warehouse_id": id, "data": stock})
+}
+
+// accessibleWarehouseParam parses the :id parameter and rejects warehouses
+// outside the user's own site. It writes the error response itself.
+func (h *Handler) accessibleWarehouseParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+
+	if pinned := middleware.PinnedWarehouseID(c); pinned != nil && *pinned != id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this warehouse"})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// locationWarehouse returns the warehouse a location belongs to and rejects
+// locations outside the site the user is pinned to.
+func (h *Handler) locationWarehouse(c *gin.Context, locationID int) (*int, error) {
+	var warehouseID sql.NullInt64
+	err := h.DB.QueryRow(`SELECT warehouse_id FROM locations WHERE id = $1`, locationID).Scan(&warehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	id := nullableInt(warehouseID)
+	if pinned := middleware.PinnedWarehouseID(c); pinned != nil && (id == nil || *id != *pinned) {
+		return nil, errLocationNotAccessible
+	}
+
+	return id, nil
+}
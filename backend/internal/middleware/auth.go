@@ -3,10 +3,17 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"wms-backend/internal/auth"
+
 	"github.com/gin-gonic/gin"
 )
 
-func AuthGin() gin.HandlerFunc {
+const (
+	ClaimsKey = "claims"
+	UserIDKey = "user_id"
+)
+
+func AuthGin(tokens *auth.TokenManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -15,20 +22,39 @@ func AuthGin() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		if tokenString == "" {
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if tokenString == "" || tokenString == authHeader {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
 			c.Abort()
 			return
 		}
 
-		// Simple token validation - in production use proper JWT validation
-		if !strings.HasPrefix(tokenString, "token_") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		claims, err := tokens.Parse(tokenString, auth.TokenTypeAccess)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
+		c.Set(ClaimsKey, claims)
+		c.Set(UserIDKey, claims.UserID)
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// GetClaims returns the token claims stored by AuthGin, or nil when the
+// request did not pass through it.
+func GetClaims(c *gin.Context) *auth.Claims {
+	value, ok := c.Get(ClaimsKey)
+	if !ok {
+		return nil
+	}
+	claims, _ := value.(*auth.Claims)
+	return claims
+}
+
+// CurrentUserID returns the id of the authenticated user, or 0 when the
+// request did not pass through AuthGin.
+func CurrentUserID(c *gin.Context) int {
+	return c.GetInt(UserIDKey)
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"wms-backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRoles allows the request through when the authenticated user holds
+// at least one of roles. It must run after AuthGin.
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil || !claims.HasRole(roles...) {
+			forbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission allows the request through when one of the user's roles
+// grants perm. It must run after AuthGin.
+func RequirePermission(perm auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil || !claims.Can(perm) {
+			forbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+func forbidden(c *gin.Context) {
+	c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to perform this action"})
+	c.Abort()
+}
+
+// RequireSuperuser restricts a route to accounts with is_superuser set.
+func RequireSuperuser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil || !claims.IsSuperuser {
+			forbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireStaff restricts a route to warehouse staff, that is users who are
+// not bound to a tenant. A tenant picked with X-Tenant-ID does not count.
+func RequireStaff() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil || claims.TenantID != nil {
+			forbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wms-backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireStaff(t *testing.T) {
+	tenant := 3
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		header string
+		want   int
+	}{
+		{name: "staff", claims: &auth.Claims{UserID: 1}, want: http.StatusOK},
+		{name: "staff working for a tenant", claims: &auth.Claims{UserID: 1}, header: "3", want: http.StatusOK},
+		{name: "tenant user", claims: &auth.Claims{UserID: 2, IsSuperuser: true, TenantID: &tenant}, want: http.StatusForbidden},
+		{name: "anonymous", want: http.StatusForbidden},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.POST("/locations", func(c *gin.Context) {
+				if tt.claims != nil {
+					c.Set(ClaimsKey, tt.claims)
+				}
+			}, Tenant(), RequireStaff(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/locations", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Tenant-ID", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const TenantIDKey = "tenant_id"
+
+// Tenant resolves the tenant a request operates on. Users bound to a tenant
+// are always pinned to it. Staff that are not bound to any tenant (3PL
+// operators, superusers) see every tenant's data unless they narrow the
+// request with the X-Tenant-ID header. It must run after AuthGin.
+func Tenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil {
+			c.Next()
+			return
+		}
+
+		if claims.TenantID != nil {
+			c.Set(TenantIDKey, *claims.TenantID)
+			c.Next()
+			return
+		}
+
+		if header := c.GetHeader("X-Tenant-ID"); header != "" {
+			id, err := strconv.Atoi(header)
+			if err != nil || id <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid X-Tenant-ID header"})
+				c.Abort()
+				return
+			}
+			c.Set(TenantIDKey, id)
+		}
+
+		c.Next()
+	}
+}
+
+// TenantID returns the tenant resolved by Tenant, or nil when the request is
+// not restricted to a single tenant. The value can be passed directly as a
+// query argument: a nil pointer is sent as NULL.
+func TenantID(c *gin.Context) *int {
+	value, ok := c.Get(TenantIDKey)
+	if !ok {
+		return nil
+	}
+	id := value.(int)
+	return &id
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Allocation strategies, set per product
+const (
+	AllocationFEFO  = "fefo"
+	AllocationFIFO  = "fifo"
+	AllocationLIFO  = "lifo"
+	AllocationFixed = "fixed"
+)
+
+// AllocationPlan says which balances an outbound quantity is taken from.
+// Short is what could not be allocated.
+type AllocationPlan struct {
+	ProductID int              `json:"product_id"`
+	Strategy  string           `json:"strategy"`
+	Requested int              `json:"requested"`
+	Allocated int              `json:"allocated"`
+	Short     int              `json:"short"`
+	Lines     []AllocationLine `json:"lines"`
+}
+
+type AllocationLine struct {
+	LocationID   int        `json:"location_id"`
+	WarehouseID  *int       `json:"warehouse_id"`
+	LocationCode string     `json:"location_code"`
+	Batch        string     `json:"batch"`
+	ExpiryDate   *time.Time `json:"expiry_date"`
+	ReceivedAt   time.Time  `json:"received_at"`
+	Quantity     int        `json:"quantity"`
+}
+
+type AllocationStrategyRequest struct {
+	Strategy        string `json:"strategy" binding:"required"`
+	FixedLocationID *int   `json:"fixed_location_id"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Attachment owner types, each naming the table OwnerID points into.
+// Goods receipts stand in for the legacy penerimaan_barang records.
+const (
+	AttachGoodsReceipt = "goods_receipt"
+	AttachQualityCheck = "quality_check"
+	AttachQCInspection = "qc_inspection"
+	AttachReturn       = "return"
+	AttachDispatch     = "dispatch"
+)
+
+// Attachment is a photo or document kept as evidence on a document. URL
+// and ThumbnailURL are signed links that work without a token until
+// URLExpiresAt; ThumbnailURL is only set for images.
+type Attachment struct {
+	ID           int        `json:"id"`
+	OwnerType    string     `json:"owner_type"`
+	OwnerID      int        `json:"owner_id"`
+	FileName     string     `json:"file_name"`
+	ContentType  string     `json:"content_type"`
+	SizeBytes    int64      `json:"size_bytes"`
+	Checksum     string     `json:"checksum"`
+	Notes        string     `json:"notes"`
+	UploadedBy   *int       `json:"uploaded_by"`
+	UploadedName string     `json:"uploaded_by_name"`
+	CreatedAt    time.Time  `json:"created_at"`
+	URL          string     `json:"url"`
+	ThumbnailURL string     `json:"thumbnail_url,omitempty"`
+	URLExpiresAt *time.Time `json:"url_expires_at"`
+
+	StorageKey   string  `json:"-"`
+	ThumbnailKey *string `json:"-"`
+}
@@ -0,0 +1,151 @@
+package models
+
+import "time"
+
+// Goods receipt statuses. A receipt moves draft → received → qc → putaway →
+// completed; it can be cancelled until put-away starts.
+const (
+	GoodsReceiptDraft     = "draft"
+	GoodsReceiptReceived  = "received"
+	GoodsReceiptQC        = "qc"
+	GoodsReceiptPutaway   = "putaway"
+	GoodsReceiptCompleted = "completed"
+	GoodsReceiptCancelled = "cancelled"
+)
+
+// QC verdicts on a goods receipt line
+const (
+	QCPending  = "pending"
+	QCAccepted = "accepted"
+	QCRejected = "rejected"
+)
+
+// Where rejected receipt lines go when the receipt is completed
+const (
+	DispositionQuarantine       = "quarantine"
+	DispositionReturnToSupplier = "return_to_supplier"
+)
+
+// Goods receipt sources, one per inbound endpoint family
+const (
+	SourceGoodsReceipt = "goods_receipt"
+	SourceReceiving    = "receiving"
+	SourceReception    = "reception"
+	SourcePenerimaan   = "penerimaan"
+)
+
+type GoodsReceipt struct {
+	ID             int                `json:"id"`
+	DocumentNumber string             `json:"document_number"`
+	ReceiptDate    time.Time          `json:"receipt_date"`
+	SupplierID     *int               `json:"supplier_id"`
+	SupplierName   string             `json:"supplier_name"`
+	PONumber       string             `json:"po_number"`
+	WarehouseID    *int               `json:"warehouse_id"`
+	Status         string             `json:"status"`
+	Source         string             `json:"source"`
+	Notes          string             `json:"notes"`
+	CreatedBy      *int               `json:"created_by"`
+	TenantID       *int               `json:"-"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+	CompletedAt    *time.Time         `json:"completed_at"`
+	Lines          []GoodsReceiptLine `json:"lines,omitempty"`
+}
+
+type GoodsReceiptLine struct {
+	ID           int        `json:"id"`
+	ReceiptID    int        `json:"receipt_id"`
+	ProductID    *int       `json:"product_id"`
+	SKU          string     `json:"sku"`
+	ProductName  string     `json:"product_name"`
+	Quantity     int        `json:"quantity"`
+	UnitID       *int       `json:"unit_id"`
+	UnitName     string     `json:"unit_name"`
+	Batch        string     `json:"batch"`
+	ExpiryDate   *time.Time `json:"expiry_date"`
+	LocationID   *int       `json:"location_id"`
+	LocationName string     `json:"location_name"`
+	QCStatus     string     `json:"qc_status"`
+	QCNotes      string     `json:"qc_notes"`
+	Disposition  string     `json:"disposition,omitempty"`
+	// Units of an accepted line that failed inspection
+	RejectedQuantity *int       `json:"rejected_quantity"`
+	QCBy             *int       `json:"qc_by"`
+	QCAt             *time.Time `json:"qc_at"`
+	PostedAt         *time.Time `json:"posted_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type GoodsReceiptStatusChange struct {
+	ID            int       `json:"id"`
+	ReceiptID     int       `json:"receipt_id"`
+	FromStatus    string    `json:"from_status"`
+	ToStatus      string    `json:"to_status"`
+	ChangedBy     *int      `json:"changed_by"`
+	ChangedByName string    `json:"changed_by_name"`
+	ChangedAt     time.Time `json:"changed_at"`
+}
+
+type GoodsReceiptRequest struct {
+	DocumentNumber string                    `json:"document_number"`
+	ReceiptDate    string                    `json:"receipt_date"`
+	SupplierID     *int                      `json:"supplier_id"`
+	SupplierName   string                    `json:"supplier_name"`
+	PONumber       string                    `json:"po_number"`
+	WarehouseID    *int                      `json:"warehouse_id"`
+	Notes          string                    `json:"notes"`
+	Lines          []GoodsReceiptLineRequest `json:"lines"`
+}
+
+type GoodsReceiptLineRequest struct {
+	ProductID    *int   `json:"product_id"`
+	SKU          string `json:"sku"`
+	ProductName  string `json:"product_name"`
+	Quantity     int    `json:"quantity" binding:"required,min=1"`
+	UnitID       *int   `json:"unit_id"`
+	UnitName     string `json:"unit_name"`
+	Batch        string `json:"batch"`
+	ExpiryDate   string `json:"expiry_date"`
+	LocationID   *int   `json:"location_id"`
+	LocationName string `json:"location_name"`
+}
+
+type GoodsReceiptStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+type GoodsReceiptQCRequest struct {
+	QCStatus    string `json:"qc_status" binding:"required"`
+	Notes       string `json:"notes"`
+	Disposition string `json:"disposition"`
+}
+
+type GoodsReceiptPutawayRequest struct {
+	LocationID int `json:"location_id" binding:"required"`
+}
+
+type GoodsReceiptLineProductRequest struct {
+	ProductID int `json:"product_id" binding:"required"`
+}
+
+// QuarantineStock is a rejected receipt line held back from inventory
+type QuarantineStock struct {
+	ID             int        `json:"id"`
+	ReceiptID      *int       `json:"receipt_id"`
+	ReceiptLineID  *int       `json:"receipt_line_id"`
+	ReturnID       *int       `json:"return_id"`
+	DocumentNumber string     `json:"document_number"`
+	ProductID      *int       `json:"product_id"`
+	ProductName    string     `json:"product_name"`
+	Quantity       int        `json:"quantity"`
+	Batch          string     `json:"batch"`
+	ExpiryDate     *time.Time `json:"expiry_date"`
+	SupplierID     *int       `json:"supplier_id"`
+	SupplierName   string     `json:"supplier_name"`
+	WarehouseID    *int       `json:"warehouse_id"`
+	Disposition    string     `json:"disposition"`
+	Reason         string     `json:"reason"`
+	CreatedBy      *int       `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
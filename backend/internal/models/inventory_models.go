@@ -56,22 +56,7 @@ type Dispatch struct {
 	Product    Product   `json:"product" gorm:"foreignKey:ProductID"`
 	Quantity   int       `json:"quantity"`
 	Customer   string    `json:"customer"`
-	Status     string    `json:"status"` // PENDING, PICKED, SHIPPED, DELIVERED
-	Notes      string    `json:"notes"`
-	CreatedBy  uint      `json:"created_by"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-}
-
-// Return - Pengembalian barang
-type Return struct {
-	ID         uint      `json:"id" gorm:"primaryKey"`
-	ProductID  uint      `json:"product_id"`
-	Product    Product   `json:"product" gorm:"foreignKey:ProductID"`
-	Quantity   int       `json:"quantity"`
-	ReturnType string    `json:"return_type"` // CUSTOMER, SUPPLIER
-	Reason     string    `json:"reason"`
-	Status     string    `json:"status"` // PENDING, APPROVED, REJECTED
+	Status     string    `json:"status"` // pending, picked, packed, shipped, delivered, cancelled
 	Notes      string    `json:"notes"`
 	CreatedBy  uint      `json:"created_by"`
 	CreatedAt  time.Time `json:"created_at"`
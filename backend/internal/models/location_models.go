@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// Location types, outermost first. Docks, staging areas and returns areas
+// sit beside the storage tree rather than inside it.
+const (
+	LocationZone    = "zone"
+	LocationAisle   = "aisle"
+	LocationRack    = "rack"
+	LocationLevel   = "level"
+	LocationBin     = "bin"
+	LocationDock    = "dock"
+	LocationStaging = "staging"
+	LocationReturns = "returns"
+)
+
+// Location is a place stock can be kept, from a whole zone down to a single
+// bin. Nil capacities are unlimited. CategoryID dedicates the location and
+// everything below it to one product category; a lower PickSequence is
+// closer to the pick face.
+type Location struct {
+	ID                    int       `json:"id" db:"id"`
+	WarehouseID           *int      `json:"warehouse_id" db:"warehouse_id"`
+	ParentID              *int      `json:"parent_id" db:"parent_id"`
+	ParentCode            string    `json:"parent_code,omitempty"`
+	Name                  string    `json:"name" db:"name"`
+	Code                  string    `json:"code" db:"code"`
+	Type                  string    `json:"location_type" db:"location_type"`
+	Description           string    `json:"description" db:"description"`
+	CategoryID            *int      `json:"category_id" db:"category_id"`
+	PickSequence          *int      `json:"pick_sequence" db:"pick_sequence"`
+	MaxUnits              *int      `json:"max_units" db:"max_units"`
+	MaxWeight             *float64  `json:"max_weight" db:"max_weight"`
+	MaxVolume             *float64  `json:"max_volume" db:"max_volume"`
+	TemperatureControlled bool      `json:"temperature_controlled" db:"temperature_controlled"`
+	Hazmat                bool      `json:"hazmat" db:"hazmat"`
+	IsActive              bool      `json:"is_active" db:"is_active"`
+	UnitsOnHand           int       `json:"units_on_hand"`
+	CreatedAt             time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type LocationRequest struct {
+	WarehouseID           *int     `json:"warehouse_id"`
+	ParentID              *int     `json:"parent_id"`
+	Name                  string   `json:"name"`
+	Code                  string   `json:"code" binding:"required"`
+	Type                  string   `json:"location_type" binding:"required"`
+	Description           string   `json:"description"`
+	CategoryID            *int     `json:"category_id"`
+	PickSequence          *int     `json:"pick_sequence"`
+	MaxUnits              *int     `json:"max_units" binding:"omitempty,min=0"`
+	MaxWeight             *float64 `json:"max_weight" binding:"omitempty,min=0"`
+	MaxVolume             *float64 `json:"max_volume" binding:"omitempty,min=0"`
+	TemperatureControlled bool     `json:"temperature_controlled"`
+	Hazmat                bool     `json:"hazmat"`
+	IsActive              *bool    `json:"is_active"`
+}
+
+// LocationRangeRequest creates every code between FromCode and ToCode, e.g.
+// A-01-01..A-20-05. Both codes must have the same shape; numeric segments
+// are expanded and keep the zero padding of FromCode. When
+// PickSequenceStart is set, locations are numbered from it in code order.
+type LocationRangeRequest struct {
+	WarehouseID           *int     `json:"warehouse_id"`
+	ParentID              *int     `json:"parent_id"`
+	Type                  string   `json:"location_type" binding:"required"`
+	FromCode              string   `json:"from_code" binding:"required"`
+	ToCode                string   `json:"to_code" binding:"required"`
+	Separator             string   `json:"separator"`
+	CategoryID            *int     `json:"category_id"`
+	PickSequenceStart     *int     `json:"pick_sequence_start"`
+	MaxUnits              *int     `json:"max_units" binding:"omitempty,min=0"`
+	MaxWeight             *float64 `json:"max_weight" binding:"omitempty,min=0"`
+	MaxVolume             *float64 `json:"max_volume" binding:"omitempty,min=0"`
+	TemperatureControlled bool     `json:"temperature_controlled"`
+	Hazmat                bool     `json:"hazmat"`
+}
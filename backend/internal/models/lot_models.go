@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// StockLot is the balance of one lot of a product at one location. An
+// empty Batch is stock booked without a lot. Reserved and quarantined
+// stock is not available.
+type StockLot struct {
+	ProductID    int        `json:"product_id"`
+	ProductName  string     `json:"product_name"`
+	SKU          string     `json:"sku"`
+	LocationID   int        `json:"location_id"`
+	LocationCode string     `json:"location_code"`
+	Batch        string     `json:"batch"`
+	ExpiryDate   *time.Time `json:"expiry_date"`
+	DaysToExpiry *int       `json:"days_to_expiry"`
+	Expired      bool       `json:"expired"`
+	Quantity     int        `json:"quantity"`
+	Reserved     int        `json:"reserved_quantity"`
+	Quarantined  int        `json:"quarantined_quantity"`
+	Available    int        `json:"available_quantity"`
+}
+
+// LotOrigin is a goods receipt line that brought a lot in.
+type LotOrigin struct {
+	ReceiptID      int        `json:"receipt_id"`
+	DocumentNumber string     `json:"document_number"`
+	ReceiptDate    time.Time  `json:"receipt_date"`
+	SupplierID     *int       `json:"supplier_id"`
+	SupplierName   string     `json:"supplier_name"`
+	Quantity       int        `json:"quantity"`
+	QCStatus       string     `json:"qc_status"`
+	ExpiryDate     *time.Time `json:"expiry_date"`
+}
+
+// LotRecipient is a customer that was issued part of a lot.
+type LotRecipient struct {
+	Reference    string    `json:"reference"`
+	CustomerID   *int      `json:"customer_id"`
+	CustomerName string    `json:"customer_name"`
+	Quantity     int       `json:"quantity"`
+	IssuedAt     time.Time `json:"issued_at"`
+}
+
+type LotTrace struct {
+	ProductID  int            `json:"product_id"`
+	Batch      string         `json:"batch"`
+	Origins    []LotOrigin    `json:"origins"`
+	OnHand     []StockLot     `json:"on_hand"`
+	Recipients []LotRecipient `json:"recipients"`
+}
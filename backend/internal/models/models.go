@@ -1,26 +1,33 @@
 package models
 
 type User struct {
-	ID        int      `json:"id"`
-	Username  string   `json:"username"`
-	Email     string   `json:"email"`
-	IsStaff   bool     `json:"is_staff"`
-	Role      string   `json:"role"`
-	Roles     []string `json:"roles"`
-	FirstName string   `json:"first_name"`
-	LastName  string   `json:"last_name"`
-	IsActive  bool     `json:"is_active"`
+	ID          int      `json:"id"`
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	IsStaff     bool     `json:"is_staff"`
+	Role        string   `json:"role"`
+	Roles       []string `json:"roles"`
+	FirstName   string   `json:"first_name"`
+	LastName    string   `json:"last_name"`
+	IsActive    bool     `json:"is_active"`
+	WarehouseID *int     `json:"warehouse_id,omitempty"`
 }
 
 type Product struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	SKU         string  `json:"sku"`
-	CategoryID  int     `json:"category_id"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Stock       int     `json:"stock"`
-	CreatedAt   string  `json:"created_at"`
+	ID                    int      `json:"id"`
+	Name                  string   `json:"name"`
+	SKU                   string   `json:"sku"`
+	CategoryID            int      `json:"category_id"`
+	Description           string   `json:"description"`
+	Price                 float64  `json:"price"`
+	Stock                 int      `json:"stock"`
+	TemperatureControlled bool     `json:"temperature_controlled"`
+	Hazmat                bool     `json:"hazmat"`
+	UnitWeight            *float64 `json:"unit_weight"`
+	UnitVolume            *float64 `json:"unit_volume"`
+	AllocationStrategy    string   `json:"allocation_strategy"`
+	FixedLocationID       *int     `json:"fixed_location_id"`
+	CreatedAt             string   `json:"created_at"`
 }
 
 type Category struct {
@@ -45,13 +52,14 @@ type RegisterRequest struct {
 }
 
 type CreateUserRequest struct {
-	Username  string   `json:"username"`
-	Email     string   `json:"email"`
-	Password  string   `json:"password"`
-	FirstName string   `json:"first_name"`
-	LastName  string   `json:"last_name"`
-	Role      string   `json:"role"`
-	Roles     []string `json:"roles"`
+	Username    string   `json:"username"`
+	Email       string   `json:"email"`
+	Password    string   `json:"password"`
+	FirstName   string   `json:"first_name"`
+	LastName    string   `json:"last_name"`
+	Role        string   `json:"role"`
+	Roles       []string `json:"roles"`
+	WarehouseID *int     `json:"warehouse_id"`
 }
 
 type TokenResponse struct {
@@ -59,6 +67,10 @@ type TokenResponse struct {
 	Refresh string `json:"refresh"`
 }
 
+type RefreshRequest struct {
+	Refresh string `json:"refresh"`
+}
+
 // Goods Receipt Models
 type PenerimaanBarang struct {
 	ID         int    `json:"id"`
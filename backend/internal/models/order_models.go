@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+// Outbound order statuses, in lifecycle order. Orders can be cancelled
+// until they ship.
+const (
+	OrderNew       = "new"
+	OrderAllocated = "allocated"
+	OrderPicking   = "picking"
+	OrderPacked    = "packed"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+)
+
+// RefOrderLine is the reservation reference of an order line; stock is
+// reserved and shipped per line.
+const RefOrderLine = "ORDER_LINE"
+
+// Order is a sales order to ship to a customer. BackorderOf links a
+// backorder to the order whose shortfall it carries.
+type Order struct {
+	ID           int         `json:"id"`
+	OrderNumber  string      `json:"order_number"`
+	CustomerID   *int        `json:"customer_id"`
+	Customer     string      `json:"customer"`
+	WarehouseID  *int        `json:"warehouse_id"`
+	Status       string      `json:"status"`
+	OrderDate    time.Time   `json:"order_date"`
+	RequiredDate *time.Time  `json:"required_date"`
+	TotalAmount  float64     `json:"total_amount"`
+	Notes        string      `json:"notes"`
+	BackorderOf  *int        `json:"backorder_of"`
+	CreatedBy    *int        `json:"created_by"`
+	ShippedAt    *time.Time  `json:"shipped_at"`
+	DeliveredAt  *time.Time  `json:"delivered_at"`
+	TenantID     *int        `json:"-"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+	Lines        []OrderLine `json:"lines,omitempty"`
+}
+
+// OrderLine tracks how much of its quantity is reserved (allocated),
+// shipped and carried over to a backorder. The rest is outstanding.
+// PickedQuantity is the part of the allocation already picked.
+type OrderLine struct {
+	ID                  int     `json:"id"`
+	OrderID             int     `json:"order_id"`
+	ProductID           int     `json:"product_id"`
+	ProductName         string  `json:"product_name"`
+	SKU                 string  `json:"sku"`
+	UnitID              *int    `json:"unit_id"`
+	Quantity            int     `json:"quantity"`
+	UnitPrice           float64 `json:"unit_price"`
+	AllocatedQuantity   int     `json:"allocated_quantity"`
+	PickedQuantity      int     `json:"picked_quantity"`
+	ShippedQuantity     int     `json:"shipped_quantity"`
+	BackorderedQuantity int     `json:"backordered_quantity"`
+	OutstandingQuantity int     `json:"outstanding_quantity"`
+}
+
+type OrderRequest struct {
+	CustomerID   int                `json:"customer_id" binding:"required"`
+	OrderDate    string             `json:"order_date"`
+	RequiredDate string             `json:"required_date"`
+	Notes        string             `json:"notes"`
+	Lines        []OrderLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+type OrderLineRequest struct {
+	ProductID int     `json:"product_id" binding:"required"`
+	UnitID    *int    `json:"unit_id"`
+	Quantity  int     `json:"quantity" binding:"required,min=1"`
+	UnitPrice float64 `json:"unit_price" binding:"min=0"`
+}
+
+// OrderShortage is what an allocation run could not reserve for a line.
+type OrderShortage struct {
+	LineID    int `json:"line_id"`
+	ProductID int `json:"product_id"`
+	Requested int `json:"requested"`
+	Available int `json:"available"`
+}
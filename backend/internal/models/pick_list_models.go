@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// Picking modes. A single pick list covers one order, a batch list several
+// orders, and zone picking splits several orders into one list per zone.
+const (
+	PickSingle = "single"
+	PickBatch  = "batch"
+	PickZone   = "zone"
+)
+
+// Pick list and pick line statuses
+const (
+	PickListOpen       = "open"
+	PickListInProgress = "in_progress"
+	PickListCompleted  = "completed"
+	PickListCancelled  = "cancelled"
+
+	PickLinePending   = "pending"
+	PickLinePicked    = "picked"
+	PickLineShort     = "short"
+	PickLineCancelled = "cancelled"
+)
+
+type PickList struct {
+	ID             int            `json:"id"`
+	DocumentNumber string         `json:"document_number"`
+	WarehouseID    *int           `json:"warehouse_id"`
+	Mode           string         `json:"mode"`
+	ZoneID         *int           `json:"zone_id"`
+	Status         string         `json:"status"`
+	AssignedTo     *int           `json:"assigned_to"`
+	CreatedBy      *int           `json:"created_by"`
+	CompletedAt    *time.Time     `json:"completed_at"`
+	TenantID       *int           `json:"-"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	Lines          []PickListLine `json:"lines,omitempty"`
+}
+
+// PickListLine takes one reservation from its location. Lines are
+// numbered in walk order.
+type PickListLine struct {
+	ID             int        `json:"id"`
+	PickListID     int        `json:"pick_list_id"`
+	Sequence       int        `json:"sequence"`
+	OrderID        int        `json:"order_id"`
+	OrderNumber    string     `json:"order_number"`
+	OrderLineID    int        `json:"order_line_id"`
+	ReservationID  int        `json:"reservation_id"`
+	ProductID      int        `json:"product_id"`
+	ProductName    string     `json:"product_name"`
+	SKU            string     `json:"sku"`
+	LocationID     int        `json:"location_id"`
+	LocationCode   string     `json:"location_code"`
+	Batch          string     `json:"batch"`
+	Quantity       int        `json:"quantity"`
+	PickedQuantity int        `json:"picked_quantity"`
+	Status         string     `json:"status"`
+	Notes          string     `json:"notes"`
+	PickedBy       *int       `json:"picked_by"`
+	PickedAt       *time.Time `json:"picked_at"`
+}
+
+type PickListRequest struct {
+	Mode       string `json:"mode" binding:"required"`
+	OrderIDs   []int  `json:"order_ids" binding:"required,min=1"`
+	AssignedTo *int   `json:"assigned_to"`
+}
+
+type PickAssignRequest struct {
+	UserID *int `json:"user_id"`
+}
+
+// PickConfirmRequest confirms a line from a handheld. Quantity defaults to
+// the full line; less than that is a short pick. LocationCode, when
+// scanned, must match the line.
+type PickConfirmRequest struct {
+	Quantity     *int   `json:"quantity" binding:"omitempty,min=0"`
+	LocationCode string `json:"location_code"`
+	Notes        string `json:"notes"`
+}
+
+type PickShortRequest struct {
+	PickedQuantity int    `json:"picked_quantity" binding:"min=0"`
+	Notes          string `json:"notes"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// Put-away task statuses
+const (
+	PutawayPending   = "pending"
+	PutawayCompleted = "completed"
+	PutawayCancelled = "cancelled"
+)
+
+// PutawaySuggestion is one candidate bin for a product, best first.
+// RemainingUnits is nil when the bin has no unit limit.
+type PutawaySuggestion struct {
+	LocationID     int      `json:"location_id"`
+	LocationCode   string   `json:"location_code"`
+	LocationType   string   `json:"location_type"`
+	PickSequence   *int     `json:"pick_sequence"`
+	UnitsOnHand    int      `json:"units_on_hand"`
+	RemainingUnits *int     `json:"remaining_units"`
+	Reasons        []string `json:"reasons"`
+}
+
+// PutawayTask moves received stock from a dock into storage. The operator
+// may confirm a different bin than suggested as long as it fits.
+type PutawayTask struct {
+	ID                    int        `json:"id"`
+	WarehouseID           *int       `json:"warehouse_id"`
+	ProductID             int        `json:"product_id"`
+	ProductName           string     `json:"product_name"`
+	SKU                   string     `json:"sku"`
+	Quantity              int        `json:"quantity"`
+	Batch                 string     `json:"batch"`
+	ExpiryDate            *time.Time `json:"expiry_date"`
+	FromLocationID        int        `json:"from_location_id"`
+	FromLocationCode      string     `json:"from_location_code"`
+	SuggestedLocationID   *int       `json:"suggested_location_id"`
+	SuggestedLocationCode string     `json:"suggested_location_code"`
+	ToLocationID          *int       `json:"to_location_id"`
+	ToLocationCode        string     `json:"to_location_code"`
+	Status                string     `json:"status"`
+	Reference             string     `json:"reference"`
+	ReceiptLineID         *int       `json:"receipt_line_id"`
+	AssignedTo            *int       `json:"assigned_to"`
+	CreatedBy             *int       `json:"created_by"`
+	ConfirmedBy           *int       `json:"confirmed_by"`
+	ConfirmedAt           *time.Time `json:"confirmed_at"`
+	TenantID              *int       `json:"-"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+type PutawayTaskRequest struct {
+	ProductID      int    `json:"product_id" binding:"required"`
+	Quantity       int    `json:"quantity" binding:"required,min=1"`
+	FromLocationID int    `json:"from_location_id" binding:"required"`
+	Batch          string `json:"batch"`
+	ExpiryDate     string `json:"expiry_date"`
+	AssignedTo     *int   `json:"assigned_to"`
+}
+
+type PutawayAssignRequest struct {
+	UserID *int `json:"user_id"`
+}
+
+// PutawayConfirmRequest carries the bin the operator scanned, by code or id.
+type PutawayConfirmRequest struct {
+	LocationCode string `json:"location_code"`
+	LocationID   int    `json:"location_id"`
+}
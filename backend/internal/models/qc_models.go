@@ -0,0 +1,258 @@
+package models
+
+import "time"
+
+// QC inspection types. Incoming inspections decide on a goods receipt
+// line before it is posted, outgoing ones on stock about to leave and
+// return ones on goods received back from customers.
+const (
+	QCIncoming = "INCOMING"
+	QCOutgoing = "OUTGOING"
+	QCReturn   = "RETURN"
+)
+
+// QC inspection statuses. An inspection is open until its result is
+// recorded or it is cancelled.
+const (
+	InspectionOpen      = "open"
+	InspectionCompleted = "completed"
+	InspectionCancelled = "cancelled"
+)
+
+// Quarantine hold statuses. Released stock can be allocated again,
+// scrapped stock has left inventory.
+const (
+	HoldActive   = "active"
+	HoldReleased = "released"
+	HoldScrapped = "scrapped"
+)
+
+// Checklist item types. A measurement passes when its value is within the
+// item's tolerance.
+const (
+	QCItemCheck       = "check"
+	QCItemMeasurement = "measurement"
+)
+
+// QCInspection splits Quantity of a product into passed and failed units.
+// CheckedQuantity is how many were actually looked at, which may be a
+// sample of the whole. An inspection opened under a template with a
+// sampling plan carries the sample it asks for.
+type QCInspection struct {
+	ID              int        `json:"id"`
+	DocumentNumber  string     `json:"document_number"`
+	CheckType       string     `json:"check_type"`
+	ReceiptLineID   *int       `json:"receipt_line_id"`
+	DispatchID      *int       `json:"dispatch_id"`
+	ReturnID        *int       `json:"return_id"`
+	SourceDocument  string     `json:"source_document"`
+	ProductID       int        `json:"product_id"`
+	ProductName     string     `json:"product_name"`
+	SKU             string     `json:"sku"`
+	LocationID      *int       `json:"location_id"`
+	LocationCode    string     `json:"location_code"`
+	Batch           string     `json:"batch"`
+	Quantity        int        `json:"quantity"`
+	CheckedQuantity int        `json:"checked_quantity"`
+	PassedQuantity  int        `json:"passed_quantity"`
+	FailedQuantity  int        `json:"failed_quantity"`
+	Status          string     `json:"status"`
+	Notes           string     `json:"notes"`
+	InspectedBy     *int       `json:"inspected_by"`
+	InspectedAt     *time.Time `json:"inspected_at"`
+	HoldID          *int       `json:"hold_id"`
+	HoldStatus      string     `json:"hold_status"`
+	TemplateID      *int       `json:"template_id"`
+	SamplingPlanID  *int       `json:"sampling_plan_id"`
+	Sampling        *Sampling  `json:"sampling"`
+	Defects         int        `json:"defects"`
+	WarehouseID     *int       `json:"warehouse_id"`
+	CreatedBy       *int       `json:"created_by"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	Checklist []QCTemplateItem `json:"checklist,omitempty"`
+	Results   []QCItemResult   `json:"results,omitempty"`
+}
+
+// QuarantineHold is stock an inspection keeps from being allocated.
+type QuarantineHold struct {
+	ID             int        `json:"id"`
+	InspectionID   int        `json:"inspection_id"`
+	DocumentNumber string     `json:"document_number"`
+	ProductID      int        `json:"product_id"`
+	ProductName    string     `json:"product_name"`
+	LocationID     int        `json:"location_id"`
+	LocationCode   string     `json:"location_code"`
+	Batch          string     `json:"batch"`
+	Quantity       int        `json:"quantity"`
+	Status         string     `json:"status"`
+	Reason         string     `json:"reason"`
+	CreatedBy      *int       `json:"created_by"`
+	ReleasedBy     *int       `json:"released_by"`
+	ReleasedAt     *time.Time `json:"released_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// QCInspectionRequest opens an inspection. Incoming inspections name a
+// receipt line and return inspections a received return; the product,
+// batch and location come from that document. Outgoing inspections name
+// the stock itself and may refer to the dispatch it is for. Quantity
+// defaults to all of the document's goods still undecided.
+type QCInspectionRequest struct {
+	CheckType     string `json:"check_type" binding:"required"`
+	ReceiptLineID *int   `json:"receipt_line_id"`
+	DispatchID    *int   `json:"dispatch_id"`
+	ReturnID      *int   `json:"return_id"`
+	ProductID     int    `json:"product_id"`
+	LocationID    int    `json:"location_id"`
+	Batch         string `json:"batch"`
+	Quantity      *int   `json:"quantity" binding:"omitempty,min=1"`
+	Notes         string `json:"notes"`
+}
+
+// QCResultRequest records an inspection's outcome. Passed and failed
+// quantities must add up to the inspected quantity. Under a sampling plan
+// they may be left out: the lot passes whole when the defects found in the
+// sample are within the acceptance number and fails whole otherwise.
+// CheckedQuantity defaults to the sample size, or all of the quantity.
+type QCResultRequest struct {
+	CheckedQuantity *int                  `json:"checked_quantity" binding:"omitempty,min=0"`
+	PassedQuantity  *int                  `json:"passed_quantity" binding:"omitempty,min=0"`
+	FailedQuantity  *int                  `json:"failed_quantity" binding:"omitempty,min=0"`
+	Items           []QCItemResultRequest `json:"items" binding:"dive"`
+	Notes           string                `json:"notes"`
+}
+
+// QCItemResultRequest answers one checklist item. Checks take Passed,
+// measurements take Value. Defects defaults to one for a failed item.
+type QCItemResultRequest struct {
+	TemplateItemID int      `json:"template_item_id" binding:"required"`
+	Passed         *bool    `json:"passed"`
+	Value          *float64 `json:"value"`
+	Defects        int      `json:"defects" binding:"min=0"`
+	PhotoURL       string   `json:"photo_url"`
+	Notes          string   `json:"notes"`
+}
+
+type QuarantineHoldRequest struct {
+	Notes string `json:"notes"`
+}
+
+// QCSamplingPlan sizes samples by ISO 2859-1 single sampling for normal
+// inspection at one general inspection level and AQL.
+type QCSamplingPlan struct {
+	ID              int       `json:"id"`
+	Name            string    `json:"name"`
+	InspectionLevel string    `json:"inspection_level"`
+	AQL             float64   `json:"aql"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Sampling is what a plan asks for on one lot. The lot is accepted with
+// at most AcceptNumber defects in the sample and rejected from
+// RejectNumber on.
+type Sampling struct {
+	LotSize      int    `json:"lot_size"`
+	CodeLetter   string `json:"code_letter"`
+	SampleSize   int    `json:"sample_size"`
+	AcceptNumber int    `json:"accept_number"`
+	RejectNumber int    `json:"reject_number"`
+}
+
+// QCTemplate is the checklist for a product, or for every product of a
+// category that has none of its own.
+type QCTemplate struct {
+	ID             int              `json:"id"`
+	Name           string           `json:"name"`
+	Description    string           `json:"description"`
+	ProductID      *int             `json:"product_id"`
+	ProductName    string           `json:"product_name"`
+	CategoryID     *int             `json:"category_id"`
+	CategoryName   string           `json:"category_name"`
+	SamplingPlanID *int             `json:"sampling_plan_id"`
+	IsActive       bool             `json:"is_active"`
+	CreatedBy      *int             `json:"created_by"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+	Items          []QCTemplateItem `json:"items"`
+}
+
+type QCTemplateItem struct {
+	ID            int      `json:"id"`
+	Position      int      `json:"position"`
+	Label         string   `json:"label"`
+	ItemType      string   `json:"item_type"`
+	Unit          string   `json:"unit"`
+	TargetValue   *float64 `json:"target_value"`
+	MinValue      *float64 `json:"min_value"`
+	MaxValue      *float64 `json:"max_value"`
+	PhotoRequired bool     `json:"photo_required"`
+	IsRequired    bool     `json:"is_required"`
+}
+
+// QCItemResult is the answer to one checklist item of an inspection.
+type QCItemResult struct {
+	ID             int       `json:"id"`
+	TemplateItemID *int      `json:"template_item_id"`
+	Label          string    `json:"label"`
+	ItemType       string    `json:"item_type"`
+	Passed         bool      `json:"passed"`
+	Value          *float64  `json:"value"`
+	Defects        int       `json:"defects"`
+	PhotoURL       string    `json:"photo_url"`
+	Notes          string    `json:"notes"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type QCSamplingPlanRequest struct {
+	Name            string  `json:"name" binding:"required"`
+	InspectionLevel string  `json:"inspection_level"`
+	AQL             float64 `json:"aql" binding:"required"`
+}
+
+// QCTemplateRequest creates or replaces a template. It is attached to
+// exactly one of ProductID or CategoryID; items are kept in the order
+// given.
+type QCTemplateRequest struct {
+	Name           string                  `json:"name" binding:"required"`
+	Description    string                  `json:"description"`
+	ProductID      *int                    `json:"product_id"`
+	CategoryID     *int                    `json:"category_id"`
+	SamplingPlanID *int                    `json:"sampling_plan_id"`
+	IsActive       *bool                   `json:"is_active"`
+	Items          []QCTemplateItemRequest `json:"items" binding:"dive"`
+}
+
+type QCTemplateItemRequest struct {
+	Label         string   `json:"label" binding:"required"`
+	ItemType      string   `json:"item_type"`
+	Unit          string   `json:"unit"`
+	TargetValue   *float64 `json:"target_value"`
+	MinValue      *float64 `json:"min_value"`
+	MaxValue      *float64 `json:"max_value"`
+	PhotoRequired bool     `json:"photo_required"`
+	IsRequired    *bool    `json:"is_required"`
+}
+
+// QCDefectRate sums up what inspections found in one supplier's goods.
+// DefectRate is defects per hundred sampled units.
+type QCDefectRate struct {
+	SupplierID      *int               `json:"supplier_id"`
+	SupplierName    string             `json:"supplier_name"`
+	Inspections     int                `json:"inspections"`
+	RejectedLots    int                `json:"rejected_lots"`
+	CheckedQuantity int                `json:"checked_quantity"`
+	Defects         int                `json:"defects"`
+	DefectRate      float64            `json:"defect_rate"`
+	Items           []QCItemDefectRate `json:"items"`
+}
+
+// QCItemDefectRate is how often one checklist item failed.
+type QCItemDefectRate struct {
+	Label   string `json:"label"`
+	Results int    `json:"results"`
+	Failed  int    `json:"failed"`
+	Defects int    `json:"defects"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Stock reservation statuses
+const (
+	ReservationActive   = "active"
+	ReservationReleased = "released"
+	ReservationConsumed = "consumed"
+)
+
+// StockReservation holds part of a balance for one document until it is
+// shipped or cancelled.
+type StockReservation struct {
+	ID            int       `json:"id"`
+	ProductID     int       `json:"product_id"`
+	ProductName   string    `json:"product_name"`
+	SKU           string    `json:"sku"`
+	LocationID    int       `json:"location_id"`
+	LocationCode  string    `json:"location_code"`
+	Batch         string    `json:"batch"`
+	Quantity      int       `json:"quantity"`
+	ReferenceType string    `json:"reference_type"`
+	ReferenceID   int       `json:"reference_id"`
+	Status        string    `json:"status"`
+	CreatedBy     *int      `json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Outbound request statuses
+const (
+	OutboundPending   = "pending"
+	OutboundConfirmed = "confirmed"
+	OutboundShipped   = "shipped"
+	OutboundCancelled = "cancelled"
+)
+
+// OutboundConfirmRequest optionally restricts the reservation to one
+// location; otherwise the product's allocation strategy picks.
+type OutboundConfirmRequest struct {
+	LocationID int `json:"location_id"`
+}
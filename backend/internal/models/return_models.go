@@ -0,0 +1,117 @@
+package models
+
+import "time"
+
+// Return types. Customers send goods back to the warehouse; the warehouse
+// sends goods back to suppliers.
+const (
+	ReturnCustomer = "CUSTOMER"
+	ReturnSupplier = "SUPPLIER"
+)
+
+// Return statuses. Customer returns go pending → approved → received →
+// completed once QC has decided on every received unit; supplier returns
+// go pending → approved → completed when they are shipped.
+const (
+	ReturnPending   = "pending"
+	ReturnApproved  = "approved"
+	ReturnRejected  = "rejected"
+	ReturnReceived  = "received"
+	ReturnCompleted = "completed"
+	ReturnCancelled = "cancelled"
+)
+
+// Where QC sends returned goods. Quarantined goods and goods going back to
+// the supplier leave inventory for the quarantine list, like rejected
+// receipt lines.
+const (
+	DispositionRestock = "restock"
+	DispositionScrap   = "scrap"
+)
+
+// Return is an RMA. It names the document the goods originally moved on:
+// an issuing or dispatch for customer returns, a receipt line for supplier
+// returns.
+type Return struct {
+	ID               int                 `json:"id"`
+	DocumentNumber   string              `json:"document_number"`
+	ReturnType       string              `json:"return_type"`
+	IssuingID        *int                `json:"issuing_id"`
+	DispatchID       *int                `json:"dispatch_id"`
+	ReceiptLineID    *int                `json:"receipt_line_id"`
+	SourceDocument   string              `json:"source_document"`
+	ProductID        int                 `json:"product_id"`
+	ProductName      string              `json:"product_name"`
+	SKU              string              `json:"sku"`
+	Quantity         int                 `json:"quantity"`
+	Batch            string              `json:"batch"`
+	CustomerID       *int                `json:"customer_id"`
+	Customer         string              `json:"customer"`
+	SupplierID       *int                `json:"supplier_id"`
+	WarehouseID      *int                `json:"warehouse_id"`
+	Reason           string              `json:"reason"`
+	Notes            string              `json:"notes"`
+	Status           string              `json:"status"`
+	LocationID       *int                `json:"location_id"`
+	LocationCode     string              `json:"location_code"`
+	ReceivedQuantity int                 `json:"received_quantity"`
+	DecisionNotes    string              `json:"decision_notes"`
+	DecidedBy        *int                `json:"decided_by"`
+	DecidedAt        *time.Time          `json:"decided_at"`
+	ReceivedBy       *int                `json:"received_by"`
+	ReceivedAt       *time.Time          `json:"received_at"`
+	CompletedAt      *time.Time          `json:"completed_at"`
+	CreatedBy        *int                `json:"created_by"`
+	CreatedAt        time.Time           `json:"created_at"`
+	UpdatedAt        time.Time           `json:"updated_at"`
+	Dispositions     []ReturnDisposition `json:"dispositions,omitempty"`
+}
+
+type ReturnDisposition struct {
+	ID           int       `json:"id"`
+	Disposition  string    `json:"disposition"`
+	Quantity     int       `json:"quantity"`
+	LocationID   *int      `json:"location_id"`
+	LocationCode string    `json:"location_code"`
+	Notes        string    `json:"notes"`
+	CreatedBy    *int      `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ReturnRequest opens an RMA against exactly one of IssuingID or
+// DispatchID for a customer return, or ReceiptLineID for a supplier
+// return. The product and batch come from that document.
+type ReturnRequest struct {
+	ReturnType    string `json:"return_type" binding:"required"`
+	IssuingID     *int   `json:"issuing_id"`
+	DispatchID    *int   `json:"dispatch_id"`
+	ReceiptLineID *int   `json:"receipt_line_id"`
+	Quantity      int    `json:"quantity" binding:"required,min=1"`
+	Reason        string `json:"reason" binding:"required"`
+	Notes         string `json:"notes"`
+}
+
+type ReturnDecisionRequest struct {
+	Notes string `json:"notes"`
+}
+
+// ReturnReceiveRequest books the goods that came back into a returns
+// location. Quantity defaults to the whole return.
+type ReturnReceiveRequest struct {
+	LocationID int  `json:"location_id" binding:"required"`
+	Quantity   *int `json:"quantity" binding:"omitempty,min=1"`
+}
+
+// ReturnDispositionRequest decides on received goods. Restocked goods need
+// the LocationID they are put away to.
+type ReturnDispositionRequest struct {
+	Disposition string `json:"disposition" binding:"required"`
+	Quantity    int    `json:"quantity" binding:"required,min=1"`
+	LocationID  int    `json:"location_id"`
+	Notes       string `json:"notes"`
+}
+
+// ReturnShipRequest sends a supplier return from LocationID.
+type ReturnShipRequest struct {
+	LocationID int `json:"location_id" binding:"required"`
+}
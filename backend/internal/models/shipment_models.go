@@ -0,0 +1,100 @@
+package models
+
+import "time"
+
+// Dispatch statuses. Picking reserves the stock, packing puts it into
+// cartons and the stock only leaves inventory when the shipment carrying
+// the cartons ships.
+const (
+	DispatchPending   = "pending"
+	DispatchPicked    = "picked"
+	DispatchPacked    = "packed"
+	DispatchShipped   = "shipped"
+	DispatchDelivered = "delivered"
+	DispatchCancelled = "cancelled"
+)
+
+// Shipment statuses
+const (
+	ShipmentOpen      = "open"
+	ShipmentShipped   = "shipped"
+	ShipmentDelivered = "delivered"
+	ShipmentCancelled = "cancelled"
+)
+
+// Carton is one packed unit, identified by an SSCC-style code.
+type Carton struct {
+	ID          int          `json:"id"`
+	SSCC        string       `json:"sscc"`
+	ShipmentID  *int         `json:"shipment_id"`
+	WarehouseID *int         `json:"warehouse_id"`
+	WeightKg    *float64     `json:"weight_kg"`
+	LengthCm    *float64     `json:"length_cm"`
+	WidthCm     *float64     `json:"width_cm"`
+	HeightCm    *float64     `json:"height_cm"`
+	PackedBy    *int         `json:"packed_by"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Lines       []CartonLine `json:"lines,omitempty"`
+}
+
+type CartonLine struct {
+	ID          int    `json:"id"`
+	DispatchID  int    `json:"dispatch_id"`
+	ProductName string `json:"product_name"`
+	Customer    string `json:"customer"`
+	Quantity    int    `json:"quantity"`
+}
+
+// Shipment groups cartons handed to one carrier.
+type Shipment struct {
+	ID             int        `json:"id"`
+	ShipmentNumber string     `json:"shipment_number"`
+	WarehouseID    *int       `json:"warehouse_id"`
+	Carrier        string     `json:"carrier"`
+	TrackingNumber string     `json:"tracking_number"`
+	ShipDate       *time.Time `json:"ship_date"`
+	Status         string     `json:"status"`
+	Notes          string     `json:"notes"`
+	CreatedBy      *int       `json:"created_by"`
+	ShippedAt      *time.Time `json:"shipped_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Cartons        []Carton   `json:"cartons,omitempty"`
+}
+
+// DispatchPickRequest optionally restricts picking to one location;
+// otherwise the product's allocation strategy picks.
+type DispatchPickRequest struct {
+	LocationID int `json:"location_id"`
+}
+
+type CartonRequest struct {
+	Lines    []CartonLineRequest `json:"lines" binding:"required,min=1,dive"`
+	WeightKg *float64            `json:"weight_kg" binding:"omitempty,gt=0"`
+	LengthCm *float64            `json:"length_cm" binding:"omitempty,gt=0"`
+	WidthCm  *float64            `json:"width_cm" binding:"omitempty,gt=0"`
+	HeightCm *float64            `json:"height_cm" binding:"omitempty,gt=0"`
+}
+
+type CartonLineRequest struct {
+	DispatchID int `json:"dispatch_id" binding:"required"`
+	Quantity   int `json:"quantity" binding:"required,min=1"`
+}
+
+// ShipmentRequest creates or edits an open shipment. CartonIDs is the
+// full set of cartons on it.
+type ShipmentRequest struct {
+	Carrier        string `json:"carrier" binding:"required"`
+	TrackingNumber string `json:"tracking_number"`
+	ShipDate       string `json:"ship_date"`
+	Notes          string `json:"notes"`
+	CartonIDs      []int  `json:"carton_ids"`
+}
+
+// ShipmentShipRequest can fill in the tracking number and ship date when
+// the carrier collects; the ship date defaults to today.
+type ShipmentShipRequest struct {
+	TrackingNumber string `json:"tracking_number"`
+	ShipDate       string `json:"ship_date"`
+}
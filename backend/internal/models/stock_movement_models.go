@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// Stock movement types. IN adds to a location's balance, OUT subtracts.
+const (
+	MovementIn  = "IN"
+	MovementOut = "OUT"
+)
+
+// Reference types tie a movement to the document that caused it
+const (
+	RefGoodsReceipt = "GOODS_RECEIPT"
+	RefIssuing      = "ISSUING"
+	RefInventory    = "INVENTORY"
+	RefOpname       = "OPNAME"
+	RefAdjustment   = "ADJUSTMENT"
+	RefTransfer     = "TRANSFER"
+	RefPutaway      = "PUTAWAY"
+	RefDispatch     = "DISPATCH"
+	RefOutbound     = "OUTBOUND_REQUEST"
+	RefOpening      = "OPENING"
+	RefReturn       = "RETURN"
+	RefQualityCheck = "QUALITY_CHECK"
+)
+
+type StockLedgerEntry struct {
+	ID            int        `json:"id"`
+	ProductID     int        `json:"product_id"`
+	ProductName   string     `json:"product_name"`
+	SKU           string     `json:"sku"`
+	LocationID    *int       `json:"location_id"`
+	LocationCode  string     `json:"location_code"`
+	MovementType  string     `json:"movement_type"`
+	Quantity      int        `json:"quantity"`
+	Reference     string     `json:"reference"`
+	ReferenceType string     `json:"reference_type"`
+	ReasonCode    string     `json:"reason_code"`
+	Batch         string     `json:"batch"`
+	ExpiryDate    *time.Time `json:"expiry_date"`
+	Notes         string     `json:"notes"`
+	CreatedBy     *int       `json:"created_by"`
+	CreatedByName string     `json:"created_by_name"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type StockAdjustmentRequest struct {
+	ProductID    int    `json:"product_id" binding:"required"`
+	LocationID   int    `json:"location_id" binding:"required"`
+	MovementType string `json:"movement_type" binding:"required"`
+	Quantity     int    `json:"quantity" binding:"required,min=1"`
+	ReasonCode   string `json:"reason_code" binding:"required"`
+	Batch        string `json:"batch"`
+	ExpiryDate   string `json:"expiry_date"`
+	Notes        string `json:"notes"`
+}
+
+type StockReasonCode struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Direction   string `json:"direction"`
+}
+
+// StockDrift compares a balance with the sum of its movements
+type StockDrift struct {
+	ProductID    int    `json:"product_id"`
+	ProductName  string `json:"product_name"`
+	LocationID   int    `json:"location_id"`
+	LocationCode string `json:"location_code"`
+	InventoryQty int    `json:"inventory_quantity"`
+	LedgerQty    int    `json:"ledger_quantity"`
+	Drift        int    `json:"drift"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// Stock opname statuses. Counting happens while open; a submitted count
+// waits for a supervisor to approve or reject it.
+const (
+	StockOpnameOpen      = "open"
+	StockOpnameSubmitted = "submitted"
+	StockOpnameApproved  = "approved"
+	StockOpnameRejected  = "rejected"
+	StockOpnameCancelled = "cancelled"
+)
+
+type StockOpnameSession struct {
+	ID             int               `json:"id"`
+	DocumentNumber string            `json:"document_number"`
+	WarehouseID    *int              `json:"warehouse_id"`
+	LocationID     *int              `json:"location_id"`
+	Blind          bool              `json:"blind"`
+	Status         string            `json:"status"`
+	Notes          string            `json:"notes"`
+	ReviewNotes    string            `json:"review_notes"`
+	CreatedBy      *int              `json:"created_by"`
+	ReviewedBy     *int              `json:"reviewed_by"`
+	ReviewedAt     *time.Time        `json:"reviewed_at"`
+	TenantID       *int              `json:"-"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	Lines          []StockOpnameLine `json:"lines,omitempty"`
+}
+
+// StockOpnameLine is one product at one location. SystemQuantity and
+// Difference are withheld from counters while a blind count is open.
+type StockOpnameLine struct {
+	ID              int        `json:"id"`
+	OpnameID        int        `json:"opname_id"`
+	ProductID       int        `json:"product_id"`
+	ProductName     string     `json:"product_name"`
+	SKU             string     `json:"sku"`
+	LocationID      int        `json:"location_id"`
+	LocationCode    string     `json:"location_code"`
+	SystemQuantity  *int       `json:"system_quantity,omitempty"`
+	CountedQuantity *int       `json:"counted_quantity"`
+	Difference      *int       `json:"difference,omitempty"`
+	CountedBy       *int       `json:"counted_by"`
+	CountedAt       *time.Time `json:"counted_at"`
+	Notes           string     `json:"notes"`
+}
+
+type StockOpnameRequest struct {
+	WarehouseID *int   `json:"warehouse_id"`
+	LocationID  *int   `json:"location_id"`
+	ProductIDs  []int  `json:"product_ids"`
+	Blind       *bool  `json:"blind"`
+	Notes       string `json:"notes"`
+}
+
+type StockOpnameCountRequest struct {
+	ProductID       int    `json:"product_id" binding:"required"`
+	LocationID      int    `json:"location_id" binding:"required"`
+	CountedQuantity *int   `json:"counted_quantity" binding:"required,min=0"`
+	Notes           string `json:"notes"`
+}
+
+type StockOpnameReviewRequest struct {
+	Notes string `json:"notes"`
+}
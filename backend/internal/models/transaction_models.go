@@ -29,12 +29,18 @@ type Unit struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
-type Location struct {
-	ID          int       `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
-	Code        string    `json:"code" db:"code"`
-	Description string    `json:"description" db:"description"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+type Warehouse struct {
+	ID        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Location  string    `json:"location" db:"location"`
+	ManagerID *int      `json:"manager_id" db:"manager_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type WarehouseRequest struct {
+	Name      string `json:"name" binding:"required"`
+	Location  string `json:"location"`
+	ManagerID *int   `json:"manager_id"`
 }
 
 type Receiving struct {
@@ -46,6 +52,7 @@ type Receiving struct {
 	Quantity       int       `json:"quantity" db:"quantity"`
 	UnitID         int       `json:"unit_id" db:"unit_id"`
 	LocationID     int       `json:"location_id" db:"location_id"`
+	WarehouseID    *int      `json:"warehouse_id" db:"warehouse_id"`
 	Remarks        string    `json:"remarks" db:"remarks"`
 	CreatedBy      int       `json:"created_by" db:"created_by"`
 	CreatedAt      time.Time `json:"created_at" db:"created_at"`
@@ -66,6 +73,7 @@ type Issuing struct {
 	Quantity       int       `json:"quantity" db:"quantity"`
 	UnitID         int       `json:"unit_id" db:"unit_id"`
 	LocationID     int       `json:"location_id" db:"location_id"`
+	WarehouseID    *int      `json:"warehouse_id" db:"warehouse_id"`
 	Remarks        string    `json:"remarks" db:"remarks"`
 	CreatedBy      int       `json:"created_by" db:"created_by"`
 	CreatedAt      time.Time `json:"created_at" db:"created_at"`
@@ -83,7 +91,7 @@ type ReceivingRequest struct {
 	ProductID   int    `json:"product_id" binding:"required"`
 	Quantity    int    `json:"quantity" binding:"required,min=1"`
 	UnitID      int    `json:"unit_id" binding:"required"`
-	LocationID  int    `json:"location_id" binding:"required"`
+	LocationID  int    `json:"location_id"`
 	Remarks     string `json:"remarks"`
 }
 
@@ -93,6 +101,7 @@ type IssuingRequest struct {
 	ProductID  int    `json:"product_id" binding:"required"`
 	Quantity   int    `json:"quantity" binding:"required,min=1"`
 	UnitID     int    `json:"unit_id" binding:"required"`
-	LocationID int    `json:"location_id" binding:"required"`
+	LocationID int    `json:"location_id"`
+	Batch      string `json:"batch"`
 	Remarks    string `json:"remarks"`
 }
\ No newline at end of file
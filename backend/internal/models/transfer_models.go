@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Transfer modes and statuses. Direct transfers complete on creation;
+// two-step transfers go pending → in_transit → completed.
+const (
+	TransferDirect  = "direct"
+	TransferTwoStep = "two_step"
+
+	TransferPending   = "pending"
+	TransferInTransit = "in_transit"
+	TransferCompleted = "completed"
+	TransferCancelled = "cancelled"
+)
+
+type Transfer struct {
+	ID              int            `json:"id"`
+	DocumentNumber  string         `json:"document_number"`
+	FromWarehouseID *int           `json:"from_warehouse_id"`
+	ToWarehouseID   *int           `json:"to_warehouse_id"`
+	Mode            string         `json:"mode"`
+	Status          string         `json:"status"`
+	Notes           string         `json:"notes"`
+	CreatedBy       *int           `json:"created_by"`
+	SentBy          *int           `json:"sent_by"`
+	SentAt          *time.Time     `json:"sent_at"`
+	ReceivedBy      *int           `json:"received_by"`
+	ReceivedAt      *time.Time     `json:"received_at"`
+	TenantID        *int           `json:"-"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	Lines           []TransferLine `json:"lines,omitempty"`
+}
+
+// TransferLine moves one product, and one lot once sent. A line without a
+// batch is split per lot when the stock leaves its source.
+type TransferLine struct {
+	ID               int        `json:"id"`
+	TransferID       int        `json:"transfer_id"`
+	ProductID        int        `json:"product_id"`
+	ProductName      string     `json:"product_name"`
+	SKU              string     `json:"sku"`
+	Batch            string     `json:"batch"`
+	ExpiryDate       *time.Time `json:"expiry_date"`
+	FromLocationID   int        `json:"from_location_id"`
+	FromLocationCode string     `json:"from_location_code"`
+	ToLocationID     int        `json:"to_location_id"`
+	ToLocationCode   string     `json:"to_location_code"`
+	Quantity         int        `json:"quantity"`
+}
+
+type TransferRequest struct {
+	Mode  string                `json:"mode"`
+	Notes string                `json:"notes"`
+	Lines []TransferLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+type TransferLineRequest struct {
+	ProductID      int    `json:"product_id" binding:"required"`
+	Batch          string `json:"batch"`
+	FromLocationID int    `json:"from_location_id" binding:"required"`
+	ToLocationID   int    `json:"to_location_id" binding:"required"`
+	Quantity       int    `json:"quantity" binding:"required,min=1"`
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalKeys(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr error
+	}{
+		{key: "attachments/2026/a.jpg"},
+		{key: "a.jpg"},
+		{key: "", wantErr: ErrInvalidKey},
+		{key: "../outside.jpg", wantErr: ErrInvalidKey},
+		{key: "attachments/../../outside.jpg", wantErr: ErrInvalidKey},
+		{key: "/absolute.jpg", wantErr: ErrInvalidKey},
+		{key: "attachments//a.jpg", wantErr: ErrInvalidKey},
+		{key: `attachments\a.jpg`, wantErr: ErrInvalidKey},
+	}
+
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := s.Put(tt.key, strings.NewReader("photo"))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Put() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			f, err := s.Open(tt.key)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			body, _ := io.ReadAll(f)
+			f.Close()
+			if string(body) != "photo" {
+				t.Errorf("Open() read %q, want %q", body, "photo")
+			}
+
+			if err := s.Delete(tt.key); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if err := s.Delete(tt.key); err != nil {
+				t.Errorf("second Delete() error = %v", err)
+			}
+			if _, err := s.Open(tt.key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Open() after Delete() error = %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+// Package storage keeps uploaded files outside the database. Callers only
+// see the Storage interface so the backend can be swapped by configuration.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrNotFound   = errors.New("stored file not found")
+	ErrInvalidKey = errors.New("invalid storage key")
+)
+
+// Storage puts, reads back and removes files by key. Keys are slash
+// separated relative paths chosen by the caller.
+type Storage interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// New returns the backend named by kind. Only "local" exists so far; an
+// S3-compatible backend plugs in here.
+func New(kind, root string) (Storage, error) {
+	switch kind {
+	case "", "local":
+		return NewLocal(root)
+	default:
+		return nil, fmt.Errorf("unsupported storage backend %q", kind)
+	}
+}
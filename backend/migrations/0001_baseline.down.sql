@@ -0,0 +1,23 @@
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS outbound_requests;
+DROP TABLE IF EXISTS inbound_requests;
+DROP TABLE IF EXISTS pemeriksaan_kualitas;
+DROP TABLE IF EXISTS detail_penerimaan;
+DROP TABLE IF EXISTS penerimaan_barang;
+DROP TABLE IF EXISTS quality_checks;
+DROP TABLE IF EXISTS dispatches;
+DROP TABLE IF EXISTS receptions;
+DROP TABLE IF EXISTS issuing;
+DROP TABLE IF EXISTS receiving;
+DROP TABLE IF EXISTS stock_movements;
+DROP TABLE IF EXISTS inventory;
+DROP TABLE IF EXISTS inventory_legacy;
+DROP TABLE IF EXISTS customers;
+DROP TABLE IF EXISTS suppliers;
+DROP TABLE IF EXISTS warehouse_product;
+DROP TABLE IF EXISTS warehouse_category;
+DROP TABLE IF EXISTS locations;
+DROP TABLE IF EXISTS units;
+DROP TABLE IF EXISTS auth_user;
+DROP TABLE IF EXISTS warehouses;
+DROP TABLE IF EXISTS tenants;
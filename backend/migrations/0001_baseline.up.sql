@@ -0,0 +1,353 @@
+-- Baseline schema. Every statement is idempotent so databases created by the
+-- old startup code (createTables and the loose *.sql files) are adopted as-is.
+
+CREATE TABLE IF NOT EXISTS tenants (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) UNIQUE NOT NULL,
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS warehouses (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    location VARCHAR(300) DEFAULT '',
+    manager_id INTEGER DEFAULT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS auth_user (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(150) UNIQUE NOT NULL,
+    email VARCHAR(254) NOT NULL,
+    password VARCHAR(128) NOT NULL,
+    is_staff BOOLEAN DEFAULT FALSE,
+    is_superuser BOOLEAN DEFAULT FALSE,
+    is_active BOOLEAN DEFAULT TRUE,
+    first_name VARCHAR(150) DEFAULT '',
+    last_name VARCHAR(150) DEFAULT '',
+    role VARCHAR(50) DEFAULT '',
+    roles TEXT DEFAULT 'user',
+    user_type VARCHAR(50) DEFAULT 'user',
+    company_name VARCHAR(200) DEFAULT '',
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    date_joined TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE auth_user ADD COLUMN IF NOT EXISTS role VARCHAR(50) DEFAULT '';
+ALTER TABLE auth_user ADD COLUMN IF NOT EXISTS tenant_id INTEGER REFERENCES tenants(id);
+
+-- Master data
+
+CREATE TABLE IF NOT EXISTS units (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    symbol VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS locations (
+    id SERIAL PRIMARY KEY,
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    name VARCHAR(200) NOT NULL,
+    code VARCHAR(50) NOT NULL,
+    description TEXT DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS warehouse_id INTEGER REFERENCES warehouses(id);
+
+CREATE TABLE IF NOT EXISTS warehouse_category (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT DEFAULT '',
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS warehouse_product (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    sku VARCHAR(50) UNIQUE NOT NULL,
+    category_id INTEGER REFERENCES warehouse_category(id),
+    unit_id INTEGER REFERENCES units(id),
+    description TEXT DEFAULT '',
+    price DECIMAL(10,2) NOT NULL,
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE warehouse_product ADD COLUMN IF NOT EXISTS unit_id INTEGER REFERENCES units(id);
+
+CREATE TABLE IF NOT EXISTS suppliers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    contact_person VARCHAR(200) DEFAULT '',
+    phone VARCHAR(50) DEFAULT '',
+    email VARCHAR(200) DEFAULT '',
+    address TEXT DEFAULT '',
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS customers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    contact_person VARCHAR(200) DEFAULT '',
+    phone VARCHAR(50) DEFAULT '',
+    email VARCHAR(200) DEFAULT '',
+    address TEXT DEFAULT '',
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Stock
+
+-- Older databases created inventory keyed by product_name/category from
+-- migrations/004. Keep those rows aside instead of guessing product ids.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'inventory' AND column_name = 'product_name')
+       AND NOT EXISTS (SELECT 1 FROM information_schema.columns
+                       WHERE table_name = 'inventory' AND column_name = 'product_id') THEN
+        ALTER TABLE inventory RENAME TO inventory_legacy;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS inventory (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES warehouse_product(id),
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    quantity INTEGER DEFAULT 0,
+    min_stock INTEGER DEFAULT 0,
+    tenant_id INTEGER REFERENCES tenants(id),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(product_id, location_id)
+);
+ALTER TABLE inventory ALTER COLUMN location_id DROP DEFAULT;
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER REFERENCES warehouse_product(id),
+    movement_type VARCHAR(50) NOT NULL,
+    quantity INTEGER NOT NULL,
+    reference VARCHAR(200) DEFAULT '',
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Documents
+
+CREATE TABLE IF NOT EXISTS receiving (
+    id SERIAL PRIMARY KEY,
+    document_number VARCHAR(100) UNIQUE NOT NULL,
+    receive_date DATE NOT NULL,
+    supplier_id INTEGER REFERENCES suppliers(id),
+    product_id INTEGER REFERENCES warehouse_product(id),
+    quantity INTEGER NOT NULL,
+    unit_id INTEGER REFERENCES units(id),
+    location_id INTEGER REFERENCES locations(id),
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    remarks TEXT DEFAULT '',
+    status VARCHAR(50) DEFAULT 'pending',
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS issuing (
+    id SERIAL PRIMARY KEY,
+    document_number VARCHAR(100) UNIQUE NOT NULL,
+    issue_date DATE NOT NULL,
+    customer_id INTEGER REFERENCES customers(id),
+    product_id INTEGER REFERENCES warehouse_product(id),
+    quantity INTEGER NOT NULL,
+    unit_id INTEGER REFERENCES units(id),
+    location_id INTEGER REFERENCES locations(id),
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    remarks TEXT DEFAULT '',
+    status VARCHAR(50) DEFAULT 'pending',
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE receiving ALTER COLUMN created_by DROP DEFAULT;
+ALTER TABLE issuing ALTER COLUMN created_by DROP DEFAULT;
+
+CREATE TABLE IF NOT EXISTS receptions (
+    id SERIAL PRIMARY KEY,
+    product_name VARCHAR(255) NOT NULL,
+    category VARCHAR(100) DEFAULT '',
+    quantity INTEGER NOT NULL,
+    location VARCHAR(255) DEFAULT '',
+    notes TEXT DEFAULT '',
+    received_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    status VARCHAR(50) DEFAULT 'pending',
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id)
+);
+
+CREATE TABLE IF NOT EXISTS dispatches (
+    id SERIAL PRIMARY KEY,
+    product_name VARCHAR(255) NOT NULL,
+    category VARCHAR(100) DEFAULT '',
+    customer VARCHAR(200) DEFAULT '',
+    quantity INTEGER NOT NULL,
+    location VARCHAR(255) DEFAULT '',
+    notes TEXT DEFAULT '',
+    dispatch_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    status VARCHAR(50) DEFAULT 'pending',
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id)
+);
+ALTER TABLE dispatches ADD COLUMN IF NOT EXISTS customer VARCHAR(200) DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS quality_checks (
+    id SERIAL PRIMARY KEY,
+    reception_id INTEGER UNIQUE REFERENCES receptions(id),
+    product_name VARCHAR(200),
+    quantity INTEGER,
+    status VARCHAR(50),
+    notes TEXT,
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    checked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_quality_checks_reception_id ON quality_checks(reception_id);
+
+CREATE TABLE IF NOT EXISTS penerimaan_barang (
+    id SERIAL PRIMARY KEY,
+    no_dokumen VARCHAR(50) UNIQUE NOT NULL,
+    tanggal DATE NOT NULL,
+    supplier VARCHAR(100) NOT NULL,
+    no_po VARCHAR(50),
+    status VARCHAR(20) DEFAULT 'draft' CHECK (status IN ('draft', 'in_progress', 'completed', 'cancelled')),
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS detail_penerimaan (
+    id SERIAL PRIMARY KEY,
+    penerimaan_id INTEGER REFERENCES penerimaan_barang(id) ON DELETE CASCADE,
+    sku VARCHAR(50) NOT NULL,
+    nama_barang VARCHAR(200) NOT NULL,
+    jumlah INTEGER NOT NULL,
+    batch VARCHAR(50),
+    expired_date DATE,
+    satuan VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS pemeriksaan_kualitas (
+    id SERIAL PRIMARY KEY,
+    detail_penerimaan_id INTEGER REFERENCES detail_penerimaan(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('diterima', 'ditolak')),
+    keterangan TEXT,
+    created_by INTEGER REFERENCES auth_user(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Tenant requests
+
+CREATE TABLE IF NOT EXISTS inbound_requests (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER REFERENCES warehouse_product(id),
+    quantity INTEGER NOT NULL,
+    supplier VARCHAR(200) NOT NULL,
+    status VARCHAR(50) DEFAULT 'pending',
+    notes TEXT DEFAULT '',
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS outbound_requests (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER REFERENCES warehouse_product(id),
+    quantity INTEGER NOT NULL,
+    destination VARCHAR(200) NOT NULL,
+    status VARCHAR(50) DEFAULT 'pending',
+    notes TEXT DEFAULT '',
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id SERIAL PRIMARY KEY,
+    order_number VARCHAR(100) UNIQUE NOT NULL,
+    customer VARCHAR(200) NOT NULL,
+    status VARCHAR(50) DEFAULT 'pending',
+    total_amount DECIMAL(10,2) DEFAULT 0,
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Columns added after the original tables shipped
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'warehouse_category', 'warehouse_product', 'inventory', 'stock_movements',
+        'suppliers', 'customers', 'receiving', 'issuing', 'receptions', 'dispatches',
+        'quality_checks', 'penerimaan_barang', 'inbound_requests', 'outbound_requests', 'orders'
+    ] LOOP
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS tenant_id INTEGER REFERENCES tenants(id)', t);
+        EXECUTE format('CREATE INDEX IF NOT EXISTS %I ON %I(tenant_id)', 'idx_' || t || '_tenant_id', t);
+    END LOOP;
+
+    FOREACH t IN ARRAY ARRAY[
+        'stock_movements', 'quality_checks', 'receptions', 'dispatches',
+        'penerimaan_barang', 'pemeriksaan_kualitas'
+    ] LOOP
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS created_by INTEGER REFERENCES auth_user(id)', t);
+    END LOOP;
+
+    FOREACH t IN ARRAY ARRAY['receiving', 'issuing'] LOOP
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS warehouse_id INTEGER REFERENCES warehouses(id)', t);
+    END LOOP;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_locations_warehouse_id ON locations(warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product_id ON stock_movements(product_id);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_created_at ON stock_movements(created_at);
+CREATE INDEX IF NOT EXISTS idx_penerimaan_tanggal ON penerimaan_barang(tanggal);
+CREATE INDEX IF NOT EXISTS idx_detail_penerimaan_id ON detail_penerimaan(penerimaan_id);
+CREATE INDEX IF NOT EXISTS idx_pemeriksaan_detail_id ON pemeriksaan_kualitas(detail_penerimaan_id);
+
+-- Backfill tenants for accounts registered before the tenants table existed
+INSERT INTO tenants (name)
+SELECT DISTINCT company_name FROM auth_user
+WHERE user_type IN ('tenant', 'tenant_admin') AND company_name <> ''
+ON CONFLICT (name) DO NOTHING;
+
+UPDATE auth_user u SET tenant_id = t.id
+FROM tenants t
+WHERE u.tenant_id IS NULL AND u.user_type IN ('tenant', 'tenant_admin') AND u.company_name = t.name;
+
+-- The old startup code re-inserted the default locations on every boot.
+-- Fold the copies into the oldest row before making codes unique per site.
+CREATE TEMP TABLE location_duplicates ON COMMIT DROP AS
+SELECT l.id, keep.id AS keep_id
+FROM locations l
+JOIN (
+    SELECT MIN(id) AS id, warehouse_id, code FROM locations GROUP BY warehouse_id, code
+) keep ON keep.code = l.code AND keep.warehouse_id IS NOT DISTINCT FROM l.warehouse_id AND keep.id <> l.id;
+
+UPDATE receiving r SET location_id = d.keep_id FROM location_duplicates d WHERE r.location_id = d.id;
+UPDATE issuing i SET location_id = d.keep_id FROM location_duplicates d WHERE i.location_id = d.id;
+
+INSERT INTO inventory (product_id, location_id, quantity, min_stock, tenant_id, updated_at)
+SELECT i.product_id, d.keep_id, SUM(i.quantity), MAX(i.min_stock), MIN(i.tenant_id), NOW()
+FROM inventory i
+JOIN location_duplicates d ON i.location_id = d.id
+GROUP BY i.product_id, d.keep_id
+ON CONFLICT (product_id, location_id)
+DO UPDATE SET quantity = inventory.quantity + EXCLUDED.quantity, updated_at = NOW();
+
+DELETE FROM inventory WHERE location_id IN (SELECT id FROM location_duplicates);
+DELETE FROM locations WHERE id IN (SELECT id FROM location_duplicates);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_locations_warehouse_code ON locations(warehouse_id, code);
@@ -0,0 +1,16 @@
+-- Default rows that are still referenced by business data are left in place.
+DELETE FROM warehouse_category c
+WHERE c.name = 'Electronics' AND c.tenant_id IS NULL
+  AND NOT EXISTS (SELECT 1 FROM warehouse_product p WHERE p.category_id = c.id);
+
+DELETE FROM locations l
+WHERE l.code IN ('WH-A', 'WH-B', 'CS-01')
+  AND NOT EXISTS (SELECT 1 FROM inventory i WHERE i.location_id = l.id)
+  AND NOT EXISTS (SELECT 1 FROM receiving r WHERE r.location_id = l.id)
+  AND NOT EXISTS (SELECT 1 FROM issuing i WHERE i.location_id = l.id);
+
+DELETE FROM units u
+WHERE u.symbol IN ('pcs', 'box', 'kg', 'ltr')
+  AND NOT EXISTS (SELECT 1 FROM warehouse_product p WHERE p.unit_id = u.id)
+  AND NOT EXISTS (SELECT 1 FROM receiving r WHERE r.unit_id = u.id)
+  AND NOT EXISTS (SELECT 1 FROM issuing i WHERE i.unit_id = u.id);
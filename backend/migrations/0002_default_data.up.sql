@@ -0,0 +1,31 @@
+-- Default superuser (admin/admin). Change the password after first login.
+INSERT INTO auth_user (username, email, password, is_staff, is_superuser, is_active, first_name, last_name, roles, date_joined)
+VALUES ('admin', 'admin@admin.com', '$2a$10$DZeOTduCZxAFFPMXHltwROxwdNtJKLyPLx9IxumOlmmEC1ZUvH/L.', true, true, true, 'Admin', 'User', 'admin', NOW())
+ON CONFLICT (username) DO NOTHING;
+
+INSERT INTO units (name, symbol)
+SELECT v.name, v.symbol FROM (VALUES
+    ('Pieces', 'pcs'),
+    ('Box', 'box'),
+    ('Kilogram', 'kg'),
+    ('Liter', 'ltr')
+) AS v(name, symbol)
+WHERE NOT EXISTS (SELECT 1 FROM units u WHERE u.symbol = v.symbol);
+
+-- Locations created before multi-warehouse support belong to a default site
+INSERT INTO warehouses (name, location)
+SELECT 'Main Warehouse', '' WHERE NOT EXISTS (SELECT 1 FROM warehouses);
+
+UPDATE locations SET warehouse_id = (SELECT MIN(id) FROM warehouses) WHERE warehouse_id IS NULL;
+
+INSERT INTO locations (warehouse_id, name, code, description)
+SELECT (SELECT MIN(id) FROM warehouses), v.name, v.code, v.description FROM (VALUES
+    ('Warehouse A', 'WH-A', 'Main warehouse storage'),
+    ('Warehouse B', 'WH-B', 'Secondary warehouse storage'),
+    ('Cold Storage', 'CS-01', 'Temperature controlled storage')
+) AS v(name, code, description)
+ON CONFLICT (warehouse_id, code) DO NOTHING;
+
+INSERT INTO warehouse_category (name, description)
+SELECT 'Electronics', 'Electronic items'
+WHERE NOT EXISTS (SELECT 1 FROM warehouse_category WHERE name = 'Electronics' AND tenant_id IS NULL);
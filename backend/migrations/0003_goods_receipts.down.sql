@@ -0,0 +1,135 @@
+-- Restore the three legacy inbound tables from goods receipts. Documents
+-- created through /api/goods-receipts have no legacy home and are dropped.
+
+CREATE TABLE receiving (
+    id SERIAL PRIMARY KEY,
+    document_number VARCHAR(100) UNIQUE NOT NULL,
+    receive_date DATE NOT NULL,
+    supplier_id INTEGER REFERENCES suppliers(id),
+    product_id INTEGER REFERENCES warehouse_product(id),
+    quantity INTEGER NOT NULL,
+    unit_id INTEGER REFERENCES units(id),
+    location_id INTEGER REFERENCES locations(id),
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    remarks TEXT DEFAULT '',
+    status VARCHAR(50) DEFAULT 'pending',
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_receiving_tenant_id ON receiving(tenant_id);
+
+CREATE TABLE receptions (
+    id SERIAL PRIMARY KEY,
+    product_name VARCHAR(255) NOT NULL,
+    category VARCHAR(100) DEFAULT '',
+    quantity INTEGER NOT NULL,
+    location VARCHAR(255) DEFAULT '',
+    notes TEXT DEFAULT '',
+    received_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    status VARCHAR(50) DEFAULT 'pending',
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id)
+);
+CREATE INDEX idx_receptions_tenant_id ON receptions(tenant_id);
+
+CREATE TABLE penerimaan_barang (
+    id SERIAL PRIMARY KEY,
+    no_dokumen VARCHAR(50) UNIQUE NOT NULL,
+    tanggal DATE NOT NULL,
+    supplier VARCHAR(100) NOT NULL,
+    no_po VARCHAR(50),
+    status VARCHAR(20) DEFAULT 'draft' CHECK (status IN ('draft', 'in_progress', 'completed', 'cancelled')),
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_penerimaan_barang_tenant_id ON penerimaan_barang(tenant_id);
+CREATE INDEX idx_penerimaan_tanggal ON penerimaan_barang(tanggal);
+
+CREATE TABLE detail_penerimaan (
+    id SERIAL PRIMARY KEY,
+    penerimaan_id INTEGER REFERENCES penerimaan_barang(id) ON DELETE CASCADE,
+    sku VARCHAR(50) NOT NULL,
+    nama_barang VARCHAR(200) NOT NULL,
+    jumlah INTEGER NOT NULL,
+    batch VARCHAR(50),
+    expired_date DATE,
+    satuan VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_detail_penerimaan_id ON detail_penerimaan(penerimaan_id);
+
+CREATE TABLE pemeriksaan_kualitas (
+    id SERIAL PRIMARY KEY,
+    detail_penerimaan_id INTEGER REFERENCES detail_penerimaan(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('diterima', 'ditolak')),
+    keterangan TEXT,
+    created_by INTEGER REFERENCES auth_user(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_pemeriksaan_detail_id ON pemeriksaan_kualitas(detail_penerimaan_id);
+
+-- receiving held exactly one line per document
+INSERT INTO receiving (document_number, receive_date, supplier_id, product_id, quantity, unit_id, location_id,
+                       warehouse_id, remarks, status, created_by, tenant_id, created_at)
+SELECT gr.document_number, gr.receipt_date, gr.supplier_id, l.product_id, l.quantity, l.unit_id, l.location_id,
+       gr.warehouse_id, gr.notes, 'pending', gr.created_by, gr.tenant_id, gr.created_at
+FROM goods_receipts gr
+JOIN goods_receipt_lines l ON l.receipt_id = gr.id
+WHERE gr.source = 'receiving';
+
+CREATE TEMP TABLE reception_ids ON COMMIT DROP AS
+SELECT gr.id AS receipt_id, nextval(pg_get_serial_sequence('receptions', 'id')) AS reception_id
+FROM goods_receipts gr WHERE gr.source = 'reception';
+
+INSERT INTO receptions (id, product_name, category, quantity, location, notes, received_date, status, created_by, tenant_id)
+SELECT m.reception_id, l.product_name, gr.supplier_name, l.quantity, l.location_name, gr.notes, gr.created_at,
+       CASE gr.status
+           WHEN 'draft' THEN 'pending'
+           WHEN 'qc' THEN 'quality_check'
+           WHEN 'putaway' THEN 'quality_check'
+           WHEN 'cancelled' THEN 'rejected'
+           ELSE gr.status
+       END,
+       gr.created_by, gr.tenant_id
+FROM goods_receipts gr
+JOIN reception_ids m ON m.receipt_id = gr.id
+JOIN LATERAL (SELECT * FROM goods_receipt_lines WHERE receipt_id = gr.id ORDER BY id LIMIT 1) l ON TRUE;
+
+ALTER TABLE quality_checks DROP CONSTRAINT IF EXISTS quality_checks_reception_id_fkey;
+DELETE FROM quality_checks qc WHERE NOT EXISTS (SELECT 1 FROM reception_ids m WHERE m.receipt_id = qc.reception_id);
+UPDATE quality_checks SET reception_id = -reception_id;
+UPDATE quality_checks qc SET reception_id = m.reception_id FROM reception_ids m WHERE m.receipt_id = -qc.reception_id;
+ALTER TABLE quality_checks ADD CONSTRAINT quality_checks_reception_id_fkey
+    FOREIGN KEY (reception_id) REFERENCES receptions(id);
+
+INSERT INTO penerimaan_barang (no_dokumen, tanggal, supplier, no_po, status, created_by, tenant_id, created_at, updated_at)
+SELECT gr.document_number, gr.receipt_date, gr.supplier_name, gr.po_number,
+       CASE gr.status
+           WHEN 'draft' THEN 'draft'
+           WHEN 'completed' THEN 'completed'
+           WHEN 'cancelled' THEN 'cancelled'
+           ELSE 'in_progress'
+       END,
+       gr.created_by, gr.tenant_id, gr.created_at, gr.updated_at
+FROM goods_receipts gr WHERE gr.source = 'penerimaan';
+
+INSERT INTO detail_penerimaan (penerimaan_id, sku, nama_barang, jumlah, batch, expired_date, satuan, created_at)
+SELECT p.id, l.sku, l.product_name, l.quantity, l.batch, l.expiry_date, l.unit_name, l.created_at
+FROM goods_receipt_lines l
+JOIN goods_receipts gr ON l.receipt_id = gr.id AND gr.source = 'penerimaan'
+JOIN penerimaan_barang p ON p.no_dokumen = gr.document_number;
+
+INSERT INTO pemeriksaan_kualitas (detail_penerimaan_id, status, keterangan, created_by, created_at)
+SELECT d.id, CASE l.qc_status WHEN 'accepted' THEN 'diterima' ELSE 'ditolak' END, l.qc_notes, l.qc_by, l.qc_at
+FROM goods_receipt_lines l
+JOIN goods_receipts gr ON l.receipt_id = gr.id AND gr.source = 'penerimaan'
+JOIN penerimaan_barang p ON p.no_dokumen = gr.document_number
+JOIN detail_penerimaan d ON d.penerimaan_id = p.id AND d.created_at = l.created_at AND d.sku = l.sku
+WHERE l.qc_status <> 'pending';
+
+DROP TABLE goods_receipt_lines;
+DROP TABLE goods_receipts;
+DROP SEQUENCE goods_receipt_number_seq;
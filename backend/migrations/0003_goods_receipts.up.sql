@@ -0,0 +1,164 @@
+-- One inbound document model replacing receiving, receptions and
+-- penerimaan_barang/detail_penerimaan/pemeriksaan_kualitas.
+
+CREATE SEQUENCE IF NOT EXISTS goods_receipt_number_seq;
+
+CREATE TABLE goods_receipts (
+    id SERIAL PRIMARY KEY,
+    document_number VARCHAR(100) UNIQUE NOT NULL
+        DEFAULT 'GR-' || LPAD(nextval('goods_receipt_number_seq')::TEXT, 6, '0'),
+    receipt_date DATE NOT NULL DEFAULT CURRENT_DATE,
+    supplier_id INTEGER REFERENCES suppliers(id),
+    supplier_name VARCHAR(200) DEFAULT '',
+    po_number VARCHAR(100) DEFAULT '',
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'received', 'qc', 'putaway', 'completed', 'cancelled')),
+    -- Which endpoint family created the document; legacy ids are kept for traceability
+    source VARCHAR(20) NOT NULL DEFAULT 'goods_receipt',
+    source_id INTEGER,
+    notes TEXT DEFAULT '',
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+CREATE INDEX idx_goods_receipts_tenant_id ON goods_receipts(tenant_id);
+CREATE INDEX idx_goods_receipts_status ON goods_receipts(status);
+CREATE INDEX idx_goods_receipts_source ON goods_receipts(source, source_id);
+
+CREATE TABLE goods_receipt_lines (
+    id SERIAL PRIMARY KEY,
+    receipt_id INTEGER NOT NULL REFERENCES goods_receipts(id) ON DELETE CASCADE,
+    product_id INTEGER REFERENCES warehouse_product(id),
+    sku VARCHAR(50) DEFAULT '',
+    product_name VARCHAR(255) NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_id INTEGER REFERENCES units(id),
+    unit_name VARCHAR(20) DEFAULT '',
+    batch VARCHAR(50) DEFAULT '',
+    expiry_date DATE,
+    location_id INTEGER REFERENCES locations(id),
+    -- Free-text location from clients that do not know location ids
+    location_name VARCHAR(255) DEFAULT '',
+    qc_status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (qc_status IN ('pending', 'accepted', 'rejected')),
+    qc_notes TEXT DEFAULT '',
+    qc_by INTEGER REFERENCES auth_user(id),
+    qc_at TIMESTAMP,
+    -- Set when the line's stock was booked; guards against double posting
+    posted_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_goods_receipt_lines_receipt_id ON goods_receipt_lines(receipt_id);
+
+-- receiving: already posted to stock when it was created
+
+INSERT INTO goods_receipts (document_number, receipt_date, supplier_id, supplier_name, warehouse_id, status,
+                            source, source_id, notes, created_by, tenant_id, created_at, updated_at, completed_at)
+SELECT r.document_number, r.receive_date, r.supplier_id, COALESCE(s.name, ''), r.warehouse_id, 'completed',
+       'receiving', r.id, COALESCE(r.remarks, ''), r.created_by, r.tenant_id, r.created_at, r.created_at, r.created_at
+FROM receiving r
+LEFT JOIN suppliers s ON r.supplier_id = s.id;
+
+INSERT INTO goods_receipt_lines (receipt_id, product_id, sku, product_name, quantity, unit_id, unit_name,
+                                 location_id, qc_status, qc_at, posted_at, created_at)
+SELECT gr.id, r.product_id, COALESCE(p.sku, ''), COALESCE(p.name, ''), r.quantity, r.unit_id, COALESCE(u.symbol, ''),
+       r.location_id, 'accepted', r.created_at, r.created_at, r.created_at
+FROM receiving r
+JOIN goods_receipts gr ON gr.source = 'receiving' AND gr.source_id = r.id
+LEFT JOIN warehouse_product p ON r.product_id = p.id
+LEFT JOIN units u ON r.unit_id = u.id;
+
+-- receptions: single free-text line, verdict kept in quality_checks
+
+INSERT INTO goods_receipts (document_number, receipt_date, supplier_name, status, source, source_id,
+                            notes, created_by, tenant_id, created_at, updated_at, completed_at)
+SELECT 'RCP-' || LPAD(r.id::TEXT, 6, '0'), r.received_date::DATE, COALESCE(r.category, ''),
+       CASE LOWER(r.status)
+           WHEN 'received' THEN 'received'
+           WHEN 'quality_check' THEN 'qc'
+           WHEN 'completed' THEN 'completed'
+           WHEN 'rejected' THEN 'cancelled'
+           ELSE 'draft'
+       END,
+       'reception', r.id, COALESCE(r.notes, ''), r.created_by, r.tenant_id, r.received_date, r.received_date,
+       CASE WHEN LOWER(r.status) = 'completed' THEN r.received_date END
+FROM receptions r;
+
+INSERT INTO goods_receipt_lines (receipt_id, product_id, sku, product_name, quantity, location_name,
+                                 qc_status, qc_notes, qc_by, qc_at, posted_at, created_at)
+SELECT gr.id, p.id, COALESCE(p.sku, ''), r.product_name, r.quantity, COALESCE(r.location, ''),
+       CASE UPPER(COALESCE(qc.status, ''))
+           WHEN 'PASS' THEN 'accepted'
+           WHEN 'FAIL' THEN 'rejected'
+           ELSE 'pending'
+       END,
+       COALESCE(qc.notes, ''), qc.created_by, qc.checked_at,
+       -- The app booked completed receptions through POST /inventory itself
+       CASE WHEN gr.status = 'completed' THEN gr.completed_at END,
+       r.received_date
+FROM receptions r
+JOIN goods_receipts gr ON gr.source = 'reception' AND gr.source_id = r.id
+LEFT JOIN quality_checks qc ON qc.reception_id = r.id
+LEFT JOIN LATERAL (
+    SELECT id, sku FROM warehouse_product wp
+    WHERE LOWER(wp.name) = LOWER(r.product_name)
+      AND wp.tenant_id IS NOT DISTINCT FROM r.tenant_id
+    ORDER BY wp.id LIMIT 1
+) p ON TRUE
+WHERE r.quantity > 0;
+
+-- quality_checks now point at goods receipts. Negate first so the unique
+-- index never sees two rows with the same id mid-update.
+ALTER TABLE quality_checks DROP CONSTRAINT IF EXISTS quality_checks_reception_id_fkey;
+UPDATE quality_checks SET reception_id = -reception_id WHERE reception_id > 0;
+UPDATE quality_checks qc SET reception_id = gr.id
+FROM goods_receipts gr
+WHERE gr.source = 'reception' AND gr.source_id = -qc.reception_id;
+DELETE FROM quality_checks WHERE reception_id < 0;
+ALTER TABLE quality_checks ADD CONSTRAINT quality_checks_reception_id_fkey
+    FOREIGN KEY (reception_id) REFERENCES goods_receipts(id) ON DELETE CASCADE;
+
+-- penerimaan_barang: multi-line documents with batch/expiry
+
+INSERT INTO goods_receipts (document_number, receipt_date, supplier_name, po_number, status, source, source_id,
+                            created_by, tenant_id, created_at, updated_at, completed_at)
+SELECT p.no_dokumen, p.tanggal, p.supplier, COALESCE(p.no_po, ''),
+       CASE p.status
+           WHEN 'in_progress' THEN 'qc'
+           WHEN 'completed' THEN 'completed'
+           WHEN 'cancelled' THEN 'cancelled'
+           ELSE 'draft'
+       END,
+       'penerimaan', p.id, p.created_by, p.tenant_id, p.created_at, p.updated_at,
+       CASE WHEN p.status = 'completed' THEN p.updated_at END
+FROM penerimaan_barang p;
+
+INSERT INTO goods_receipt_lines (receipt_id, product_id, sku, product_name, quantity, unit_name, batch, expiry_date,
+                                 qc_status, qc_notes, qc_by, qc_at, posted_at, created_at)
+SELECT gr.id, wp.id, d.sku, d.nama_barang, d.jumlah, d.satuan, COALESCE(d.batch, ''), d.expired_date,
+       CASE pk.status WHEN 'diterima' THEN 'accepted' WHEN 'ditolak' THEN 'rejected' ELSE 'pending' END,
+       COALESCE(pk.keterangan, ''), pk.created_by, pk.created_at,
+       -- Completion never booked stock before; treat it as settled rather than post it now
+       CASE WHEN gr.status = 'completed' THEN gr.completed_at END,
+       d.created_at
+FROM detail_penerimaan d
+JOIN goods_receipts gr ON gr.source = 'penerimaan' AND gr.source_id = d.penerimaan_id
+LEFT JOIN LATERAL (
+    SELECT status, keterangan, created_by, created_at FROM pemeriksaan_kualitas
+    WHERE detail_penerimaan_id = d.id ORDER BY created_at DESC, id DESC LIMIT 1
+) pk ON TRUE
+LEFT JOIN LATERAL (
+    SELECT id FROM warehouse_product
+    WHERE sku = d.sku AND tenant_id IS NOT DISTINCT FROM gr.tenant_id
+    ORDER BY id LIMIT 1
+) wp ON TRUE
+WHERE d.jumlah > 0;
+
+DROP TABLE pemeriksaan_kualitas;
+DROP TABLE detail_penerimaan;
+DROP TABLE penerimaan_barang;
+DROP TABLE receptions;
+DROP TABLE receiving;
@@ -0,0 +1,4 @@
+DROP TABLE quarantine_stock;
+ALTER TABLE goods_receipt_lines DROP COLUMN disposition;
+ALTER TABLE stock_movements DROP COLUMN expiry_date;
+ALTER TABLE stock_movements DROP COLUMN batch;
@@ -0,0 +1,30 @@
+-- Completing a goods receipt books accepted lines with their batch and
+-- expiry, and parks rejected lines in quarantine or for return to supplier.
+
+ALTER TABLE stock_movements ADD COLUMN batch VARCHAR(50) DEFAULT '';
+ALTER TABLE stock_movements ADD COLUMN expiry_date DATE;
+
+ALTER TABLE goods_receipt_lines ADD COLUMN disposition VARCHAR(30)
+    CHECK (disposition IN ('quarantine', 'return_to_supplier'));
+
+CREATE TABLE quarantine_stock (
+    id SERIAL PRIMARY KEY,
+    receipt_id INTEGER NOT NULL REFERENCES goods_receipts(id),
+    receipt_line_id INTEGER UNIQUE NOT NULL REFERENCES goods_receipt_lines(id),
+    product_id INTEGER REFERENCES warehouse_product(id),
+    product_name VARCHAR(255) NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    batch VARCHAR(50) DEFAULT '',
+    expiry_date DATE,
+    supplier_id INTEGER REFERENCES suppliers(id),
+    supplier_name VARCHAR(200) DEFAULT '',
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    disposition VARCHAR(30) NOT NULL DEFAULT 'quarantine'
+        CHECK (disposition IN ('quarantine', 'return_to_supplier')),
+    reason TEXT DEFAULT '',
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_quarantine_stock_tenant_id ON quarantine_stock(tenant_id);
+CREATE INDEX idx_quarantine_stock_product_id ON quarantine_stock(product_id);
@@ -0,0 +1 @@
+DROP TABLE goods_receipt_status_history;
@@ -0,0 +1,14 @@
+-- Audit trail of goods receipt status changes
+CREATE TABLE goods_receipt_status_history (
+    id SERIAL PRIMARY KEY,
+    receipt_id INTEGER NOT NULL REFERENCES goods_receipts(id) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    changed_by INTEGER REFERENCES auth_user(id),
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_goods_receipt_status_history_receipt_id ON goods_receipt_status_history(receipt_id);
+
+-- Seed the trail with each existing receipt's current status
+INSERT INTO goods_receipt_status_history (receipt_id, from_status, to_status, changed_by, changed_at)
+SELECT id, NULL, status, created_by, updated_at FROM goods_receipts;
@@ -0,0 +1,3 @@
+DROP TABLE stock_opname_lines;
+DROP TABLE stock_opnames;
+DROP SEQUENCE stock_opname_number_seq;
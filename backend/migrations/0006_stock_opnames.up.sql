@@ -0,0 +1,41 @@
+-- Cycle counts. A count covers either a whole location or a set of products
+-- and freezes stock movements on what it covers until it is closed.
+
+CREATE SEQUENCE IF NOT EXISTS stock_opname_number_seq;
+
+CREATE TABLE stock_opnames (
+    id SERIAL PRIMARY KEY,
+    document_number VARCHAR(100) UNIQUE NOT NULL
+        DEFAULT 'SO-' || LPAD(nextval('stock_opname_number_seq')::TEXT, 6, '0'),
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    -- Set for location counts; NULL for product-set counts
+    location_id INTEGER REFERENCES locations(id),
+    blind BOOLEAN NOT NULL DEFAULT TRUE,
+    status VARCHAR(20) NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'submitted', 'approved', 'rejected', 'cancelled')),
+    notes TEXT DEFAULT '',
+    review_notes TEXT DEFAULT '',
+    created_by INTEGER REFERENCES auth_user(id),
+    reviewed_by INTEGER REFERENCES auth_user(id),
+    reviewed_at TIMESTAMP,
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_stock_opnames_tenant_id ON stock_opnames(tenant_id);
+CREATE INDEX idx_stock_opnames_status ON stock_opnames(status);
+
+CREATE TABLE stock_opname_lines (
+    id SERIAL PRIMARY KEY,
+    opname_id INTEGER NOT NULL REFERENCES stock_opnames(id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES warehouse_product(id),
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    -- Snapshot taken when the count was opened
+    system_quantity INTEGER NOT NULL DEFAULT 0,
+    counted_quantity INTEGER CHECK (counted_quantity >= 0),
+    counted_by INTEGER REFERENCES auth_user(id),
+    counted_at TIMESTAMP,
+    notes TEXT DEFAULT '',
+    UNIQUE (opname_id, product_id, location_id)
+);
+CREATE INDEX idx_stock_opname_lines_location ON stock_opname_lines(location_id, product_id);
@@ -0,0 +1,8 @@
+DELETE FROM stock_movements WHERE reference_type = 'OPENING';
+DROP INDEX idx_stock_movements_reference;
+DROP INDEX idx_stock_movements_product_location;
+ALTER TABLE stock_movements DROP COLUMN notes;
+ALTER TABLE stock_movements DROP COLUMN reason_code;
+ALTER TABLE stock_movements DROP COLUMN reference_type;
+ALTER TABLE stock_movements DROP COLUMN location_id;
+DROP TABLE stock_reason_codes;
@@ -0,0 +1,36 @@
+-- stock_movements becomes the ledger behind inventory: every movement
+-- names its location, so inventory.quantity per product and location equals
+-- the signed sum of its movements (IN adds, OUT subtracts).
+
+ALTER TABLE stock_movements ADD COLUMN location_id INTEGER REFERENCES locations(id);
+ALTER TABLE stock_movements ADD COLUMN reference_type VARCHAR(30) DEFAULT '';
+ALTER TABLE stock_movements ADD COLUMN notes TEXT DEFAULT '';
+CREATE INDEX idx_stock_movements_product_location ON stock_movements(product_id, location_id);
+CREATE INDEX idx_stock_movements_reference ON stock_movements(reference);
+
+CREATE TABLE stock_reason_codes (
+    code VARCHAR(30) PRIMARY KEY,
+    description VARCHAR(200) NOT NULL,
+    -- Which movement types the code may be used with: IN, OUT or BOTH
+    direction VARCHAR(4) NOT NULL DEFAULT 'BOTH' CHECK (direction IN ('IN', 'OUT', 'BOTH')),
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+INSERT INTO stock_reason_codes (code, description, direction) VALUES
+    ('DAMAGED', 'Damaged goods written off', 'OUT'),
+    ('EXPIRED', 'Expired goods written off', 'OUT'),
+    ('LOST', 'Missing stock', 'OUT'),
+    ('SAMPLE', 'Taken as sample', 'OUT'),
+    ('FOUND', 'Stock found', 'IN'),
+    ('CORRECTION', 'Correction of a booking error', 'BOTH'),
+    ('OTHER', 'Other, explained in notes', 'BOTH');
+
+ALTER TABLE stock_movements ADD COLUMN reason_code VARCHAR(30) REFERENCES stock_reason_codes(code);
+
+-- Movements booked before locations were recorded cannot be attributed, so
+-- each balance is opened with a single movement that explains it.
+INSERT INTO stock_movements (product_id, location_id, movement_type, quantity, reference, reference_type, tenant_id, created_at)
+SELECT product_id, location_id, CASE WHEN quantity > 0 THEN 'IN' ELSE 'OUT' END, ABS(quantity),
+       'OPENING BALANCE', 'OPENING', tenant_id, NOW()
+FROM inventory
+WHERE quantity <> 0;
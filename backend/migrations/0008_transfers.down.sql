@@ -0,0 +1,3 @@
+DROP TABLE transfer_lines;
+DROP TABLE transfers;
+DROP SEQUENCE transfer_number_seq;
@@ -0,0 +1,38 @@
+-- Internal stock transfers. Transfers within a warehouse move stock in one
+-- step; between warehouses stock leaves on send and is in transit until the
+-- destination receives it.
+
+CREATE SEQUENCE IF NOT EXISTS transfer_number_seq;
+
+CREATE TABLE transfers (
+    id SERIAL PRIMARY KEY,
+    document_number VARCHAR(100) UNIQUE NOT NULL
+        DEFAULT 'TRF-' || LPAD(nextval('transfer_number_seq')::TEXT, 6, '0'),
+    from_warehouse_id INTEGER REFERENCES warehouses(id),
+    to_warehouse_id INTEGER REFERENCES warehouses(id),
+    mode VARCHAR(20) NOT NULL DEFAULT 'direct' CHECK (mode IN ('direct', 'two_step')),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'in_transit', 'completed', 'cancelled')),
+    notes TEXT DEFAULT '',
+    created_by INTEGER REFERENCES auth_user(id),
+    sent_by INTEGER REFERENCES auth_user(id),
+    sent_at TIMESTAMP,
+    received_by INTEGER REFERENCES auth_user(id),
+    received_at TIMESTAMP,
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_transfers_tenant_id ON transfers(tenant_id);
+CREATE INDEX idx_transfers_status ON transfers(status);
+
+CREATE TABLE transfer_lines (
+    id SERIAL PRIMARY KEY,
+    transfer_id INTEGER NOT NULL REFERENCES transfers(id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES warehouse_product(id),
+    from_location_id INTEGER NOT NULL REFERENCES locations(id),
+    to_location_id INTEGER NOT NULL REFERENCES locations(id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    CHECK (from_location_id <> to_location_id)
+);
+CREATE INDEX idx_transfer_lines_transfer_id ON transfer_lines(transfer_id);
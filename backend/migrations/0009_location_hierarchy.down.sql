@@ -0,0 +1,11 @@
+DROP INDEX idx_locations_parent_id;
+ALTER TABLE locations DROP CONSTRAINT locations_parent_not_self;
+ALTER TABLE locations DROP COLUMN updated_at;
+ALTER TABLE locations DROP COLUMN is_active;
+ALTER TABLE locations DROP COLUMN hazmat;
+ALTER TABLE locations DROP COLUMN temperature_controlled;
+ALTER TABLE locations DROP COLUMN max_volume;
+ALTER TABLE locations DROP COLUMN max_weight;
+ALTER TABLE locations DROP COLUMN max_units;
+ALTER TABLE locations DROP COLUMN location_type;
+ALTER TABLE locations DROP COLUMN parent_id;
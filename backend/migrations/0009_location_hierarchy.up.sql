@@ -0,0 +1,19 @@
+-- Locations form a tree per warehouse: zone > aisle > rack > level > bin.
+-- Docks and staging areas hang directly off the warehouse or a zone. The
+-- flat locations that existed before become zones.
+
+ALTER TABLE locations ADD COLUMN parent_id INTEGER REFERENCES locations(id);
+ALTER TABLE locations ADD COLUMN location_type VARCHAR(20) NOT NULL DEFAULT 'zone'
+    CHECK (location_type IN ('zone', 'aisle', 'rack', 'level', 'bin', 'dock', 'staging'));
+ALTER TABLE locations ADD COLUMN max_units INTEGER CHECK (max_units >= 0);
+ALTER TABLE locations ADD COLUMN max_weight NUMERIC(12,3) CHECK (max_weight >= 0);
+ALTER TABLE locations ADD COLUMN max_volume NUMERIC(12,3) CHECK (max_volume >= 0);
+ALTER TABLE locations ADD COLUMN temperature_controlled BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE locations ADD COLUMN hazmat BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE locations ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE locations ADD COLUMN updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE locations ADD CONSTRAINT locations_parent_not_self CHECK (parent_id <> id);
+
+CREATE INDEX idx_locations_parent_id ON locations(parent_id);
+
+UPDATE locations SET temperature_controlled = true WHERE code = 'CS-01';
@@ -0,0 +1,7 @@
+DROP TABLE putaway_tasks;
+ALTER TABLE locations DROP COLUMN pick_sequence;
+ALTER TABLE locations DROP COLUMN category_id;
+ALTER TABLE warehouse_product DROP COLUMN unit_volume;
+ALTER TABLE warehouse_product DROP COLUMN unit_weight;
+ALTER TABLE warehouse_product DROP COLUMN hazmat;
+ALTER TABLE warehouse_product DROP COLUMN temperature_controlled;
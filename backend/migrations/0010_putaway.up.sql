@@ -0,0 +1,37 @@
+-- Directed put-away. Products carry the handling flags and unit sizes the
+-- engine needs; locations can be dedicated to a category and carry a pick
+-- sequence, lower meaning closer to the pick face. Tasks move stock from a
+-- dock into the bin the operator scans.
+
+ALTER TABLE warehouse_product ADD COLUMN temperature_controlled BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE warehouse_product ADD COLUMN hazmat BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE warehouse_product ADD COLUMN unit_weight NUMERIC(12,3) CHECK (unit_weight >= 0);
+ALTER TABLE warehouse_product ADD COLUMN unit_volume NUMERIC(12,3) CHECK (unit_volume >= 0);
+
+ALTER TABLE locations ADD COLUMN category_id INTEGER REFERENCES warehouse_category(id);
+ALTER TABLE locations ADD COLUMN pick_sequence INTEGER;
+
+CREATE TABLE putaway_tasks (
+    id SERIAL PRIMARY KEY,
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    product_id INTEGER NOT NULL REFERENCES warehouse_product(id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    batch VARCHAR(100) NOT NULL DEFAULT '',
+    expiry_date DATE,
+    from_location_id INTEGER NOT NULL REFERENCES locations(id),
+    suggested_location_id INTEGER REFERENCES locations(id),
+    to_location_id INTEGER REFERENCES locations(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'cancelled')),
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    receipt_line_id INTEGER REFERENCES goods_receipt_lines(id),
+    assigned_to INTEGER REFERENCES auth_user(id),
+    created_by INTEGER REFERENCES auth_user(id),
+    confirmed_by INTEGER REFERENCES auth_user(id),
+    confirmed_at TIMESTAMP,
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_putaway_tasks_status ON putaway_tasks(warehouse_id, status);
+CREATE INDEX idx_putaway_tasks_suggested ON putaway_tasks(suggested_location_id) WHERE status = 'pending';
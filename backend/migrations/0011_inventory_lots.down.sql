@@ -0,0 +1,19 @@
+ALTER TABLE transfer_lines DROP COLUMN expiry_date;
+ALTER TABLE transfer_lines DROP COLUMN batch;
+
+DROP INDEX idx_stock_movements_batch;
+DROP INDEX idx_inventory_expiry_date;
+
+-- Fold the lots of each product and location back into one balance
+UPDATE inventory i SET quantity = s.quantity
+FROM (
+    SELECT MIN(id) AS id, SUM(quantity) AS quantity FROM inventory GROUP BY product_id, location_id
+) s
+WHERE i.id = s.id;
+DELETE FROM inventory i
+WHERE i.id <> (SELECT MIN(id) FROM inventory WHERE product_id = i.product_id AND location_id = i.location_id);
+
+ALTER TABLE inventory DROP CONSTRAINT inventory_product_location_batch_key;
+ALTER TABLE inventory ADD CONSTRAINT inventory_product_id_location_id_key UNIQUE (product_id, location_id);
+ALTER TABLE inventory DROP COLUMN expiry_date;
+ALTER TABLE inventory DROP COLUMN batch;
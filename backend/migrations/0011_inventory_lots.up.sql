@@ -0,0 +1,16 @@
+-- Inventory balances are kept per lot. An empty batch is stock whose lot is
+-- unknown; that holds everything booked before lots were tracked, because
+-- outbound movements of that time carried no batch and the old balances
+-- cannot be split reliably.
+
+ALTER TABLE inventory ADD COLUMN batch VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE inventory ADD COLUMN expiry_date DATE;
+ALTER TABLE inventory DROP CONSTRAINT inventory_product_id_location_id_key;
+ALTER TABLE inventory ADD CONSTRAINT inventory_product_location_batch_key UNIQUE (product_id, location_id, batch);
+CREATE INDEX idx_inventory_expiry_date ON inventory(expiry_date) WHERE expiry_date IS NOT NULL;
+
+CREATE INDEX idx_stock_movements_batch ON stock_movements(product_id, batch) WHERE batch <> '';
+
+-- Transfers and put-aways carry the lot they move
+ALTER TABLE transfer_lines ADD COLUMN batch VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE transfer_lines ADD COLUMN expiry_date DATE;
@@ -0,0 +1,7 @@
+ALTER TABLE dispatches DROP COLUMN posted_at;
+ALTER TABLE dispatches DROP COLUMN warehouse_id;
+ALTER TABLE dispatches DROP COLUMN product_id;
+ALTER TABLE inventory DROP COLUMN received_at;
+ALTER TABLE warehouse_product DROP CONSTRAINT warehouse_product_fixed_location;
+ALTER TABLE warehouse_product DROP COLUMN fixed_location_id;
+ALTER TABLE warehouse_product DROP COLUMN allocation_strategy;
@@ -0,0 +1,19 @@
+-- Outbound stock is allocated per product strategy: FEFO by expiry, FIFO or
+-- LIFO by the date a lot arrived at its location, or a fixed location.
+-- received_at of balances that predate it falls back to their last update.
+
+ALTER TABLE warehouse_product ADD COLUMN allocation_strategy VARCHAR(10) NOT NULL DEFAULT 'fefo'
+    CHECK (allocation_strategy IN ('fifo', 'fefo', 'lifo', 'fixed'));
+ALTER TABLE warehouse_product ADD COLUMN fixed_location_id INTEGER REFERENCES locations(id);
+ALTER TABLE warehouse_product ADD CONSTRAINT warehouse_product_fixed_location
+    CHECK (allocation_strategy <> 'fixed' OR fixed_location_id IS NOT NULL);
+
+ALTER TABLE inventory ADD COLUMN received_at TIMESTAMP;
+UPDATE inventory SET received_at = COALESCE(updated_at, CURRENT_TIMESTAMP);
+ALTER TABLE inventory ALTER COLUMN received_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE inventory ALTER COLUMN received_at SET NOT NULL;
+
+-- Shipped dispatches take their stock out through the allocator
+ALTER TABLE dispatches ADD COLUMN product_id INTEGER REFERENCES warehouse_product(id);
+ALTER TABLE dispatches ADD COLUMN warehouse_id INTEGER REFERENCES warehouses(id);
+ALTER TABLE dispatches ADD COLUMN posted_at TIMESTAMP;
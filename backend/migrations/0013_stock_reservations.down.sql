@@ -0,0 +1,6 @@
+ALTER TABLE outbound_requests DROP COLUMN updated_at;
+ALTER TABLE outbound_requests DROP COLUMN warehouse_id;
+DROP TABLE stock_reservations;
+ALTER TABLE inventory DROP CONSTRAINT inventory_reserved_non_negative;
+ALTER TABLE inventory DROP CONSTRAINT inventory_quantity_non_negative;
+ALTER TABLE inventory DROP COLUMN reserved_quantity;
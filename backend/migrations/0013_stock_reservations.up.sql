@@ -0,0 +1,39 @@
+-- Confirmed outbound requests reserve stock. Reserved stock stays on hand
+-- but can no longer be allocated to anything else, so available is
+-- quantity - reserved_quantity.
+
+ALTER TABLE inventory ADD COLUMN reserved_quantity INTEGER NOT NULL DEFAULT 0;
+
+-- Balances driven negative before on-hand was guarded are corrected up to
+-- zero, with a movement that explains it.
+INSERT INTO stock_movements (product_id, location_id, movement_type, quantity, reference, reference_type,
+                             reason_code, batch, expiry_date, notes, tenant_id, created_at)
+SELECT product_id, location_id, 'IN', -quantity, 'NEGATIVE BALANCE', 'ADJUSTMENT',
+       'CORRECTION', batch, expiry_date, 'Negative balance cleared', tenant_id, NOW()
+FROM inventory
+WHERE quantity < 0;
+UPDATE inventory SET quantity = 0 WHERE quantity < 0;
+
+ALTER TABLE inventory ADD CONSTRAINT inventory_quantity_non_negative CHECK (quantity >= 0);
+ALTER TABLE inventory ADD CONSTRAINT inventory_reserved_non_negative CHECK (reserved_quantity >= 0);
+
+CREATE TABLE stock_reservations (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES warehouse_product(id),
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    batch VARCHAR(50) NOT NULL DEFAULT '',
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    -- The document the stock is held for, e.g. OUTBOUND_REQUEST 12
+    reference_type VARCHAR(30) NOT NULL,
+    reference_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'released', 'consumed')),
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_stock_reservations_reference ON stock_reservations(reference_type, reference_id);
+CREATE INDEX idx_stock_reservations_active ON stock_reservations(product_id, location_id) WHERE status = 'active';
+
+ALTER TABLE outbound_requests ADD COLUMN warehouse_id INTEGER REFERENCES warehouses(id);
+ALTER TABLE outbound_requests ADD COLUMN updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
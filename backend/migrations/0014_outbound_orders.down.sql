@@ -0,0 +1,20 @@
+ALTER TABLE dispatches DROP COLUMN order_id;
+ALTER TABLE issuing DROP COLUMN order_id;
+DROP TABLE order_lines;
+DROP INDEX idx_orders_status;
+ALTER TABLE orders DROP COLUMN updated_at;
+ALTER TABLE orders DROP COLUMN delivered_at;
+ALTER TABLE orders DROP COLUMN shipped_at;
+ALTER TABLE orders DROP COLUMN created_by;
+ALTER TABLE orders DROP COLUMN backorder_of;
+ALTER TABLE orders DROP COLUMN notes;
+ALTER TABLE orders DROP COLUMN required_date;
+ALTER TABLE orders DROP COLUMN order_date;
+ALTER TABLE orders DROP COLUMN warehouse_id;
+ALTER TABLE orders DROP COLUMN customer_id;
+ALTER TABLE orders DROP CONSTRAINT orders_status;
+ALTER TABLE orders ALTER COLUMN status DROP NOT NULL;
+ALTER TABLE orders ALTER COLUMN status SET DEFAULT 'pending';
+UPDATE orders SET status = 'pending' WHERE status = 'new';
+ALTER TABLE orders ALTER COLUMN order_number DROP DEFAULT;
+DROP SEQUENCE IF EXISTS order_number_seq;
@@ -0,0 +1,49 @@
+-- Outbound orders go new → allocated → picking → packed → shipped →
+-- delivered, or are cancelled before they ship. Stock is reserved per line
+-- on allocation; what cannot be shipped is carried over to a backorder.
+
+CREATE SEQUENCE IF NOT EXISTS order_number_seq;
+ALTER TABLE orders ALTER COLUMN order_number SET DEFAULT 'SO-' || LPAD(nextval('order_number_seq')::TEXT, 6, '0');
+
+UPDATE orders SET status = 'new' WHERE status IS NULL OR status = 'pending';
+ALTER TABLE orders ALTER COLUMN status SET DEFAULT 'new';
+ALTER TABLE orders ALTER COLUMN status SET NOT NULL;
+-- Orders from before the lifecycle keep whatever status they had
+ALTER TABLE orders ADD CONSTRAINT orders_status
+    CHECK (status IN ('new', 'allocated', 'picking', 'packed', 'shipped', 'delivered', 'cancelled')) NOT VALID;
+
+ALTER TABLE orders ADD COLUMN customer_id INTEGER REFERENCES customers(id);
+ALTER TABLE orders ADD COLUMN warehouse_id INTEGER REFERENCES warehouses(id);
+ALTER TABLE orders ADD COLUMN order_date DATE NOT NULL DEFAULT CURRENT_DATE;
+ALTER TABLE orders ADD COLUMN required_date DATE;
+ALTER TABLE orders ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN backorder_of INTEGER REFERENCES orders(id);
+ALTER TABLE orders ADD COLUMN created_by INTEGER REFERENCES auth_user(id);
+ALTER TABLE orders ADD COLUMN shipped_at TIMESTAMP;
+ALTER TABLE orders ADD COLUMN delivered_at TIMESTAMP;
+ALTER TABLE orders ADD COLUMN updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+CREATE INDEX idx_orders_status ON orders(status);
+
+UPDATE orders o SET customer_id = c.id
+FROM customers c
+WHERE c.name = o.customer AND c.tenant_id IS NOT DISTINCT FROM o.tenant_id;
+
+CREATE TABLE order_lines (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES warehouse_product(id),
+    unit_id INTEGER REFERENCES units(id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(10,2) NOT NULL DEFAULT 0,
+    -- Reserved and not yet shipped; shipped; carried over to a backorder
+    allocated_quantity INTEGER NOT NULL DEFAULT 0 CHECK (allocated_quantity >= 0),
+    shipped_quantity INTEGER NOT NULL DEFAULT 0 CHECK (shipped_quantity >= 0),
+    backordered_quantity INTEGER NOT NULL DEFAULT 0 CHECK (backordered_quantity >= 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (allocated_quantity + shipped_quantity + backordered_quantity <= quantity)
+);
+CREATE INDEX idx_order_lines_order_id ON order_lines(order_id);
+
+-- Documents generated when an order ships
+ALTER TABLE issuing ADD COLUMN order_id INTEGER REFERENCES orders(id);
+ALTER TABLE dispatches ADD COLUMN order_id INTEGER REFERENCES orders(id);
@@ -0,0 +1,4 @@
+ALTER TABLE order_lines DROP COLUMN picked_quantity;
+DROP TABLE pick_list_lines;
+DROP TABLE pick_lists;
+DROP SEQUENCE IF EXISTS pick_list_number_seq;
@@ -0,0 +1,50 @@
+-- Pick lists send pickers through the reserved stock of allocated orders
+-- in walk order. A list covers one order (single), several orders (batch)
+-- or the part of several orders stored in one zone (zone).
+
+CREATE SEQUENCE IF NOT EXISTS pick_list_number_seq;
+
+CREATE TABLE pick_lists (
+    id SERIAL PRIMARY KEY,
+    document_number VARCHAR(100) UNIQUE NOT NULL
+        DEFAULT 'PCK-' || LPAD(nextval('pick_list_number_seq')::TEXT, 6, '0'),
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    mode VARCHAR(10) NOT NULL CHECK (mode IN ('single', 'batch', 'zone')),
+    zone_id INTEGER REFERENCES locations(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'in_progress', 'completed', 'cancelled')),
+    assigned_to INTEGER REFERENCES auth_user(id),
+    created_by INTEGER REFERENCES auth_user(id),
+    completed_at TIMESTAMP,
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_pick_lists_status ON pick_lists(status);
+CREATE INDEX idx_pick_lists_assigned_to ON pick_lists(assigned_to);
+
+-- One line per reservation, so a picker always takes a known lot from a
+-- known location
+CREATE TABLE pick_list_lines (
+    id SERIAL PRIMARY KEY,
+    pick_list_id INTEGER NOT NULL REFERENCES pick_lists(id) ON DELETE CASCADE,
+    order_id INTEGER NOT NULL REFERENCES orders(id),
+    order_line_id INTEGER NOT NULL REFERENCES order_lines(id),
+    reservation_id INTEGER NOT NULL REFERENCES stock_reservations(id),
+    product_id INTEGER NOT NULL REFERENCES warehouse_product(id),
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    batch VARCHAR(50) NOT NULL DEFAULT '',
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    picked_quantity INTEGER NOT NULL DEFAULT 0 CHECK (picked_quantity >= 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'picked', 'short', 'cancelled')),
+    sequence INTEGER NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    picked_by INTEGER REFERENCES auth_user(id),
+    picked_at TIMESTAMP,
+    CHECK (picked_quantity <= quantity)
+);
+CREATE INDEX idx_pick_list_lines_pick_list_id ON pick_list_lines(pick_list_id);
+CREATE INDEX idx_pick_list_lines_reservation_id ON pick_list_lines(reservation_id);
+
+ALTER TABLE order_lines ADD COLUMN picked_quantity INTEGER NOT NULL DEFAULT 0 CHECK (picked_quantity >= 0);
@@ -0,0 +1,12 @@
+DROP TABLE carton_lines;
+DROP TABLE cartons;
+DROP SEQUENCE IF EXISTS carton_serial_seq;
+DROP TABLE shipments;
+DROP SEQUENCE IF EXISTS shipment_number_seq;
+ALTER TABLE dispatches DROP COLUMN updated_at;
+ALTER TABLE dispatches DROP COLUMN delivered_at;
+ALTER TABLE dispatches DROP COLUMN shipped_at;
+ALTER TABLE dispatches DROP COLUMN packed_at;
+ALTER TABLE dispatches DROP COLUMN picked_at;
+ALTER TABLE dispatches DROP CONSTRAINT dispatches_status;
+ALTER TABLE dispatches ALTER COLUMN status DROP NOT NULL;
@@ -0,0 +1,67 @@
+-- Dispatches go pending → picked → packed → shipped → delivered, or are
+-- cancelled before they ship. Picking reserves the stock, packing puts it
+-- into cartons, and the stock leaves inventory when the shipment carrying
+-- the cartons ships.
+
+UPDATE dispatches SET status = LOWER(status) WHERE status IS NOT NULL;
+UPDATE dispatches SET status = 'pending' WHERE status IS NULL OR status = '';
+ALTER TABLE dispatches ALTER COLUMN status SET NOT NULL;
+-- Dispatches from before the flow keep whatever status they had
+ALTER TABLE dispatches ADD CONSTRAINT dispatches_status
+    CHECK (status IN ('pending', 'picked', 'packed', 'shipped', 'delivered', 'cancelled')) NOT VALID;
+
+ALTER TABLE dispatches ADD COLUMN picked_at TIMESTAMP;
+ALTER TABLE dispatches ADD COLUMN packed_at TIMESTAMP;
+ALTER TABLE dispatches ADD COLUMN shipped_at TIMESTAMP;
+ALTER TABLE dispatches ADD COLUMN delivered_at TIMESTAMP;
+ALTER TABLE dispatches ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE SEQUENCE IF NOT EXISTS shipment_number_seq;
+
+CREATE TABLE shipments (
+    id SERIAL PRIMARY KEY,
+    shipment_number VARCHAR(100) UNIQUE NOT NULL
+        DEFAULT 'SHP-' || LPAD(nextval('shipment_number_seq')::TEXT, 6, '0'),
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    carrier VARCHAR(100) NOT NULL,
+    tracking_number VARCHAR(100) NOT NULL DEFAULT '',
+    ship_date DATE,
+    status VARCHAR(20) NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'shipped', 'delivered', 'cancelled')),
+    notes TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES auth_user(id),
+    shipped_at TIMESTAMP,
+    delivered_at TIMESTAMP,
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_shipments_status ON shipments(status);
+CREATE INDEX idx_shipments_tracking_number ON shipments(tracking_number);
+
+-- The serial reference of carton SSCCs
+CREATE SEQUENCE IF NOT EXISTS carton_serial_seq;
+
+CREATE TABLE cartons (
+    id SERIAL PRIMARY KEY,
+    sscc CHAR(18) UNIQUE NOT NULL,
+    shipment_id INTEGER REFERENCES shipments(id),
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    weight_kg NUMERIC(10, 3) CHECK (weight_kg > 0),
+    length_cm NUMERIC(10, 2) CHECK (length_cm > 0),
+    width_cm NUMERIC(10, 2) CHECK (width_cm > 0),
+    height_cm NUMERIC(10, 2) CHECK (height_cm > 0),
+    packed_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_cartons_shipment_id ON cartons(shipment_id);
+
+CREATE TABLE carton_lines (
+    id SERIAL PRIMARY KEY,
+    carton_id INTEGER NOT NULL REFERENCES cartons(id) ON DELETE CASCADE,
+    dispatch_id INTEGER NOT NULL REFERENCES dispatches(id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0)
+);
+CREATE INDEX idx_carton_lines_carton_id ON carton_lines(carton_id);
+CREATE INDEX idx_carton_lines_dispatch_id ON carton_lines(dispatch_id);
@@ -0,0 +1,13 @@
+UPDATE stock_movements SET reason_code = 'DAMAGED' WHERE reason_code = 'SCRAPPED';
+DELETE FROM stock_reason_codes WHERE code = 'SCRAPPED';
+DELETE FROM quarantine_stock WHERE return_id IS NOT NULL;
+ALTER TABLE quarantine_stock DROP COLUMN return_id;
+ALTER TABLE quarantine_stock ALTER COLUMN receipt_line_id SET NOT NULL;
+ALTER TABLE quarantine_stock ALTER COLUMN receipt_id SET NOT NULL;
+DROP TABLE return_dispositions;
+DROP TABLE returns;
+DROP SEQUENCE IF EXISTS return_number_seq;
+UPDATE locations SET location_type = 'staging' WHERE location_type = 'returns';
+ALTER TABLE locations DROP CONSTRAINT locations_location_type_check;
+ALTER TABLE locations ADD CONSTRAINT locations_location_type_check
+    CHECK (location_type IN ('zone', 'aisle', 'rack', 'level', 'bin', 'dock', 'staging'));
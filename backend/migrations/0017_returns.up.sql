@@ -0,0 +1,78 @@
+-- Returns (RMAs). A customer return comes back against the issuing or
+-- dispatch that sent the goods out, is received into a returns location
+-- and stays there until QC decides where each unit goes. A supplier
+-- return sends goods from a receipt back to the supplier once approved.
+
+ALTER TABLE locations DROP CONSTRAINT locations_location_type_check;
+ALTER TABLE locations ADD CONSTRAINT locations_location_type_check
+    CHECK (location_type IN ('zone', 'aisle', 'rack', 'level', 'bin', 'dock', 'staging', 'returns'));
+
+CREATE SEQUENCE IF NOT EXISTS return_number_seq;
+
+CREATE TABLE returns (
+    id SERIAL PRIMARY KEY,
+    document_number VARCHAR(100) UNIQUE NOT NULL
+        DEFAULT 'RMA-' || LPAD(nextval('return_number_seq')::TEXT, 6, '0'),
+    return_type VARCHAR(20) NOT NULL CHECK (return_type IN ('CUSTOMER', 'SUPPLIER')),
+    issuing_id INTEGER REFERENCES issuing(id),
+    dispatch_id INTEGER REFERENCES dispatches(id),
+    receipt_line_id INTEGER REFERENCES goods_receipt_lines(id),
+    product_id INTEGER NOT NULL REFERENCES warehouse_product(id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    batch VARCHAR(50) NOT NULL DEFAULT '',
+    customer_id INTEGER REFERENCES customers(id),
+    customer VARCHAR(200) NOT NULL DEFAULT '',
+    supplier_id INTEGER REFERENCES suppliers(id),
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    reason TEXT NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected', 'received', 'completed', 'cancelled')),
+    -- Where the goods went on receipt, or left from for a supplier return
+    location_id INTEGER REFERENCES locations(id),
+    received_quantity INTEGER NOT NULL DEFAULT 0 CHECK (received_quantity >= 0),
+    decision_notes TEXT NOT NULL DEFAULT '',
+    decided_by INTEGER REFERENCES auth_user(id),
+    decided_at TIMESTAMP,
+    received_by INTEGER REFERENCES auth_user(id),
+    received_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Customer returns come back against one outbound document, supplier
+    -- returns go back against one receipt line
+    CHECK ((return_type = 'CUSTOMER' AND num_nonnulls(issuing_id, dispatch_id) = 1 AND receipt_line_id IS NULL)
+        OR (return_type = 'SUPPLIER' AND receipt_line_id IS NOT NULL AND num_nonnulls(issuing_id, dispatch_id) = 0)),
+    CHECK (received_quantity <= quantity)
+);
+CREATE INDEX idx_returns_status ON returns(status);
+CREATE INDEX idx_returns_issuing_id ON returns(issuing_id);
+CREATE INDEX idx_returns_dispatch_id ON returns(dispatch_id);
+CREATE INDEX idx_returns_receipt_line_id ON returns(receipt_line_id);
+
+-- QC's decisions on received customer returns. A return is completed once
+-- every received unit has one.
+CREATE TABLE return_dispositions (
+    id SERIAL PRIMARY KEY,
+    return_id INTEGER NOT NULL REFERENCES returns(id),
+    disposition VARCHAR(30) NOT NULL
+        CHECK (disposition IN ('restock', 'quarantine', 'scrap', 'return_to_supplier')),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    -- Where restocked goods were put
+    location_id INTEGER REFERENCES locations(id),
+    notes TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES auth_user(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_return_dispositions_return_id ON return_dispositions(return_id);
+
+-- Returned goods QC holds back sit in quarantine beside rejected receipts
+ALTER TABLE quarantine_stock ALTER COLUMN receipt_id DROP NOT NULL;
+ALTER TABLE quarantine_stock ALTER COLUMN receipt_line_id DROP NOT NULL;
+ALTER TABLE quarantine_stock ADD COLUMN return_id INTEGER REFERENCES returns(id);
+ALTER TABLE quarantine_stock ADD CHECK (num_nonnulls(receipt_line_id, return_id) = 1);
+
+INSERT INTO stock_reason_codes (code, description, direction) VALUES
+    ('SCRAPPED', 'Returned goods scrapped', 'OUT');
@@ -0,0 +1,8 @@
+UPDATE stock_reason_codes SET description = 'Returned goods scrapped' WHERE code = 'SCRAPPED';
+DROP TABLE quarantine_holds;
+DROP TABLE qc_inspections;
+DROP SEQUENCE IF EXISTS qc_inspection_number_seq;
+ALTER TABLE goods_receipt_lines DROP COLUMN rejected_quantity;
+ALTER TABLE inventory DROP CONSTRAINT inventory_quarantine_within_quantity;
+ALTER TABLE inventory DROP CONSTRAINT inventory_quarantined_non_negative;
+ALTER TABLE inventory DROP COLUMN quarantined_quantity;
@@ -0,0 +1,74 @@
+-- QC inspections split a quantity into passed and failed. Incoming
+-- inspections decide on a receipt line before it is posted; outgoing and
+-- return inspections hold the stock they look at in quarantine. Held stock
+-- stays on hand but cannot be allocated, so available is
+-- quantity - reserved_quantity - quarantined_quantity.
+
+ALTER TABLE inventory ADD COLUMN quarantined_quantity INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE inventory ADD CONSTRAINT inventory_quarantined_non_negative CHECK (quarantined_quantity >= 0);
+ALTER TABLE inventory ADD CONSTRAINT inventory_quarantine_within_quantity CHECK (quarantined_quantity <= quantity);
+
+-- Units of an accepted line that failed inspection; they go to quarantine
+-- stock when the receipt is posted and the rest into inventory
+ALTER TABLE goods_receipt_lines ADD COLUMN rejected_quantity INTEGER CHECK (rejected_quantity > 0);
+
+CREATE SEQUENCE IF NOT EXISTS qc_inspection_number_seq;
+
+CREATE TABLE qc_inspections (
+    id SERIAL PRIMARY KEY,
+    document_number VARCHAR(100) UNIQUE NOT NULL
+        DEFAULT 'QCI-' || LPAD(nextval('qc_inspection_number_seq')::TEXT, 6, '0'),
+    check_type VARCHAR(20) NOT NULL CHECK (check_type IN ('INCOMING', 'OUTGOING', 'RETURN')),
+    receipt_line_id INTEGER REFERENCES goods_receipt_lines(id),
+    dispatch_id INTEGER REFERENCES dispatches(id),
+    return_id INTEGER REFERENCES returns(id),
+    product_id INTEGER NOT NULL REFERENCES warehouse_product(id),
+    -- Where the inspected stock sits; unknown for goods not yet put away
+    location_id INTEGER REFERENCES locations(id),
+    batch VARCHAR(50) NOT NULL DEFAULT '',
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    checked_quantity INTEGER NOT NULL DEFAULT 0 CHECK (checked_quantity >= 0),
+    passed_quantity INTEGER NOT NULL DEFAULT 0 CHECK (passed_quantity >= 0),
+    failed_quantity INTEGER NOT NULL DEFAULT 0 CHECK (failed_quantity >= 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'completed', 'cancelled')),
+    notes TEXT NOT NULL DEFAULT '',
+    inspected_by INTEGER REFERENCES auth_user(id),
+    inspected_at TIMESTAMP,
+    warehouse_id INTEGER REFERENCES warehouses(id),
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((check_type = 'INCOMING' AND receipt_line_id IS NOT NULL AND num_nonnulls(dispatch_id, return_id) = 0)
+        OR (check_type = 'OUTGOING' AND location_id IS NOT NULL AND num_nonnulls(receipt_line_id, return_id) = 0)
+        OR (check_type = 'RETURN' AND return_id IS NOT NULL AND num_nonnulls(receipt_line_id, dispatch_id) = 0)),
+    CHECK (checked_quantity <= quantity),
+    CHECK (status <> 'completed' OR passed_quantity + failed_quantity = quantity)
+);
+CREATE INDEX idx_qc_inspections_status ON qc_inspections(status);
+CREATE INDEX idx_qc_inspections_receipt_line_id ON qc_inspections(receipt_line_id);
+CREATE INDEX idx_qc_inspections_return_id ON qc_inspections(return_id);
+
+-- Stock an inspection holds back. An active hold counts towards the
+-- balance's quarantined_quantity until it is released or scrapped.
+CREATE TABLE quarantine_holds (
+    id SERIAL PRIMARY KEY,
+    inspection_id INTEGER NOT NULL UNIQUE REFERENCES qc_inspections(id),
+    product_id INTEGER NOT NULL REFERENCES warehouse_product(id),
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    batch VARCHAR(50) NOT NULL DEFAULT '',
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'released', 'scrapped')),
+    reason TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES auth_user(id),
+    released_by INTEGER REFERENCES auth_user(id),
+    released_at TIMESTAMP,
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_quarantine_holds_status ON quarantine_holds(status);
+CREATE INDEX idx_quarantine_holds_stock ON quarantine_holds(product_id, location_id, batch);
+
+-- Stock QC scraps from a hold uses the same reason as scrapped returns
+UPDATE stock_reason_codes SET description = 'Goods scrapped after QC' WHERE code = 'SCRAPPED';
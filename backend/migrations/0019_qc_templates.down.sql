@@ -0,0 +1,11 @@
+DROP TABLE qc_inspection_results;
+ALTER TABLE qc_inspections DROP COLUMN defects;
+ALTER TABLE qc_inspections DROP COLUMN reject_number;
+ALTER TABLE qc_inspections DROP COLUMN accept_number;
+ALTER TABLE qc_inspections DROP COLUMN sample_size;
+ALTER TABLE qc_inspections DROP COLUMN code_letter;
+ALTER TABLE qc_inspections DROP COLUMN sampling_plan_id;
+ALTER TABLE qc_inspections DROP COLUMN template_id;
+DROP TABLE qc_template_items;
+DROP TABLE qc_templates;
+DROP TABLE qc_sampling_plans;
@@ -0,0 +1,77 @@
+-- Inspection templates. A product's own active template wins over its
+-- category's. Templates list checklist items, some of them measurements
+-- with a tolerance, and may name an AQL sampling plan that sizes the
+-- sample from the inspected quantity. Results are kept per item.
+
+CREATE TABLE qc_sampling_plans (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    -- General inspection level of ISO 2859-1
+    inspection_level VARCHAR(3) NOT NULL DEFAULT 'II' CHECK (inspection_level IN ('I', 'II', 'III')),
+    aql NUMERIC(6,3) NOT NULL CHECK (aql > 0),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE qc_templates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    product_id INTEGER REFERENCES warehouse_product(id),
+    category_id INTEGER REFERENCES warehouse_category(id),
+    sampling_plan_id INTEGER REFERENCES qc_sampling_plans(id),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (num_nonnulls(product_id, category_id) = 1)
+);
+CREATE UNIQUE INDEX idx_qc_templates_product ON qc_templates(product_id) WHERE is_active;
+CREATE UNIQUE INDEX idx_qc_templates_category ON qc_templates(category_id) WHERE is_active;
+
+CREATE TABLE qc_template_items (
+    id SERIAL PRIMARY KEY,
+    template_id INTEGER NOT NULL REFERENCES qc_templates(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    label VARCHAR(200) NOT NULL,
+    item_type VARCHAR(20) NOT NULL DEFAULT 'check' CHECK (item_type IN ('check', 'measurement')),
+    unit VARCHAR(20) NOT NULL DEFAULT '',
+    target_value NUMERIC(14,4),
+    min_value NUMERIC(14,4),
+    max_value NUMERIC(14,4),
+    photo_required BOOLEAN NOT NULL DEFAULT FALSE,
+    is_required BOOLEAN NOT NULL DEFAULT TRUE,
+    CHECK (item_type = 'measurement' OR num_nonnulls(target_value, min_value, max_value) = 0),
+    CHECK (min_value IS NULL OR max_value IS NULL OR min_value <= max_value)
+);
+CREATE INDEX idx_qc_template_items_template_id ON qc_template_items(template_id);
+
+-- The template and sample an inspection was opened with, kept as they
+-- were so later edits to the plan do not rewrite history
+ALTER TABLE qc_inspections ADD COLUMN template_id INTEGER REFERENCES qc_templates(id);
+ALTER TABLE qc_inspections ADD COLUMN sampling_plan_id INTEGER REFERENCES qc_sampling_plans(id);
+ALTER TABLE qc_inspections ADD COLUMN code_letter CHAR(1);
+ALTER TABLE qc_inspections ADD COLUMN sample_size INTEGER CHECK (sample_size > 0);
+ALTER TABLE qc_inspections ADD COLUMN accept_number INTEGER CHECK (accept_number >= 0);
+ALTER TABLE qc_inspections ADD COLUMN reject_number INTEGER CHECK (reject_number > accept_number);
+ALTER TABLE qc_inspections ADD COLUMN defects INTEGER NOT NULL DEFAULT 0 CHECK (defects >= 0);
+
+-- Label and type are copied from the template item, which may change or
+-- go away later
+CREATE TABLE qc_inspection_results (
+    id SERIAL PRIMARY KEY,
+    inspection_id INTEGER NOT NULL REFERENCES qc_inspections(id) ON DELETE CASCADE,
+    template_item_id INTEGER REFERENCES qc_template_items(id) ON DELETE SET NULL,
+    label VARCHAR(200) NOT NULL,
+    item_type VARCHAR(20) NOT NULL,
+    passed BOOLEAN NOT NULL,
+    measured_value NUMERIC(14,4),
+    defects INTEGER NOT NULL DEFAULT 0 CHECK (defects >= 0),
+    photo_url TEXT NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_qc_inspection_results_inspection_id ON qc_inspection_results(inspection_id);
+CREATE INDEX idx_qc_inspection_results_template_item_id ON qc_inspection_results(template_item_id);
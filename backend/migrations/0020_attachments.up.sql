@@ -0,0 +1,23 @@
+-- Photos and documents attached to receipts, QC records, returns and
+-- dispatches. The file itself lives on the storage backend under
+-- storage_key; owner_type names the table owner_id points into.
+
+CREATE TABLE attachments (
+    id SERIAL PRIMARY KEY,
+    owner_type VARCHAR(30) NOT NULL
+        CHECK (owner_type IN ('goods_receipt', 'quality_check', 'qc_inspection', 'return', 'dispatch')),
+    owner_id INTEGER NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    -- Hex SHA-256 of the contents
+    checksum CHAR(64) NOT NULL,
+    storage_key VARCHAR(255) UNIQUE NOT NULL,
+    -- Set for images, which get a JPEG preview
+    thumbnail_key VARCHAR(255) UNIQUE,
+    notes TEXT NOT NULL DEFAULT '',
+    uploaded_by INTEGER REFERENCES auth_user(id),
+    tenant_id INTEGER REFERENCES tenants(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_attachments_owner ON attachments(owner_type, owner_id);
@@ -0,0 +1,2 @@
+DROP SEQUENCE IF EXISTS issuing_number_seq;
+DROP SEQUENCE IF EXISTS stock_adjustment_number_seq;
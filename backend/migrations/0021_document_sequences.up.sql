@@ -0,0 +1,5 @@
+-- Numbers for stock adjustments and issuings, which used to be derived from
+-- the clock and could collide when two were booked at once.
+
+CREATE SEQUENCE IF NOT EXISTS stock_adjustment_number_seq;
+CREATE SEQUENCE IF NOT EXISTS issuing_number_seq;
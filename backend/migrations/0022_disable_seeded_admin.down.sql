@@ -0,0 +1,3 @@
+-- The seeded admin is deliberately not switched back on, and pgcrypto is
+-- left installed.
+SELECT 1;
@@ -0,0 +1,12 @@
+-- 0002, and createTables before it, seeded an admin account with the
+-- password "admin", each install with its own salt. Any admin account that
+-- still has that password is switched off; create a real superuser with
+-- `wms-migrate create-admin` instead.
+
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+UPDATE auth_user SET is_active = false
+WHERE username = 'admin'
+  -- crypt only understands the $2a$ hashes Go's bcrypt writes; CASE keeps
+  -- it away from anything else
+  AND CASE WHEN password LIKE '$2a$%' THEN password = crypt('admin', password) ELSE false END;
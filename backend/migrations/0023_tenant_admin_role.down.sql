@@ -0,0 +1,5 @@
+UPDATE auth_user
+SET roles = array_to_string(array_replace(string_to_array(replace(roles, ' ', ''), ','), 'tenant_admin', 'admin'), ','),
+    role = CASE WHEN role = 'tenant_admin' THEN 'admin' ELSE role END
+WHERE tenant_id IS NOT NULL
+  AND 'tenant_admin' = ANY(string_to_array(replace(roles, ' ', ''), ','));
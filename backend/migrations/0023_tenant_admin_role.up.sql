@@ -0,0 +1,8 @@
+-- Tenant admins used to be created with the staff admin role, which grants
+-- every permission over the shared warehouse. They get their own role.
+
+UPDATE auth_user
+SET roles = array_to_string(array_replace(string_to_array(replace(roles, ' ', ''), ','), 'admin', 'tenant_admin'), ','),
+    role = CASE WHEN role = 'admin' THEN 'tenant_admin' ELSE role END
+WHERE tenant_id IS NOT NULL
+  AND 'admin' = ANY(string_to_array(replace(roles, ' ', ''), ','));
@@ -0,0 +1,9 @@
+// Package migrations embeds the versioned SQL scripts that define the
+// database schema. Files are named NNNN_description.up.sql and
+// NNNN_description.down.sql and are applied in version order.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -61,11 +61,15 @@ case "$1" in
       "test")
         go test ./...
         ;;
+      "migrate")
+        go run ./cmd/wms-migrate "${3:-up}" "${@:4}"
+        ;;
       *)
         echo "Available backend commands:"
         echo "  ./dev.sh backend run   - Run Go server"
         echo "  ./dev.sh backend build - Build Go binary"
         echo "  ./dev.sh backend test  - Run tests"
+        echo "  ./dev.sh backend migrate [up|down|status|create|force] - Manage schema migrations"
         ;;
     esac
     ;;
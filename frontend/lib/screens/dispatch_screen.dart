@@ -123,6 +123,7 @@ class _DispatchScreenState extends State<DispatchScreen> {
   }
 
   Widget _buildStatusChip(String status) {
+    status = status.toUpperCase();
     Color color = status == 'DELIVERED' ? Colors.green : 
                   status == 'SHIPPED' ? Colors.blue : Colors.orange;
     return Chip(
@@ -196,7 +197,8 @@ class AddDispatchDialogState extends State<AddDispatchDialog> {
   String _selectedStatus = 'PENDING';
   DateTime _selectedDate = DateTime.now();
   
-  final List<String> _statusOptions = ['PENDING', 'SHIPPED', 'DELIVERED'];
+  // New dispatches start as pending; the backend moves them on as they are picked, packed and shipped
+  final List<String> _statusOptions = ['PENDING'];
   
   final List<String> _productSuggestions = [
     'Laptop Dell XPS 13',
@@ -28,4 +28,4 @@ flutter run -d web-server --web-port 3000 --web-hostname 0.0.0.0
 
 echo "Backend: http://localhost:8000"
 echo "Frontend: http://localhost:3000"
-echo "Admin: http://localhost:8000/admin (admin/admin123)"
\ No newline at end of file
+echo "Admin: create one with backend/cmd/wms-migrate create-admin"
\ No newline at end of file